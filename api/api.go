@@ -2,11 +2,16 @@ package api
 
 import (
 	"context"
+	"net/http"
 
 	"github.com/gorilla/mux"
 	"github.com/methods/go-methods-lib/log"
 	"github.com/methods/go-methods-lib/server"
+	"github.com/ofs/alpha-search-api/auth"
 	"github.com/ofs/alpha-search-api/config"
+	"github.com/ofs/alpha-search-api/health"
+	"github.com/ofs/alpha-search-api/metrics"
+	"github.com/ofs/alpha-search-api/ratelimit"
 )
 
 var (
@@ -21,52 +26,132 @@ type API interface {
 
 // SearchAPI manages stored data
 type SearchAPI struct {
-	DefaultMaxResults int
+	CacheControl      string
+	DebugCaptureToken string
 	Elasticsearch     Elasticsearcher
+	HealthCheck       *health.Health
 	Host              string
 	Index             string
+	IndexVersion      IndexVersioner
 	Router            *mux.Router
 	ShowScore         bool
+	Vary              string
+	settings          *settingsStore
 }
 
-// CreateSearchAPI manages all the routes configured to API
-func CreateSearchAPI(cfg config.Configuration, elasticsearch Elasticsearcher, errorChan chan error) {
+// CreateSearchAPI manages all the routes configured to API, returning the api so
+// that its settings can be reloaded
+func CreateSearchAPI(cfg config.Configuration, elasticsearch Elasticsearcher, checker ElasticHealthChecker, versioner IndexVersioner, keys *auth.Keys, errorChan chan error) *SearchAPI {
 	router := mux.NewRouter()
-	Routes(cfg, elasticsearch, router)
+	api := Routes(cfg, elasticsearch, checker, versioner, keys, router)
 
 	httpServer = server.New(cfg.BindAddr, router)
 
 	// Disable this here to allow main to manage graceful shutdown of the entire app.
 	httpServer.HandleOSSignals = false
 
+	// Replace the default request id handler so that ids from callers are
+	// validated and returned in the response
+	httpServer.Middleware[server.RequestIDHandlerKey] = requestIDHandler
+
 	go func() {
 		log.Info("Starting search API...", nil)
-		if err := httpServer.ListenAndServe(); err != nil {
+		// The server is closed on shutdown, which is not an error
+		if err := httpServer.ListenAndServe(); err != nil && err != http.ErrServerClosed {
 			log.ErrorC("search API http server returned error", err, nil)
 			errorChan <- err
 		}
 	}()
+
+	return api
 }
 
-// Routes represents a list of endpoints that exist with this api
-func Routes(cfg config.Configuration, elasticsearch Elasticsearcher, router *mux.Router) *SearchAPI {
+// Routes represents a list of endpoints that exist with this api, readiness
+// checks against elasticsearch are only made if a checker is given, ETags
+// only change with the index if a versioner is given and API keys are only
+// accepted if keys are given
+func Routes(cfg config.Configuration, elasticsearch Elasticsearcher, checker ElasticHealthChecker, versioner IndexVersioner, keys *auth.Keys, router *mux.Router) *SearchAPI {
 
 	host := cfg.Host + cfg.BindAddr
 
 	api := SearchAPI{
-		DefaultMaxResults: cfg.DefaultMaxResults,
+		CacheControl:      cfg.HTTPCacheControl,
+		DebugCaptureToken: cfg.DebugCaptureToken,
 		Elasticsearch:     elasticsearch,
+		HealthCheck:       health.New(cfg.HealthCheckInterval),
 		Host:              host,
 		Index:             cfg.ElasticSearchConfig.DestIndex,
+		IndexVersion:      versioner,
 		Router:            router,
 		ShowScore:         cfg.ElasticSearchConfig.ShowScore,
+		Vary:              cfg.HTTPVary,
+		settings:          newSettingsStore(NewSettings(cfg, keys)),
+	}
+
+	if checker != nil {
+		addElasticsearchChecks(api.HealthCheck, checker, api.Index, cfg.ElasticSearchConfig.MappingCheck)
+	}
+
+	cors := newCORSPolicy(api.settings, cfg.CORSAllowedMethods, cfg.CORSAllowedHeaders, cfg.CORSExposedHeaders, cfg.CORSMaxAge, cfg.CORSAllowCredentials)
+
+	access := &accessControl{
+		settings: api.settings,
+		limiter:  ratelimit.New(),
+	}
+	if cfg.RateLimitTrustForwarded {
+		access.trustedProxies = cfg.RateLimitTrustedProxies
+	}
+
+	api.Router.Use(cors.handler, metricsHandler, tracingHandler, access.handler, debugCaptureHandler(cfg.DebugCaptureToken, cfg.DebugCaptureSampleRate))
+
+	if cfg.CompressionEnabled {
+		api.Router.Use(newCompressor(cfg.CompressionLevel, cfg.CompressionMinSize).handler)
 	}
 
+	// Validation is added last so that it sees uncompressed responses
+	if validator := newSpecValidator(cfg.OpenAPIValidation); validator != nil {
+		api.Router.Use(validator.handler)
+	}
+
+	api.Router.HandleFunc("/health", api.Health).Methods("GET")
+	api.Router.HandleFunc("/ready", api.Ready).Methods("GET")
 	api.Router.HandleFunc("/search/courses", api.SearchCourses).Methods("GET")
 	api.Router.HandleFunc("/search/institution-courses", api.SearchInstitutionCourses).Methods("GET")
+	api.Router.Handle("/metrics", metrics.Handler()).Methods("GET")
+	api.Router.HandleFunc("/openapi.yml", api.OpenAPI).Methods("GET")
+	api.Router.HandleFunc("/docs", api.Docs).Methods("GET")
+	api.Router.Methods("OPTIONS").HandlerFunc(api.Options)
+
+	// Middleware only runs for matched routes, so responses for unknown paths and
+	// methods need the CORS headers adding for browsers to be able to read them,
+	// and the rate limit headers as every other response has them
+	api.Router.NotFoundHandler = cors.handler(access.handler(http.NotFoundHandler()))
+	api.Router.MethodNotAllowedHandler = cors.handler(access.handler(http.HandlerFunc(api.methodNotAllowed)))
 	return &api
 }
 
+// methodNotAllowed responds to requests for paths routed for other methods. As
+// the route for OPTIONS matches every path, unknown paths are not found
+func (api *SearchAPI) methodNotAllowed(w http.ResponseWriter, r *http.Request) {
+	if !api.routed(r) {
+		http.NotFound(w, r)
+		return
+	}
+
+	w.WriteHeader(http.StatusMethodNotAllowed)
+}
+
+// Settings returns the settings currently applied to requests
+func (api *SearchAPI) Settings() *Settings {
+	return api.settings.load()
+}
+
+// UpdateSettings applies the settings to requests from now on, requests already
+// being handled keep the settings they started with
+func (api *SearchAPI) UpdateSettings(settings *Settings) {
+	api.settings.store(settings)
+}
+
 // Close represents the graceful shutting down of the http server
 func Close(ctx context.Context) error {
 	if err := httpServer.Shutdown(ctx); err != nil {
@@ -0,0 +1,177 @@
+package api
+
+import (
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/methods/go-methods-lib/log"
+	errs "github.com/ofs/alpha-search-api/apierrors"
+	"github.com/ofs/alpha-search-api/auth"
+	"github.com/ofs/alpha-search-api/metrics"
+	"github.com/ofs/alpha-search-api/ratelimit"
+)
+
+// apiKeyHeader is sent by clients to identify themselves with their API key
+const apiKeyHeader = "X-Api-Key"
+
+// anonymousClient labels metrics for requests made without an API key
+const anonymousClient = "anonymous"
+
+var rateLimitedTotal = metrics.NewCounterVec(
+	"search_api_rate_limited_total",
+	"The number of requests rejected for exceeding a rate limit, partitioned by client (anonymous for requests without an API key).",
+	"client",
+)
+
+// accessControl authenticates API keys and applies rate limits, per client for
+// requests with a key and per IP address for anonymous requests, using the keys
+// and limits in the current settings
+type accessControl struct {
+	settings *settingsStore
+	limiter  *ratelimit.Limiter
+	// trustedProxies is the number of proxies in front of the api appending to
+	// X-Forwarded-For, or 0 if the header is not trusted
+	trustedProxies int
+}
+
+// scopes lists the scope a client needs for each route protected by access
+// control, other routes (health checks and metrics) are not authenticated or limited
+var scopes = map[string]string{
+	"/search/courses":             auth.ScopeSearch,
+	"/search/institution-courses": auth.ScopeSearch,
+}
+
+// handler rejects requests with an unknown key, a key without the scope needed
+// for the route or which exceed their rate limit. Every response to a caller
+// with a limit has rate limit headers, routes which are not limited report the
+// limit without counting against it
+func (a *accessControl) handler(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		scope, ok := scopes[routeName(r)]
+		if !ok || r.Method == http.MethodOptions {
+			a.report(w, r)
+			h.ServeHTTP(w, r)
+			return
+		}
+
+		ctx := r.Context()
+		logData := log.Data{"route": routeName(r)}
+		settings := a.settings.load()
+
+		var client *auth.Client
+		if key := r.Header.Get(apiKeyHeader); key != "" {
+			if client, ok = settings.Keys.Lookup(key); !ok {
+				log.InfoCtx(ctx, "request rejected, invalid api key", logData)
+				Error(ctx, w, errs.New(errs.ErrInvalidAPIKey, http.StatusUnauthorized, nil))
+				return
+			}
+
+			logData["client"] = client.Name
+
+			if !client.HasScope(scope) {
+				log.InfoCtx(ctx, "request rejected, api key does not have the required scope", logData)
+				Error(ctx, w, errs.New(errs.ErrInsufficientScope, http.StatusForbidden, map[string]string{"scope": scope}))
+				return
+			}
+		} else if settings.KeyRequired {
+			Error(ctx, w, errs.New(errs.ErrAPIKeyRequired, http.StatusUnauthorized, nil))
+			return
+		}
+
+		bucket, limit, name := a.bucket(r, settings, client)
+		if !limit.Enabled() {
+			h.ServeHTTP(w, r)
+			return
+		}
+
+		result := a.limiter.Allow(bucket, limit)
+		writeRateLimitHeaders(w, result)
+
+		if !result.Allowed {
+			rateLimitedTotal.Inc(name)
+
+			retryAfter := ceilSeconds(result.RetryAfter)
+			logData["retry_after"] = retryAfter
+			log.InfoCtx(ctx, "request rejected, rate limit exceeded", logData)
+
+			w.Header().Set("Retry-After", retryAfter)
+			Error(ctx, w, errs.New(errs.ErrRateLimitExceeded, http.StatusTooManyRequests, map[string]string{"retry_after": retryAfter}))
+			return
+		}
+
+		h.ServeHTTP(w, r)
+	})
+}
+
+// report sets the rate limit headers for the caller of a route which is not
+// limited, treating an unknown key as no key as the route does not check it
+func (a *accessControl) report(w http.ResponseWriter, r *http.Request) {
+	settings := a.settings.load()
+
+	var client *auth.Client
+	if key := r.Header.Get(apiKeyHeader); key != "" {
+		client, _ = settings.Keys.Lookup(key)
+	}
+
+	bucket, limit, _ := a.bucket(r, settings, client)
+	if limit.Enabled() {
+		writeRateLimitHeaders(w, a.limiter.Peek(bucket, limit))
+	}
+}
+
+func writeRateLimitHeaders(w http.ResponseWriter, result ratelimit.Result) {
+	w.Header().Set("X-RateLimit-Limit", strconv.Itoa(result.Limit))
+	w.Header().Set("X-RateLimit-Remaining", strconv.Itoa(result.Remaining))
+	w.Header().Set("X-RateLimit-Reset", ceilSeconds(result.Reset))
+}
+
+// bucket returns the rate limit bucket and limit for the request, and the client name used in metrics
+func (a *accessControl) bucket(r *http.Request, settings *Settings, client *auth.Client) (string, ratelimit.Limit, string) {
+	if client == nil {
+		return "ip:" + a.clientIP(r), settings.IPLimit, anonymousClient
+	}
+
+	limit := settings.KeyLimit
+	if client.RateLimit > 0 {
+		limit = ratelimit.Limit{Rate: client.RateLimit, Burst: client.Burst}
+	}
+
+	return "key:" + client.ID(), limit, client.Name
+}
+
+// clientIP returns the address of the caller, taken from X-Forwarded-For only
+// when the api is known to be behind proxies which append to it. Each proxy
+// appends the address it received the request from, so the address appended by
+// the outermost trusted proxy is the entry that many from the right; entries to
+// its left are sent by the caller and cannot be trusted
+func (a *accessControl) clientIP(r *http.Request) string {
+	if a.trustedProxies > 0 {
+		var forwarded []string
+		for _, header := range r.Header.Values("X-Forwarded-For") {
+			for _, address := range strings.Split(header, ",") {
+				if address = strings.TrimSpace(address); address != "" {
+					forwarded = append(forwarded, address)
+				}
+			}
+		}
+
+		if len(forwarded) >= a.trustedProxies {
+			return forwarded[len(forwarded)-a.trustedProxies]
+		}
+	}
+
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+
+	return host
+}
+
+func ceilSeconds(d time.Duration) string {
+	return strconv.Itoa(int(math.Ceil(d.Seconds())))
+}
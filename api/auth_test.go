@@ -0,0 +1,119 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/ofs/alpha-search-api/auth"
+	"github.com/ofs/alpha-search-api/config"
+)
+
+func TestClientIP(t *testing.T) {
+	testCases := map[string]struct {
+		trustedProxies int
+		forwarded      []string
+		expected       string
+	}{
+		"not trusted":            {0, []string{"203.0.113.9"}, "192.0.2.1"},
+		"no header":              {1, nil, "192.0.2.1"},
+		"one proxy":              {1, []string{"203.0.113.9"}, "203.0.113.9"},
+		"spoofed by the caller":  {1, []string{"198.51.100.1, 203.0.113.9"}, "203.0.113.9"},
+		"two proxies":            {2, []string{"198.51.100.1, 203.0.113.9, 10.0.0.2"}, "203.0.113.9"},
+		"separate headers":       {2, []string{"198.51.100.1", "203.0.113.9", "10.0.0.2"}, "203.0.113.9"},
+		"fewer than the proxies": {2, []string{"203.0.113.9"}, "192.0.2.1"},
+	}
+
+	for name, tc := range testCases {
+		a := &accessControl{trustedProxies: tc.trustedProxies}
+
+		r := httptest.NewRequest("GET", "/search/courses", nil)
+		r.RemoteAddr = "192.0.2.1:51234"
+		for _, forwarded := range tc.forwarded {
+			r.Header.Add("X-Forwarded-For", forwarded)
+		}
+
+		if ip := a.clientIP(r); ip != tc.expected {
+			t.Errorf("%s: expected %s, got %s", name, tc.expected, ip)
+		}
+	}
+}
+
+func TestBucketByKey(t *testing.T) {
+	keys, err := auth.NewKeys([]*auth.Client{
+		{Name: "partner", Key: "first-secret"},
+		{Name: "partner", Key: "second-secret"},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	reloaded, err := auth.NewKeys([]*auth.Client{{Name: "partner", Key: "first-secret"}})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	a := &accessControl{}
+	settings := &Settings{}
+	r := httptest.NewRequest("GET", "/search/courses", nil)
+
+	bucket := func(keys *auth.Keys, key string) string {
+		client, _ := keys.Lookup(key)
+		name, _, _ := a.bucket(r, settings, client)
+		return name
+	}
+
+	if bucket(keys, "first-secret") == bucket(keys, "second-secret") {
+		t.Error("expected keys given the same client name not to share a bucket")
+	}
+	if bucket(keys, "first-secret") != bucket(reloaded, "first-secret") {
+		t.Error("expected a key to keep its bucket when keys are reloaded")
+	}
+}
+
+func TestRateLimitHeadersOnEveryResponse(t *testing.T) {
+	cfg := config.Default()
+	cfg.RateLimitIPRate = 0.001
+	cfg.RateLimitIPBurst = 2
+
+	router := mux.NewRouter()
+	Routes(*cfg, nil, nil, nil, nil, router)
+
+	request := func(method, target string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(method, target, nil))
+		return w
+	}
+
+	// The search is counted, other responses report the limit without being counted
+	w := request("GET", "/search/courses?limit=many")
+	if remaining := w.Header().Get("X-RateLimit-Remaining"); remaining != "1" {
+		t.Errorf("expected the search to be counted, got %q remaining", remaining)
+	}
+
+	for _, tc := range []struct {
+		method, target string
+		status         int
+	}{
+		{"GET", "/health", http.StatusOK},
+		{"GET", "/metrics", http.StatusOK},
+		{"GET", "/openapi.yml", http.StatusOK},
+		{"GET", "/unknown", http.StatusNotFound},
+		{"POST", "/health", http.StatusMethodNotAllowed},
+		{"GET", "/health", http.StatusOK},
+	} {
+		w = request(tc.method, tc.target)
+
+		header := w.Header()
+		if w.Code != tc.status || header.Get("X-RateLimit-Limit") != "2" || header.Get("X-RateLimit-Remaining") != "1" || header.Get("X-RateLimit-Reset") == "" {
+			t.Errorf("%s %s: expected %d with the rate limit of the caller, got %d and %v", tc.method, tc.target, tc.status, w.Code, header)
+		}
+	}
+
+	// Callers without a limit have nothing to report
+	router = mux.NewRouter()
+	Routes(*config.Default(), nil, nil, nil, nil, router)
+	if w = request("GET", "/health"); w.Header().Get("X-RateLimit-Limit") != "" {
+		t.Errorf("expected no rate limit headers without a limit, got %v", w.Header())
+	}
+}
@@ -7,6 +7,7 @@ import (
 	"io/ioutil"
 	"net/http"
 
+	"github.com/methods/go-methods-lib/common"
 	"github.com/methods/go-methods-lib/log"
 	errs "github.com/ofs/alpha-search-api/apierrors"
 	"github.com/ofs/alpha-search-api/models"
@@ -21,6 +22,10 @@ const (
 
 	defaultLimit  = 20
 	defaultOffset = 0
+
+	// cacheBypassHeader is sent by callers, with a value of true, to ensure
+	// search results are not served from the cache
+	cacheBypassHeader = "X-Cache-Bypass"
 )
 
 type contextKey string
@@ -29,22 +34,36 @@ func (c contextKey) String() string {
 	return string(c)
 }
 
-// Error handles transforms an error into structured error message
+// Error handles transforms an error into structured error message, errors
+// without a status are returned as internal server errors
 func Error(ctx context.Context, w http.ResponseWriter, err error) {
+	errorObject, ok := err.(*errs.ErrorObject)
+	if !ok {
+		status := http.StatusInternalServerError
+		if err == errs.ErrCircuitBreakerOpen || err == errs.ErrShuttingDown {
+			status = http.StatusServiceUnavailable
+		} else {
+			err = errs.ErrInternalServer
+		}
+		errorObject = errs.New(err, status, nil).(*errs.ErrorObject)
+	}
+
 	errorResponse := &models.ErrorResponse{
 		Errors: []*models.ErrorObject{
 			&models.ErrorObject{
-				Error:       err.(*errs.ErrorObject).Error(),
-				ErrorValues: err.(*errs.ErrorObject).Values(),
+				Error:       errorObject.Error(),
+				ErrorValues: errorObject.Values(),
 			},
 		},
 	}
 
-	ErrorResponse(ctx, w, err.(*errs.ErrorObject).Status(), errorResponse)
+	ErrorResponse(ctx, w, errorObject.Status(), errorResponse)
 }
 
 // ErrorResponse sets the structured error message in the http response body
 func ErrorResponse(ctx context.Context, w http.ResponseWriter, status int, errorResponse *models.ErrorResponse) {
+	errorResponse.RequestID = common.GetRequestId(ctx)
+
 	b, err := json.Marshal(errorResponse)
 	if err != nil {
 		http.Error(w, errs.ErrInternalServer.Error(), http.StatusInternalServerError)
@@ -79,10 +98,6 @@ func drainBody(ctx context.Context, r *http.Request) {
 }
 
 func writeBody(ctx context.Context, w http.ResponseWriter, b []byte) {
-	//Allow CORS here By * or specific origin
-	w.Header().Set("Access-Control-Allow-Origin", "*")
-	w.Header().Set("Access-Control-Allow-Headers", "Content-Type")
-
 	w.Header().Set("Content-Type", "application/json")
 	if _, err := w.Write(b); err != nil {
 		log.ErrorCtx(ctx, errors.WithMessage(err, "failed to write response body"), nil)
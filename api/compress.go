@@ -0,0 +1,304 @@
+package api
+
+import (
+	"bufio"
+	"compress/gzip"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/methods/go-methods-lib/log"
+	"github.com/pkg/errors"
+)
+
+// encoder creates a writer compressing data written to w
+type encoder struct {
+	name  string
+	pool  *sync.Pool
+	reset func(interface{}, io.Writer) io.WriteCloser
+}
+
+// compressor negotiates the content encoding of responses, compressing those
+// with a compressible content type once they exceed a minimum size
+type compressor struct {
+	encoders []encoder
+	minSize  int
+}
+
+// newCompressor creates a compressor using the given gzip level, falling back to
+// the default level if it is invalid
+func newCompressor(level, minSize int) *compressor {
+	// Check the level is valid up front as the pool cannot return errors
+	if _, err := gzip.NewWriterLevel(ioutil.Discard, level); err != nil {
+		log.Error(errors.Wrap(err, "invalid compression level, using default"), log.Data{"level": level})
+		level = gzip.DefaultCompression
+	}
+
+	gzipPool := &sync.Pool{New: func() interface{} {
+		w, _ := gzip.NewWriterLevel(ioutil.Discard, level)
+		return w
+	}}
+
+	return &compressor{
+		minSize: minSize,
+		encoders: []encoder{{
+			name: "gzip",
+			pool: gzipPool,
+			reset: func(w interface{}, dst io.Writer) io.WriteCloser {
+				gw := w.(*gzip.Writer)
+				gw.Reset(dst)
+				return gw
+			},
+		}},
+	}
+}
+
+// handler compresses the response if the client accepts one of the supported encodings
+func (c *compressor) handler(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		addVary(w.Header(), "Accept-Encoding")
+
+		enc := c.negotiate(r.Header.Get("Accept-Encoding"))
+		if enc == nil || r.Method == http.MethodHead {
+			h.ServeHTTP(w, r)
+			return
+		}
+
+		// ETags of compressed representations are suffixed with the encoding, the
+		// suffix is removed so handlers can compare against their own ETag
+		suffix := "-" + enc.name
+		ifNoneMatch := r.Header.Get("If-None-Match")
+		if strings.Contains(ifNoneMatch, suffix+`"`) {
+			r.Header.Set("If-None-Match", strings.Replace(ifNoneMatch, suffix+`"`, `"`, -1))
+		}
+
+		cw := &compressWriter{
+			ResponseWriter:  w,
+			encoder:         enc,
+			minSize:         c.minSize,
+			etagSuffix:      suffix,
+			clientHasSuffix: strings.Contains(ifNoneMatch, suffix+`"`),
+		}
+		defer cw.Close()
+
+		h.ServeHTTP(cw, r)
+	})
+}
+
+// negotiate chooses the encoding preferred by the client from its Accept-Encoding header
+func (c *compressor) negotiate(acceptEncoding string) *encoder {
+	if acceptEncoding == "" {
+		return nil
+	}
+
+	var best *encoder
+	bestQ := 0.0
+
+	for _, part := range strings.Split(acceptEncoding, ",") {
+		name, q := parseCoding(part)
+		if q <= 0 {
+			continue
+		}
+
+		for i := range c.encoders {
+			if (name == c.encoders[i].name || name == "*") && q > bestQ {
+				best = &c.encoders[i]
+				bestQ = q
+			}
+		}
+	}
+
+	return best
+}
+
+func parseCoding(part string) (string, float64) {
+	fields := strings.Split(part, ";")
+	name := strings.ToLower(strings.TrimSpace(fields[0]))
+	q := 1.0
+
+	for _, param := range fields[1:] {
+		param = strings.TrimSpace(param)
+		if strings.HasPrefix(param, "q=") {
+			value, err := strconv.ParseFloat(strings.TrimPrefix(param, "q="), 64)
+			if err != nil {
+				return name, 0
+			}
+			q = value
+		}
+	}
+
+	return name, q
+}
+
+// compressWriter buffers the start of the response until it knows whether it
+// is large enough to be worth compressing
+type compressWriter struct {
+	http.ResponseWriter
+	encoder         *encoder
+	minSize         int
+	etagSuffix      string
+	clientHasSuffix bool
+
+	status      int
+	wroteHeader bool
+	decided     bool
+	buf         []byte
+	writer      io.WriteCloser
+}
+
+func (cw *compressWriter) WriteHeader(status int) {
+	if cw.wroteHeader {
+		return
+	}
+	cw.wroteHeader = true
+	cw.status = status
+
+	// Responses without a body are sent straight away
+	if status == http.StatusNotModified || status == http.StatusNoContent || status < http.StatusOK {
+		if status == http.StatusNotModified && cw.clientHasSuffix {
+			cw.suffixETag()
+		}
+		cw.decided = true
+		cw.ResponseWriter.WriteHeader(status)
+	}
+}
+
+func (cw *compressWriter) Write(b []byte) (int, error) {
+	if !cw.wroteHeader {
+		cw.WriteHeader(http.StatusOK)
+	}
+
+	if cw.decided {
+		if cw.writer != nil {
+			return cw.writer.Write(b)
+		}
+		return cw.ResponseWriter.Write(b)
+	}
+
+	if !compressible(cw.Header()) || cw.Header().Get("Content-Encoding") != "" {
+		if err := cw.start(false); err != nil {
+			return 0, err
+		}
+		return cw.ResponseWriter.Write(b)
+	}
+
+	cw.buf = append(cw.buf, b...)
+	if len(cw.buf) >= cw.minSize {
+		if err := cw.start(true); err != nil {
+			return 0, err
+		}
+	}
+
+	return len(b), nil
+}
+
+// start writes the status line and headers, and any buffered data, either compressed or not
+func (cw *compressWriter) start(compress bool) error {
+	cw.decided = true
+
+	if compress {
+		cw.Header().Set("Content-Encoding", cw.encoder.name)
+		cw.Header().Del("Content-Length")
+		cw.suffixETag()
+	}
+
+	cw.ResponseWriter.WriteHeader(cw.status)
+
+	if compress {
+		cw.writer = cw.encoder.reset(cw.encoder.pool.Get(), cw.ResponseWriter)
+	}
+
+	if len(cw.buf) == 0 {
+		return nil
+	}
+
+	var err error
+	if cw.writer != nil {
+		_, err = cw.writer.Write(cw.buf)
+	} else {
+		_, err = cw.ResponseWriter.Write(cw.buf)
+	}
+	cw.buf = nil
+
+	return err
+}
+
+func (cw *compressWriter) suffixETag() {
+	etag := cw.Header().Get("ETag")
+	if strings.HasSuffix(etag, `"`) && !strings.HasSuffix(etag, cw.etagSuffix+`"`) {
+		cw.Header().Set("ETag", strings.TrimSuffix(etag, `"`)+cw.etagSuffix+`"`)
+	}
+}
+
+// Flush sends any buffered data to the client, compressing it if a streaming
+// handler has flushed before the minimum size was reached
+func (cw *compressWriter) Flush() {
+	if !cw.decided && cw.wroteHeader {
+		cw.start(len(cw.buf) > 0 && compressible(cw.Header()) && cw.Header().Get("Content-Encoding") == "")
+	}
+
+	if f, ok := cw.writer.(interface{ Flush() error }); ok {
+		f.Flush()
+	}
+
+	if f, ok := cw.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// Hijack allows the underlying connection to be taken over
+func (cw *compressWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	if h, ok := cw.ResponseWriter.(http.Hijacker); ok {
+		return h.Hijack()
+	}
+	return nil, nil, errors.New("compress: response does not implement http.Hijacker")
+}
+
+// Close writes any remaining buffered data and completes the compressed stream
+func (cw *compressWriter) Close() error {
+	if !cw.wroteHeader {
+		return nil
+	}
+
+	if !cw.decided {
+		// The response was smaller than the minimum size
+		if err := cw.start(false); err != nil {
+			return err
+		}
+	}
+
+	if cw.writer == nil {
+		return nil
+	}
+
+	err := cw.writer.Close()
+	cw.encoder.pool.Put(cw.writer)
+	cw.writer = nil
+
+	return err
+}
+
+// compressible returns true for text based content types
+func compressible(header http.Header) bool {
+	contentType := strings.ToLower(header.Get("Content-Type"))
+	if i := strings.Index(contentType, ";"); i >= 0 {
+		contentType = contentType[:i]
+	}
+	contentType = strings.TrimSpace(contentType)
+
+	switch {
+	case strings.HasPrefix(contentType, "text/"),
+		strings.HasSuffix(contentType, "+json"),
+		contentType == "application/json",
+		contentType == "application/yaml",
+		contentType == "application/x-yaml",
+		contentType == "application/xml":
+		return true
+	}
+
+	return false
+}
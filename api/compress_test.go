@@ -0,0 +1,214 @@
+package api
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestNegotiate(t *testing.T) {
+	c := newCompressor(gzip.DefaultCompression, 0)
+
+	testCases := map[string]bool{
+		"":                         false,
+		"gzip":                     true,
+		"GZIP":                     true,
+		"deflate, gzip;q=0.5":      true,
+		"*":                        true,
+		"gzip;q=0":                 false,
+		"br":                       false,
+		"identity":                 false,
+		"gzip;q=invalid, identity": false,
+	}
+
+	for acceptEncoding, expected := range testCases {
+		if enc := c.negotiate(acceptEncoding); (enc != nil) != expected {
+			t.Errorf("%q: expected gzip to be chosen %v, got %v", acceptEncoding, expected, enc)
+		}
+	}
+}
+
+// compressed makes a request through the compressor to a handler writing the
+// body with the content type given
+func compressed(t *testing.T, acceptEncoding, contentType string, body []byte) *httptest.ResponseRecorder {
+	t.Helper()
+
+	h := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", contentType)
+		w.Write(body)
+	})
+
+	req := httptest.NewRequest("GET", "/search/courses", nil)
+	req.Header.Set("Accept-Encoding", acceptEncoding)
+
+	w := httptest.NewRecorder()
+	newCompressor(gzip.DefaultCompression, 1024).handler(h).ServeHTTP(w, req)
+
+	return w
+}
+
+func gunzip(t *testing.T, b []byte) []byte {
+	t.Helper()
+
+	r, err := gzip.NewReader(bytes.NewReader(b))
+	if err != nil {
+		t.Fatalf("failed to read gzip response: %v", err)
+	}
+	decoded, err := ioutil.ReadAll(r)
+	if err != nil {
+		t.Fatalf("failed to read gzip response: %v", err)
+	}
+
+	return decoded
+}
+
+func TestCompressMinimumSize(t *testing.T) {
+	large := []byte(`{"courses":"` + strings.Repeat("a", 2048) + `"}`)
+	small := []byte(`{"courses":[]}`)
+
+	w := compressed(t, "gzip", "application/json", large)
+	if encoding := w.Header().Get("Content-Encoding"); encoding != "gzip" {
+		t.Fatalf("expected a large response to be compressed, got %q", encoding)
+	}
+	if decoded := gunzip(t, w.Body.Bytes()); !bytes.Equal(decoded, large) {
+		t.Errorf("expected the response to be decoded, got %s", decoded)
+	}
+	if vary := w.Header().Get("Vary"); !strings.Contains(vary, "Accept-Encoding") {
+		t.Errorf("expected to vary by Accept-Encoding, got %q", vary)
+	}
+
+	w = compressed(t, "gzip", "application/json", small)
+	if encoding := w.Header().Get("Content-Encoding"); encoding != "" || w.Body.String() != string(small) {
+		t.Errorf("expected a response under the minimum size not to be compressed, got %q and %s", encoding, w.Body)
+	}
+
+	w = compressed(t, "gzip", "image/png", large)
+	if encoding := w.Header().Get("Content-Encoding"); encoding != "" {
+		t.Errorf("expected an incompressible content type not to be compressed, got %q", encoding)
+	}
+
+	w = compressed(t, "br", "application/json", large)
+	if encoding := w.Header().Get("Content-Encoding"); encoding != "" || !bytes.Equal(w.Body.Bytes(), large) {
+		t.Errorf("expected no encoding when gzip is not accepted, got %q", encoding)
+	}
+}
+
+func TestCompressETag(t *testing.T) {
+	body := []byte(`{"courses":"` + strings.Repeat("a", 2048) + `"}`)
+
+	h := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("ETag", `"v1"`)
+		if r.Header.Get("If-None-Match") == `"v1"` {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.Write(body)
+	})
+	handler := newCompressor(gzip.DefaultCompression, 1024).handler(h)
+
+	request := func(acceptEncoding, ifNoneMatch string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("GET", "/search/courses", nil)
+		req.Header.Set("Accept-Encoding", acceptEncoding)
+		if ifNoneMatch != "" {
+			req.Header.Set("If-None-Match", ifNoneMatch)
+		}
+
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, req)
+		return w
+	}
+
+	w := request("gzip", "")
+	if etag := w.Header().Get("ETag"); w.Code != http.StatusOK || etag != `"v1-gzip"` {
+		t.Errorf("expected the ETag of the compressed response to be suffixed, got %d and %q", w.Code, etag)
+	}
+
+	w = request("gzip", `"v1-gzip"`)
+	if etag := w.Header().Get("ETag"); w.Code != http.StatusNotModified || etag != `"v1-gzip"` || w.Body.Len() != 0 {
+		t.Errorf("expected the suffixed ETag to match, got %d and %q", w.Code, etag)
+	}
+
+	w = request("identity", `"v1"`)
+	if etag := w.Header().Get("ETag"); w.Code != http.StatusNotModified || etag != `"v1"` {
+		t.Errorf("expected the uncompressed ETag to match, got %d and %q", w.Code, etag)
+	}
+
+	// A compressed representation is not a match for an uncompressed one
+	w = request("identity", `"v1-gzip"`)
+	if w.Code != http.StatusOK || w.Header().Get("Content-Encoding") != "" {
+		t.Errorf("expected the full uncompressed response, got %d", w.Code)
+	}
+}
+
+func TestCompressFlush(t *testing.T) {
+	first := []byte(`{"courses":[`)
+	release := make(chan struct{})
+
+	h := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write(first)
+
+		f, ok := w.(http.Flusher)
+		if !ok {
+			t.Error("expected the response to be flushable")
+			return
+		}
+		f.Flush()
+
+		<-release
+		w.Write([]byte(`]}`))
+	})
+
+	// The compressor is wrapped by the metrics and tracing recorders as it is in the router
+	server := httptest.NewServer(metricsHandler(tracingHandler(newCompressor(gzip.DefaultCompression, 1024).handler(h))))
+	defer server.Close()
+	defer close(release)
+
+	req, _ := http.NewRequest("GET", server.URL, nil)
+	req.Header.Set("Accept-Encoding", "gzip")
+
+	// The transport would otherwise decompress the response itself
+	client := &http.Client{Transport: &http.Transport{DisableCompression: true}}
+
+	type flushed struct {
+		encoding string
+		body     []byte
+		err      error
+	}
+	received := make(chan flushed, 1)
+	go func() {
+		resp, err := client.Do(req)
+		if err != nil {
+			received <- flushed{err: err}
+			return
+		}
+		defer resp.Body.Close()
+
+		r, err := gzip.NewReader(resp.Body)
+		if err != nil {
+			received <- flushed{encoding: resp.Header.Get("Content-Encoding"), err: err}
+			return
+		}
+		b := make([]byte, len(first))
+		_, err = io.ReadFull(r, b)
+		received <- flushed{encoding: resp.Header.Get("Content-Encoding"), body: b, err: err}
+	}()
+
+	// The first part is received before the handler finishes
+	select {
+	case f := <-received:
+		if f.err != nil || f.encoding != "gzip" || !bytes.Equal(f.body, first) {
+			t.Errorf("expected %s to be flushed compressed, got %q, %s and %v", first, f.encoding, f.body, f.err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Error("expected the flushed data to reach the client")
+	}
+}
@@ -0,0 +1,575 @@
+package api_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/ofs/alpha-search-api/api"
+	errs "github.com/ofs/alpha-search-api/apierrors"
+	"github.com/ofs/alpha-search-api/cache"
+	"github.com/ofs/alpha-search-api/config"
+	"github.com/ofs/alpha-search-api/models"
+	"github.com/ofs/alpha-search-api/openapi"
+)
+
+const debugToken = "contract-test-token"
+
+// validValues are used for parameters which have no example in the specification
+var validValues = map[string]string{
+	"offset": "20",
+	"q":      "maths",
+}
+
+// invalidValues are inputs each parameter must reject, in addition to those derived from its schema
+var invalidValues = map[string][]string{
+	"countries":        {"atlantis", "england,", "wales,,scotland", "wales,-wales"},
+	"filters":          {"not_a_filter", "part_time,full_time", "honours_award,-honours_award", ",part_time"},
+	"institutions":     {"university of leeds, "},
+	"length_of_course": {"0", "8", "three", "3,", "99999999999999999999"},
+	"limit":            {"99999999999999999999"},
+	"offset":           {"1000", "99999999999999999999"},
+	"profile":          {"yes"},
+	"subjects":         {"CAH09-01-01,,CAH10-01-01"},
+}
+
+// notForwarded lists the parameters of each operation which are handled by the
+// api rather than passed to elasticsearch
+var notForwarded = map[string][]string{
+	"/search/courses":             {"profile"},
+	"/search/institution-courses": {"limit", "offset", "profile"},
+}
+
+// fakeElasticsearch returns a single course with every field set, or the given
+// error, recording the arguments of the last query by parameter name
+type fakeElasticsearch struct {
+	err error
+
+	mutex sync.Mutex
+	last  map[string]string
+	calls int
+}
+
+func (f *fakeElasticsearch) QueryCoursesSearch(ctx context.Context, index, term string, limit, offset int, filters map[string]string, countries, lengthOfCourse, institutions, subjects []string) (*models.SearchResponse, int, error) {
+	f.record(term, filters, countries, lengthOfCourse, institutions, subjects, map[string]string{
+		"limit":  strconv.Itoa(limit),
+		"offset": strconv.Itoa(offset),
+	})
+	return f.response()
+}
+
+func (f *fakeElasticsearch) QueryInstitutionCoursesSearch(ctx context.Context, index, term string, filters map[string]string, countries, lengthOfCourse, institutions, subjects []string) (*models.SearchResponse, int, error) {
+	f.record(term, filters, countries, lengthOfCourse, institutions, subjects, map[string]string{})
+	return f.response()
+}
+
+func (f *fakeElasticsearch) record(term string, filters map[string]string, countries, lengthOfCourse, institutions, subjects []string, args map[string]string) {
+	args["q"] = term
+	args["filters"] = fmt.Sprint(filters)
+	args["countries"] = fmt.Sprint(countries)
+	args["length_of_course"] = fmt.Sprint(lengthOfCourse)
+	args["institutions"] = fmt.Sprint(institutions)
+	args["subjects"] = fmt.Sprint(subjects)
+
+	f.mutex.Lock()
+	f.last = args
+	f.calls++
+	f.mutex.Unlock()
+}
+
+func (f *fakeElasticsearch) lastQuery() map[string]string {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	return f.last
+}
+
+func (f *fakeElasticsearch) queries() int {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	return f.calls
+}
+
+func (f *fakeElasticsearch) response() (*models.SearchResponse, int, error) {
+	if f.err != nil {
+		return nil, 0, f.err
+	}
+
+	return &models.SearchResponse{
+		Took:    3,
+		Profile: []byte(`{"shards":[]}`),
+		Hits: models.Hits{
+			Total: 1,
+			HitList: []models.HitList{{
+				Score: 1.5,
+				Highlight: models.Highlight{
+					EnglishTitle:    []string{"\u0001SMaths\u0001E and Physics"},
+					InstitutionName: []string{"\u0001SMaths\u0001E University"},
+				},
+				Source: models.SearchResult{Doc: models.Document{
+					SortName:         "example university",
+					KISCourseID:      "MP001",
+					EnglishTitle:     "Maths and Physics",
+					WelshTitle:       "Mathemateg a Ffiseg",
+					Country:          "Wales",
+					DistanceLearning: "0",
+					FoundationYear:   "1",
+					HonoursAward:     "1",
+					LengthOfCourse:   "3",
+					Link:             "https://example.ac.uk/courses/mp001",
+					Mode:             "1",
+					NHSFunded:        "0",
+					SandwichYear:     "0",
+					SubjectCode:      "CAH09-01-01",
+					SubjectName:      "Mathematics",
+					YearAbroad:       "2",
+					Institution: &models.Institution{
+						PublicUKPRN:     "10000001",
+						PublicUKPRNName: "Example University",
+						UKPRN:           "10000001",
+						UKPRNName:       "Example University",
+						LCUKPRNName:     "example university",
+					},
+					Location: &models.LocationObject{
+						EnglishName: "Main campus",
+						WelshName:   "Prif gampws",
+						Latitude:    "51.48",
+						Longitude:   "-3.18",
+					},
+					Qualification: &models.Qualification{
+						Code:  "021",
+						Label: "BSc",
+						Level: "F",
+						Name:  "Bachelor of Science",
+					},
+				}},
+			}},
+		},
+	}, http.StatusOK, nil
+}
+
+type contract struct {
+	t      *testing.T
+	doc    *openapi.Document
+	server *httptest.Server
+	router *mux.Router
+}
+
+func newContract(t *testing.T, es api.Elasticsearcher, configure func(*config.Configuration)) *contract {
+	doc, err := openapi.Load()
+	if err != nil {
+		t.Fatalf("failed to load specification: %v", err)
+	}
+
+	c := *config.Default()
+	c.DebugCaptureToken = debugToken
+	c.OpenAPIValidation = api.ValidationOff
+	if configure != nil {
+		configure(&c)
+	}
+
+	router := mux.NewRouter()
+	api.Routes(c, es, nil, nil, nil, router)
+
+	server := httptest.NewServer(router)
+	t.Cleanup(server.Close)
+
+	return &contract{t: t, doc: doc, server: server, router: router}
+}
+
+// call makes the request and checks the response has the expected status and matches
+// the specification, the body of the returned response can be read again
+func (c *contract) call(op *openapi.Operation, query url.Values, header http.Header, expectedStatus int) *http.Response {
+	c.t.Helper()
+
+	target := c.server.URL + op.Path
+	if len(query) > 0 {
+		target += "?" + query.Encode()
+	}
+
+	req, err := http.NewRequest(op.Method, target, nil)
+	if err != nil {
+		c.t.Fatalf("failed to create request: %v", err)
+	}
+	for name, values := range header {
+		req.Header[name] = values
+	}
+
+	resp, err := c.server.Client().Do(req)
+	if err != nil {
+		c.t.Fatalf("%s %s: request failed: %v", op.Method, target, err)
+	}
+	defer resp.Body.Close()
+
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		c.t.Fatalf("%s %s: failed to read body: %v", op.Method, target, err)
+	}
+
+	if resp.StatusCode != expectedStatus {
+		c.t.Errorf("%s %s: expected status %d, got %d: %s", op.Method, target, expectedStatus, resp.StatusCode, body)
+	}
+
+	for _, violation := range c.doc.ValidateResponse(op, resp.StatusCode, resp.Header.Get("Content-Type"), body) {
+		c.t.Errorf("%s %s: %s", op.Method, target, violation)
+	}
+
+	resp.Body = ioutil.NopCloser(bytes.NewReader(body))
+
+	return resp
+}
+
+// errorMessages decodes the error response returned by call
+func (c *contract) errorMessages(resp *http.Response) []string {
+	c.t.Helper()
+
+	var errorResponse models.ErrorResponse
+	if err := json.NewDecoder(resp.Body).Decode(&errorResponse); err != nil {
+		c.t.Fatalf("failed to decode error response: %v", err)
+	}
+
+	var messages []string
+	for _, e := range errorResponse.Errors {
+		messages = append(messages, e.Error)
+	}
+
+	return messages
+}
+
+// isSearch returns true for operations which query elasticsearch
+func isSearch(op *openapi.Operation) bool {
+	return strings.HasPrefix(op.Path, "/search/")
+}
+
+func TestContractEveryRouteIsDocumented(t *testing.T) {
+	c := newContract(t, &fakeElasticsearch{}, nil)
+
+	c.router.Walk(func(route *mux.Route, router *mux.Router, ancestors []*mux.Route) error {
+		path, err := route.GetPathTemplate()
+		if err != nil {
+			// Routes without a path, such as the one answering OPTIONS for every path
+			return nil
+		}
+
+		methods, _ := route.GetMethods()
+		for _, method := range methods {
+			if _, ok := c.doc.Operation(path, method); !ok {
+				t.Errorf("route %s %s is not documented", method, path)
+			}
+		}
+
+		return nil
+	})
+}
+
+func TestContractEveryOperationSucceedsWithoutParameters(t *testing.T) {
+	c := newContract(t, &fakeElasticsearch{}, nil)
+
+	for _, op := range c.doc.Operations() {
+		c.call(op, nil, nil, http.StatusOK)
+	}
+}
+
+func TestContractValidParameters(t *testing.T) {
+	c := newContract(t, &fakeElasticsearch{}, nil)
+
+	for _, op := range c.doc.Operations() {
+		all := url.Values{}
+
+		for _, p := range op.Parameters {
+			if p.In != "query" {
+				continue
+			}
+
+			for _, value := range validInputs(p) {
+				c.call(op, url.Values{p.Name: {value}}, nil, http.StatusOK)
+			}
+
+			all.Set(p.Name, validInputs(p)[0])
+		}
+
+		if len(all) > 0 {
+			c.call(op, all, nil, http.StatusOK)
+		}
+	}
+}
+
+func TestContractParametersReachElasticsearch(t *testing.T) {
+	es := &fakeElasticsearch{}
+	c := newContract(t, es, nil)
+
+	for _, op := range c.doc.Operations() {
+		if !isSearch(op) {
+			continue
+		}
+
+		c.call(op, nil, nil, http.StatusOK)
+		unset := es.lastQuery()
+
+	parameters:
+		for _, p := range op.Parameters {
+			if p.In != "query" {
+				continue
+			}
+			for _, name := range notForwarded[op.Path] {
+				if p.Name == name {
+					continue parameters
+				}
+			}
+
+			value, ok := unset[p.Name]
+			if !ok {
+				t.Errorf("%s %s: parameter %s is documented but not passed to elasticsearch", op.Method, op.Path, p.Name)
+				continue
+			}
+
+			// Send the first valid input differing from the default, so the query must change
+			sent := false
+			for _, input := range validInputs(p) {
+				if input == toString(p.Schema["default"]) {
+					continue
+				}
+
+				c.call(op, url.Values{p.Name: {input}}, nil, http.StatusOK)
+				if es.lastQuery()[p.Name] == value {
+					t.Errorf("%s %s: parameter %s=%s did not change the query", op.Method, op.Path, p.Name, input)
+				}
+				sent = true
+				break
+			}
+
+			if !sent {
+				t.Errorf("%s %s: no valid input for parameter %s differs from its default", op.Method, op.Path, p.Name)
+			}
+		}
+	}
+}
+
+func TestContractInvalidParameters(t *testing.T) {
+	c := newContract(t, &fakeElasticsearch{}, nil)
+
+	for _, op := range c.doc.Operations() {
+		for _, p := range op.Parameters {
+			if p.In != "query" {
+				continue
+			}
+
+			for _, value := range invalidInputs(p) {
+				c.call(op, url.Values{p.Name: {value}}, nil, http.StatusBadRequest)
+			}
+		}
+	}
+}
+
+func TestContractProfileRequiresTrustedCaller(t *testing.T) {
+	c := newContract(t, &fakeElasticsearch{}, nil)
+
+	for _, op := range c.doc.Operations() {
+		if _, ok := op.Parameter("query", "profile"); !ok {
+			continue
+		}
+
+		query := url.Values{"profile": {"true"}}
+
+		for _, header := range []http.Header{nil, {"X-Debug-Capture": {"wrong"}}} {
+			resp := c.call(op, query, header, http.StatusBadRequest)
+			if messages := c.errorMessages(resp); len(messages) != 1 || messages[0] != errs.ErrProfileNotPermitted.Error() {
+				t.Errorf("%s %s: expected only %q, got %v", op.Method, op.Path, errs.ErrProfileNotPermitted, messages)
+			}
+		}
+
+		resp := c.call(op, url.Values{"profile": {"yes"}}, http.Header{"X-Debug-Capture": {debugToken}}, http.StatusBadRequest)
+		if messages := c.errorMessages(resp); len(messages) != 1 || messages[0] != errs.ErrProfileWrongType.Error() {
+			t.Errorf("%s %s: expected only %q, got %v", op.Method, op.Path, errs.ErrProfileWrongType, messages)
+		}
+
+		c.call(op, query, http.Header{"X-Debug-Capture": {debugToken}}, http.StatusOK)
+	}
+}
+
+func TestContractCacheBypassRequiresTrustedCaller(t *testing.T) {
+	es := &fakeElasticsearch{}
+	c := newContract(t, cache.NewSearcher(es, 10, time.Minute), nil)
+
+	bypass := http.Header{"X-Cache-Bypass": {"true"}}
+	trusted := http.Header{"X-Cache-Bypass": {"true"}, "X-Debug-Capture": {debugToken}}
+
+	for _, op := range c.doc.Operations() {
+		if !isSearch(op) {
+			continue
+		}
+
+		c.call(op, nil, nil, http.StatusOK)
+		queries := es.queries()
+
+		c.call(op, nil, bypass, http.StatusOK)
+		if es.queries() != queries {
+			t.Errorf("%s %s: expected the cache bypass to be ignored from an untrusted caller", op.Method, op.Path)
+		}
+
+		c.call(op, nil, trusted, http.StatusOK)
+		if es.queries() != queries+1 {
+			t.Errorf("%s %s: expected a trusted caller to bypass the cache", op.Method, op.Path)
+		}
+	}
+}
+
+func TestContractNotModified(t *testing.T) {
+	c := newContract(t, &fakeElasticsearch{}, nil)
+
+	for _, op := range c.doc.Operations() {
+		if !isSearch(op) {
+			continue
+		}
+
+		resp := c.call(op, nil, nil, http.StatusOK)
+
+		etag := resp.Header.Get("ETag")
+		if etag == "" {
+			t.Errorf("%s %s: expected an ETag", op.Method, op.Path)
+			continue
+		}
+
+		c.call(op, nil, http.Header{"If-None-Match": {etag}}, http.StatusNotModified)
+	}
+}
+
+func TestContractInvalidAPIKey(t *testing.T) {
+	c := newContract(t, &fakeElasticsearch{}, nil)
+
+	for _, op := range c.doc.Operations() {
+		if isSearch(op) {
+			c.call(op, nil, http.Header{"X-Api-Key": {"unknown"}}, http.StatusUnauthorized)
+		}
+	}
+}
+
+func TestContractRateLimited(t *testing.T) {
+	c := newContract(t, &fakeElasticsearch{}, func(cfg *config.Configuration) {
+		cfg.RateLimitIPRate = 0.001
+		cfg.RateLimitIPBurst = 1
+	})
+
+	// Every search shares the limit for the test client's address, so only the first succeeds
+	status := http.StatusOK
+
+	for _, op := range c.doc.Operations() {
+		if !isSearch(op) {
+			continue
+		}
+
+		resp := c.call(op, nil, nil, status)
+		if status == http.StatusOK {
+			status = http.StatusTooManyRequests
+			resp = c.call(op, nil, nil, status)
+		}
+
+		if retryAfter, err := strconv.Atoi(resp.Header.Get("Retry-After")); err != nil || retryAfter <= 0 {
+			t.Errorf("%s %s: expected a positive Retry-After, got %q", op.Method, op.Path, resp.Header.Get("Retry-After"))
+		}
+	}
+}
+
+func TestContractElasticsearchErrors(t *testing.T) {
+	cases := []struct {
+		err    error
+		status int
+	}{
+		{errs.ErrCircuitBreakerOpen, http.StatusServiceUnavailable},
+		{errs.ErrShuttingDown, http.StatusServiceUnavailable},
+		{errs.ErrUnexpectedStatusCode, http.StatusInternalServerError},
+	}
+
+	for _, tc := range cases {
+		c := newContract(t, &fakeElasticsearch{err: tc.err}, nil)
+
+		for _, op := range c.doc.Operations() {
+			if isSearch(op) {
+				c.call(op, url.Values{"q": {"maths"}}, nil, tc.status)
+			}
+		}
+	}
+}
+
+// validInputs returns values the parameter must accept, the first being used
+// when all parameters are sent together
+func validInputs(p *openapi.Parameter) []string {
+	var values []string
+	if p.Example != "" {
+		values = append(values, p.Example)
+	}
+	if value, ok := validValues[p.Name]; ok {
+		values = append(values, value)
+	}
+
+	switch p.Schema["type"] {
+	case "integer":
+		if value, ok := p.Schema["default"]; ok {
+			values = append(values, toString(value))
+		}
+		if value, ok := p.Schema["minimum"]; ok {
+			values = append(values, toString(value))
+		}
+		if value, ok := p.Schema["maximum"]; ok {
+			values = append(values, toString(value))
+		}
+	case "boolean":
+		// true is not tested here as it may need other parameters, e.g. a trusted caller for profile
+		values = append(values, "false")
+	}
+
+	if len(values) == 0 {
+		values = append(values, "value")
+	}
+
+	return values
+}
+
+// invalidInputs returns values the parameter must reject
+func invalidInputs(p *openapi.Parameter) []string {
+	values := invalidValues[p.Name]
+
+	switch p.Schema["type"] {
+	case "integer":
+		values = append(values, "abc", "1.5")
+		if value, ok := p.Schema["minimum"].(int); ok {
+			values = append(values, strconv.Itoa(value-1))
+		}
+		if value, ok := p.Schema["maximum"].(int); ok {
+			values = append(values, strconv.Itoa(value+1))
+		}
+	case "boolean":
+		values = append(values, "maybe")
+	case "string":
+		if value, ok := p.Schema["maxLength"].(int); ok {
+			values = append(values, strings.Repeat("a", value+1))
+		}
+	}
+
+	return values
+}
+
+func toString(value interface{}) string {
+	switch v := value.(type) {
+	case int:
+		return strconv.Itoa(v)
+	case bool:
+		return strconv.FormatBool(v)
+	case string:
+		return v
+	}
+
+	return ""
+}
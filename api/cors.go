@@ -0,0 +1,184 @@
+package api
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+// corsPolicy determines which cross origin requests browsers are allowed to make
+type corsPolicy struct {
+	settings         *settingsStore
+	allowedMethods   string
+	allowedHeaders   string
+	exposedHeaders   string
+	maxAge           string
+	allowCredentials bool
+}
+
+// newCORSPolicy creates a policy from the configured lists, taking the allowed
+// origins from the current settings; origins may be exact, "*" for any origin or
+// contain a wildcard, e.g. https://*.example.com
+func newCORSPolicy(settings *settingsStore, methods, headers, exposed []string, maxAge time.Duration, allowCredentials bool) *corsPolicy {
+	policy := &corsPolicy{
+		settings:         settings,
+		allowedMethods:   strings.Join(trimAll(methods), ", "),
+		allowedHeaders:   strings.Join(trimAll(headers), ", "),
+		exposedHeaders:   strings.Join(trimAll(exposed), ", "),
+		allowCredentials: allowCredentials,
+	}
+
+	if maxAge > 0 {
+		policy.maxAge = strconv.Itoa(int(maxAge.Seconds()))
+	}
+
+	return policy
+}
+
+// handler adds CORS headers to every response to an allowed origin, including
+// error responses, and answers preflight requests
+func (c *corsPolicy) handler(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		origin := r.Header.Get("Origin")
+		preflight := r.Method == http.MethodOptions && r.Header.Get("Access-Control-Request-Method") != ""
+
+		if origin != "" {
+			c.writeHeaders(w.Header(), origin, preflight)
+		}
+
+		if preflight {
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+
+		h.ServeHTTP(w, r)
+	})
+}
+
+func (c *corsPolicy) writeHeaders(header http.Header, origin string, preflight bool) {
+	allowAny, allowed := c.allowed(origin)
+
+	// The allowed origin depends on the request unless every origin is allowed,
+	// so shared caches must key on it
+	if !allowAny {
+		addVary(header, "Origin")
+	}
+
+	if !allowed {
+		return
+	}
+
+	// Credentials are only allowed for origins which are listed, reflecting any
+	// origin with credentials would give every site access to them
+	if allowAny {
+		header.Set("Access-Control-Allow-Origin", "*")
+	} else {
+		header.Set("Access-Control-Allow-Origin", origin)
+
+		if c.allowCredentials {
+			header.Set("Access-Control-Allow-Credentials", "true")
+		}
+	}
+
+	if !preflight {
+		if c.exposedHeaders != "" {
+			header.Set("Access-Control-Expose-Headers", c.exposedHeaders)
+		}
+		return
+	}
+
+	addVary(header, "Access-Control-Request-Method, Access-Control-Request-Headers")
+
+	if c.allowedMethods != "" {
+		header.Set("Access-Control-Allow-Methods", c.allowedMethods)
+	}
+	if c.allowedHeaders != "" {
+		header.Set("Access-Control-Allow-Headers", c.allowedHeaders)
+	}
+	if c.maxAge != "" {
+		header.Set("Access-Control-Max-Age", c.maxAge)
+	}
+}
+
+// allowed returns whether any origin is allowed and whether the given origin is
+func (c *corsPolicy) allowed(origin string) (bool, bool) {
+	origin = strings.ToLower(origin)
+	allowedOrigins := c.settings.load().CORSAllowedOrigins
+
+	for _, pattern := range allowedOrigins {
+		if pattern == "*" {
+			return true, true
+		}
+	}
+
+	for _, pattern := range allowedOrigins {
+		if matchOrigin(pattern, origin) {
+			return false, true
+		}
+	}
+
+	return false, false
+}
+
+// matchOrigin compares an origin to a pattern containing at most one wildcard
+func matchOrigin(pattern, origin string) bool {
+	i := strings.Index(pattern, "*")
+	if i < 0 {
+		return pattern == origin
+	}
+
+	prefix, suffix := pattern[:i], pattern[i+1:]
+
+	return len(origin) > len(prefix)+len(suffix) &&
+		strings.HasPrefix(origin, prefix) &&
+		strings.HasSuffix(origin, suffix)
+}
+
+// Options is routed for OPTIONS requests to any path so that preflights are not
+// rejected before reaching the CORS handler, which answers them itself. Other
+// OPTIONS requests succeed only for paths served by another route
+func (api *SearchAPI) Options(w http.ResponseWriter, r *http.Request) {
+	if !api.routed(r) {
+		http.NotFound(w, r)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// routed returns true if a route other than the one for OPTIONS serves the
+// path of the request, with any method
+func (api *SearchAPI) routed(r *http.Request) bool {
+	found := false
+
+	api.Router.Walk(func(route *mux.Route, router *mux.Router, ancestors []*mux.Route) error {
+		// The route for OPTIONS is the only one without a path
+		if _, err := route.GetPathTemplate(); err != nil || found {
+			return nil
+		}
+
+		match := &mux.RouteMatch{}
+		if route.Match(r, match) || match.MatchErr == mux.ErrMethodMismatch {
+			found = true
+		}
+
+		return nil
+	})
+
+	return found
+}
+
+// trimAll returns the non empty values with surrounding whitespace removed
+func trimAll(values []string) []string {
+	var trimmed []string
+	for _, value := range values {
+		if value = strings.TrimSpace(value); value != "" {
+			trimmed = append(trimmed, value)
+		}
+	}
+
+	return trimmed
+}
@@ -0,0 +1,137 @@
+package api_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/ofs/alpha-search-api/api"
+	"github.com/ofs/alpha-search-api/config"
+)
+
+func corsHeaders(t *testing.T, origins []string, allowCredentials bool, origin string) http.Header {
+	t.Helper()
+
+	cfg := config.Default()
+	cfg.CORSAllowedOrigins = origins
+	cfg.CORSAllowCredentials = allowCredentials
+
+	router := mux.NewRouter()
+	api.Routes(*cfg, nil, nil, nil, nil, router)
+
+	req := httptest.NewRequest("GET", "/health", nil)
+	req.Header.Set("Origin", origin)
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	return w.Header()
+}
+
+func TestCORSCredentials(t *testing.T) {
+	header := corsHeaders(t, []string{"https://*.example.com"}, true, "https://app.example.com")
+	if header.Get("Access-Control-Allow-Origin") != "https://app.example.com" || header.Get("Access-Control-Allow-Credentials") != "true" {
+		t.Errorf("expected a listed origin to be allowed credentials, got %v", header)
+	}
+
+	header = corsHeaders(t, []string{"https://*.example.com"}, true, "https://evil.test")
+	if header.Get("Access-Control-Allow-Origin") != "" || header.Get("Access-Control-Allow-Credentials") != "" {
+		t.Errorf("expected an unlisted origin not to be allowed, got %v", header)
+	}
+
+	// Any origin is allowed, but never reflected with credentials
+	header = corsHeaders(t, []string{"*"}, true, "https://evil.test")
+	if header.Get("Access-Control-Allow-Origin") != "*" || header.Get("Access-Control-Allow-Credentials") != "" {
+		t.Errorf("expected credentials not to be allowed for any origin, got %v", header)
+	}
+}
+
+// corsRouter routes requests with CORS allowed for the origin given
+func corsRouter(origin string, configure func(*config.Configuration)) *mux.Router {
+	cfg := config.Default()
+	cfg.CORSAllowedOrigins = []string{origin}
+	if configure != nil {
+		configure(cfg)
+	}
+
+	router := mux.NewRouter()
+	api.Routes(*cfg, &fakeElasticsearch{}, nil, nil, nil, router)
+
+	return router
+}
+
+func TestCORSPreflight(t *testing.T) {
+	const origin = "https://app.example.com"
+	cfg := config.Default()
+	router := corsRouter(origin, nil)
+
+	paths := []string{"/health", "/ready", "/search/courses", "/search/institution-courses", "/metrics", "/openapi.yml", "/docs"}
+	for _, path := range paths {
+		req := httptest.NewRequest("OPTIONS", path, nil)
+		req.Header.Set("Origin", origin)
+		req.Header.Set("Access-Control-Request-Method", "GET")
+		req.Header.Set("Access-Control-Request-Headers", "X-Api-Key")
+
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		header := w.Header()
+		expected := map[string]string{
+			"Access-Control-Allow-Origin":  origin,
+			"Access-Control-Allow-Methods": strings.Join(cfg.CORSAllowedMethods, ", "),
+			"Access-Control-Allow-Headers": strings.Join(cfg.CORSAllowedHeaders, ", "),
+			"Access-Control-Max-Age":       "600",
+		}
+		for name, value := range expected {
+			if header.Get(name) != value {
+				t.Errorf("%s: expected %s %q, got %q", path, name, value, header.Get(name))
+			}
+		}
+		if w.Code != http.StatusNoContent || w.Body.Len() != 0 {
+			t.Errorf("%s: expected an empty 204, got %d", path, w.Code)
+		}
+		if vary := strings.Join(header.Values("Vary"), ", "); !strings.Contains(vary, "Origin") || !strings.Contains(vary, "Access-Control-Request-Method") {
+			t.Errorf("%s: expected to vary by origin and requested method, got %q", path, vary)
+		}
+	}
+}
+
+func TestCORSErrorResponses(t *testing.T) {
+	const origin = "https://app.example.com"
+	router := corsRouter(origin, func(cfg *config.Configuration) {
+		cfg.RateLimitIPRate = 0.001
+		cfg.RateLimitIPBurst = 1
+	})
+
+	cases := []struct {
+		method, target string
+		status         int
+	}{
+		{"GET", "/search/courses?limit=many", http.StatusBadRequest},
+		{"GET", "/search/courses", http.StatusTooManyRequests},
+		{"GET", "/unknown", http.StatusNotFound},
+		{"POST", "/health", http.StatusMethodNotAllowed},
+		{"POST", "/unknown", http.StatusNotFound},
+		{"OPTIONS", "/unknown", http.StatusNotFound},
+	}
+
+	for _, tc := range cases {
+		req := httptest.NewRequest(tc.method, tc.target, nil)
+		req.Header.Set("Origin", origin)
+
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		if w.Code != tc.status {
+			t.Errorf("%s %s: expected %d, got %d", tc.method, tc.target, tc.status, w.Code)
+		}
+		if allowed := w.Header().Get("Access-Control-Allow-Origin"); allowed != origin {
+			t.Errorf("%s %s: expected the origin to be allowed, got %q", tc.method, tc.target, allowed)
+		}
+		if exposed := w.Header().Get("Access-Control-Expose-Headers"); !strings.Contains(exposed, "Retry-After") {
+			t.Errorf("%s %s: expected headers to be exposed, got %q", tc.method, tc.target, exposed)
+		}
+	}
+}
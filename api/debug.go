@@ -0,0 +1,36 @@
+package api
+
+import (
+	"crypto/subtle"
+	"math/rand"
+	"net/http"
+
+	"github.com/ofs/alpha-search-api/logging"
+)
+
+// debugCaptureHeader is sent by trusted callers, with the configured token as
+// its value, to have the elasticsearch query and response logged for a request
+const debugCaptureHeader = "X-Debug-Capture"
+
+// debugCaptureHandler enables capturing of elasticsearch payloads for requests
+// carrying the debug capture token and for a random sample of all requests
+func debugCaptureHandler(token string, sampleRate float64) func(http.Handler) http.Handler {
+	return func(h http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if isTrustedDebugRequest(r, token) || (sampleRate > 0 && rand.Float64() < sampleRate) {
+				r = r.WithContext(logging.WithCapture(r.Context()))
+			}
+
+			h.ServeHTTP(w, r)
+		})
+	}
+}
+
+// isTrustedDebugRequest returns true if the request carries the debug capture token
+func isTrustedDebugRequest(r *http.Request, token string) bool {
+	if token == "" {
+		return false
+	}
+
+	return subtle.ConstantTimeCompare([]byte(r.Header.Get(debugCaptureHeader)), []byte(token)) == 1
+}
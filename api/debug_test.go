@@ -0,0 +1,68 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/ofs/alpha-search-api/logging"
+)
+
+func TestIsTrustedDebugRequest(t *testing.T) {
+	testCases := map[string]struct {
+		token    string
+		header   []string
+		expected bool
+	}{
+		"matching token":         {"secret-token", []string{"secret-token"}, true},
+		"wrong token":            {"secret-token", []string{"secret-tokem"}, false},
+		"prefix of the token":    {"secret-token", []string{"secret"}, false},
+		"token with suffix":      {"secret-token", []string{"secret-token2"}, false},
+		"different case":         {"secret-token", []string{"SECRET-TOKEN"}, false},
+		"no header":              {"secret-token", nil, false},
+		"empty header":           {"secret-token", []string{""}, false},
+		"no token configured":    {"", []string{""}, false},
+		"no token and no header": {"", nil, false},
+	}
+
+	for name, tc := range testCases {
+		r := httptest.NewRequest("GET", "/search/courses", nil)
+		for _, value := range tc.header {
+			r.Header.Add(debugCaptureHeader, value)
+		}
+
+		if trusted := isTrustedDebugRequest(r, tc.token); trusted != tc.expected {
+			t.Errorf("%s: expected %t, got %t", name, tc.expected, trusted)
+		}
+	}
+}
+
+func TestDebugCaptureHandler(t *testing.T) {
+	testCases := map[string]struct {
+		header     string
+		sampleRate float64
+		expected   bool
+	}{
+		"trusted":                    {"secret-token", 0, true},
+		"untrusted":                  {"wrong", 0, false},
+		"untrusted and sampled":      {"wrong", 1, true},
+		"not sampled without header": {"", 0, false},
+	}
+
+	for name, tc := range testCases {
+		var captured bool
+		h := debugCaptureHandler("secret-token", tc.sampleRate)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			captured = logging.CaptureEnabled(r.Context())
+		}))
+
+		r := httptest.NewRequest("GET", "/search/courses", nil)
+		if tc.header != "" {
+			r.Header.Set(debugCaptureHeader, tc.header)
+		}
+		h.ServeHTTP(httptest.NewRecorder(), r)
+
+		if captured != tc.expected {
+			t.Errorf("%s: expected capture %t, got %t", name, tc.expected, captured)
+		}
+	}
+}
@@ -11,3 +11,11 @@ type Elasticsearcher interface {
 	QueryCoursesSearch(ctx context.Context, index, term string, limit, offset int, listOfFilters map[string]string, listOfCountries, listOfLengthOfCourses, institutionList, subjects []string) (*models.SearchResponse, int, error)
 	QueryInstitutionCoursesSearch(ctx context.Context, index, term string, filters map[string]string, countries, lengthOfCourse, institutions, subjects []string) (*models.SearchResponse, int, error)
 }
+
+// ElasticHealthChecker - An interface used to check the state of elasticsearch
+type ElasticHealthChecker interface {
+	ClusterHealth(ctx context.Context) (*models.ClusterHealth, error)
+	IndexExists(ctx context.Context, index string) error
+	CheckMapping(ctx context.Context, index string) ([]string, error)
+	CircuitBreakerOpen() bool
+}
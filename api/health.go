@@ -0,0 +1,108 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	"github.com/methods/go-methods-lib/log"
+	errs "github.com/ofs/alpha-search-api/apierrors"
+	"github.com/ofs/alpha-search-api/health"
+	"github.com/ofs/alpha-search-api/models"
+	"github.com/pkg/errors"
+)
+
+// Health reports that the process is alive and able to serve requests
+func (api *SearchAPI) Health(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	defer drainBody(ctx, r)
+
+	writeHealth(ctx, w, http.StatusOK, &models.HealthResponse{Status: models.HealthStatusOK})
+}
+
+// Ready reports whether all dependencies of the service are available
+func (api *SearchAPI) Ready(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	defer drainBody(ctx, r)
+
+	ready, checks := api.HealthCheck.Ready()
+
+	response := &models.HealthResponse{
+		Status: models.HealthStatusOK,
+		Checks: checks,
+	}
+
+	status := http.StatusOK
+	if !ready {
+		log.InfoCtx(ctx, "Ready handler: service is not ready to receive traffic", log.Data{"checks": checks})
+		response.Status = models.HealthStatusFail
+		status = http.StatusServiceUnavailable
+	}
+
+	writeHealth(ctx, w, status, response)
+}
+
+func writeHealth(ctx context.Context, w http.ResponseWriter, status int, response *models.HealthResponse) {
+	b, err := json.Marshal(response)
+	if err != nil {
+		log.ErrorCtx(ctx, errors.WithMessage(err, "failed to marshal health response into bytes"), nil)
+		http.Error(w, errs.ErrInternalServer.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(status)
+
+	if _, err := w.Write(b); err != nil {
+		log.ErrorCtx(ctx, errors.WithMessage(err, "failed to write health response body"), nil)
+	}
+}
+
+// addElasticsearchChecks registers readiness checks against the elasticsearch cluster.
+// Unless the mapping check is off, the index is checked to hold the fields searches
+// refer to, which only makes the service unready when enforced
+func addElasticsearchChecks(h *health.Health, checker ElasticHealthChecker, index, mappingCheck string) {
+	h.AddCheck("elasticsearch_circuit_breaker", func(ctx context.Context) error {
+		if checker.CircuitBreakerOpen() {
+			return errs.ErrCircuitBreakerOpen
+		}
+		return nil
+	})
+
+	h.AddCheck("elasticsearch_cluster", func(ctx context.Context) error {
+		clusterHealth, err := checker.ClusterHealth(ctx)
+		if err != nil {
+			return err
+		}
+
+		if clusterHealth.Status == "red" {
+			return errs.ErrUnhealthyCluster
+		}
+		return nil
+	})
+
+	h.AddCheck("elasticsearch_index", func(ctx context.Context) error {
+		return checker.IndexExists(ctx, index)
+	})
+
+	checkMapping := func(ctx context.Context) error {
+		problems, err := checker.CheckMapping(ctx, index)
+		if err != nil {
+			return err
+		}
+
+		if len(problems) > 0 {
+			return errors.Errorf("%s: %s", errs.ErrMappingMismatch, strings.Join(problems, "; "))
+		}
+		return nil
+	}
+
+	switch mappingCheck {
+	case ValidationLog:
+		h.AddWarningCheck("elasticsearch_mapping", checkMapping)
+	case ValidationEnforce:
+		h.AddCheck("elasticsearch_mapping", checkMapping)
+	}
+}
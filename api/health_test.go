@@ -0,0 +1,173 @@
+package api_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/ofs/alpha-search-api/api"
+	"github.com/ofs/alpha-search-api/config"
+	"github.com/ofs/alpha-search-api/lifecycle"
+	"github.com/ofs/alpha-search-api/models"
+)
+
+// fakeChecker reports a healthy cluster, holding an index whose mapping has the problems given
+type fakeChecker struct {
+	problems []string
+}
+
+func (f *fakeChecker) ClusterHealth(ctx context.Context) (*models.ClusterHealth, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	return &models.ClusterHealth{Status: "green"}, nil
+}
+
+func (f *fakeChecker) IndexExists(ctx context.Context, index string) error {
+	return nil
+}
+
+func (f *fakeChecker) CheckMapping(ctx context.Context, index string) ([]string, error) {
+	return f.problems, nil
+}
+
+func (f *fakeChecker) CircuitBreakerOpen() bool {
+	return false
+}
+
+func ready(t *testing.T, checker api.ElasticHealthChecker, mappingCheck string) (int, map[string]models.HealthCheck) {
+	t.Helper()
+
+	cfg := config.Default()
+	cfg.ElasticSearchConfig.MappingCheck = mappingCheck
+
+	router := mux.NewRouter()
+	api.Routes(*cfg, nil, checker, nil, nil, router)
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("GET", "/ready", nil))
+
+	response := &models.HealthResponse{}
+	if err := json.Unmarshal(w.Body.Bytes(), response); err != nil {
+		t.Fatalf("failed to decode readiness response: %v", err)
+	}
+
+	checks := make(map[string]models.HealthCheck)
+	for _, check := range response.Checks {
+		checks[check.Name] = check
+	}
+
+	return w.Code, checks
+}
+
+func TestReadyMappingCheck(t *testing.T) {
+	broken := &fakeChecker{problems: []string{"courses-1: doc.country_code.keyword is not mapped, expected keyword"}}
+
+	status, checks := ready(t, broken, api.ValidationOff)
+	if _, ok := checks["elasticsearch_mapping"]; ok || status != http.StatusOK {
+		t.Errorf("expected the mapping not to be checked when off, got %d and %v", status, checks)
+	}
+
+	status, checks = ready(t, &fakeChecker{}, api.ValidationEnforce)
+	if check := checks["elasticsearch_mapping"]; check.Status != models.HealthStatusOK || status != http.StatusOK {
+		t.Errorf("expected a complete mapping to be ready, got %d and %+v", status, check)
+	}
+
+	// Problems are reported without making the service unready unless enforced
+	status, checks = ready(t, broken, api.ValidationLog)
+	if check := checks["elasticsearch_mapping"]; check.Status != models.HealthStatusWarn || status != http.StatusOK {
+		t.Errorf("expected a warning, got %d and %+v", status, check)
+	}
+
+	status, checks = ready(t, broken, api.ValidationEnforce)
+	check := checks["elasticsearch_mapping"]
+	if check.Status != models.HealthStatusFail || status != http.StatusServiceUnavailable {
+		t.Errorf("expected the service to be unready, got %d and %+v", status, check)
+	}
+	if !strings.Contains(check.Message, "doc.country_code.keyword is not mapped") {
+		t.Errorf("expected the problems in the message, got %q", check.Message)
+	}
+}
+
+func TestReadyProbeCanceled(t *testing.T) {
+	router := mux.NewRouter()
+	api.Routes(*config.Default(), nil, &fakeChecker{}, nil, nil, router)
+
+	// A probe which gives up does not fail the checks, whose results are cached for other probes
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/ready", nil).WithContext(ctx))
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("GET", "/ready", nil))
+	if w.Code != http.StatusOK {
+		t.Errorf("expected the service to be ready, got %d: %s", w.Code, w.Body)
+	}
+}
+
+func TestReadyWhileDraining(t *testing.T) {
+	router := mux.NewRouter()
+	searchAPI := api.Routes(*config.Default(), nil, &fakeChecker{}, nil, nil, router)
+
+	searchAPI.HealthCheck.Drain()
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("GET", "/ready", nil))
+	if w.Code != http.StatusServiceUnavailable || !strings.Contains(w.Body.String(), `"name":"shutdown"`) {
+		t.Errorf("expected the service to be unready while shutting down, got %d: %s", w.Code, w.Body.String())
+	}
+
+	// The service is still live
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("GET", "/health", nil))
+	if w.Code != http.StatusOK {
+		t.Errorf("expected the service to be live while shutting down, got %d", w.Code)
+	}
+}
+
+func TestReadyDuringShutdownDelay(t *testing.T) {
+	router := mux.NewRouter()
+	searchAPI := api.Routes(*config.Default(), nil, &fakeChecker{}, nil, nil, router)
+
+	ready := func() int {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest("GET", "/ready", nil))
+		return w.Code
+	}
+
+	if status := ready(); status != http.StatusOK {
+		t.Fatalf("expected the service to be ready, got %d", status)
+	}
+
+	// Probes made between draining and the server closing see the service is unready
+	delay := 50 * time.Millisecond
+	probed := make(chan int, 1)
+	var drained time.Time
+
+	shutdown := lifecycle.New(time.Second)
+	shutdown.Add("readiness", func(ctx context.Context) error {
+		searchAPI.HealthCheck.Drain()
+		drained = time.Now()
+		go func() { probed <- ready() }()
+		return nil
+	})
+	shutdown.Add("readiness delay", lifecycle.Wait(delay))
+	shutdown.Add("http server", func(ctx context.Context) error {
+		if waited := time.Since(drained); waited < delay {
+			t.Errorf("expected the server to close after the readiness delay, closed after %v", waited)
+		}
+		return nil
+	})
+
+	if err := shutdown.Run(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if status := <-probed; status != http.StatusServiceUnavailable {
+		t.Errorf("expected a probe during the delay to fail, got %d", status)
+	}
+}
@@ -0,0 +1,84 @@
+package api
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"strings"
+)
+
+// IndexVersioner - An interface used to find the version of the index being searched,
+// which changes whenever the data is reloaded
+type IndexVersioner interface {
+	Version() string
+}
+
+// writeCacheableBody writes a response which can be cached by clients and CDNs. A
+// strong ETag is computed over the index version and body, so cached responses are
+// invalidated when the index is replaced, and a 304 is returned if it matches If-None-Match
+func (api *SearchAPI) writeCacheableBody(ctx context.Context, w http.ResponseWriter, r *http.Request, b []byte) {
+	etag := api.etag(b)
+
+	w.Header().Set("ETag", etag)
+	if api.CacheControl != "" {
+		w.Header().Set("Cache-Control", api.CacheControl)
+	}
+	if api.Vary != "" {
+		addVary(w.Header(), api.Vary)
+	}
+
+	if etagMatches(r.Header.Get("If-None-Match"), etag) {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+
+	writeBody(ctx, w, b)
+}
+
+func (api *SearchAPI) etag(b []byte) string {
+	hash := sha256.New()
+
+	if api.IndexVersion != nil {
+		hash.Write([]byte(api.IndexVersion.Version()))
+	}
+	hash.Write([]byte{0})
+	hash.Write(b)
+
+	return `"` + hex.EncodeToString(hash.Sum(nil)[:16]) + `"`
+}
+
+// etagMatches uses the weak comparison required for If-None-Match to check whether
+// the client already holds the current representation
+func etagMatches(ifNoneMatch, etag string) bool {
+	if ifNoneMatch == "" {
+		return false
+	}
+
+	for _, candidate := range strings.Split(ifNoneMatch, ",") {
+		candidate = strings.TrimSpace(candidate)
+		if candidate == "*" || strings.TrimPrefix(candidate, "W/") == etag {
+			return true
+		}
+	}
+
+	return false
+}
+
+// addVary adds the field names to the Vary header unless they are already listed
+func addVary(header http.Header, fields string) {
+	existing := make(map[string]bool)
+	for _, value := range header["Vary"] {
+		for _, field := range strings.Split(value, ",") {
+			existing[strings.ToLower(strings.TrimSpace(field))] = true
+		}
+	}
+
+	for _, field := range strings.Split(fields, ",") {
+		field = strings.TrimSpace(field)
+		if field != "" && !existing[strings.ToLower(field)] {
+			header.Add("Vary", field)
+			existing[strings.ToLower(field)] = true
+		}
+	}
+}
@@ -0,0 +1,153 @@
+package api
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+type fixedVersion string
+
+func (v fixedVersion) Version() string {
+	return string(v)
+}
+
+var strongETag = regexp.MustCompile(`^"[0-9a-f]{32}"$`)
+
+// cacheable writes the body through writeCacheableBody with the If-None-Match header given
+func cacheable(api *SearchAPI, ifNoneMatch string, body []byte) *httptest.ResponseRecorder {
+	req := httptest.NewRequest("GET", "/search/courses", nil)
+	if ifNoneMatch != "" {
+		req.Header.Set("If-None-Match", ifNoneMatch)
+	}
+
+	w := httptest.NewRecorder()
+	api.writeCacheableBody(context.Background(), w, req, body)
+
+	return w
+}
+
+func TestETag(t *testing.T) {
+	api := &SearchAPI{IndexVersion: fixedVersion("courses-1")}
+	body := []byte(`{"number_of_items":1}`)
+
+	etag := api.etag(body)
+	if !strongETag.MatchString(etag) {
+		t.Errorf("expected a strong quoted ETag, got %s", etag)
+	}
+
+	if again := api.etag(body); again != etag {
+		t.Errorf("expected the same ETag for the same body, got %s and %s", etag, again)
+	}
+
+	if other := api.etag([]byte(`{"number_of_items":2}`)); other == etag {
+		t.Error("expected the ETag to change with the body")
+	}
+
+	reloaded := &SearchAPI{IndexVersion: fixedVersion("courses-2")}
+	if other := reloaded.etag(body); other == etag {
+		t.Error("expected the ETag to change with the index version")
+	}
+
+	if unversioned := (&SearchAPI{}).etag(body); !strongETag.MatchString(unversioned) || unversioned == etag {
+		t.Errorf("expected a different strong ETag without an index version, got %s", unversioned)
+	}
+}
+
+func TestETagMatches(t *testing.T) {
+	etag := `"abc123"`
+
+	testCases := map[string]bool{
+		"":                    false,
+		`"abc123"`:            true,
+		`W/"abc123"`:          true,
+		`"other"`:             false,
+		`"other", "abc123"`:   true,
+		`"other",W/"abc123" `: true,
+		`"other", "another"`:  false,
+		"*":                   true,
+		`"other", *`:          true,
+		"abc123":              false,
+		`"abc12"`:             false,
+		`"ABC123"`:            false,
+	}
+
+	for ifNoneMatch, expected := range testCases {
+		if matches := etagMatches(ifNoneMatch, etag); matches != expected {
+			t.Errorf("%q: expected match %t, got %t", ifNoneMatch, expected, matches)
+		}
+	}
+}
+
+func TestWriteCacheableBody(t *testing.T) {
+	api := &SearchAPI{CacheControl: "public, max-age=60", Vary: "Accept-Encoding, X-Api-Key", IndexVersion: fixedVersion("courses-1")}
+	body := []byte(`{"number_of_items":1}`)
+
+	w := cacheable(api, "", body)
+	if w.Code != http.StatusOK || w.Body.String() != string(body) {
+		t.Fatalf("expected the body with status 200, got %d: %s", w.Code, w.Body.String())
+	}
+
+	etag := w.Header().Get("ETag")
+	if !strongETag.MatchString(etag) {
+		t.Errorf("expected a strong ETag, got %q", etag)
+	}
+	if cacheControl := w.Header().Get("Cache-Control"); cacheControl != "public, max-age=60" {
+		t.Errorf("unexpected Cache-Control %q", cacheControl)
+	}
+	if vary := strings.Join(w.Header()["Vary"], ","); vary != "Accept-Encoding,X-Api-Key" {
+		t.Errorf("unexpected Vary %q", vary)
+	}
+
+	for _, ifNoneMatch := range []string{etag, `"stale", ` + etag, "W/" + etag, "*"} {
+		w := cacheable(api, ifNoneMatch, body)
+
+		if w.Code != http.StatusNotModified {
+			t.Errorf("%q: expected 304, got %d", ifNoneMatch, w.Code)
+		}
+		if w.Body.Len() != 0 {
+			t.Errorf("%q: expected no body, got %q", ifNoneMatch, w.Body.String())
+		}
+		if w.Header().Get("Content-Type") != "" {
+			t.Errorf("%q: expected no Content-Type, got %q", ifNoneMatch, w.Header().Get("Content-Type"))
+		}
+		if w.Header().Get("ETag") != etag || w.Header().Get("Cache-Control") == "" {
+			t.Errorf("%q: expected the caching headers to be repeated, got %v", ifNoneMatch, w.Header())
+		}
+	}
+
+	// Once the index is reloaded the ETag held by the client no longer matches
+	api.IndexVersion = fixedVersion("courses-2")
+
+	w = cacheable(api, etag, body)
+	if w.Code != http.StatusOK || w.Body.String() != string(body) {
+		t.Errorf("expected the body after the index version changed, got %d: %s", w.Code, w.Body.String())
+	}
+	if w.Header().Get("ETag") == etag {
+		t.Error("expected a new ETag after the index version changed")
+	}
+}
+
+func TestWriteCacheableBodyWithoutCacheHeaders(t *testing.T) {
+	w := cacheable(&SearchAPI{}, "", []byte(`{}`))
+
+	if w.Header().Get("Cache-Control") != "" || len(w.Header()["Vary"]) != 0 {
+		t.Errorf("expected no Cache-Control or Vary when they are not configured, got %v", w.Header())
+	}
+	if w.Header().Get("ETag") == "" {
+		t.Error("expected an ETag")
+	}
+}
+
+func TestAddVary(t *testing.T) {
+	header := http.Header{"Vary": {"accept-encoding, Origin"}}
+
+	addVary(header, "Accept-Encoding, X-Api-Key,, x-api-key")
+
+	if vary := strings.Join(header["Vary"], "|"); vary != "accept-encoding, Origin|X-Api-Key" {
+		t.Errorf("expected only new fields to be added, got %q", vary)
+	}
+}
@@ -0,0 +1,105 @@
+package api
+
+import (
+	"bufio"
+	"net"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/ofs/alpha-search-api/metrics"
+	"github.com/pkg/errors"
+)
+
+var (
+	requestsTotal = metrics.NewCounterVec(
+		"search_api_http_requests_total",
+		"The number of http requests handled, partitioned by route, method and status code.",
+		"route", "method", "status",
+	)
+
+	requestDuration = metrics.NewHistogramVec(
+		"search_api_http_request_duration_seconds",
+		"The time taken to handle http requests, partitioned by route, method and status code.",
+		metrics.DefaultBuckets,
+		"route", "method", "status",
+	)
+
+	zeroResultsTotal = metrics.NewCounterVec(
+		"search_api_search_zero_results_total",
+		"The number of searches which returned no results, partitioned by route.",
+		"route",
+	)
+
+	resultSize = metrics.NewHistogramVec(
+		"search_api_search_result_size",
+		"The total number of courses matching each search, partitioned by route.",
+		[]float64{0, 1, 5, 10, 20, 50, 100, 250, 500, 1000, 3500},
+		"route",
+	)
+)
+
+// statusRecorder captures the status code written to the response
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+}
+
+func (r *statusRecorder) WriteHeader(status int) {
+	r.status = status
+	r.ResponseWriter.WriteHeader(status)
+}
+
+// Flush sends buffered data to the client, so that streaming handlers and the
+// compressor can flush through the recorder
+func (r *statusRecorder) Flush() {
+	if f, ok := r.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// Hijack allows the underlying connection to be taken over
+func (r *statusRecorder) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	if h, ok := r.ResponseWriter.(http.Hijacker); ok {
+		return h.Hijack()
+	}
+	return nil, nil, errors.New("metrics: response does not implement http.Hijacker")
+}
+
+// metricsHandler records the count and duration of every request to a route
+func metricsHandler(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		rec := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+
+		h.ServeHTTP(rec, r)
+
+		route := routeName(r)
+		status := strconv.Itoa(rec.status)
+
+		requestsTotal.Inc(route, r.Method, status)
+		requestDuration.Observe(time.Since(start).Seconds(), route, r.Method, status)
+	})
+}
+
+// observeResults records the total number of courses matching a search
+func observeResults(r *http.Request, numberOfCourses int) {
+	route := routeName(r)
+
+	if numberOfCourses == 0 {
+		zeroResultsTotal.Inc(route)
+	}
+	resultSize.Observe(float64(numberOfCourses), route)
+}
+
+// routeName returns the path template of the matched route to keep metric cardinality low
+func routeName(r *http.Request) string {
+	if route := mux.CurrentRoute(r); route != nil {
+		if template, err := route.GetPathTemplate(); err == nil {
+			return template
+		}
+	}
+
+	return "unknown"
+}
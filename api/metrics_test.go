@@ -0,0 +1,41 @@
+package api_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/ofs/alpha-search-api/api"
+	"github.com/ofs/alpha-search-api/config"
+)
+
+func TestMetricsScrape(t *testing.T) {
+	router := mux.NewRouter()
+	api.Routes(*config.Default(), &fakeElasticsearch{}, nil, nil, nil, router)
+
+	for _, target := range []string{"/search/courses?q=maths", "/search/courses?limit=many"} {
+		router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", target, nil))
+	}
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("GET", "/metrics", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected the metrics, got %d", w.Code)
+	}
+
+	exposed := w.Body.String()
+	for _, line := range []string{
+		"# TYPE search_api_http_requests_total counter",
+		`search_api_http_requests_total{route="/search/courses",method="GET",status="200"} `,
+		`search_api_http_requests_total{route="/search/courses",method="GET",status="400"} `,
+		`search_api_http_request_duration_seconds_bucket{route="/search/courses",method="GET",status="200",le="+Inf"} `,
+		`search_api_http_request_duration_seconds_count{route="/search/courses",method="GET",status="200"} `,
+		`search_api_search_result_size_count{route="/search/courses"} `,
+	} {
+		if !strings.Contains(exposed, line) {
+			t.Errorf("expected %q in the metrics", line)
+		}
+	}
+}
@@ -0,0 +1,143 @@
+package api
+
+import (
+	"bytes"
+	"net/http"
+
+	"github.com/methods/go-methods-lib/log"
+	errs "github.com/ofs/alpha-search-api/apierrors"
+	"github.com/ofs/alpha-search-api/config"
+	"github.com/ofs/alpha-search-api/models"
+	"github.com/ofs/alpha-search-api/openapi"
+	"github.com/pkg/errors"
+)
+
+// A list of modes for validating requests and responses against the specification
+const (
+	ValidationOff     = config.ModeOff
+	ValidationLog     = config.ModeLog
+	ValidationEnforce = config.ModeEnforce
+)
+
+// OpenAPI returns the specification of the api
+func (api *SearchAPI) OpenAPI(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/yaml")
+	w.Write(openapi.Spec)
+}
+
+// Docs returns a page documenting the api, rendered from the specification
+func (api *SearchAPI) Docs(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Write(openapi.Docs)
+}
+
+// specValidator checks requests and responses match the specification so that
+// drift between the two is caught, logging any violations and, when enforcing,
+// rejecting invalid requests and replacing invalid responses with an error
+type specValidator struct {
+	doc     *openapi.Document
+	enforce bool
+}
+
+// newSpecValidator returns nil if validation is off or the mode is not recognised
+func newSpecValidator(mode string) *specValidator {
+	switch mode {
+	case "", ValidationOff:
+		return nil
+	case ValidationLog, ValidationEnforce:
+	default:
+		log.Error(errors.Errorf("unknown openapi validation mode [%s], validation is off", mode), nil)
+		return nil
+	}
+
+	doc, err := openapi.Load()
+	if err != nil {
+		log.Error(errors.WithMessage(err, "unable to load openapi specification, validation is off"), nil)
+		return nil
+	}
+
+	return &specValidator{doc: doc, enforce: mode == ValidationEnforce}
+}
+
+// handler validates the request and response of documented operations
+func (v *specValidator) handler(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		route := routeName(r)
+		logData := log.Data{"route": route, "method": r.Method}
+
+		if r.Method == http.MethodOptions {
+			h.ServeHTTP(w, r)
+			return
+		}
+
+		op, ok := v.doc.Operation(route, r.Method)
+		if !ok {
+			log.ErrorCtx(ctx, errors.New("openapi validation: operation is not documented"), logData)
+			h.ServeHTTP(w, r)
+			return
+		}
+
+		if violations := v.doc.ValidateRequest(op, r); len(violations) > 0 {
+			logData["violations"] = violations
+			log.ErrorCtx(ctx, errors.New("openapi validation: request does not match specification"), logData)
+
+			if v.enforce {
+				ErrorResponse(ctx, w, http.StatusBadRequest, violationsResponse(errs.ErrRequestViolatesSpec, violations))
+				return
+			}
+		}
+
+		recorder := &bufferedResponse{ResponseWriter: w, status: http.StatusOK}
+		h.ServeHTTP(recorder, r)
+
+		if violations := v.doc.ValidateResponse(op, recorder.status, w.Header().Get("Content-Type"), recorder.body.Bytes()); len(violations) > 0 {
+			logData["status"] = recorder.status
+			logData["violations"] = violations
+			log.ErrorCtx(ctx, errors.New("openapi validation: response does not match specification"), logData)
+
+			if v.enforce {
+				for _, header := range []string{"Cache-Control", "Content-Length", "ETag"} {
+					w.Header().Del(header)
+				}
+				ErrorResponse(ctx, w, http.StatusInternalServerError, violationsResponse(errs.ErrResponseViolatesSpec, violations))
+				return
+			}
+		}
+
+		w.WriteHeader(recorder.status)
+		w.Write(recorder.body.Bytes())
+	})
+}
+
+func violationsResponse(err error, violations []string) *models.ErrorResponse {
+	response := &models.ErrorResponse{}
+	for _, violation := range violations {
+		response.Errors = append(response.Errors, &models.ErrorObject{
+			Error:       err.Error(),
+			ErrorValues: map[string]string{"violation": violation},
+		})
+	}
+
+	return response
+}
+
+// bufferedResponse holds the status and body of a response so they can be
+// checked before being sent
+type bufferedResponse struct {
+	http.ResponseWriter
+	status      int
+	wroteHeader bool
+	body        bytes.Buffer
+}
+
+func (b *bufferedResponse) WriteHeader(status int) {
+	if !b.wroteHeader {
+		b.status = status
+		b.wroteHeader = true
+	}
+}
+
+func (b *bufferedResponse) Write(p []byte) (int, error) {
+	return b.body.Write(p)
+}
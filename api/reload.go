@@ -0,0 +1,120 @@
+package api
+
+import (
+	"sync"
+
+	"github.com/methods/go-methods-lib/log"
+	"github.com/ofs/alpha-search-api/auth"
+	"github.com/ofs/alpha-search-api/cache"
+	"github.com/ofs/alpha-search-api/config"
+	"github.com/ofs/alpha-search-api/logging"
+	"github.com/ofs/alpha-search-api/metrics"
+	"github.com/pkg/errors"
+)
+
+// A list of reload results recorded in metrics
+const (
+	reloadSuccess = "success"
+	reloadFailure = "failure"
+)
+
+var reloadsTotal = metrics.NewCounterVec(
+	"search_api_config_reloads_total",
+	"The number of times the configuration has been reloaded while running, partitioned by result (success or failure).",
+	"result",
+)
+
+// Reloader reads the configuration again while the api is running, applying the
+// settings which can be changed without a restart. Nothing is applied unless the
+// whole configuration, including any API keys file, is valid
+type Reloader struct {
+	mu      sync.Mutex
+	file    string
+	started *config.Configuration
+	current *config.Configuration
+	api     *SearchAPI
+	cache   *cache.Searcher
+}
+
+// NewReloader creates a reloader for the api started with the configuration,
+// read from the file given (if any) and environment variables. The search cache
+// is optional
+func NewReloader(file string, cfg *config.Configuration, api *SearchAPI, searchCache *cache.Searcher) *Reloader {
+	return &Reloader{
+		file:    file,
+		started: cfg,
+		current: cfg,
+		api:     api,
+		cache:   searchCache,
+	}
+}
+
+// Reload reads the configuration and API keys file, applying the settings which
+// can be reloaded. Changes to other settings are logged as needing a restart
+func (r *Reloader) Reload() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	logData := log.Data{"config_file": r.file}
+
+	cfg, keys, err := r.load()
+	if err != nil {
+		reloadsTotal.Inc(reloadFailure)
+		log.ErrorC("failed to reload configuration, the current settings are kept", err, logData)
+		return err
+	}
+
+	var applied, restart []string
+	for _, name := range r.current.Changed(cfg) {
+		if config.IsReloadable(name) {
+			applied = append(applied, name)
+		}
+	}
+	for _, name := range r.started.Changed(cfg) {
+		if !config.IsReloadable(name) {
+			restart = append(restart, name)
+		}
+	}
+
+	// Validation has already checked the options, so logging cannot fail to be configured
+	logging.Configure(logging.Options{
+		Level:          cfg.LogLevel,
+		MaxPayloadSize: cfg.LogMaxPayloadSize,
+		RedactFields:   cfg.LogRedactFields,
+	})
+
+	if r.cache != nil {
+		r.cache.SetTTL(cfg.CacheTTL)
+	}
+
+	r.api.UpdateSettings(NewSettings(*cfg, keys))
+	r.current = cfg
+
+	reloadsTotal.Inc(reloadSuccess)
+
+	logData["changed"] = applied
+	logData["keys"] = keys.Len()
+	log.Info("configuration reloaded", logData)
+
+	if len(restart) > 0 {
+		log.Info("configuration has changed settings which are only applied on restart", log.Data{"config_file": r.file, "restart_required": restart})
+	}
+
+	return nil
+}
+
+func (r *Reloader) load() (*config.Configuration, *auth.Keys, error) {
+	cfg, err := config.Load(r.file)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var keys *auth.Keys
+	if cfg.APIKeysFile != "" {
+		if keys, err = auth.LoadKeys(cfg.APIKeysFile); err != nil {
+			return nil, nil, errors.WithMessage(err, "failed to reload api keys")
+		}
+	}
+
+	return cfg, keys, nil
+}
@@ -0,0 +1,106 @@
+package api_test
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/ofs/alpha-search-api/api"
+	"github.com/ofs/alpha-search-api/config"
+)
+
+func writeConfig(t *testing.T, path, content string) {
+	t.Helper()
+
+	if err := ioutil.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatalf("failed to write file: %v", err)
+	}
+}
+
+func TestReload(t *testing.T) {
+	dir := t.TempDir()
+	configFile := filepath.Join(dir, "config.yml")
+	keysFile := filepath.Join(dir, "keys.json")
+
+	writeConfig(t, configFile, "default_max_results: 100\n")
+
+	cfg, err := config.Load(configFile)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	router := mux.NewRouter()
+	searchAPI := api.Routes(*cfg, &fakeElasticsearch{}, nil, nil, nil, router)
+	reloader := api.NewReloader(configFile, cfg, searchAPI, nil)
+
+	started := searchAPI.Settings()
+
+	preflight := func() string {
+		r := httptest.NewRequest("OPTIONS", "/search/courses", nil)
+		r.Header.Set("Origin", "https://example.com")
+		r.Header.Set("Access-Control-Request-Method", "GET")
+
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, r)
+
+		return w.Header().Get("Access-Control-Allow-Origin")
+	}
+
+	if origin := preflight(); origin != "*" {
+		t.Fatalf("expected any origin to be allowed on startup, got %q", origin)
+	}
+
+	writeConfig(t, keysFile, `{"keys": [{"client": "partner", "key": "secret", "scopes": ["search"]}]}`)
+	writeConfig(t, configFile, `
+default_max_results: 50
+cors_allowed_origins: ["https://other.example.com"]
+api_key_required: true
+api_keys_file: `+keysFile+`
+rate_limit_ip_rate: 5
+bind_addr: ":8080"
+`)
+
+	if err = reloader.Reload(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	settings := searchAPI.Settings()
+	if settings.DefaultMaxResults != 50 || !settings.KeyRequired || settings.IPLimit.Rate != 5 {
+		t.Errorf("expected the settings to be reloaded, got %+v", settings)
+	}
+	if !reflect.DeepEqual(settings.CORSAllowedOrigins, []string{"https://other.example.com"}) {
+		t.Errorf("expected the allowed origins to be reloaded, got %v", settings.CORSAllowedOrigins)
+	}
+	if _, ok := settings.Keys.Lookup("secret"); !ok {
+		t.Error("expected the api keys to be reloaded")
+	}
+	if started.DefaultMaxResults != 100 {
+		t.Error("expected the settings requests started with to be unchanged")
+	}
+
+	if origin := preflight(); origin != "" {
+		t.Errorf("expected the origin to no longer be allowed, got %q", origin)
+	}
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("GET", "/search/courses?q=maths", nil))
+	if w.Code != http.StatusUnauthorized {
+		t.Errorf("expected an api key to be required, got %d", w.Code)
+	}
+
+	// Nothing is applied from an invalid configuration or keys file
+	for _, invalid := range []string{"default_max_results: 0\n", "api_keys_file: " + filepath.Join(dir, "missing.json") + "\n"} {
+		writeConfig(t, configFile, invalid)
+
+		if err = reloader.Reload(); err == nil {
+			t.Errorf("expected an error reloading %q", invalid)
+		}
+		if searchAPI.Settings() != settings {
+			t.Errorf("expected the settings to be kept after reloading %q", invalid)
+		}
+	}
+}
@@ -0,0 +1,33 @@
+package api
+
+import (
+	"net/http"
+	"regexp"
+
+	"github.com/methods/go-methods-lib/common"
+)
+
+// requestIDSize is the length of generated request ids
+const requestIDSize = 16
+
+// validRequestID limits the ids accepted from callers so they cannot be used to
+// inject arbitrary content into logs or headers sent to elasticsearch
+var validRequestID = regexp.MustCompile(`^[A-Za-z0-9._:-]{1,64}$`)
+
+// requestIDHandler accepts a valid X-Request-Id from the caller or generates a
+// new one, setting it on the request context (used to correlate log events) and
+// echoing it on the response
+func requestIDHandler(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requestID := r.Header.Get(common.RequestHeaderKey)
+
+		if !validRequestID.MatchString(requestID) {
+			requestID = common.NewRequestID(requestIDSize)
+			r.Header.Set(common.RequestHeaderKey, requestID)
+		}
+
+		w.Header().Set(common.RequestHeaderKey, requestID)
+
+		h.ServeHTTP(w, r.WithContext(common.WithRequestId(r.Context(), requestID)))
+	})
+}
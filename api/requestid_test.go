@@ -0,0 +1,99 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/ofs/alpha-search-api/config"
+	"github.com/ofs/alpha-search-api/elasticsearch"
+	"github.com/ofs/alpha-search-api/models"
+)
+
+// opaqueIDRecorder is an elasticsearch stub failing every request, recording the
+// X-Opaque-Id header of the last one
+type opaqueIDRecorder struct {
+	mutex    sync.Mutex
+	opaqueID string
+}
+
+func (o *opaqueIDRecorder) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	o.mutex.Lock()
+	o.opaqueID = r.Header.Get("X-Opaque-Id")
+	o.mutex.Unlock()
+
+	w.WriteHeader(http.StatusInternalServerError)
+}
+
+func (o *opaqueIDRecorder) last() string {
+	o.mutex.Lock()
+	defer o.mutex.Unlock()
+
+	return o.opaqueID
+}
+
+func TestRequestID(t *testing.T) {
+	recorder := &opaqueIDRecorder{}
+	stub := httptest.NewServer(recorder)
+	defer stub.Close()
+
+	es := elasticsearch.NewElasticSearchAPI(*stub.Client(), stub.URL, nil, elasticsearch.NewCircuitBreaker(100, time.Minute), time.Second)
+
+	router := mux.NewRouter()
+	Routes(*config.Default(), es, nil, nil, nil, router)
+	handler := requestIDHandler(router)
+
+	testCases := map[string]struct {
+		requestID string
+		kept      bool
+	}{
+		"alphanumeric":        {"abc123XYZ", true},
+		"allowed punctuation": {"req-1.2_3:4", true},
+		"longest allowed":     {strings.Repeat("a", 64), true},
+		"missing":             {"", false},
+		"too long":            {strings.Repeat("a", 65), false},
+		"space":               {"abc 123", false},
+		"slash":               {"abc/123", false},
+		"markup":              {"<script>", false},
+		"non ascii":           {"réquest", false},
+	}
+
+	for name, tc := range testCases {
+		req := httptest.NewRequest("GET", "/search/courses?q=maths", nil)
+		if tc.requestID != "" {
+			req.Header.Set("X-Request-Id", tc.requestID)
+		}
+		w := httptest.NewRecorder()
+
+		handler.ServeHTTP(w, req)
+
+		echoed := w.Header().Get("X-Request-Id")
+		if tc.kept && echoed != tc.requestID {
+			t.Errorf("%s: expected %q to be kept, got %q", name, tc.requestID, echoed)
+		}
+		if !tc.kept && (echoed == tc.requestID || len(echoed) != requestIDSize || !validRequestID.MatchString(echoed)) {
+			t.Errorf("%s: expected %q to be replaced with a generated id, got %q", name, tc.requestID, echoed)
+		}
+
+		if w.Code != http.StatusInternalServerError {
+			t.Fatalf("%s: expected the elasticsearch error to be returned, got %d: %s", name, w.Code, w.Body.String())
+		}
+
+		var body models.ErrorResponse
+		if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil {
+			t.Fatalf("%s: failed to decode error response: %v", name, err)
+		}
+		if body.RequestID != echoed {
+			t.Errorf("%s: expected request id %q in the error body, got %q", name, echoed, body.RequestID)
+		}
+
+		if opaqueID := recorder.last(); opaqueID != echoed {
+			t.Errorf("%s: expected request id %q to be sent to elasticsearch as X-Opaque-Id, got %q", name, echoed, opaqueID)
+		}
+	}
+}
@@ -8,8 +8,11 @@ import (
 
 	"github.com/methods/go-methods-lib/log"
 	errs "github.com/ofs/alpha-search-api/apierrors"
+	"github.com/ofs/alpha-search-api/cache"
+	"github.com/ofs/alpha-search-api/elasticsearch"
 	"github.com/ofs/alpha-search-api/helpers"
 	"github.com/ofs/alpha-search-api/models"
+	"github.com/ofs/alpha-search-api/tracing"
 	"github.com/pkg/errors"
 )
 
@@ -27,17 +30,22 @@ func (api *SearchAPI) SearchCourses(w http.ResponseWriter, r *http.Request) {
 	lengthOfCourse := r.FormValue("length_of_course")
 	institutions := r.FormValue("institutions")
 	subjects := r.FormValue("subjects")
+	requestedProfile := r.FormValue("profile")
 
 	requestedLimit := r.FormValue("limit")
 	requestedOffset := r.FormValue("offset")
 
-	logData := log.Data{"api_config": api, "limit": requestedLimit, "offset": requestedOffset, "search_term": term}
+	logData := log.Data{"limit": requestedLimit, "offset": requestedOffset, "search_term": term}
 
 	log.InfoCtx(ctx, "SearchCourses handler: attempting to get list of courses relevant to search term", logData)
 
+	_, validateSpan := tracing.StartSpan(ctx, "validate query parameters", tracing.KindInternal)
+
 	var errorObjects []*models.ErrorObject
 
-	limit, err := helpers.CalculateLimit(ctx, defaultLimit, api.DefaultMaxResults, requestedLimit)
+	defaultMaxResults := api.settings.load().DefaultMaxResults
+
+	limit, err := helpers.CalculateLimit(ctx, defaultLimit, defaultMaxResults, requestedLimit)
 	if err != nil {
 		errorObjects = append(errorObjects, &models.ErrorObject{Error: err.Error(), ErrorValues: err.(*errs.ErrorObject).Values()})
 	}
@@ -48,7 +56,7 @@ func (api *SearchAPI) SearchCourses(w http.ResponseWriter, r *http.Request) {
 	}
 
 	page := &models.PageVariables{
-		DefaultMaxResults: api.DefaultMaxResults,
+		DefaultMaxResults: defaultMaxResults,
 		Limit:             limit,
 		Offset:            offset,
 	}
@@ -93,13 +101,54 @@ func (api *SearchAPI) SearchCourses(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 
+	// An empty list is passed on as a single empty value, which does not filter
+	institutionList := []string{""}
+	if institutions != "" {
+		var institutionErrorObject []*models.ErrorObject
+
+		// Validate filter by institutions
+		institutionList, institutionErrorObject = models.ValidateList("institutions", strings.ToLower(institutions))
+		if institutionErrorObject != nil {
+			errorObjects = append(errorObjects, institutionErrorObject...)
+		}
+	}
+
+	subjectList := []string{""}
+	if subjects != "" {
+		var subjectErrorObject []*models.ErrorObject
+
+		// Validate filter by subjects
+		subjectList, subjectErrorObject = models.ValidateList("subjects", strings.ToUpper(subjects))
+		if subjectErrorObject != nil {
+			errorObjects = append(errorObjects, subjectErrorObject...)
+		}
+	}
+
+	profile, err := helpers.ParseProfile(requestedProfile)
+	if err != nil {
+		errorObjects = append(errorObjects, &models.ErrorObject{Error: err.Error(), ErrorValues: err.(*errs.ErrorObject).Values()})
+	}
+
+	if profile {
+		if isTrustedDebugRequest(r, api.DebugCaptureToken) {
+			ctx = cache.WithBypass(elasticsearch.WithProfile(ctx))
+		} else {
+			errorObjects = append(errorObjects, &models.ErrorObject{Error: errs.ErrProfileNotPermitted.Error(), ErrorValues: map[string]string{"profile": "true"}})
+		}
+	}
+
+	validateSpan.SetAttribute("validation.errors", len(errorObjects))
+	validateSpan.End()
+
 	if errorObjects != nil {
 		ErrorResponse(ctx, w, http.StatusBadRequest, &models.ErrorResponse{Errors: errorObjects})
 		return
 	}
 
-	institutionList := strings.Split(strings.ToLower(institutions), ",")
-	subjectList := strings.Split(strings.ToUpper(subjects), ",")
+	// Only trusted callers can send every search to elasticsearch
+	if r.Header.Get(cacheBypassHeader) == "true" && isTrustedDebugRequest(r, api.DebugCaptureToken) {
+		ctx = cache.WithBypass(ctx)
+	}
 
 	log.InfoCtx(ctx, "search Courses endpoint: just before querying search index", logData)
 	// Search for courses in elasticsearch
@@ -124,16 +173,31 @@ func (api *SearchAPI) SearchCourses(w http.ResponseWriter, r *http.Request) {
 		doc := result.Source.Doc
 		if api.ShowScore {
 			doc.Score = result.Score
+		} else if doc.Institution != nil {
+			// Copy the institution as responses may be shared between requests by the cache
+			institution := *doc.Institution
+			institution.LCUKPRNName = ""
+
+			doc.SortName = ""
+			doc.Institution = &institution
 		} else {
 			doc.SortName = ""
-			doc.Institution.LCUKPRNName = ""
 		}
 		searchResults.Items = append(searchResults.Items, doc)
 	}
 
 	searchResults.Count = len(searchResults.Items)
 
+	if profile {
+		searchResults.Debug = &models.SearchDebug{Took: response.Took, Profile: response.Profile}
+	}
+
+	observeResults(r, searchResults.TotalResults)
+
+	_, marshalSpan := tracing.StartSpan(ctx, "marshal response", tracing.KindInternal)
 	b, err := json.Marshal(searchResults)
+	marshalSpan.SetAttribute("response.size", len(b))
+	marshalSpan.End()
 	if err != nil {
 		log.ErrorCtx(ctx, errors.WithMessage(err, "search Courses endpoint: failed to marshal search resource into bytes"), logData)
 
@@ -142,7 +206,14 @@ func (api *SearchAPI) SearchCourses(w http.ResponseWriter, r *http.Request) {
 	}
 
 	log.InfoCtx(ctx, "SearchCourses handler: successfully got list of course resources", logData)
-	writeBody(ctx, w, b)
+	if profile {
+		// Profiled responses are specific to the caller so must never be stored
+		w.Header().Set("Cache-Control", "no-store")
+		writeBody(ctx, w, b)
+		return
+	}
+
+	api.writeCacheableBody(ctx, w, r, b)
 }
 
 func getSnippets(ctx context.Context, result models.HitList) models.HitList {
@@ -170,7 +241,7 @@ func getSnippets(ctx context.Context, result models.HitList) models.HitList {
 			prevEnd = snippet.End
 
 			result.Source.Doc.Matches.KISCourseID = append(result.Source.Doc.Matches.KISCourseID, snippet)
-			log.InfoCtx(ctx, "getSearch endpoint: added code snippet", logData)
+			log.DebugCtx(ctx, "getSearch endpoint: added code snippet", logData)
 
 			highlightedCode = string(highlightedCode[end+2:])
 		}
@@ -199,7 +270,7 @@ func getSnippets(ctx context.Context, result models.HitList) models.HitList {
 			prevEnd = snippet.End
 
 			result.Source.Doc.Matches.EnglishTitle = append(result.Source.Doc.Matches.EnglishTitle, snippet)
-			log.InfoCtx(ctx, "getSearch endpoint: added label snippet", logData)
+			log.DebugCtx(ctx, "getSearch endpoint: added label snippet", logData)
 
 			highlightedLabel = string(highlightedLabel[end+2:])
 		}
@@ -8,8 +8,11 @@ import (
 
 	"github.com/methods/go-methods-lib/log"
 	errs "github.com/ofs/alpha-search-api/apierrors"
+	"github.com/ofs/alpha-search-api/cache"
+	"github.com/ofs/alpha-search-api/elasticsearch"
 	"github.com/ofs/alpha-search-api/helpers"
 	"github.com/ofs/alpha-search-api/models"
+	"github.com/ofs/alpha-search-api/tracing"
 	"github.com/pkg/errors"
 )
 
@@ -27,17 +30,22 @@ func (api *SearchAPI) SearchInstitutionCourses(w http.ResponseWriter, r *http.Re
 	lengthOfCourse := r.FormValue("length_of_course")
 	institutions := r.FormValue("institutions")
 	subjects := r.FormValue("subjects")
+	requestedProfile := r.FormValue("profile")
 
 	requestedLimit := r.FormValue("limit")
 	requestedOffset := r.FormValue("offset")
 
-	logData := log.Data{"api_config": api, "limit": requestedLimit, "offset": requestedOffset, "search_term": term}
+	logData := log.Data{"limit": requestedLimit, "offset": requestedOffset, "search_term": term}
 
 	log.InfoCtx(ctx, "SearchInstitutionCourses handler: attempting to get list of courses relevant to search term", logData)
 
+	_, validateSpan := tracing.StartSpan(ctx, "validate query parameters", tracing.KindInternal)
+
 	var errorObjects []*models.ErrorObject
 
-	limit, err := helpers.CalculateLimit(ctx, defaultLimit, api.DefaultMaxResults, requestedLimit)
+	defaultMaxResults := api.settings.load().DefaultMaxResults
+
+	limit, err := helpers.CalculateLimit(ctx, defaultLimit, defaultMaxResults, requestedLimit)
 	if err != nil {
 		errorObjects = append(errorObjects, &models.ErrorObject{Error: err.Error(), ErrorValues: err.(*errs.ErrorObject).Values()})
 	}
@@ -48,7 +56,7 @@ func (api *SearchAPI) SearchInstitutionCourses(w http.ResponseWriter, r *http.Re
 	}
 
 	page := &models.PageVariables{
-		DefaultMaxResults: api.DefaultMaxResults,
+		DefaultMaxResults: defaultMaxResults,
 		Limit:             limit,
 		Offset:            offset,
 	}
@@ -93,13 +101,54 @@ func (api *SearchAPI) SearchInstitutionCourses(w http.ResponseWriter, r *http.Re
 		}
 	}
 
+	// An empty list is passed on as a single empty value, which does not filter
+	institutionList := []string{""}
+	if institutions != "" {
+		var institutionErrorObject []*models.ErrorObject
+
+		// Validate filter by institutions
+		institutionList, institutionErrorObject = models.ValidateList("institutions", strings.ToLower(institutions))
+		if institutionErrorObject != nil {
+			errorObjects = append(errorObjects, institutionErrorObject...)
+		}
+	}
+
+	subjectList := []string{""}
+	if subjects != "" {
+		var subjectErrorObject []*models.ErrorObject
+
+		// Validate filter by subjects
+		subjectList, subjectErrorObject = models.ValidateList("subjects", strings.ToUpper(subjects))
+		if subjectErrorObject != nil {
+			errorObjects = append(errorObjects, subjectErrorObject...)
+		}
+	}
+
+	profile, err := helpers.ParseProfile(requestedProfile)
+	if err != nil {
+		errorObjects = append(errorObjects, &models.ErrorObject{Error: err.Error(), ErrorValues: err.(*errs.ErrorObject).Values()})
+	}
+
+	if profile {
+		if isTrustedDebugRequest(r, api.DebugCaptureToken) {
+			ctx = cache.WithBypass(elasticsearch.WithProfile(ctx))
+		} else {
+			errorObjects = append(errorObjects, &models.ErrorObject{Error: errs.ErrProfileNotPermitted.Error(), ErrorValues: map[string]string{"profile": "true"}})
+		}
+	}
+
+	validateSpan.SetAttribute("validation.errors", len(errorObjects))
+	validateSpan.End()
+
 	if errorObjects != nil {
 		ErrorResponse(ctx, w, http.StatusBadRequest, &models.ErrorResponse{Errors: errorObjects})
 		return
 	}
 
-	institutionList := strings.Split(strings.ToLower(institutions), ",")
-	subjectList := strings.Split(strings.ToUpper(subjects), ",")
+	// Only trusted callers can send every search to elasticsearch
+	if r.Header.Get(cacheBypassHeader) == "true" && isTrustedDebugRequest(r, api.DebugCaptureToken) {
+		ctx = cache.WithBypass(ctx)
+	}
 
 	log.InfoCtx(ctx, "search Institution courses endpoint: just before querying search index", logData)
 	// Search for courses in elasticsearch
@@ -111,6 +160,8 @@ func (api *SearchAPI) SearchInstitutionCourses(w http.ResponseWriter, r *http.Re
 		return
 	}
 
+	observeResults(r, len(response.Hits.HitList))
+
 	items := groupCoursesByInstitution(api.ShowScore, response)
 
 	searchResults := &models.InstitutionCoursesSearchResult{
@@ -132,7 +183,14 @@ func (api *SearchAPI) SearchInstitutionCourses(w http.ResponseWriter, r *http.Re
 		searchResults.Count = len(searchResults.Items)
 	}
 
+	if profile {
+		searchResults.Debug = &models.SearchDebug{Took: response.Took, Profile: response.Profile}
+	}
+
+	_, marshalSpan := tracing.StartSpan(ctx, "marshal response", tracing.KindInternal)
 	b, err := json.Marshal(searchResults)
+	marshalSpan.SetAttribute("response.size", len(b))
+	marshalSpan.End()
 	if err != nil {
 		log.ErrorCtx(ctx, errors.WithMessage(err, "search Institution courses endpoint: failed to marshal search resource into bytes"), logData)
 
@@ -141,7 +199,14 @@ func (api *SearchAPI) SearchInstitutionCourses(w http.ResponseWriter, r *http.Re
 	}
 
 	log.InfoCtx(ctx, "SearchInstitutionCourses handler: successfully got list of course resources", logData)
-	writeBody(ctx, w, b)
+	if profile {
+		// Profiled responses are specific to the caller so must never be stored
+		w.Header().Set("Cache-Control", "no-store")
+		writeBody(ctx, w, b)
+		return
+	}
+
+	api.writeCacheableBody(ctx, w, r, b)
 }
 
 func groupCoursesByInstitution(showScore bool, response *models.SearchResponse) (institutions []models.Institution) {
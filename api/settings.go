@@ -0,0 +1,58 @@
+package api
+
+import (
+	"strings"
+	"sync/atomic"
+
+	"github.com/ofs/alpha-search-api/auth"
+	"github.com/ofs/alpha-search-api/config"
+	"github.com/ofs/alpha-search-api/ratelimit"
+)
+
+// Settings hold the values which can be changed while the api is running. They
+// are replaced as a whole, so each request sees a consistent set
+type Settings struct {
+	DefaultMaxResults  int
+	CORSAllowedOrigins []string
+	Keys               *auth.Keys
+	KeyRequired        bool
+	KeyLimit           ratelimit.Limit
+	IPLimit            ratelimit.Limit
+}
+
+// NewSettings creates settings from the configuration, accepting the API keys given
+func NewSettings(cfg config.Configuration, keys *auth.Keys) *Settings {
+	settings := &Settings{
+		DefaultMaxResults: cfg.DefaultMaxResults,
+		Keys:              keys,
+		KeyRequired:       cfg.APIKeyRequired,
+		KeyLimit:          ratelimit.Limit{Rate: cfg.RateLimitKeyRate, Burst: cfg.RateLimitKeyBurst},
+		IPLimit:           ratelimit.Limit{Rate: cfg.RateLimitIPRate, Burst: cfg.RateLimitIPBurst},
+	}
+
+	for _, origin := range trimAll(cfg.CORSAllowedOrigins) {
+		settings.CORSAllowedOrigins = append(settings.CORSAllowedOrigins, strings.ToLower(origin))
+	}
+
+	return settings
+}
+
+// settingsStore holds the current settings, shared by the handlers which use them
+type settingsStore struct {
+	current atomic.Value
+}
+
+func newSettingsStore(settings *Settings) *settingsStore {
+	s := &settingsStore{}
+	s.store(settings)
+
+	return s
+}
+
+func (s *settingsStore) load() *Settings {
+	return s.current.Load().(*Settings)
+}
+
+func (s *settingsStore) store(settings *Settings) {
+	s.current.Store(settings)
+}
@@ -0,0 +1,33 @@
+package api
+
+import (
+	"net/http"
+
+	"github.com/ofs/alpha-search-api/tracing"
+	"github.com/pkg/errors"
+)
+
+// tracingHandler starts a span for every request to a route, continuing any
+// trace propagated by the caller in the traceparent header
+func tracingHandler(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		route := routeName(r)
+
+		ctx := tracing.Extract(r.Context(), r.Header)
+		ctx, span := tracing.StartSpan(ctx, r.Method+" "+route, tracing.KindServer)
+		defer span.End()
+
+		span.SetAttribute("http.method", r.Method)
+		span.SetAttribute("http.route", route)
+		span.SetAttribute("http.target", r.URL.RequestURI())
+
+		rec := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+
+		h.ServeHTTP(rec, r.WithContext(ctx))
+
+		span.SetAttribute("http.status_code", rec.status)
+		if rec.status >= http.StatusInternalServerError {
+			span.RecordError(errors.New(http.StatusText(rec.status)))
+		}
+	})
+}
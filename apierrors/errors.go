@@ -44,18 +44,32 @@ var (
 	ErrInvalidFilter            = errors.New("invalid filters")
 	ErrDuplicateFilters         = errors.New("use of the same filter option more than once")
 	ErrInvalidCountry           = errors.New("invalid countries")
+	ErrDuplicateCountries       = errors.New("use of the same country more than once")
+	ErrEmptyListValue           = errors.New("lists cannot contain empty values, values must be separated by a single comma")
 	ErrLengthOfCourseWrongType  = errors.New("length_of_course values needs to be a number")
 	ErrLengthOfCourseOutOfRange = errors.New("length_of_course values needs to be numbers between the range of 1 and 7")
 	ErrEmptySearchTerm          = errors.New("empty search term")
+	ErrProfileWrongType         = errors.New("profile value needs to be true or false")
+	ErrProfileNotPermitted      = errors.New("profiling searches is only available to trusted callers")
+	ErrAPIKeyRequired           = errors.New("an api key is required, send it in the X-Api-Key header")
+	ErrInvalidAPIKey            = errors.New("invalid api key")
+	ErrInsufficientScope        = errors.New("api key does not have the scope required for this request")
+	ErrRateLimitExceeded        = errors.New("rate limit exceeded, retry after the number of seconds given")
+	ErrRequestViolatesSpec      = errors.New("request does not match the openapi specification")
 
+	ErrCircuitBreakerOpen     = errors.New("circuit breaker open, calls to elastic are temporarily suspended")
 	ErrCourseNotFound         = errors.New("course not found")
 	ErrIndexNotFound          = errors.New("search index not found")
 	ErrInstitutionNotFound    = errors.New("institution not found")
 	ErrInternalServer         = errors.New("internal server error")
+	ErrMappingMismatch        = errors.New("index mapping does not match the fields searched")
 	ErrMarshallingQuery       = errors.New("failed to marshal query to bytes for request body to send to elastic")
+	ErrResponseViolatesSpec   = errors.New("response does not match the openapi specification")
 	ErrParsingQueryParameters = errors.New("failed to parse query parameters, values must be an integer")
+	ErrShuttingDown           = errors.New("the service is shutting down")
 	ErrUnmarshallingJSON      = errors.New("failed to parse json body")
 	ErrUnexpectedStatusCode   = errors.New("unexpected status code from elastic api")
+	ErrUnhealthyCluster       = errors.New("elastic cluster health is red")
 
 	NotFoundMap = map[error]bool{
 		ErrCourseNotFound:      true,
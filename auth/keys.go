@@ -0,0 +1,110 @@
+package auth
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+
+	"github.com/pkg/errors"
+)
+
+// ScopeSearch allows a client to call the search endpoints
+const ScopeSearch = "search"
+
+// Client is a third party identified by an API key
+type Client struct {
+	Name      string   `json:"client"`
+	Key       string   `json:"key"`
+	Scopes    []string `json:"scopes"`
+	RateLimit float64  `json:"rate_limit,omitempty"`
+	Burst     int      `json:"burst,omitempty"`
+
+	id string
+}
+
+// ID identifies the client by the hash of its key, so that it stays the same
+// when keys are reloaded and differs between keys given the same client name
+func (c *Client) ID() string {
+	return c.id
+}
+
+// HasScope returns true if the client has been granted the scope
+func (c *Client) HasScope(scope string) bool {
+	for _, s := range c.Scopes {
+		if s == scope {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Keys holds the clients allowed to call the api, looked up by API key
+type Keys struct {
+	clients map[[sha256.Size]byte]*Client
+}
+
+type keysFile struct {
+	Keys []*Client `json:"keys"`
+}
+
+// LoadKeys reads a JSON file of clients in the form
+// {"keys": [{"client": "name", "key": "secret", "scopes": ["search"], "rate_limit": 10, "burst": 20}]}
+func LoadKeys(path string) (*Keys, error) {
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to read api keys file")
+	}
+
+	var file keysFile
+	if err = json.Unmarshal(b, &file); err != nil {
+		return nil, errors.Wrap(err, "failed to parse api keys file")
+	}
+
+	return NewKeys(file.Keys)
+}
+
+// NewKeys creates a lookup of the given clients, which must all have a name and a unique key
+func NewKeys(clients []*Client) (*Keys, error) {
+	keys := &Keys{clients: make(map[[sha256.Size]byte]*Client)}
+
+	for i, client := range clients {
+		if client.Name == "" || client.Key == "" {
+			return nil, fmt.Errorf("api key %d must have a client name and key", i)
+		}
+		if client.RateLimit < 0 || client.Burst < 0 {
+			return nil, fmt.Errorf("api key for client [%s] has a negative rate limit", client.Name)
+		}
+
+		hash := sha256.Sum256([]byte(client.Key))
+		if _, ok := keys.clients[hash]; ok {
+			return nil, fmt.Errorf("api key for client [%s] is not unique", client.Name)
+		}
+		client.id = hex.EncodeToString(hash[:])
+		keys.clients[hash] = client
+	}
+
+	return keys, nil
+}
+
+// Lookup returns the client with the given key. Keys are compared by their hash
+// so the time taken does not reveal how much of a guessed key is correct
+func (k *Keys) Lookup(key string) (*Client, bool) {
+	if k == nil {
+		return nil, false
+	}
+
+	client, ok := k.clients[sha256.Sum256([]byte(key))]
+	return client, ok
+}
+
+// Len returns the number of keys
+func (k *Keys) Len() int {
+	if k == nil {
+		return 0
+	}
+
+	return len(k.clients)
+}
@@ -0,0 +1,104 @@
+package auth_test
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/ofs/alpha-search-api/auth"
+)
+
+func writeKeys(t *testing.T, content string) string {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), "keys.json")
+	if err := ioutil.WriteFile(path, []byte(content), 0600); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	return path
+}
+
+func TestLoadKeys(t *testing.T) {
+	path := writeKeys(t, `{"keys": [
+		{"client": "partner", "key": "partner-secret", "scopes": ["search"], "rate_limit": 10, "burst": 20},
+		{"client": "internal", "key": "internal-secret"}
+	]}`)
+
+	keys, err := auth.LoadKeys(path)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if keys.Len() != 2 {
+		t.Errorf("expected 2 keys, got %d", keys.Len())
+	}
+
+	client, ok := keys.Lookup("partner-secret")
+	if !ok || client.Name != "partner" || client.RateLimit != 10 || client.Burst != 20 {
+		t.Fatalf("expected the partner client, got %+v", client)
+	}
+	if !client.HasScope(auth.ScopeSearch) {
+		t.Error("expected the partner to have the search scope")
+	}
+
+	client, ok = keys.Lookup("internal-secret")
+	if !ok || client.HasScope(auth.ScopeSearch) {
+		t.Errorf("expected the internal client without scopes, got %+v", client)
+	}
+
+	if _, ok = keys.Lookup("partner"); ok {
+		t.Error("expected an unknown key not to be found")
+	}
+}
+
+func TestLoadKeysErrors(t *testing.T) {
+	testCases := map[string]struct {
+		content string
+		problem string
+	}{
+		"invalid json":    {`{"keys": [`, "failed to parse api keys file"},
+		"no client name":  {`{"keys": [{"key": "secret"}]}`, "api key 0 must have a client name and key"},
+		"no key":          {`{"keys": [{"client": "partner"}, {"client": "other"}]}`, "api key 0 must have a client name and key"},
+		"negative rate":   {`{"keys": [{"client": "partner", "key": "secret", "rate_limit": -1}]}`, "api key for client [partner] has a negative rate limit"},
+		"negative burst":  {`{"keys": [{"client": "partner", "key": "secret", "burst": -1}]}`, "api key for client [partner] has a negative rate limit"},
+		"duplicate key":   {`{"keys": [{"client": "partner", "key": "secret"}, {"client": "other", "key": "secret"}]}`, "api key for client [other] is not unique"},
+		"wrong key types": {`{"keys": [{"client": "partner", "key": "secret", "scopes": "search"}]}`, "failed to parse api keys file"},
+	}
+
+	for name, tc := range testCases {
+		_, err := auth.LoadKeys(writeKeys(t, tc.content))
+		if err == nil || !strings.Contains(err.Error(), tc.problem) {
+			t.Errorf("%s: expected %q, got %v", name, tc.problem, err)
+		}
+	}
+
+	_, err := auth.LoadKeys(filepath.Join(t.TempDir(), "missing.json"))
+	if err == nil || !strings.Contains(err.Error(), "failed to read api keys file") {
+		t.Errorf("expected a missing file to fail, got %v", err)
+	}
+}
+
+func TestClientID(t *testing.T) {
+	keys, err := auth.NewKeys([]*auth.Client{{Name: "partner", Key: "first"}, {Name: "partner", Key: "second"}})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	first, _ := keys.Lookup("first")
+	second, _ := keys.Lookup("second")
+	if first.ID() == "" || first.ID() == second.ID() {
+		t.Errorf("expected clients to be identified by their key, got %q and %q", first.ID(), second.ID())
+	}
+	if strings.Contains(first.ID(), "first") {
+		t.Error("expected the id not to reveal the key")
+	}
+}
+
+func TestNilKeys(t *testing.T) {
+	var keys *auth.Keys
+
+	if _, ok := keys.Lookup("secret"); ok || keys.Len() != 0 {
+		t.Error("expected no keys to be found without a keys file")
+	}
+}
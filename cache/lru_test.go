@@ -0,0 +1,78 @@
+package cache
+
+import (
+	"testing"
+	"time"
+)
+
+func TestLRUEviction(t *testing.T) {
+	c := NewLRU(2, 0)
+
+	if c.Set("a", 1) || c.Set("b", 2) {
+		t.Error("expected no eviction before the cache is full")
+	}
+
+	// Reading a makes b the least recently used
+	if value, ok := c.Get("a"); !ok || value != 1 {
+		t.Errorf("expected a to be cached, got %v", value)
+	}
+
+	if !c.Set("c", 3) {
+		t.Error("expected an entry to be evicted once the cache is full")
+	}
+	if _, ok := c.Get("b"); ok {
+		t.Error("expected the least recently used entry to be evicted")
+	}
+	for _, key := range []string{"a", "c"} {
+		if _, ok := c.Get(key); !ok {
+			t.Errorf("expected %s to be cached", key)
+		}
+	}
+
+	// Replacing an entry does not evict another
+	if c.Set("a", 4) || c.Len() != 2 {
+		t.Errorf("expected a to be replaced, got %d entries", c.Len())
+	}
+	if value, _ := c.Get("a"); value != 4 {
+		t.Errorf("expected the replaced value, got %v", value)
+	}
+
+	c.Purge()
+	if _, ok := c.Get("a"); ok || c.Len() != 0 {
+		t.Errorf("expected the cache to be empty after a purge, got %d entries", c.Len())
+	}
+}
+
+func TestLRUDisabled(t *testing.T) {
+	c := NewLRU(0, 0)
+
+	c.Set("a", 1)
+	if _, ok := c.Get("a"); ok {
+		t.Error("expected nothing to be cached with a size of zero")
+	}
+}
+
+func TestLRUExpiry(t *testing.T) {
+	c := NewLRU(10, 20*time.Millisecond)
+
+	c.Set("a", 1)
+	if _, ok := c.Get("a"); !ok {
+		t.Fatal("expected a to be cached")
+	}
+
+	// Entries already stored keep their expiry when the ttl changes
+	c.SetTTL(time.Minute)
+	c.Set("b", 2)
+
+	time.Sleep(30 * time.Millisecond)
+
+	if _, ok := c.Get("a"); ok {
+		t.Error("expected a to have expired")
+	}
+	if c.Len() != 1 {
+		t.Errorf("expected the expired entry to be removed, got %d entries", c.Len())
+	}
+	if _, ok := c.Get("b"); !ok {
+		t.Error("expected b to be held for the new ttl")
+	}
+}
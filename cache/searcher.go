@@ -0,0 +1,195 @@
+package cache
+
+import (
+	"context"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/methods/go-methods-lib/log"
+	"github.com/ofs/alpha-search-api/metrics"
+	"github.com/ofs/alpha-search-api/models"
+)
+
+type contextKey string
+
+const bypassKey = contextKey("cache-bypass")
+
+// A list of cache lookup results recorded in metrics
+const (
+	resultHit    = "hit"
+	resultMiss   = "miss"
+	resultBypass = "bypass"
+)
+
+var (
+	requestsTotal = metrics.NewCounterVec(
+		"search_api_cache_requests_total",
+		"The number of searches looked up in the cache, partitioned by query type and result (hit, miss or bypass).",
+		"query", "result",
+	)
+
+	evictionsTotal = metrics.NewCounterVec(
+		"search_api_cache_evictions_total",
+		"The number of cached searches evicted to make room for new entries.",
+	)
+
+	purgesTotal = metrics.NewCounterVec(
+		"search_api_cache_purges_total",
+		"The number of times the cache has been emptied because the index behind the alias changed.",
+	)
+)
+
+var whitespace = regexp.MustCompile(`\s+`)
+
+// Elasticsearcher - An interface used to access elasticsearch, matching the one used by the api
+type Elasticsearcher interface {
+	QueryCoursesSearch(ctx context.Context, index, term string, limit, offset int, listOfFilters map[string]string, listOfCountries, listOfLengthOfCourses, institutionList, subjects []string) (*models.SearchResponse, int, error)
+	QueryInstitutionCoursesSearch(ctx context.Context, index, term string, filters map[string]string, countries, lengthOfCourse, institutions, subjects []string) (*models.SearchResponse, int, error)
+}
+
+// Searcher caches successful search responses from the wrapped Elasticsearcher,
+// keyed by a normalised signature of the search. Cached responses are shared
+// between requests so must be treated as read only
+type Searcher struct {
+	next Elasticsearcher
+	lru  *LRU
+
+	// generation changes on every purge so that responses from searches which
+	// started before it are not cached after it
+	mu         sync.Mutex
+	generation uint64
+}
+
+// NewSearcher wraps an Elasticsearcher with a cache of up to size responses held for ttl
+func NewSearcher(next Elasticsearcher, size int, ttl time.Duration) *Searcher {
+	return &Searcher{
+		next: next,
+		lru:  NewLRU(size, ttl),
+	}
+}
+
+// WithBypass marks searches made with the context as ones which must not be served from the cache
+func WithBypass(ctx context.Context) context.Context {
+	return context.WithValue(ctx, bypassKey, true)
+}
+
+func bypassed(ctx context.Context) bool {
+	bypass, _ := ctx.Value(bypassKey).(bool)
+	return bypass
+}
+
+// Purge empties the cache, e.g. when the index behind the alias has changed
+func (s *Searcher) Purge(version string) {
+	s.mu.Lock()
+	s.generation++
+	s.lru.Purge()
+	s.mu.Unlock()
+	purgesTotal.Inc()
+
+	log.Info("search cache purged", log.Data{"index_version": version})
+}
+
+// SetTTL changes how long responses cached from now on are held for
+func (s *Searcher) SetTTL(ttl time.Duration) {
+	s.lru.SetTTL(ttl)
+}
+
+// QueryCoursesSearch returns a cached response for the search or calls elasticsearch and caches the result
+func (s *Searcher) QueryCoursesSearch(ctx context.Context, index, term string, limit, offset int, filters map[string]string, countries, lengthOfCourse, institutions, subjects []string) (*models.SearchResponse, int, error) {
+	key := signature("courses", index, term, filters, countries, lengthOfCourse, institutions, subjects, strconv.Itoa(limit), strconv.Itoa(offset))
+
+	return s.search(ctx, "courses", key, func() (*models.SearchResponse, int, error) {
+		return s.next.QueryCoursesSearch(ctx, index, term, limit, offset, filters, countries, lengthOfCourse, institutions, subjects)
+	})
+}
+
+// QueryInstitutionCoursesSearch returns a cached response for the search or calls elasticsearch and caches the result
+func (s *Searcher) QueryInstitutionCoursesSearch(ctx context.Context, index, term string, filters map[string]string, countries, lengthOfCourse, institutions, subjects []string) (*models.SearchResponse, int, error) {
+	key := signature("institution_courses", index, term, filters, countries, lengthOfCourse, institutions, subjects)
+
+	return s.search(ctx, "institution_courses", key, func() (*models.SearchResponse, int, error) {
+		return s.next.QueryInstitutionCoursesSearch(ctx, index, term, filters, countries, lengthOfCourse, institutions, subjects)
+	})
+}
+
+type cachedResponse struct {
+	response *models.SearchResponse
+	status   int
+}
+
+func (s *Searcher) search(ctx context.Context, query, key string, call func() (*models.SearchResponse, int, error)) (*models.SearchResponse, int, error) {
+	if bypassed(ctx) {
+		requestsTotal.Inc(query, resultBypass)
+		return call()
+	}
+
+	if value, ok := s.lru.Get(key); ok {
+		requestsTotal.Inc(query, resultHit)
+		cached := value.(cachedResponse)
+		return cached.response, cached.status, nil
+	}
+
+	requestsTotal.Inc(query, resultMiss)
+
+	s.mu.Lock()
+	generation := s.generation
+	s.mu.Unlock()
+
+	response, status, err := call()
+	if err != nil {
+		return response, status, err
+	}
+
+	// The response may be from the index the alias pointed to before a purge
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if generation != s.generation {
+		return response, status, nil
+	}
+
+	if s.lru.Set(key, cachedResponse{response: response, status: status}) {
+		evictionsTotal.Inc()
+	}
+
+	return response, status, nil
+}
+
+// signature builds a cache key which is the same for searches that would return
+// the same results, regardless of casing, whitespace or the order of filters
+func signature(query, index, term string, filters map[string]string, countries, lengthOfCourse, institutions, subjects []string, paging ...string) string {
+	var filterList []string
+	for key, value := range filters {
+		filterList = append(filterList, key+"="+value)
+	}
+
+	parts := []string{
+		query,
+		index,
+		whitespace.ReplaceAllString(strings.ToLower(strings.TrimSpace(term)), " "),
+		normalise(filterList),
+		normalise(countries),
+		normalise(lengthOfCourse),
+		normalise(institutions),
+		normalise(subjects),
+	}
+	parts = append(parts, paging...)
+
+	return strings.Join(parts, "|")
+}
+
+// normalise sorts a copy of the list and drops empty values
+func normalise(values []string) string {
+	var list []string
+	for _, value := range values {
+		if value != "" {
+			list = append(list, value)
+		}
+	}
+	sort.Strings(list)
+
+	return strings.Join(list, ",")
+}
@@ -0,0 +1,160 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ofs/alpha-search-api/models"
+)
+
+// fakeElasticsearch counts the searches made, returning a response holding the
+// number of the search, and waits for release if it is set
+type fakeElasticsearch struct {
+	mu      sync.Mutex
+	calls   int
+	err     error
+	started chan struct{}
+	release chan struct{}
+}
+
+func (f *fakeElasticsearch) QueryCoursesSearch(ctx context.Context, index, term string, limit, offset int, filters map[string]string, countries, lengthOfCourse, institutions, subjects []string) (*models.SearchResponse, int, error) {
+	return f.search()
+}
+
+func (f *fakeElasticsearch) QueryInstitutionCoursesSearch(ctx context.Context, index, term string, filters map[string]string, countries, lengthOfCourse, institutions, subjects []string) (*models.SearchResponse, int, error) {
+	return f.search()
+}
+
+func (f *fakeElasticsearch) search() (*models.SearchResponse, int, error) {
+	f.mu.Lock()
+	f.calls++
+	calls, release := f.calls, f.release
+	f.mu.Unlock()
+
+	if release != nil {
+		f.started <- struct{}{}
+		<-release
+	}
+	if f.err != nil {
+		return nil, 0, f.err
+	}
+
+	return &models.SearchResponse{Took: calls}, 200, nil
+}
+
+func (f *fakeElasticsearch) searches() int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	return f.calls
+}
+
+func TestSignature(t *testing.T) {
+	key := func(term string, filters map[string]string, countries []string, paging ...string) string {
+		return signature("courses", "courses", term, filters, countries, nil, nil, nil, paging...)
+	}
+
+	base := key("maths and physics", map[string]string{"part_time": "true", "honours_award": "true"}, []string{"england", "wales"}, "20", "0")
+
+	same := map[string]string{
+		"case":          key("Maths AND Physics", map[string]string{"part_time": "true", "honours_award": "true"}, []string{"england", "wales"}, "20", "0"),
+		"whitespace":    key("  maths \t and\n physics ", map[string]string{"part_time": "true", "honours_award": "true"}, []string{"england", "wales"}, "20", "0"),
+		"filter order":  key("maths and physics", map[string]string{"honours_award": "true", "part_time": "true"}, []string{"wales", "england"}, "20", "0"),
+		"empty entries": key("maths and physics", map[string]string{"part_time": "true", "honours_award": "true"}, []string{"", "wales", "england"}, "20", "0"),
+	}
+	for name, k := range same {
+		if k != base {
+			t.Errorf("%s: expected the same key, got %q and %q", name, k, base)
+		}
+	}
+
+	different := map[string]string{
+		"term":          key("maths", map[string]string{"part_time": "true", "honours_award": "true"}, []string{"england", "wales"}, "20", "0"),
+		"filter value":  key("maths and physics", map[string]string{"part_time": "false", "honours_award": "true"}, []string{"england", "wales"}, "20", "0"),
+		"countries":     key("maths and physics", map[string]string{"part_time": "true", "honours_award": "true"}, []string{"england"}, "20", "0"),
+		"paging":        key("maths and physics", map[string]string{"part_time": "true", "honours_award": "true"}, []string{"england", "wales"}, "20", "20"),
+		"word boundary": key("mathsand physics", map[string]string{"part_time": "true", "honours_award": "true"}, []string{"england", "wales"}, "20", "0"),
+	}
+	for name, k := range different {
+		if k == base {
+			t.Errorf("%s: expected a different key, got %q", name, k)
+		}
+	}
+
+	if signature("courses", "courses", "maths", nil, nil, nil, nil, nil) == signature("institution_courses", "courses", "maths", nil, nil, nil, nil, nil) {
+		t.Error("expected the query type to be part of the key")
+	}
+}
+
+func TestSearcherCaches(t *testing.T) {
+	es := &fakeElasticsearch{}
+	s := NewSearcher(es, 10, time.Minute)
+
+	search := func(ctx context.Context, term string) int {
+		response, _, err := s.QueryCoursesSearch(ctx, "courses", term, 20, 0, nil, nil, nil, nil, nil)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		return response.Took
+	}
+
+	if first, second := search(context.Background(), "maths"), search(context.Background(), " MATHS "); first != 1 || second != 1 {
+		t.Errorf("expected the second search to be cached, got responses %d and %d", first, second)
+	}
+
+	// A bypassed search is neither served from nor stored in the cache
+	if took := search(WithBypass(context.Background()), "maths"); took != 2 {
+		t.Errorf("expected the bypassed search to reach elasticsearch, got response %d", took)
+	}
+	if took := search(context.Background(), "maths"); took != 1 {
+		t.Errorf("expected the cached response to be kept, got response %d", took)
+	}
+
+	// Failures are not cached
+	es.err = errors.New("unavailable")
+	if _, _, err := s.QueryCoursesSearch(context.Background(), "courses", "physics", 20, 0, nil, nil, nil, nil, nil); err != es.err {
+		t.Errorf("expected the failure, got %v", err)
+	}
+	es.err = nil
+	if took := search(context.Background(), "physics"); took != 4 {
+		t.Errorf("expected the search to be made again after a failure, got response %d", took)
+	}
+
+	s.Purge("courses-2")
+	if took := search(context.Background(), "maths"); took != 5 {
+		t.Errorf("expected the search to be made again after a purge, got response %d", took)
+	}
+}
+
+func TestSearcherPurgeDuringSearch(t *testing.T) {
+	es := &fakeElasticsearch{started: make(chan struct{}), release: make(chan struct{})}
+	s := NewSearcher(es, 10, time.Minute)
+
+	done := make(chan struct{})
+	go func() {
+		s.QueryInstitutionCoursesSearch(context.Background(), "courses", "maths", nil, nil, nil, nil, nil)
+		close(done)
+	}()
+
+	// The alias is swapped while the search is in flight
+	<-es.started
+	s.Purge("courses-2")
+
+	es.mu.Lock()
+	release := es.release
+	es.release = nil
+	es.mu.Unlock()
+	close(release)
+	<-done
+
+	response, _, err := s.QueryInstitutionCoursesSearch(context.Background(), "courses", "maths", nil, nil, nil, nil, nil)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if response.Took != 2 || es.searches() != 2 {
+		t.Errorf("expected the response from before the purge not to be cached, got response %d", response.Took)
+	}
+}
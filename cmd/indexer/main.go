@@ -0,0 +1,186 @@
+// Command indexer loads courses into the search index from JSON, newline
+// delimited JSON or a KIS export, creating the index if it does not exist.
+//
+// Usage:
+//
+//	indexer [-index courses] [-swap] [-kis dir] [file.json ...]
+//
+// With -swap, the courses are loaded into a new index named after the time, and
+// the index given is an alias which is swapped to the new index once its documents
+// have been counted. Old indices built for the alias are pruned.
+//
+// Elasticsearch is configured with the same environment variables and -config file
+// as the api. Progress is logged after each batch, and a report of the courses
+// indexed and of those rejected or failed is written as JSON to the file given with
+// -report. The command exits with status 2 if any course was not indexed
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/methods/go-methods-lib/log"
+	"github.com/ofs/alpha-search-api/config"
+	"github.com/ofs/alpha-search-api/elasticsearch"
+	"github.com/ofs/alpha-search-api/indexer"
+	"github.com/ofs/alpha-search-api/signer"
+	"github.com/pkg/errors"
+)
+
+func main() {
+	log.Namespace = "alpha-search-indexer"
+
+	configFile := flag.String("config", os.Getenv("CONFIG_FILE"), "a YAML or JSON file holding the api's configuration, which environment variables override")
+	index := flag.String("index", "", "the index to load courses into, ES_DESTINATION_INDEX by default")
+	kis := flag.String("kis", "", "a directory holding a KIS export, from which "+indexer.KISCourseFile+" is read")
+	batchSize := flag.Int("batch-size", 500, "the number of courses sent in each bulk request")
+	retries := flag.Int("retries", 3, "the number of times a bulk request is retried")
+	retryInterval := flag.Duration("retry-interval", time.Second, "the time waited before the first retry, doubling with each retry")
+	reportFile := flag.String("report", "indexer-report.json", "the file the report is written to")
+	swap := flag.Bool("swap", false, "load into a new index and swap the alias named by -index to it")
+	keep := flag.Int("keep", 2, "with -swap, the number of indices previously built for the alias which are kept")
+	minRatio := flag.Float64("min-ratio", 0.9, "with -swap, the alias is not swapped if the new index holds fewer than this ratio of the documents it replaces")
+	flag.Parse()
+
+	cfg, err := config.Load(*configFile)
+	if err != nil {
+		log.ErrorC("errored getting configuration", err, log.Data{"config": cfg, "config_file": *configFile})
+		os.Exit(1)
+	}
+
+	if *index == "" {
+		*index = cfg.ElasticSearchConfig.DestIndex
+	}
+
+	if *kis == "" && flag.NArg() == 0 {
+		fmt.Fprintln(os.Stderr, "usage: indexer [flags] [-kis dir] [file.json ...]")
+		flag.PrintDefaults()
+		os.Exit(1)
+	}
+	if *batchSize < 1 {
+		log.Error(errors.New("batch size must be at least 1"), log.Data{"batch_size": *batchSize})
+		os.Exit(1)
+	}
+	if *keep < 0 {
+		log.Error(errors.New("the number of indices kept cannot be negative"), log.Data{"keep": *keep})
+		os.Exit(1)
+	}
+
+	report := &indexer.Report{}
+
+	courses, err := read(*kis, flag.Args(), report)
+	if err != nil {
+		log.ErrorC("errored reading courses", err, nil)
+		os.Exit(1)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGINT, syscall.SIGTERM)
+	go func() {
+		signal := <-signals
+		log.Info("os signal received, stopping", log.Data{"os_signal": signal})
+		cancel()
+	}()
+
+	requestSigner, err := signer.New(cfg.ElasticSearchConfig)
+	if err != nil {
+		log.ErrorC("errored configuring elasticsearch request signing", err, log.Data{"signer": cfg.ElasticSearchConfig.SignerName()})
+		os.Exit(1)
+	}
+
+	tlsConfig, err := elasticsearch.NewTLSConfig(cfg.ElasticSearchConfig.TLSCAFile, cfg.ElasticSearchConfig.TLSCertFile, cfg.ElasticSearchConfig.TLSKeyFile, cfg.ElasticSearchConfig.TLSServerName, cfg.ElasticSearchConfig.TLSMinVersion)
+	if err != nil {
+		log.ErrorC("errored configuring tls for elasticsearch", err, log.Data{"ca_file": cfg.ElasticSearchConfig.TLSCAFile, "cert_file": cfg.ElasticSearchConfig.TLSCertFile, "key_file": cfg.ElasticSearchConfig.TLSKeyFile})
+		os.Exit(1)
+	}
+
+	breaker := elasticsearch.NewCircuitBreaker(cfg.ElasticSearchConfig.CircuitBreakerThreshold, cfg.ElasticSearchConfig.CircuitBreakerTimeout)
+	es := elasticsearch.NewElasticSearchAPI(elasticsearch.NewHTTPClient(tlsConfig), cfg.ElasticSearchConfig.DestURL, requestSigner, breaker, cfg.ElasticSearchConfig.SlowQueryThreshold)
+
+	var loadErr error
+	if *swap {
+		name := indexer.IndexName(*index, time.Now())
+		loader := indexer.NewLoader(es, name, *batchSize, *retries, *retryInterval)
+
+		log.Info("building index", log.Data{"index": name, "alias": *index, "courses": len(courses)})
+
+		loadErr = indexer.NewBuilder(es, loader, *index, *keep, *minRatio).Build(ctx, courses, report)
+	} else {
+		loader := indexer.NewLoader(es, *index, *batchSize, *retries, *retryInterval)
+
+		created, err := loader.CreateIndex(ctx)
+		if err != nil {
+			log.ErrorC("errored creating index", err, log.Data{"index": *index})
+			os.Exit(1)
+		}
+		log.Info("loading courses", log.Data{"index": *index, "created": created, "courses": len(courses)})
+
+		loadErr = loader.Load(ctx, courses, report)
+	}
+
+	if err = writeReport(report, *reportFile); err != nil {
+		log.ErrorC("errored writing report", err, log.Data{"report": *reportFile})
+		os.Exit(1)
+	}
+
+	if loadErr != nil {
+		log.ErrorC("errored loading courses", loadErr, log.Data{"index": *index})
+		os.Exit(1)
+	}
+
+	log.Info("loaded courses", log.Data{"index": report.Index, "read": report.Read, "indexed": report.Indexed, "rejected": len(report.Rejected), "failed": len(report.Failed)})
+
+	if !report.OK() {
+		os.Exit(2)
+	}
+}
+
+// read returns the courses in the KIS export and each of the files, adding those
+// rejected to the report
+func read(kis string, files []string, report *indexer.Report) ([]*indexer.Course, error) {
+	var courses []*indexer.Course
+
+	add := func(read []*indexer.Course, rejected []indexer.Rejected) {
+		courses = append(courses, read...)
+		report.Read += len(read) + len(rejected)
+		report.Rejected = append(report.Rejected, rejected...)
+	}
+
+	if kis != "" {
+		read, rejected, err := indexer.ReadKIS(kis)
+		if err != nil {
+			return nil, errors.WithMessage(err, "failed to read KIS export "+kis)
+		}
+		add(read, rejected)
+	}
+
+	for _, file := range files {
+		read, rejected, err := indexer.ReadFile(file)
+		if err != nil {
+			return nil, err
+		}
+		add(read, rejected)
+	}
+
+	return courses, nil
+}
+
+func writeReport(report *indexer.Report, file string) error {
+	b, err := json.MarshalIndent(report, "", "  ")
+	if err != nil {
+		return errors.Wrap(err, "failed to marshal report")
+	}
+	b = append(b, '\n')
+
+	return ioutil.WriteFile(file, b, 0644)
+}
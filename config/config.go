@@ -2,55 +2,236 @@ package config
 
 import (
 	"encoding/json"
+	"io/ioutil"
+	"net/url"
 	"time"
 
 	"github.com/kelseyhightower/envconfig"
+	"github.com/pkg/errors"
+	yaml "gopkg.in/yaml.v2"
 )
 
 // Configuration structure which hold information for configuring the datasetAPI
 type Configuration struct {
-	BindAddr                string        `envconfig:"BIND_ADDR"`
-	DefaultMaxResults       int           `envconfig:"DEFAULT_MAX_RESULTS"`
-	GracefulShutdownTimeout time.Duration `envconfig:"GRACEFUL_SHUTDOWN_TIMEOUT"`
-	Host                    string        `envconfig:"HOST_NAME"`
-	ElasticSearchConfig     *ElasticSearchConfig
+	APIKeyRequired          bool                 `envconfig:"API_KEY_REQUIRED" yaml:"api_key_required"`
+	APIKeysFile             string               `envconfig:"API_KEYS_FILE" yaml:"api_keys_file"`
+	BindAddr                string               `envconfig:"BIND_ADDR" yaml:"bind_addr"`
+	CacheAliasCheckInterval time.Duration        `envconfig:"CACHE_ALIAS_CHECK_INTERVAL" yaml:"cache_alias_check_interval"`
+	CacheSize               int                  `envconfig:"CACHE_SIZE" yaml:"cache_size"`
+	CacheTTL                time.Duration        `envconfig:"CACHE_TTL" yaml:"cache_ttl"`
+	CompressionEnabled      bool                 `envconfig:"COMPRESSION_ENABLED" yaml:"compression_enabled"`
+	CompressionLevel        int                  `envconfig:"COMPRESSION_LEVEL" yaml:"compression_level"`
+	CompressionMinSize      int                  `envconfig:"COMPRESSION_MIN_SIZE" yaml:"compression_min_size"`
+	CORSAllowCredentials    bool                 `envconfig:"CORS_ALLOW_CREDENTIALS" yaml:"cors_allow_credentials"`
+	CORSAllowedHeaders      []string             `envconfig:"CORS_ALLOWED_HEADERS" yaml:"cors_allowed_headers"`
+	CORSAllowedMethods      []string             `envconfig:"CORS_ALLOWED_METHODS" yaml:"cors_allowed_methods"`
+	CORSAllowedOrigins      []string             `envconfig:"CORS_ALLOWED_ORIGINS" yaml:"cors_allowed_origins"`
+	CORSExposedHeaders      []string             `envconfig:"CORS_EXPOSED_HEADERS" yaml:"cors_exposed_headers"`
+	CORSMaxAge              time.Duration        `envconfig:"CORS_MAX_AGE" yaml:"cors_max_age"`
+	DebugCaptureSampleRate  float64              `envconfig:"DEBUG_CAPTURE_SAMPLE_RATE" yaml:"debug_capture_sample_rate"`
+	DebugCaptureToken       string               `envconfig:"DEBUG_CAPTURE_TOKEN" yaml:"debug_capture_token" json:"-"`
+	DefaultMaxResults       int                  `envconfig:"DEFAULT_MAX_RESULTS" yaml:"default_max_results"`
+	GracefulShutdownTimeout time.Duration        `envconfig:"GRACEFUL_SHUTDOWN_TIMEOUT" yaml:"graceful_shutdown_timeout"`
+	HealthCheckInterval     time.Duration        `envconfig:"HEALTHCHECK_INTERVAL" yaml:"healthcheck_interval"`
+	Host                    string               `envconfig:"HOST_NAME" yaml:"host_name"`
+	HTTPCacheControl        string               `envconfig:"HTTP_CACHE_CONTROL" yaml:"http_cache_control"`
+	HTTPVary                string               `envconfig:"HTTP_VARY" yaml:"http_vary"`
+	LogLevel                string               `envconfig:"LOG_LEVEL" yaml:"log_level"`
+	LogMaxPayloadSize       int                  `envconfig:"LOG_MAX_PAYLOAD_SIZE" yaml:"log_max_payload_size"`
+	LogRedactFields         []string             `envconfig:"LOG_REDACT_FIELDS" yaml:"log_redact_fields"`
+	OpenAPIValidation       string               `envconfig:"OPENAPI_VALIDATION" yaml:"openapi_validation"`
+	RateLimitIPBurst        int                  `envconfig:"RATE_LIMIT_IP_BURST" yaml:"rate_limit_ip_burst"`
+	RateLimitIPRate         float64              `envconfig:"RATE_LIMIT_IP_RATE" yaml:"rate_limit_ip_rate"`
+	RateLimitKeyBurst       int                  `envconfig:"RATE_LIMIT_KEY_BURST" yaml:"rate_limit_key_burst"`
+	RateLimitKeyRate        float64              `envconfig:"RATE_LIMIT_KEY_RATE" yaml:"rate_limit_key_rate"`
+	RateLimitTrustForwarded bool                 `envconfig:"RATE_LIMIT_TRUST_FORWARDED" yaml:"rate_limit_trust_forwarded"`
+	RateLimitTrustedProxies int                  `envconfig:"RATE_LIMIT_TRUSTED_PROXIES" yaml:"rate_limit_trusted_proxies"`
+	SearchBackend           string               `envconfig:"SEARCH_BACKEND" yaml:"search_backend"`
+	SearchFixtureFile       string               `envconfig:"SEARCH_FIXTURE_FILE" yaml:"search_fixture_file"`
+	ShutdownReadinessDelay  time.Duration        `envconfig:"SHUTDOWN_READINESS_DELAY" yaml:"shutdown_readiness_delay"`
+	TracingExporter         string               `envconfig:"TRACING_EXPORTER" yaml:"tracing_exporter"`
+	TracingFile             string               `envconfig:"TRACING_FILE" yaml:"tracing_file"`
+	ElasticSearchConfig     *ElasticSearchConfig `yaml:"elasticsearch"`
 }
 
 // ElasticSearchConfig structure which contains information to access mongo datastore
 type ElasticSearchConfig struct {
-	DestURL        string `envconfig:"ES_DESTINATION_URL"`
-	DestIndex      string `envconfig:"ES_DESTINATION_INDEX"`
-	ShowScore      bool   `envconfig:"ES_SHOW_SCORE"`
-	SignedRequests bool   `envconfig:"ES_SIGNED_REQUESTS"`
+	DestURL                 string        `envconfig:"ES_DESTINATION_URL" yaml:"destination_url"`
+	DestIndex               string        `envconfig:"ES_DESTINATION_INDEX" yaml:"destination_index"`
+	ShowScore               bool          `envconfig:"ES_SHOW_SCORE" yaml:"show_score"`
+	SignedRequests          bool          `envconfig:"ES_SIGNED_REQUESTS" yaml:"signed_requests"`
+	Signer                  string        `envconfig:"ES_SIGNER" yaml:"signer"`
+	AWSRegion               string        `envconfig:"ES_AWS_REGION" yaml:"aws_region"`
+	AWSService              string        `envconfig:"ES_AWS_SERVICE" yaml:"aws_service"`
+	AWSAccessKeyID          string        `envconfig:"ES_AWS_ACCESS_KEY_ID" yaml:"aws_access_key_id"`
+	AWSSecretAccessKey      string        `envconfig:"ES_AWS_SECRET_ACCESS_KEY" yaml:"aws_secret_access_key" json:"-"`
+	AWSSessionToken         string        `envconfig:"ES_AWS_SESSION_TOKEN" yaml:"aws_session_token" json:"-"`
+	AWSCredentialsFile      string        `envconfig:"ES_AWS_CREDENTIALS_FILE" yaml:"aws_credentials_file"`
+	AWSProfile              string        `envconfig:"ES_AWS_PROFILE" yaml:"aws_profile"`
+	AWSRoleARN              string        `envconfig:"ES_AWS_ROLE_ARN" yaml:"aws_role_arn"`
+	AWSRoleSessionName      string        `envconfig:"ES_AWS_ROLE_SESSION_NAME" yaml:"aws_role_session_name"`
+	AWSWebIdentityTokenFile string        `envconfig:"ES_AWS_WEB_IDENTITY_TOKEN_FILE" yaml:"aws_web_identity_token_file"`
+	Username                string        `envconfig:"ES_USERNAME" yaml:"username"`
+	Password                string        `envconfig:"ES_PASSWORD" yaml:"password" json:"-"`
+	APIKey                  string        `envconfig:"ES_API_KEY" yaml:"api_key" json:"-"`
+	TLSCAFile               string        `envconfig:"ES_TLS_CA_FILE" yaml:"tls_ca_file"`
+	TLSCertFile             string        `envconfig:"ES_TLS_CERT_FILE" yaml:"tls_cert_file"`
+	TLSKeyFile              string        `envconfig:"ES_TLS_KEY_FILE" yaml:"tls_key_file"`
+	TLSServerName           string        `envconfig:"ES_TLS_SERVER_NAME" yaml:"tls_server_name"`
+	TLSMinVersion           string        `envconfig:"ES_TLS_MIN_VERSION" yaml:"tls_min_version"`
+	StartupRetryInterval    time.Duration `envconfig:"ES_STARTUP_RETRY_INTERVAL" yaml:"startup_retry_interval"`
+	StartupTimeout          time.Duration `envconfig:"ES_STARTUP_TIMEOUT" yaml:"startup_timeout"`
+	CircuitBreakerThreshold int           `envconfig:"ES_CIRCUIT_BREAKER_THRESHOLD" yaml:"circuit_breaker_threshold"`
+	CircuitBreakerTimeout   time.Duration `envconfig:"ES_CIRCUIT_BREAKER_TIMEOUT" yaml:"circuit_breaker_timeout"`
+	SlowQueryThreshold      time.Duration `envconfig:"ES_SLOW_QUERY_THRESHOLD" yaml:"slow_query_threshold"`
+	MappingCheck            string        `envconfig:"ES_MAPPING_CHECK" yaml:"mapping_check"`
 }
 
-var cfg *Configuration
+// A list of backends which searches can be made against
+const (
+	BackendElasticsearch = "elasticsearch"
+	BackendMemory        = "memory"
+)
 
-// Get the application and returns the configuration structure
-func Get() (*Configuration, error) {
-	if cfg != nil {
-		return cfg, nil
-	}
+// A list of ways requests to elasticsearch can be signed
+const (
+	SignerNone   = "none"
+	SignerAWS    = "aws"
+	SignerBasic  = "basic"
+	SignerAPIKey = "api_key"
+)
 
-	cfg = &Configuration{
+// A list of modes for checks which can be turned off, only log problems or
+// enforce them
+const (
+	ModeOff     = "off"
+	ModeLog     = "log"
+	ModeEnforce = "enforce"
+)
+
+// redacted replaces the value of secrets when the configuration is shown
+const redacted = "[REDACTED]"
+
+// Default returns a new configuration holding the default values, without
+// reading any file or environment variables
+func Default() *Configuration {
+	return &Configuration{
 		BindAddr:                ":10100",
+		CacheAliasCheckInterval: 30 * time.Second,
+		CacheSize:               1000,
+		CacheTTL:                5 * time.Minute,
+		CompressionEnabled:      true,
+		CompressionLevel:        -1,
+		CompressionMinSize:      1024,
+		CORSAllowedHeaders:      []string{"Content-Type", "If-None-Match", "X-Api-Key", "X-Request-Id"},
+		CORSAllowedMethods:      []string{"GET", "OPTIONS"},
+		CORSAllowedOrigins:      []string{"*"},
+		CORSExposedHeaders:      []string{"ETag", "Retry-After", "X-RateLimit-Limit", "X-RateLimit-Remaining", "X-RateLimit-Reset", "X-Request-Id"},
+		CORSMaxAge:              10 * time.Minute,
 		DefaultMaxResults:       1000,
 		GracefulShutdownTimeout: 5 * time.Second,
+		HealthCheckInterval:     10 * time.Second,
 		Host:                    "http://localhost",
+		HTTPCacheControl:        "public, max-age=300",
+		HTTPVary:                "Accept-Encoding",
+		LogLevel:                "info",
+		LogMaxPayloadSize:       1024,
+		LogRedactFields:         []string{"authorization", "password", "secret", "token"},
+		OpenAPIValidation:       ModeOff,
+		RateLimitIPBurst:        20,
+		RateLimitKeyBurst:       100,
+		RateLimitKeyRate:        50,
+		RateLimitTrustedProxies: 1,
+		SearchBackend:           BackendElasticsearch,
+		ShutdownReadinessDelay:  2 * time.Second,
+		TracingExporter:         "none",
+		TracingFile:             "traces.json",
 		ElasticSearchConfig: &ElasticSearchConfig{
-			DestURL:        "http://localhost:9200",
-			DestIndex:      "courses",
-			ShowScore:      false,
-			SignedRequests: true,
+			DestURL:                 "http://localhost:9200",
+			DestIndex:               "courses",
+			ShowScore:               false,
+			SignedRequests:          true,
+			AWSRoleSessionName:      "alpha-search-api",
+			StartupRetryInterval:    5 * time.Second,
+			StartupTimeout:          2 * time.Minute,
+			CircuitBreakerThreshold: 5,
+			CircuitBreakerTimeout:   30 * time.Second,
+			SlowQueryThreshold:      time.Second,
+			MappingCheck:            ModeLog,
+			TLSMinVersion:           "1.2",
 		},
 	}
+}
+
+// Load returns the default configuration overridden by the YAML or JSON file given,
+// if any, and then by environment variables. The configuration is returned along
+// with an error listing every invalid value, so that it can still be shown
+func Load(file string) (*Configuration, error) {
+	cfg := Default()
+
+	if file != "" {
+		b, err := ioutil.ReadFile(file)
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to read config file")
+		}
+
+		// Unknown keys are rejected so that misspelt settings are not silently ignored
+		if err = yaml.UnmarshalStrict(b, cfg); err != nil {
+			return nil, errors.Wrapf(err, "failed to parse config file %s", file)
+		}
+	}
+
+	if err := envconfig.Process("", cfg); err != nil {
+		return nil, errors.Wrap(err, "failed to read config from environment variables")
+	}
+
+	return cfg, cfg.Validate()
+}
+
+// Redacted returns a copy of the configuration with secrets replaced, so that it
+// can be shown or logged
+func (config Configuration) Redacted() Configuration {
+	if config.DebugCaptureToken != "" {
+		config.DebugCaptureToken = redacted
+	}
+
+	if config.ElasticSearchConfig != nil {
+		es := *config.ElasticSearchConfig
+		if u, err := url.Parse(es.DestURL); err == nil && u.User != nil {
+			es.DestURL = u.Redacted()
+		}
+		for _, secret := range []*string{&es.AWSSecretAccessKey, &es.AWSSessionToken, &es.Password, &es.APIKey} {
+			if *secret != "" {
+				*secret = redacted
+			}
+		}
+		config.ElasticSearchConfig = &es
+	}
+
+	return config
+}
+
+// SignerName returns how requests to elasticsearch are signed, falling back to
+// ES_SIGNED_REQUESTS when ES_SIGNER is not given
+func (es *ElasticSearchConfig) SignerName() string {
+	if es.Signer != "" {
+		return es.Signer
+	}
+	if es.SignedRequests {
+		return SignerAWS
+	}
+
+	return SignerNone
+}
 
-	return cfg, envconfig.Process("", cfg)
+// YAML returns the configuration in the format of a config file, with secrets redacted
+func (config Configuration) YAML() ([]byte, error) {
+	return yaml.Marshal(config.Redacted())
 }
 
 // String is implemented to prevent sensitive fields being logged.
 // The config is returned as JSON with sensitive fields omitted.
 func (config Configuration) String() string {
-	json, _ := json.Marshal(config)
+	json, _ := json.Marshal(config.Redacted())
 	return string(json)
 }
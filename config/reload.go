@@ -0,0 +1,67 @@
+package config
+
+import (
+	"reflect"
+)
+
+// Reloadable lists the settings, by environment variable, which are applied when
+// the configuration is reloaded while the api is running. Changes to any other
+// setting are only applied on restart. There are no search boost or synonym
+// settings to reload, synonyms would be part of the index analysis
+var Reloadable = []string{
+	"API_KEY_REQUIRED",
+	"API_KEYS_FILE",
+	"CACHE_TTL",
+	"CORS_ALLOWED_ORIGINS",
+	"DEFAULT_MAX_RESULTS",
+	"LOG_LEVEL",
+	"LOG_MAX_PAYLOAD_SIZE",
+	"LOG_REDACT_FIELDS",
+	"RATE_LIMIT_IP_BURST",
+	"RATE_LIMIT_IP_RATE",
+	"RATE_LIMIT_KEY_BURST",
+	"RATE_LIMIT_KEY_RATE",
+}
+
+// IsReloadable returns true if the setting named by its environment variable is
+// applied when the configuration is reloaded
+func IsReloadable(name string) bool {
+	for _, reloadable := range Reloadable {
+		if name == reloadable {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Changed returns the settings, by environment variable, whose values differ
+// between the configurations, in the order they are declared
+func (config *Configuration) Changed(other *Configuration) []string {
+	return changed(reflect.ValueOf(config).Elem(), reflect.ValueOf(other).Elem())
+}
+
+func changed(a, b reflect.Value) []string {
+	var names []string
+	for i := 0; i < a.NumField(); i++ {
+		x, y := a.Field(i), b.Field(i)
+
+		// Nested settings, such as those for elasticsearch, are compared field by field
+		if x.Kind() == reflect.Ptr && x.Type().Elem().Kind() == reflect.Struct {
+			if x.IsNil() || y.IsNil() {
+				if x.IsNil() != y.IsNil() {
+					names = append(names, a.Type().Field(i).Name)
+				}
+				continue
+			}
+			names = append(names, changed(x.Elem(), y.Elem())...)
+			continue
+		}
+
+		if !reflect.DeepEqual(x.Interface(), y.Interface()) {
+			names = append(names, a.Type().Field(i).Tag.Get("envconfig"))
+		}
+	}
+
+	return names
+}
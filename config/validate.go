@@ -0,0 +1,192 @@
+package config
+
+import (
+	"compress/gzip"
+	"fmt"
+	"net"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/ofs/alpha-search-api/logging"
+	"github.com/ofs/alpha-search-api/tracing"
+)
+
+// ValidationError lists every invalid value in a configuration, naming each by
+// its environment variable
+type ValidationError []string
+
+func (v ValidationError) Error() string {
+	return "invalid configuration: " + strings.Join(v, "; ")
+}
+
+// validator gathers problems with values as they are checked
+type validator struct {
+	problems ValidationError
+}
+
+func (v *validator) addf(format string, args ...interface{}) {
+	v.problems = append(v.problems, fmt.Sprintf(format, args...))
+}
+
+func (v *validator) min(name string, value, min int) {
+	if value < min {
+		v.addf("%s must be at least %d, got %d", name, min, value)
+	}
+}
+
+func (v *validator) between(name string, value, min, max float64) {
+	if value < min || value > max {
+		v.addf("%s must be between %g and %g, got %g", name, min, max, value)
+	}
+}
+
+func (v *validator) notNegative(name string, value time.Duration) {
+	if value < 0 {
+		v.addf("%s cannot be negative, got %v", name, value)
+	}
+}
+
+func (v *validator) positive(name string, value time.Duration) {
+	if value <= 0 {
+		v.addf("%s must be greater than 0, got %v", name, value)
+	}
+}
+
+func (v *validator) given(name, value string) {
+	if value == "" {
+		v.addf("%s must be given", name)
+	}
+}
+
+// oneOf checks the value is one of those allowed, or not given where the empty
+// value is treated as the first of them
+func (v *validator) oneOf(name, value string, allowed ...string) {
+	if value == "" {
+		return
+	}
+	for _, a := range allowed {
+		if value == a {
+			return
+		}
+	}
+	v.addf("%s must be one of %s, got [%s]", name, strings.Join(allowed, ", "), value)
+}
+
+// url checks the value is an absolute http or https url
+func (v *validator) url(name, value string) {
+	u, err := url.Parse(value)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		v.addf("%s must be an absolute http or https url, got [%s]", name, value)
+	}
+}
+
+// Validate returns a ValidationError listing every invalid value, or nil if the
+// configuration is valid
+func (config *Configuration) Validate() error {
+	v := &validator{}
+
+	if _, _, err := net.SplitHostPort(config.BindAddr); err != nil {
+		v.addf("BIND_ADDR must be a host and port, such as :10100, got [%s]", config.BindAddr)
+	}
+	if config.APIKeyRequired && config.APIKeysFile == "" {
+		v.addf("API_KEYS_FILE must be given when API_KEY_REQUIRED is set")
+	}
+
+	v.notNegative("CACHE_ALIAS_CHECK_INTERVAL", config.CacheAliasCheckInterval)
+	v.min("CACHE_SIZE", config.CacheSize, 0)
+	v.notNegative("CACHE_TTL", config.CacheTTL)
+
+	if config.CompressionLevel < gzip.HuffmanOnly || config.CompressionLevel > gzip.BestCompression {
+		v.addf("COMPRESSION_LEVEL must be between %d and %d, got %d", gzip.HuffmanOnly, gzip.BestCompression, config.CompressionLevel)
+	}
+	v.min("COMPRESSION_MIN_SIZE", config.CompressionMinSize, 0)
+
+	v.notNegative("CORS_MAX_AGE", config.CORSMaxAge)
+	if config.CORSAllowCredentials {
+		for _, origin := range config.CORSAllowedOrigins {
+			if strings.TrimSpace(origin) == "*" {
+				v.addf("CORS_ALLOWED_ORIGINS cannot contain * when CORS_ALLOW_CREDENTIALS is set, list the origins allowed credentials")
+				break
+			}
+		}
+	}
+	v.between("DEBUG_CAPTURE_SAMPLE_RATE", config.DebugCaptureSampleRate, 0, 1)
+	v.min("DEFAULT_MAX_RESULTS", config.DefaultMaxResults, 1)
+	v.notNegative("GRACEFUL_SHUTDOWN_TIMEOUT", config.GracefulShutdownTimeout)
+	v.notNegative("SHUTDOWN_READINESS_DELAY", config.ShutdownReadinessDelay)
+	if config.ShutdownReadinessDelay > 0 && config.ShutdownReadinessDelay >= config.GracefulShutdownTimeout {
+		v.addf("SHUTDOWN_READINESS_DELAY must be less than GRACEFUL_SHUTDOWN_TIMEOUT, which it is taken from, got %v and %v", config.ShutdownReadinessDelay, config.GracefulShutdownTimeout)
+	}
+	v.notNegative("HEALTHCHECK_INTERVAL", config.HealthCheckInterval)
+	v.url("HOST_NAME", config.Host)
+
+	v.given("LOG_LEVEL", config.LogLevel)
+	v.oneOf("LOG_LEVEL", strings.ToLower(config.LogLevel), logging.LevelTrace, logging.LevelDebug, logging.LevelInfo, logging.LevelError)
+	v.min("LOG_MAX_PAYLOAD_SIZE", config.LogMaxPayloadSize, 0)
+	v.oneOf("OPENAPI_VALIDATION", config.OpenAPIValidation, ModeOff, ModeLog, ModeEnforce)
+
+	v.min("RATE_LIMIT_IP_BURST", config.RateLimitIPBurst, 0)
+	if config.RateLimitIPRate < 0 {
+		v.addf("RATE_LIMIT_IP_RATE cannot be negative, got %g", config.RateLimitIPRate)
+	}
+	v.min("RATE_LIMIT_KEY_BURST", config.RateLimitKeyBurst, 0)
+	v.min("RATE_LIMIT_TRUSTED_PROXIES", config.RateLimitTrustedProxies, 1)
+	if config.RateLimitKeyRate < 0 {
+		v.addf("RATE_LIMIT_KEY_RATE cannot be negative, got %g", config.RateLimitKeyRate)
+	}
+
+	v.given("SEARCH_BACKEND", config.SearchBackend)
+	v.oneOf("SEARCH_BACKEND", config.SearchBackend, BackendElasticsearch, BackendMemory)
+	if config.SearchBackend == BackendMemory && config.SearchFixtureFile == "" {
+		v.addf("SEARCH_FIXTURE_FILE must be given when SEARCH_BACKEND is %s", BackendMemory)
+	}
+
+	v.oneOf("TRACING_EXPORTER", config.TracingExporter, tracing.ExporterNone, tracing.ExporterStdout, tracing.ExporterFile)
+	if config.TracingExporter == tracing.ExporterFile && config.TracingFile == "" {
+		v.addf("TRACING_FILE must be given when TRACING_EXPORTER is %s", tracing.ExporterFile)
+	}
+
+	if es := config.ElasticSearchConfig; es != nil {
+		v.url("ES_DESTINATION_URL", es.DestURL)
+		v.given("ES_DESTINATION_INDEX", es.DestIndex)
+		v.positive("ES_STARTUP_RETRY_INTERVAL", es.StartupRetryInterval)
+		v.notNegative("ES_STARTUP_TIMEOUT", es.StartupTimeout)
+		v.min("ES_CIRCUIT_BREAKER_THRESHOLD", es.CircuitBreakerThreshold, 0)
+		v.notNegative("ES_CIRCUIT_BREAKER_TIMEOUT", es.CircuitBreakerTimeout)
+		v.notNegative("ES_SLOW_QUERY_THRESHOLD", es.SlowQueryThreshold)
+		v.oneOf("ES_MAPPING_CHECK", es.MappingCheck, ModeOff, ModeLog, ModeEnforce)
+		v.oneOf("ES_SIGNER", es.Signer, SignerNone, SignerAWS, SignerBasic, SignerAPIKey)
+		v.oneOf("ES_TLS_MIN_VERSION", es.TLSMinVersion, "1.0", "1.1", "1.2", "1.3")
+		if (es.TLSCertFile == "") != (es.TLSKeyFile == "") {
+			v.addf("ES_TLS_CERT_FILE and ES_TLS_KEY_FILE must be given together")
+		}
+
+		switch es.SignerName() {
+		case SignerAWS:
+			if (es.AWSAccessKeyID == "") != (es.AWSSecretAccessKey == "") {
+				v.addf("ES_AWS_ACCESS_KEY_ID and ES_AWS_SECRET_ACCESS_KEY must be given together")
+			}
+			if es.AWSWebIdentityTokenFile != "" && es.AWSRoleARN == "" {
+				v.addf("ES_AWS_ROLE_ARN must be given with ES_AWS_WEB_IDENTITY_TOKEN_FILE")
+			}
+			if es.AWSRoleARN != "" {
+				v.given("ES_AWS_ROLE_SESSION_NAME", es.AWSRoleSessionName)
+			}
+		case SignerBasic:
+			if es.Username == "" {
+				v.addf("ES_USERNAME must be given when ES_SIGNER is %s", SignerBasic)
+			}
+		case SignerAPIKey:
+			if es.APIKey == "" {
+				v.addf("ES_API_KEY must be given when ES_SIGNER is %s", SignerAPIKey)
+			}
+		}
+	}
+
+	if len(v.problems) > 0 {
+		return v.problems
+	}
+
+	return nil
+}
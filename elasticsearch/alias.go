@@ -0,0 +1,150 @@
+package elasticsearch
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/methods/go-methods-lib/log"
+	errs "github.com/ofs/alpha-search-api/apierrors"
+	"github.com/pkg/errors"
+)
+
+// ResolveAlias returns the comma separated, sorted names of the indices behind an
+// alias, or the name itself if it refers to a concrete index rather than an alias
+func (api *API) ResolveAlias(ctx context.Context, alias string) (string, error) {
+	path := api.url + "/_alias/" + alias
+
+	logData := log.Data{"path": path}
+
+	responseBody, status, err := api.CallElastic(ctx, path, "GET", nil)
+	logData["status"] = status
+	if err != nil {
+		if status == http.StatusNotFound {
+			if err = api.IndexExists(ctx, alias); err == nil {
+				return alias, nil
+			}
+		}
+
+		log.ErrorCtx(ctx, errors.WithMessage(err, "failed to resolve elasticsearch alias"), logData)
+		return "", err
+	}
+
+	indices := make(map[string]interface{})
+	if err = json.Unmarshal(responseBody, &indices); err != nil {
+		log.ErrorCtx(ctx, errors.WithMessage(err, "unable to unmarshal json body"), logData)
+		return "", errs.ErrUnmarshallingJSON
+	}
+
+	var names []string
+	for name := range indices {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	return strings.Join(names, ","), nil
+}
+
+// resolveTimeout is the maximum time taken to resolve an alias
+const resolveTimeout = 5 * time.Second
+
+// IndexWatcher periodically resolves an alias so that dependants can react
+// when the alias is swapped to a new index, e.g. after data is reloaded
+type IndexWatcher struct {
+	api      *API
+	alias    string
+	interval time.Duration
+
+	mu        sync.RWMutex
+	version   string
+	listeners []func(version string)
+
+	done chan struct{}
+	once sync.Once
+}
+
+// NewIndexWatcher creates a watcher for the given alias (or index)
+func NewIndexWatcher(api *API, alias string, interval time.Duration) *IndexWatcher {
+	return &IndexWatcher{
+		api:      api,
+		alias:    alias,
+		interval: interval,
+		done:     make(chan struct{}),
+	}
+}
+
+// OnChange registers a function called with the new version whenever the indices behind the alias change
+func (w *IndexWatcher) OnChange(fn func(version string)) {
+	w.mu.Lock()
+	w.listeners = append(w.listeners, fn)
+	w.mu.Unlock()
+}
+
+// Version returns the names of the indices last seen behind the alias
+func (w *IndexWatcher) Version() string {
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+
+	return w.version
+}
+
+// Start resolves the alias immediately and then at every interval until Close
+// is called; a zero interval resolves the alias once only
+func (w *IndexWatcher) Start() {
+	w.check()
+
+	if w.interval <= 0 {
+		return
+	}
+
+	go func() {
+		ticker := time.NewTicker(w.interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ticker.C:
+				w.check()
+			case <-w.done:
+				return
+			}
+		}
+	}()
+}
+
+// Close stops the watcher
+func (w *IndexWatcher) Close() {
+	w.once.Do(func() {
+		close(w.done)
+	})
+}
+
+func (w *IndexWatcher) check() {
+	ctx, cancel := context.WithTimeout(context.Background(), resolveTimeout)
+	defer cancel()
+
+	version, err := w.api.ResolveAlias(ctx, w.alias)
+	if err != nil {
+		return
+	}
+
+	w.mu.Lock()
+	previous := w.version
+	w.version = version
+	listeners := w.listeners
+	w.mu.Unlock()
+
+	if previous == "" || previous == version {
+		return
+	}
+
+	log.Info("index behind alias has changed", log.Data{"alias": w.alias, "previous": previous, "current": version})
+
+	for _, fn := range listeners {
+		fn(version)
+	}
+}
@@ -0,0 +1,86 @@
+package elasticsearch
+
+import (
+	"sync"
+	"time"
+)
+
+// CircuitBreaker stops calls being made to elasticsearch once a number of
+// consecutive calls have failed, allowing a single trial call through after
+// the timeout has elapsed to determine whether the cluster has recovered
+type CircuitBreaker struct {
+	mu        sync.Mutex
+	threshold int
+	timeout   time.Duration
+	failures  int
+	openedAt  time.Time
+}
+
+// NewCircuitBreaker creates a circuit breaker which opens after threshold
+// consecutive failures; a threshold of zero or less disables the breaker
+func NewCircuitBreaker(threshold int, timeout time.Duration) *CircuitBreaker {
+	return &CircuitBreaker{
+		threshold: threshold,
+		timeout:   timeout,
+	}
+}
+
+// Allow determines whether a call to elasticsearch can be made
+func (cb *CircuitBreaker) Allow() bool {
+	if cb == nil || cb.threshold <= 0 {
+		return true
+	}
+
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+
+	if cb.failures < cb.threshold {
+		return true
+	}
+
+	// Half open, let a trial call through and restart the timer so that
+	// concurrent callers continue to be rejected until it completes
+	if time.Since(cb.openedAt) >= cb.timeout {
+		cb.openedAt = time.Now()
+		return true
+	}
+
+	return false
+}
+
+// Success records a successful call, closing the breaker
+func (cb *CircuitBreaker) Success() {
+	if cb == nil {
+		return
+	}
+
+	cb.mu.Lock()
+	cb.failures = 0
+	cb.mu.Unlock()
+}
+
+// Failure records a failed call, opening the breaker once the threshold is reached
+func (cb *CircuitBreaker) Failure() {
+	if cb == nil {
+		return
+	}
+
+	cb.mu.Lock()
+	cb.failures++
+	if cb.failures == cb.threshold {
+		cb.openedAt = time.Now()
+	}
+	cb.mu.Unlock()
+}
+
+// IsOpen returns true if calls to elasticsearch are currently being rejected
+func (cb *CircuitBreaker) IsOpen() bool {
+	if cb == nil || cb.threshold <= 0 {
+		return false
+	}
+
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+
+	return cb.failures >= cb.threshold
+}
@@ -6,26 +6,43 @@ import (
 	"io/ioutil"
 	"net/http"
 	"net/url"
+	"sync"
+	"time"
 
+	"github.com/methods/go-methods-lib/common"
 	"github.com/methods/go-methods-lib/log"
 	errs "github.com/ofs/alpha-search-api/apierrors"
+	"github.com/ofs/alpha-search-api/logging"
+	"github.com/ofs/alpha-search-api/signer"
+	"github.com/ofs/alpha-search-api/tracing"
 	"github.com/pkg/errors"
-	awsauth "github.com/smartystreets/go-aws-auth"
 )
 
+// opaqueIDHeader is recorded by elasticsearch against tasks and slow log entries
+const opaqueIDHeader = "X-Opaque-Id"
+
 // API aggregates a client and URL and other common data for accessing the API
 type API struct {
-	client       http.Client
-	url          string
-	signRequests bool
+	breaker            *CircuitBreaker
+	client             http.Client
+	url                string
+	signer             signer.Signer
+	slowQueryThreshold time.Duration
+
+	// calls tracks the calls in flight so they can finish before shutting down
+	mu     sync.RWMutex
+	closed bool
+	calls  sync.WaitGroup
 }
 
-// NewElasticSearchAPI creates an API object
-func NewElasticSearchAPI(client http.Client, elasticSearchAPIURL string, signRequests bool) *API {
+// NewElasticSearchAPI creates an API object, requests are only signed if a signer is given
+func NewElasticSearchAPI(client http.Client, elasticSearchAPIURL string, requestSigner signer.Signer, breaker *CircuitBreaker, slowQueryThreshold time.Duration) *API {
 	return &API{
-		client:       client,
-		url:          elasticSearchAPIURL,
-		signRequests: signRequests,
+		breaker:            breaker,
+		client:             client,
+		url:                elasticSearchAPIURL,
+		signer:             requestSigner,
+		slowQueryThreshold: slowQueryThreshold,
 	}
 }
 
@@ -34,6 +51,7 @@ type Body struct {
 	From      int        `json:"from"`
 	Size      int        `json:"size"`
 	Highlight *Highlight `json:"highlight,omitempty"`
+	Profile   bool       `json:"profile,omitempty"`
 	Query     Query      `json:"query"`
 	Sort      []Criteria `json:"sort"`
 }
@@ -98,8 +116,26 @@ type Order struct {
 
 // CallElastic builds a request to elastic search based on the method, path and payload
 func (api *API) CallElastic(ctx context.Context, path, method string, payload interface{}) ([]byte, int, error) {
+	return api.call(ctx, path, method, payload, api.breaker)
+}
+
+// Ping calls the root of the cluster without going through the circuit breaker,
+// so that waiting for the cluster on startup neither opens it nor is held up by it
+func (api *API) Ping(ctx context.Context) (int, error) {
+	_, status, err := api.call(ctx, api.url, "GET", nil, nil)
+	return status, err
+}
+
+// call makes the request, recording its outcome in the circuit breaker if one is given
+func (api *API) call(ctx context.Context, path, method string, payload interface{}, breaker *CircuitBreaker) ([]byte, int, error) {
 	logData := log.Data{"url": path, "method": method}
 
+	if !api.begin() {
+		log.ErrorCtx(ctx, errors.WithMessage(errs.ErrShuttingDown, "call to elastic refused"), logData)
+		return nil, 0, errs.ErrShuttingDown
+	}
+	defer api.calls.Done()
+
 	URL, err := url.Parse(path)
 	if err != nil {
 		log.ErrorCtx(ctx, errors.WithMessage(err, "failed to create url for elastic call"), logData)
@@ -113,7 +149,9 @@ func (api *API) CallElastic(ctx context.Context, path, method string, payload in
 	if payload != nil {
 		req, err = http.NewRequest(method, path, bytes.NewReader(payload.([]byte)))
 		req.Header.Add("Content-type", "application/json")
-		logData["payload"] = string(payload.([]byte))
+		if logging.CaptureEnabled(ctx) {
+			logData["payload"] = string(payload.([]byte))
+		}
 	} else {
 		req, err = http.NewRequest(method, path, nil)
 	}
@@ -123,29 +161,118 @@ func (api *API) CallElastic(ctx context.Context, path, method string, payload in
 		return nil, 0, err
 	}
 
-	if api.signRequests {
-		awsauth.Sign(req)
+	op := operation(method, URL)
+
+	ctx, span := tracing.StartSpan(ctx, "elasticsearch "+op, tracing.KindClient)
+	defer span.End()
+
+	span.SetAttribute("db.system", "elasticsearch")
+	span.SetAttribute("db.operation", op)
+	span.SetAttribute("http.method", method)
+	span.SetAttribute("http.url", URL.Redacted())
+
+	req = req.WithContext(ctx)
+	tracing.Inject(ctx, req.Header)
+
+	// Allows slow log entries in elasticsearch to be correlated with requests to this api
+	if requestID := common.GetRequestId(ctx); requestID != "" {
+		req.Header.Set(opaqueIDHeader, requestID)
+	}
+
+	// Checked before signing so that credentials are not retrieved for calls which are refused
+	if !breaker.Allow() {
+		callErrorsTotal.Inc(op)
+		span.RecordError(errs.ErrCircuitBreakerOpen)
+		log.ErrorCtx(ctx, errs.ErrCircuitBreakerOpen, logData)
+		return nil, 0, errs.ErrCircuitBreakerOpen
+	}
+
+	if api.signer != nil {
+		body, _ := payload.([]byte)
+		if err = api.signer.Sign(req, body); err != nil {
+			callErrorsTotal.Inc(op)
+			span.RecordError(err)
+			log.ErrorCtx(ctx, errors.WithMessage(err, "failed to sign request to elastic"), logData)
+			return nil, 0, err
+		}
 	}
 
+	start := time.Now()
 	resp, err := api.client.Do(req)
+	callDuration.Observe(time.Since(start).Seconds(), op)
 	if err != nil {
+		// Callers giving up say nothing about the state of the cluster
+		if req.Context().Err() == nil {
+			breaker.Failure()
+		}
+		callErrorsTotal.Inc(op)
+		span.RecordError(err)
 		log.ErrorCtx(ctx, errors.WithMessage(err, "failed to call elastic"), logData)
 		return nil, 0, err
 	}
 	defer resp.Body.Close()
 
 	logData["status_code"] = resp.StatusCode
+	span.SetAttribute("http.status_code", resp.StatusCode)
+
+	if resp.StatusCode >= http.StatusInternalServerError {
+		breaker.Failure()
+	} else {
+		breaker.Success()
+	}
 
 	jsonBody, err := ioutil.ReadAll(resp.Body)
 	if err != nil {
+		callErrorsTotal.Inc(op)
+		span.RecordError(err)
 		log.ErrorCtx(ctx, errors.WithMessage(err, "failed to read response body from call to elastic"), logData)
 		return nil, resp.StatusCode, err
 	}
 
 	if resp.StatusCode < http.StatusOK || resp.StatusCode >= 300 {
+		callErrorsTotal.Inc(op)
+		span.RecordError(errs.ErrUnexpectedStatusCode)
 		log.ErrorCtx(ctx, errs.ErrUnexpectedStatusCode, logData)
 		return nil, resp.StatusCode, errs.ErrUnexpectedStatusCode
 	}
 
 	return jsonBody, resp.StatusCode, nil
 }
+
+// begin records a call as in flight, returning false if the api has been closed
+func (api *API) begin() bool {
+	api.mu.RLock()
+	defer api.mu.RUnlock()
+
+	if api.closed {
+		return false
+	}
+	api.calls.Add(1)
+
+	return true
+}
+
+// Close refuses any further calls to elasticsearch, waits for those in flight to
+// finish (or the context to be done) and then closes idle connections
+func (api *API) Close(ctx context.Context) error {
+	api.mu.Lock()
+	api.closed = true
+	api.mu.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		api.calls.Wait()
+		close(done)
+	}()
+
+	var err error
+	select {
+	case <-done:
+	case <-ctx.Done():
+		err = errors.Wrap(ctx.Err(), "timed out waiting for calls to elastic to finish")
+	}
+
+	api.client.CloseIdleConnections()
+
+	return err
+}
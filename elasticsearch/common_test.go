@@ -0,0 +1,358 @@
+package elasticsearch_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/methods/go-methods-lib/common"
+	errs "github.com/ofs/alpha-search-api/apierrors"
+	"github.com/ofs/alpha-search-api/config"
+	"github.com/ofs/alpha-search-api/elasticsearch"
+	"github.com/ofs/alpha-search-api/elasticsearch/estest"
+	"github.com/ofs/alpha-search-api/signer"
+	"github.com/ofs/alpha-search-api/tracing"
+)
+
+// newStub starts a stub replaying the fixture, failing the test if any request
+// made to it was not recorded
+func newStub(t *testing.T, fixture string) *estest.Server {
+	t.Helper()
+
+	stub, err := estest.NewServer(fixture)
+	if err != nil {
+		t.Fatalf("failed to start elasticsearch stub: %v", err)
+	}
+
+	t.Cleanup(func() {
+		for _, request := range stub.Unmatched() {
+			t.Errorf("unexpected request to elasticsearch: %s", request)
+		}
+		if err := stub.Close(); err != nil {
+			t.Errorf("failed to close elasticsearch stub: %v", err)
+		}
+	})
+
+	return stub
+}
+
+// newAPI returns an API calling the stub, with a circuit breaker that opens after two failures
+func newAPI(stub *estest.Server, url string, requestSigner signer.Signer) *elasticsearch.API {
+	if url == "" {
+		url = stub.URL
+	}
+
+	return elasticsearch.NewElasticSearchAPI(stub.Client(), url, requestSigner, elasticsearch.NewCircuitBreaker(2, time.Minute), time.Second)
+}
+
+func TestCallElasticStatuses(t *testing.T) {
+	stub := newStub(t, "")
+	stub.Add(
+		estest.Interaction{Request: estest.Request{Method: "GET", Path: "/ok"}, Response: estest.Response{Status: http.StatusOK, Body: json.RawMessage(`{"acknowledged":true}`)}},
+		estest.Interaction{Request: estest.Request{Method: "GET", Path: "/created"}, Response: estest.Response{Status: http.StatusCreated, Body: json.RawMessage(`{}`)}},
+		estest.Interaction{Request: estest.Request{Method: "GET", Path: "/missing"}, Response: estest.Response{Status: http.StatusNotFound, Body: json.RawMessage(`{"status":404}`)}},
+		estest.Interaction{Request: estest.Request{Method: "GET", Path: "/redirect"}, Response: estest.Response{Status: http.StatusNotModified}},
+	)
+
+	es := newAPI(stub, "", nil)
+
+	cases := []struct {
+		path   string
+		status int
+		body   string
+		err    error
+	}{
+		{"/ok", http.StatusOK, `{"acknowledged":true}`, nil},
+		{"/created", http.StatusCreated, `{}`, nil},
+		{"/missing", http.StatusNotFound, "", errs.ErrUnexpectedStatusCode},
+		{"/redirect", http.StatusNotModified, "", errs.ErrUnexpectedStatusCode},
+	}
+
+	for _, tc := range cases {
+		body, status, err := es.CallElastic(context.Background(), stub.URL+tc.path, "GET", nil)
+
+		if status != tc.status {
+			t.Errorf("%s: expected status %d, got %d", tc.path, tc.status, status)
+		}
+		if err != tc.err {
+			t.Errorf("%s: expected error %v, got %v", tc.path, tc.err, err)
+		}
+		if string(body) != tc.body {
+			t.Errorf("%s: expected body %q, got %q", tc.path, tc.body, body)
+		}
+	}
+
+	if es.CircuitBreakerOpen() {
+		t.Error("expected client errors not to open the circuit breaker")
+	}
+}
+
+func TestCallElasticCircuitBreaker(t *testing.T) {
+	stub := newStub(t, "")
+	stub.Add(estest.Interaction{Request: estest.Request{Method: "GET", Path: "/"}, Response: estest.Response{Status: http.StatusServiceUnavailable, Text: "unavailable"}})
+
+	es := newAPI(stub, "", nil)
+
+	for i := 0; i < 2; i++ {
+		if _, status, err := es.CallElastic(context.Background(), stub.URL+"/", "GET", nil); status != http.StatusServiceUnavailable || err != errs.ErrUnexpectedStatusCode {
+			t.Errorf("call %d: expected a 503 and unexpected status error, got %d and %v", i+1, status, err)
+		}
+	}
+
+	if !es.CircuitBreakerOpen() {
+		t.Fatal("expected the circuit breaker to open after repeated server errors")
+	}
+
+	if _, status, err := es.CallElastic(context.Background(), stub.URL+"/", "GET", nil); status != 0 || err != errs.ErrCircuitBreakerOpen {
+		t.Errorf("expected the call to be refused, got %d and %v", status, err)
+	}
+
+	if received := len(stub.Received()); received != 2 {
+		t.Errorf("expected 2 requests to reach elasticsearch, got %d", received)
+	}
+}
+
+// countingSigner counts the requests it is asked to sign
+type countingSigner struct {
+	signed int
+}
+
+func (s *countingSigner) Sign(req *http.Request, body []byte) error {
+	s.signed++
+	return nil
+}
+
+func TestCallElasticCircuitBreakerBeforeSigning(t *testing.T) {
+	stub := newStub(t, "")
+	stub.Add(estest.Interaction{Request: estest.Request{Method: "GET", Path: "/"}, Response: estest.Response{Status: http.StatusServiceUnavailable, Text: "unavailable"}})
+
+	requestSigner := &countingSigner{}
+	es := newAPI(stub, "", requestSigner)
+
+	for i := 0; i < 2; i++ {
+		es.CallElastic(context.Background(), stub.URL+"/", "GET", nil)
+	}
+
+	if _, _, err := es.CallElastic(context.Background(), stub.URL+"/", "GET", nil); err != errs.ErrCircuitBreakerOpen {
+		t.Fatalf("expected the call to be refused, got %v", err)
+	}
+	if requestSigner.signed != 2 {
+		t.Errorf("expected a refused call not to be signed, got %d requests signed", requestSigner.signed)
+	}
+}
+
+func TestCallElasticCanceled(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-r.Context().Done()
+	}))
+	defer server.Close()
+
+	es := elasticsearch.NewElasticSearchAPI(http.Client{}, server.URL, nil, elasticsearch.NewCircuitBreaker(2, time.Minute), time.Second)
+
+	for i := 0; i < 3; i++ {
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+		if _, _, err := es.CallElastic(ctx, server.URL+"/", "GET", nil); err == nil {
+			t.Errorf("call %d: expected the call to be abandoned", i+1)
+		}
+		cancel()
+	}
+
+	if es.CircuitBreakerOpen() {
+		t.Error("expected calls abandoned by the caller not to open the circuit breaker")
+	}
+}
+
+func TestPingIgnoresCircuitBreaker(t *testing.T) {
+	stub := newStub(t, "")
+	stub.Add(estest.Interaction{Request: estest.Request{Method: "GET", Path: "/"}, Response: estest.Response{Status: http.StatusServiceUnavailable, Text: "unavailable"}})
+
+	es := newAPI(stub, "", nil)
+
+	for i := 0; i < 3; i++ {
+		if status, err := es.Ping(context.Background()); status != http.StatusServiceUnavailable || err != errs.ErrUnexpectedStatusCode {
+			t.Errorf("ping %d: expected a 503, got %d and %v", i+1, status, err)
+		}
+	}
+	if es.CircuitBreakerOpen() {
+		t.Fatal("expected failed pings not to open the circuit breaker")
+	}
+
+	// Pings still reach the cluster once other calls have opened the breaker
+	for i := 0; i < 2; i++ {
+		es.CallElastic(context.Background(), stub.URL+"/", "GET", nil)
+	}
+	if status, _ := es.Ping(context.Background()); status != http.StatusServiceUnavailable {
+		t.Errorf("expected the ping to reach elasticsearch, got %d", status)
+	}
+	if received := len(stub.Received()); received != 6 {
+		t.Errorf("expected 6 requests to reach elasticsearch, got %d", received)
+	}
+}
+
+func TestCallElasticConnectionError(t *testing.T) {
+	stub := newStub(t, "")
+	es := newAPI(stub, "", nil)
+	stub.Close()
+
+	if _, status, err := es.CallElastic(context.Background(), stub.URL+"/", "GET", nil); status != 0 || err == nil {
+		t.Errorf("expected an error without a status, got %d and %v", status, err)
+	}
+}
+
+func TestCallElasticInvalidURL(t *testing.T) {
+	stub := newStub(t, "")
+	es := newAPI(stub, "", nil)
+
+	if _, status, err := es.CallElastic(context.Background(), "http://[::1", "GET", nil); status != 0 || err == nil {
+		t.Errorf("expected an error without a status, got %d and %v", status, err)
+	}
+
+	if received := len(stub.Received()); received != 0 {
+		t.Errorf("expected no requests to reach elasticsearch, got %d", received)
+	}
+}
+
+func TestCallElasticHeaders(t *testing.T) {
+	stub := newStub(t, "")
+	stub.Add(estest.Interaction{Request: estest.Request{Method: "POST", Path: "/courses/_search", Body: json.RawMessage(`{"size":0}`)}, Response: estest.Response{Status: http.StatusOK, Body: json.RawMessage(`{}`)}})
+
+	es := newAPI(stub, "", nil)
+	ctx := common.WithRequestId(context.Background(), "request-1")
+
+	if _, _, err := es.CallElastic(ctx, stub.URL+"/courses/_search", "POST", []byte(`{"size":0}`)); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	received := stub.Received()[0]
+	if contentType := received.Header.Get("Content-Type"); contentType != "application/json" {
+		t.Errorf("expected a json content type, got %q", contentType)
+	}
+	if opaqueID := received.Header.Get("X-Opaque-Id"); opaqueID != "request-1" {
+		t.Errorf("expected the request id to be sent as X-Opaque-Id, got %q", opaqueID)
+	}
+	if authorization := received.Header.Get("Authorization"); authorization != "" {
+		t.Errorf("expected an unsigned request, got %q", authorization)
+	}
+}
+
+func TestCallElasticPropagatesTrace(t *testing.T) {
+	stub := newStub(t, "")
+	stub.Add(estest.Interaction{Request: estest.Request{Method: "GET", Path: "/courses"}, Response: estest.Response{Status: http.StatusOK, Body: json.RawMessage(`{}`)}})
+
+	es := newAPI(stub, "", nil)
+
+	parent, ok := tracing.ParseTraceparent("00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	if !ok {
+		t.Fatal("failed to parse parent traceparent")
+	}
+	ctx := tracing.ContextWithRemoteSpanContext(context.Background(), parent)
+
+	if _, _, err := es.CallElastic(ctx, stub.URL+"/courses", "GET", nil); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	sent, ok := tracing.ParseTraceparent(stub.Received()[0].Header.Get(tracing.TraceparentHeader))
+	if !ok {
+		t.Fatalf("expected a valid traceparent header, got %q", stub.Received()[0].Header.Get(tracing.TraceparentHeader))
+	}
+	if sent.TraceID != parent.TraceID {
+		t.Errorf("expected trace id %s to be propagated, got %s", parent.TraceID, sent.TraceID)
+	}
+	if sent.SpanID == parent.SpanID {
+		t.Error("expected the span id of the elasticsearch call, not of its parent")
+	}
+	if !sent.Sampled {
+		t.Error("expected the sampled flag of the parent to be propagated")
+	}
+}
+
+func TestCallElasticSignsRequests(t *testing.T) {
+	t.Setenv("AWS_ACCESS_KEY_ID", "AKIDEXAMPLE")
+	t.Setenv("AWS_SECRET_ACCESS_KEY", "secret")
+	t.Setenv("AWS_SESSION_TOKEN", "")
+	t.Setenv("AWS_SECURITY_TOKEN", "")
+
+	stub := newStub(t, "")
+	stub.Add(estest.Interaction{Request: estest.Request{Method: "GET", Path: "/_cluster/health"}, Response: estest.Response{Status: http.StatusOK, Body: json.RawMessage(`{"status":"green"}`)}})
+
+	// The service and region signed for are taken from the host name
+	url := "http://search-courses.eu-west-2.es.amazonaws.com"
+
+	for _, sign := range []bool{true, false} {
+		cfg := config.Default().ElasticSearchConfig
+		cfg.DestURL = url
+		cfg.SignedRequests = sign
+
+		requestSigner, err := signer.New(cfg)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		es := newAPI(stub, url, requestSigner)
+
+		health, err := es.ClusterHealth(context.Background())
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if health.Status != "green" {
+			t.Errorf("expected a green cluster, got %q", health.Status)
+		}
+
+		received := stub.Received()
+		authorization := received[len(received)-1].Header.Get("Authorization")
+
+		if !sign {
+			if authorization != "" {
+				t.Errorf("expected an unsigned request, got %q", authorization)
+			}
+			continue
+		}
+
+		if !strings.HasPrefix(authorization, "AWS4-HMAC-SHA256 Credential=AKIDEXAMPLE/") || !strings.Contains(authorization, "/eu-west-2/es/aws4_request") {
+			t.Errorf("expected a signature for elasticsearch in eu-west-2, got %q", authorization)
+		}
+	}
+}
+
+func TestClose(t *testing.T) {
+	started := make(chan struct{})
+	release := make(chan struct{})
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		close(started)
+		<-release
+		w.Write([]byte(`{}`))
+	}))
+	defer server.Close()
+
+	es := elasticsearch.NewElasticSearchAPI(http.Client{}, server.URL, nil, elasticsearch.NewCircuitBreaker(2, time.Minute), time.Second)
+
+	called := make(chan error, 1)
+	go func() {
+		_, _, err := es.CallElastic(context.Background(), server.URL+"/slow", "GET", nil)
+		called <- err
+	}()
+	<-started
+
+	// A call in flight holds up closing until it finishes
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if err := es.Close(ctx); err == nil {
+		t.Error("expected closing to time out while a call is in flight")
+	}
+
+	close(release)
+	if err := <-called; err != nil {
+		t.Errorf("expected the call in flight to finish, got %v", err)
+	}
+	if err := es.Close(context.Background()); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+
+	if _, _, err := es.CallElastic(context.Background(), server.URL+"/", "GET", nil); err != errs.ErrShuttingDown {
+		t.Errorf("expected calls to be refused once closed, got %v", err)
+	}
+}
@@ -3,16 +3,26 @@ package elasticsearch
 import (
 	"context"
 	"encoding/json"
+	"sort"
+	"time"
 
 	"github.com/pkg/errors"
 
 	"github.com/methods/go-methods-lib/log"
 	errs "github.com/ofs/alpha-search-api/apierrors"
+	"github.com/ofs/alpha-search-api/logging"
 	"github.com/ofs/alpha-search-api/models"
+	"github.com/ofs/alpha-search-api/tracing"
 )
 
 // QueryCoursesSearch builds query as a json body to call an elasticsearch index with
 func (api *API) QueryCoursesSearch(ctx context.Context, index, term string, limit, offset int, filters map[string]string, countries, lengthOfCourse, institutions, subjects []string) (*models.SearchResponse, int, error) {
+	ctx, span := tracing.StartSpan(ctx, "QueryCoursesSearch", tracing.KindInternal)
+	defer span.End()
+
+	span.SetAttribute("search.index", index)
+	span.SetAttribute("search.term_length", len(term))
+
 	response := &models.SearchResponse{}
 
 	path := api.url + "/" + index + "/_search"
@@ -22,8 +32,7 @@ func (api *API) QueryCoursesSearch(ctx context.Context, index, term string, limi
 	log.InfoCtx(ctx, "searching index", logData)
 
 	body := buildSearchQuery(term, limit, offset, filters, countries, lengthOfCourse, institutions, subjects)
-
-	log.InfoCtx(ctx, "searching index", log.Data{"query": body})
+	body.Profile = profileEnabled(ctx)
 
 	bytes, err := json.Marshal(body)
 	if err != nil {
@@ -31,22 +40,49 @@ func (api *API) QueryCoursesSearch(ctx context.Context, index, term string, limi
 		return nil, 0, errs.ErrMarshallingQuery
 	}
 
-	logData["request_body"] = string(bytes)
+	capture := logging.CaptureEnabled(ctx)
+	if capture {
+		log.InfoCtx(ctx, "debug capture: elasticsearch query", log.Data{"path": path, "request_body": string(bytes)})
+	}
 
+	start := time.Now()
 	responseBody, status, err := api.CallElastic(ctx, path, "GET", bytes)
+	duration := time.Since(start)
 	logData["status"] = status
 	if err != nil {
 		log.ErrorCtx(ctx, errors.WithMessage(err, "failed to call elasticsearch"), logData)
+		span.RecordError(err)
+		// Elasticsearch being unavailable is reported as such rather than as a missing index
+		if err == errs.ErrCircuitBreakerOpen || err == errs.ErrShuttingDown {
+			return nil, status, err
+		}
 		return nil, status, errs.ErrIndexNotFound
 	}
 
-	logData["response_body"] = string(responseBody)
+	if capture {
+		log.InfoCtx(ctx, "debug capture: elasticsearch response", log.Data{"path": path, "status": status, "response_body": string(responseBody)})
+	}
 
 	if err = json.Unmarshal(responseBody, response); err != nil {
 		log.ErrorCtx(ctx, errors.WithMessage(err, "unable to unmarshal json body"), logData)
+		span.RecordError(err)
 		return nil, status, errs.ErrUnmarshallingJSON
 	}
 
+	span.SetAttribute("search.total_results", response.Hits.Total)
+	span.SetAttribute("search.took_ms", response.Took)
+
+	api.logSlowQuery(ctx, slowQuery{
+		name:           "courses",
+		index:          index,
+		term:           term,
+		filters:        filters,
+		countries:      countries,
+		lengthOfCourse: lengthOfCourse,
+		institutions:   institutions,
+		subjects:       subjects,
+	}, duration, response)
+
 	log.InfoCtx(ctx, "search results", logData)
 
 	return response, status, nil
@@ -56,8 +92,8 @@ func buildSearchQuery(term string, limit, offset int, filters map[string]string,
 	var object Object
 	highlight := make(map[string]Object)
 
-	highlight["doc.english_title"] = object
-	highlight["doc.welsh_title"] = object
+	highlight[EnglishTitleField] = object
+	highlight[WelshTitleField] = object
 
 	query := &Body{
 		From: offset,
@@ -79,8 +115,8 @@ func buildSearchQuery(term string, limit, offset int, filters map[string]string,
 		englishTitle := make(map[string]string)
 		welshTitle := make(map[string]string)
 
-		englishTitle["doc.english_title"] = term
-		welshTitle["doc.welsh_title"] = term
+		englishTitle[EnglishTitleField] = term
+		welshTitle[WelshTitleField] = term
 
 		englishTitleMatch := Match{
 			Match: englishTitle,
@@ -111,7 +147,15 @@ func addQueryFilters(query *Body, filters map[string]string, countries, lengthOf
 		query.Query.Bool.Filter = []Filters{}
 	}
 
-	for key, value := range filters {
+	// Filters are added in order of name so that the same search always builds the same query
+	keys := make([]string, 0, len(filters))
+	for key := range filters {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		value := filters[key]
 
 		if key == "distance_learning" {
 			if value == "true" {
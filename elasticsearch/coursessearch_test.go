@@ -0,0 +1,132 @@
+package elasticsearch_test
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	errs "github.com/ofs/alpha-search-api/apierrors"
+	"github.com/ofs/alpha-search-api/elasticsearch"
+	"github.com/ofs/alpha-search-api/elasticsearch/estest"
+)
+
+const coursesSearchFixture = "testdata/coursessearch.json"
+
+func TestQueryCoursesSearch(t *testing.T) {
+	stub := newStub(t, coursesSearchFixture)
+	es := newAPI(stub, "", nil)
+
+	response, status, err := es.QueryCoursesSearch(context.Background(), "courses", "mathematics", 2, 0, map[string]string{"part_time": "false"}, []string{"XI", "XF"}, []string{"3"}, []string{""}, []string{""})
+	if err != nil || status != http.StatusOK {
+		t.Fatalf("unexpected response %d: %v", status, err)
+	}
+
+	if response.Took != 4 {
+		t.Errorf("expected took to be 4, got %d", response.Took)
+	}
+	if response.Hits.Total != 3 {
+		t.Errorf("expected a total of 3, got %d", response.Hits.Total)
+	}
+	if len(response.Hits.HitList) != 2 {
+		t.Fatalf("expected 2 hits, got %d", len(response.Hits.HitList))
+	}
+	if response.Profile != nil {
+		t.Errorf("expected no profile, got %s", response.Profile)
+	}
+
+	hit := response.Hits.HitList[0]
+	doc := hit.Source.Doc
+
+	if hit.Score != 2.1 {
+		t.Errorf("expected a score of 2.1, got %v", hit.Score)
+	}
+	if doc.KISCourseID != "MATH1" || doc.EnglishTitle != "Mathematics" || doc.WelshTitle != "Mathemateg" || doc.Mode != "Full-time" {
+		t.Errorf("unexpected document %+v", doc)
+	}
+	if doc.Institution == nil || doc.Institution.PublicUKPRNName != "Aberystwyth University" || doc.Institution.LCUKPRNName != "aberystwyth university" {
+		t.Errorf("unexpected institution %+v", doc.Institution)
+	}
+	if doc.Location == nil || doc.Location.Latitude != "52.41" {
+		t.Errorf("unexpected location %+v", doc.Location)
+	}
+	if doc.Qualification == nil || doc.Qualification.Label != "BSc (Hons)" {
+		t.Errorf("unexpected qualification %+v", doc.Qualification)
+	}
+
+	if len(hit.Highlight.EnglishTitle) != 1 || hit.Highlight.EnglishTitle[0] != "\u0001SMathematics\u0001E" {
+		t.Errorf("unexpected english title highlight %q", hit.Highlight.EnglishTitle)
+	}
+	if len(hit.Highlight.WelshTitle) != 1 || hit.Highlight.WelshTitle[0] != "\u0001SMathemateg\u0001E" {
+		t.Errorf("unexpected welsh title highlight %q", hit.Highlight.WelshTitle)
+	}
+}
+
+func TestQueryCoursesSearchWithoutTerm(t *testing.T) {
+	stub := newStub(t, coursesSearchFixture)
+	es := newAPI(stub, "", nil)
+
+	response, status, err := es.QueryCoursesSearch(context.Background(), "courses", "", 10, 2, nil, nil, nil, []string{"university of leeds"}, []string{"CAH09-01-01"})
+	if err != nil || status != http.StatusOK {
+		t.Fatalf("unexpected response %d: %v", status, err)
+	}
+
+	if len(response.Hits.HitList) != 1 || response.Hits.HitList[0].Source.Doc.KISCourseID != "MATH3" {
+		t.Errorf("expected the third course only, got %+v", response.Hits.HitList)
+	}
+}
+
+func TestQueryCoursesSearchProfile(t *testing.T) {
+	stub := newStub(t, coursesSearchFixture)
+	es := newAPI(stub, "", nil)
+
+	ctx := elasticsearch.WithProfile(context.Background())
+
+	response, _, err := es.QueryCoursesSearch(ctx, "courses", "mathematics", 2, 0, nil, nil, nil, []string{""}, []string{""})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if len(response.Profile) == 0 {
+		t.Error("expected a profile in the response")
+	}
+}
+
+func TestQueryCoursesSearchIndexNotFound(t *testing.T) {
+	stub := newStub(t, coursesSearchFixture)
+	es := newAPI(stub, "", nil)
+
+	response, status, err := es.QueryCoursesSearch(context.Background(), "missing", "mathematics", 2, 0, nil, nil, nil, []string{""}, []string{""})
+	if err != errs.ErrIndexNotFound || status != http.StatusNotFound || response != nil {
+		t.Errorf("expected index not found with a 404, got %d and %v", status, err)
+	}
+}
+
+func TestQueryCoursesSearchUnavailable(t *testing.T) {
+	stub := newStub(t, "")
+	stub.Add(estest.Interaction{Request: estest.Request{Method: "GET", Path: "/"}, Response: estest.Response{Status: http.StatusServiceUnavailable, Text: "unavailable"}})
+	es := newAPI(stub, "", nil)
+
+	for i := 0; i < 2; i++ {
+		es.CallElastic(context.Background(), stub.URL+"/", "GET", nil)
+	}
+
+	// An open circuit breaker, or a shutdown, is passed on so it is reported as unavailable
+	if _, _, err := es.QueryCoursesSearch(context.Background(), "courses", "mathematics", 2, 0, nil, nil, nil, nil, nil); err != errs.ErrCircuitBreakerOpen {
+		t.Errorf("expected the circuit breaker to be open, got %v", err)
+	}
+
+	es.Close(context.Background())
+	if _, _, err := es.QueryInstitutionCoursesSearch(context.Background(), "courses", "mathematics", nil, nil, nil, nil, nil); err != errs.ErrShuttingDown {
+		t.Errorf("expected the search to be refused while shutting down, got %v", err)
+	}
+}
+
+func TestQueryCoursesSearchInvalidResponse(t *testing.T) {
+	stub := newStub(t, coursesSearchFixture)
+	es := newAPI(stub, "", nil)
+
+	response, status, err := es.QueryCoursesSearch(context.Background(), "broken", "mathematics", 2, 0, nil, nil, nil, []string{""}, []string{""})
+	if err != errs.ErrUnmarshallingJSON || status != http.StatusOK || response != nil {
+		t.Errorf("expected an unmarshalling error with a 200, got %d and %v", status, err)
+	}
+}
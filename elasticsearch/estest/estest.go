@@ -0,0 +1,325 @@
+// Package estest provides a stand-in for elasticsearch in tests, replaying
+// recorded responses to requests matched by method, path and JSON body, with
+// newline delimited bodies recorded as an array of their lines.
+//
+// Setting ES_STUB_RECORD to the URL of a real cluster instead proxies requests
+// to it and records the responses, rewriting the fixture when the server is closed
+package estest
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"sync"
+
+	"github.com/pkg/errors"
+)
+
+// RecordEnv is the environment variable holding the URL of the cluster to record against
+const RecordEnv = "ES_STUB_RECORD"
+
+// Interaction is a recorded request and the response returned by elasticsearch
+type Interaction struct {
+	Request  Request  `json:"request"`
+	Response Response `json:"response"`
+}
+
+// Request is matched against incoming requests, the path includes any query string
+type Request struct {
+	Method string          `json:"method"`
+	Path   string          `json:"path"`
+	Body   json.RawMessage `json:"body,omitempty"`
+}
+
+// Response is returned for a matching request, with Text used for bodies which are not JSON
+type Response struct {
+	Status int             `json:"status"`
+	Body   json.RawMessage `json:"body,omitempty"`
+	Text   string          `json:"text,omitempty"`
+}
+
+// ReceivedRequest is a request made to the stub, kept so tests can check headers
+type ReceivedRequest struct {
+	Method string
+	Path   string
+	Header http.Header
+	Body   []byte
+}
+
+// Server is an elasticsearch stub listening on a local address
+type Server struct {
+	// URL of the stub, used in place of the elasticsearch URL
+	URL string
+
+	server  *httptest.Server
+	fixture string
+	target  string
+	client  *http.Client
+
+	mutex        sync.Mutex
+	interactions []Interaction
+	recorded     []Interaction
+	received     []ReceivedRequest
+	unmatched    []string
+}
+
+// NewServer starts a stub replaying the interactions in the fixture file, or
+// recording them against the cluster in ES_STUB_RECORD. An empty fixture starts
+// a stub with no interactions, to which some can be added
+func NewServer(fixture string) (*Server, error) {
+	s := &Server{
+		fixture: fixture,
+		target:  strings.TrimSuffix(os.Getenv(RecordEnv), "/"),
+		client:  &http.Client{},
+	}
+
+	if fixture != "" && !s.Recording() {
+		b, err := ioutil.ReadFile(fixture)
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to read elasticsearch fixture")
+		}
+		if err = json.Unmarshal(b, &s.interactions); err != nil {
+			return nil, errors.Wrapf(err, "failed to parse elasticsearch fixture [%s]", fixture)
+		}
+	}
+
+	s.server = httptest.NewServer(http.HandlerFunc(s.handle))
+	s.URL = s.server.URL
+
+	return s, nil
+}
+
+// Recording returns true if requests are proxied to a real cluster and recorded
+func (s *Server) Recording() bool {
+	return s.fixture != "" && s.target != ""
+}
+
+// Add adds interactions to replay, e.g. error responses which are not worth recording
+func (s *Server) Add(interactions ...Interaction) {
+	s.mutex.Lock()
+	s.interactions = append(s.interactions, interactions...)
+	s.mutex.Unlock()
+}
+
+// Client returns a client which sends requests for any host to the stub, so
+// elasticsearch URLs which affect the request, such as AWS hosts for signing, can be used
+func (s *Server) Client() http.Client {
+	dialer := &net.Dialer{}
+	addr := s.server.Listener.Addr().String()
+
+	return http.Client{
+		Transport: &http.Transport{
+			DialContext: func(ctx context.Context, network, _ string) (net.Conn, error) {
+				return dialer.DialContext(ctx, network, addr)
+			},
+		},
+	}
+}
+
+// Received returns the requests made to the stub, in order
+func (s *Server) Received() []ReceivedRequest {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	return append([]ReceivedRequest(nil), s.received...)
+}
+
+// Unmatched describes each request for which no interaction was recorded
+func (s *Server) Unmatched() []string {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	return append([]string(nil), s.unmatched...)
+}
+
+// recordings holds the interactions recorded for each fixture by every stub in
+// the test run, so that tests sharing a fixture do not overwrite each other
+var recordings = struct {
+	sync.Mutex
+	byFixture map[string][]Interaction
+}{byFixture: make(map[string][]Interaction)}
+
+// Close stops the stub. When recording, the fixture is rewritten with every
+// interaction recorded for it so far in the test run, excluding those which were added
+func (s *Server) Close() error {
+	s.server.Close()
+
+	if !s.Recording() {
+		return nil
+	}
+
+	s.mutex.Lock()
+	recorded := s.recorded
+	s.recorded = nil
+	s.mutex.Unlock()
+
+	recordings.Lock()
+	defer recordings.Unlock()
+
+	interactions := recordings.byFixture[s.fixture]
+	for _, interaction := range recorded {
+		if !contains(interactions, interaction.Request) {
+			interactions = append(interactions, interaction)
+		}
+	}
+	recordings.byFixture[s.fixture] = interactions
+
+	b, err := json.MarshalIndent(interactions, "", "  ")
+	if err != nil {
+		return errors.Wrap(err, "failed to marshal recorded interactions")
+	}
+
+	return errors.Wrap(ioutil.WriteFile(s.fixture, append(b, '\n'), 0644), "failed to write elasticsearch fixture")
+}
+
+func contains(interactions []Interaction, request Request) bool {
+	for _, interaction := range interactions {
+		recorded := interaction.Request
+		if recorded.Method == request.Method && recorded.Path == request.Path && bytes.Equal(recorded.Body, request.Body) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (s *Server) handle(w http.ResponseWriter, r *http.Request) {
+	body, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	request := Request{Method: r.Method, Path: r.URL.RequestURI()}
+	if request.Body, err = normalise(body); err != nil {
+		http.Error(w, "request body is not valid json: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	s.mutex.Lock()
+	s.received = append(s.received, ReceivedRequest{Method: r.Method, Path: request.Path, Header: r.Header.Clone(), Body: body})
+	response, ok := s.match(request)
+	s.mutex.Unlock()
+
+	if !ok && s.Recording() {
+		if response, err = s.record(r, request, body); err != nil {
+			http.Error(w, err.Error(), http.StatusBadGateway)
+			return
+		}
+		ok = true
+	}
+
+	if !ok {
+		description := fmt.Sprintf("%s %s %s", request.Method, request.Path, request.Body)
+
+		s.mutex.Lock()
+		s.unmatched = append(s.unmatched, description)
+		s.mutex.Unlock()
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusNotImplemented)
+		json.NewEncoder(w).Encode(map[string]string{"error": "no recorded interaction for " + description})
+		return
+	}
+
+	if response.Body != nil {
+		w.Header().Set("Content-Type", "application/json")
+	}
+	w.WriteHeader(response.Status)
+
+	if response.Body != nil {
+		w.Write(response.Body)
+	} else {
+		w.Write([]byte(response.Text))
+	}
+}
+
+// match returns the response of the first interaction matching the request
+func (s *Server) match(request Request) (Response, bool) {
+	for _, interaction := range s.interactions {
+		recorded := interaction.Request
+		if recorded.Method != request.Method || recorded.Path != request.Path {
+			continue
+		}
+
+		body, err := normalise(recorded.Body)
+		if err == nil && bytes.Equal(body, request.Body) {
+			return interaction.Response, true
+		}
+	}
+
+	return Response{}, false
+}
+
+// record makes the request to the real cluster and stores the response
+func (s *Server) record(r *http.Request, request Request, body []byte) (Response, error) {
+	req, err := http.NewRequest(r.Method, s.target+request.Path, bytes.NewReader(body))
+	if err != nil {
+		return Response{}, errors.Wrap(err, "failed to create request to record")
+	}
+	req.Header.Set("Content-Type", r.Header.Get("Content-Type"))
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return Response{}, errors.Wrap(err, "failed to call elasticsearch to record")
+	}
+	defer resp.Body.Close()
+
+	b, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return Response{}, errors.Wrap(err, "failed to read response to record")
+	}
+
+	response := Response{Status: resp.StatusCode}
+	if json.Valid(b) {
+		response.Body = b
+	} else {
+		response.Text = string(b)
+	}
+
+	interaction := Interaction{Request: request, Response: response}
+
+	s.mutex.Lock()
+	s.interactions = append(s.interactions, interaction)
+	s.recorded = append(s.recorded, interaction)
+	s.mutex.Unlock()
+
+	return response, nil
+}
+
+// normalise re-encodes a JSON body so that it can be compared regardless of
+// whitespace and the order of keys. Newline delimited bodies, such as those sent
+// to _bulk, are compared as an array of their lines
+func normalise(body []byte) (json.RawMessage, error) {
+	if len(bytes.TrimSpace(body)) == 0 {
+		return nil, nil
+	}
+
+	decoder := json.NewDecoder(bytes.NewReader(body))
+	decoder.UseNumber()
+
+	var values []interface{}
+	for decoder.More() {
+		var value interface{}
+		if err := decoder.Decode(&value); err != nil {
+			return nil, err
+		}
+		values = append(values, value)
+	}
+
+	switch len(values) {
+	case 0:
+		return nil, errors.Errorf("invalid character %q looking for beginning of value", bytes.TrimSpace(body)[0])
+	case 1:
+		return json.Marshal(values[0])
+	}
+
+	return json.Marshal(values)
+}
@@ -0,0 +1,207 @@
+package estest_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/ofs/alpha-search-api/elasticsearch/estest"
+)
+
+func call(t *testing.T, client http.Client, method, url, body string) (int, string) {
+	t.Helper()
+
+	req, err := http.NewRequest(method, url, strings.NewReader(body))
+	if err != nil {
+		t.Fatalf("failed to create request: %v", err)
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		t.Fatalf("request failed: %v", err)
+	}
+	defer resp.Body.Close()
+
+	b, _ := ioutil.ReadAll(resp.Body)
+
+	// Recorded json bodies are indented in fixtures
+	var compact bytes.Buffer
+	if json.Compact(&compact, b) == nil {
+		return resp.StatusCode, compact.String()
+	}
+
+	return resp.StatusCode, string(b)
+}
+
+func TestReplayMatchesNormalisedBodies(t *testing.T) {
+	stub, err := estest.NewServer("")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer stub.Close()
+
+	stub.Add(estest.Interaction{
+		Request:  estest.Request{Method: "GET", Path: "/courses/_search", Body: json.RawMessage(`{"size": 10, "from": 0}`)},
+		Response: estest.Response{Status: http.StatusOK, Body: json.RawMessage(`{"took":1}`)},
+	})
+
+	client := stub.Client()
+
+	// Keys in a different order and whitespace still match
+	if status, body := call(t, client, "GET", stub.URL+"/courses/_search", "{\n\"from\":0,\"size\":10}"); status != http.StatusOK || body != `{"took":1}` {
+		t.Errorf("expected the recorded response, got %d: %s", status, body)
+	}
+
+	// As does a request to any host, as the client always connects to the stub
+	if status, _ := call(t, client, "GET", "http://search.eu-west-2.es.amazonaws.com/courses/_search", `{"from":0,"size":10}`); status != http.StatusOK {
+		t.Errorf("expected the recorded response for another host, got %d", status)
+	}
+
+	if len(stub.Unmatched()) != 0 {
+		t.Errorf("expected every request to match, got %v", stub.Unmatched())
+	}
+
+	// A different body, path or method does not match
+	for _, request := range [][3]string{
+		{"GET", "/courses/_search", `{"from":10,"size":10}`},
+		{"GET", "/other/_search", `{"from":0,"size":10}`},
+		{"POST", "/courses/_search", `{"from":0,"size":10}`},
+	} {
+		if status, _ := call(t, client, request[0], stub.URL+request[1], request[2]); status != http.StatusNotImplemented {
+			t.Errorf("%v: expected no match, got %d", request, status)
+		}
+	}
+
+	if unmatched := stub.Unmatched(); len(unmatched) != 3 {
+		t.Errorf("expected 3 unmatched requests, got %v", unmatched)
+	}
+	if received := stub.Received(); len(received) != 5 {
+		t.Errorf("expected 5 received requests, got %d", len(received))
+	}
+}
+
+func TestReplayFixture(t *testing.T) {
+	fixture := filepath.Join(t.TempDir(), "fixture.json")
+	ioutil.WriteFile(fixture, []byte(`[
+		{"request": {"method": "HEAD", "path": "/courses"}, "response": {"status": 200}},
+		{"request": {"method": "GET", "path": "/broken"}, "response": {"status": 502, "text": "bad gateway"}}
+	]`), 0644)
+
+	stub, err := estest.NewServer(fixture)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer stub.Close()
+
+	if status, _ := call(t, stub.Client(), "HEAD", stub.URL+"/courses", ""); status != http.StatusOK {
+		t.Errorf("expected a 200, got %d", status)
+	}
+	if status, body := call(t, stub.Client(), "GET", stub.URL+"/broken", ""); status != http.StatusBadGateway || body != "bad gateway" {
+		t.Errorf("expected a 502 with a text body, got %d: %s", status, body)
+	}
+}
+
+func TestInvalidFixture(t *testing.T) {
+	fixture := filepath.Join(t.TempDir(), "fixture.json")
+	ioutil.WriteFile(fixture, []byte(`{"request": {}}`), 0644)
+
+	if _, err := estest.NewServer(fixture); err == nil {
+		t.Error("expected an error for a fixture which is not a list")
+	}
+
+	if _, err := estest.NewServer(filepath.Join(t.TempDir(), "missing.json")); err == nil {
+		t.Error("expected an error for a missing fixture")
+	}
+}
+
+func TestRecord(t *testing.T) {
+	cluster := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b, _ := ioutil.ReadAll(r.Body)
+		if r.URL.Path == "/text" {
+			w.Write([]byte("plain text"))
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"path":"` + r.URL.RequestURI() + `","received":` + string(b) + `}`))
+	}))
+	defer cluster.Close()
+
+	t.Setenv(estest.RecordEnv, cluster.URL)
+
+	fixture := filepath.Join(t.TempDir(), "fixture.json")
+
+	// Stubs sharing a fixture all contribute to it
+	for _, path := range []string{"/courses/_search?size=1", "/text"} {
+		stub, err := estest.NewServer(fixture)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if !stub.Recording() {
+			t.Fatal("expected the stub to be recording")
+		}
+
+		stub.Add(estest.Interaction{Request: estest.Request{Method: "GET", Path: "/added"}, Response: estest.Response{Status: http.StatusTeapot}})
+
+		if status, _ := call(t, stub.Client(), "GET", stub.URL+"/added", ""); status != http.StatusTeapot {
+			t.Errorf("expected added interactions to be replayed while recording, got %d", status)
+		}
+		if status, _ := call(t, stub.Client(), "GET", stub.URL+path, `{"b": 2, "a": 1}`); status != http.StatusOK {
+			t.Errorf("%s: expected the response from the cluster, got %d", path, status)
+		}
+
+		if err = stub.Close(); err != nil {
+			t.Fatalf("failed to close stub: %v", err)
+		}
+	}
+
+	t.Setenv(estest.RecordEnv, "")
+
+	stub, err := estest.NewServer(fixture)
+	if err != nil {
+		t.Fatalf("failed to replay the recorded fixture: %v", err)
+	}
+	defer stub.Close()
+
+	if status, body := call(t, stub.Client(), "GET", stub.URL+"/courses/_search?size=1", `{"a":1,"b":2}`); status != http.StatusOK || body != `{"path":"/courses/_search?size=1","received":{"b":2,"a":1}}` {
+		t.Errorf("expected the recorded json response, got %d: %s", status, body)
+	}
+	if status, body := call(t, stub.Client(), "GET", stub.URL+"/text", `{"a":1,"b":2}`); status != http.StatusOK || body != "plain text" {
+		t.Errorf("expected the recorded text response, got %d: %s", status, body)
+	}
+	if status, _ := call(t, stub.Client(), "GET", stub.URL+"/added", ""); status != http.StatusNotImplemented {
+		t.Errorf("expected added interactions not to be recorded, got %d", status)
+	}
+}
+
+func TestReplayNewlineDelimitedBodies(t *testing.T) {
+	stub, err := estest.NewServer("")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer stub.Close()
+
+	stub.Add(estest.Interaction{
+		Request:  estest.Request{Method: "POST", Path: "/_bulk", Body: json.RawMessage(`[{"index": {"_id": "1"}}, {"title": "Maths"}]`)},
+		Response: estest.Response{Status: http.StatusOK, Body: json.RawMessage(`{"errors":false}`)},
+	})
+
+	client := stub.Client()
+
+	if status, _ := call(t, client, "POST", stub.URL+"/_bulk", "{\"index\":{\"_id\":\"1\"}}\n{\"title\":\"Maths\"}\n"); status != http.StatusOK {
+		t.Errorf("expected the bulk request to match, got %d", status)
+	}
+
+	// Every line is compared, not only the first
+	if status, _ := call(t, client, "POST", stub.URL+"/_bulk", "{\"index\":{\"_id\":\"1\"}}\n{\"title\":\"Physics\"}\n"); status != http.StatusNotImplemented {
+		t.Errorf("expected a different document not to match, got %d", status)
+	}
+
+	if status, _ := call(t, client, "POST", stub.URL+"/_bulk", "}"); status != http.StatusBadRequest {
+		t.Errorf("expected an invalid body to be rejected, got %d", status)
+	}
+}
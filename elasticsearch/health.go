@@ -0,0 +1,59 @@
+package elasticsearch
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+
+	"github.com/methods/go-methods-lib/log"
+	errs "github.com/ofs/alpha-search-api/apierrors"
+	"github.com/ofs/alpha-search-api/models"
+	"github.com/pkg/errors"
+)
+
+// ClusterHealth retrieves the health status of the elasticsearch cluster
+func (api *API) ClusterHealth(ctx context.Context) (*models.ClusterHealth, error) {
+	path := api.url + "/_cluster/health"
+
+	logData := log.Data{"path": path}
+
+	responseBody, status, err := api.CallElastic(ctx, path, "GET", nil)
+	logData["status"] = status
+	if err != nil {
+		log.ErrorCtx(ctx, errors.WithMessage(err, "failed to retrieve elasticsearch cluster health"), logData)
+		return nil, err
+	}
+
+	health := &models.ClusterHealth{}
+	if err = json.Unmarshal(responseBody, health); err != nil {
+		log.ErrorCtx(ctx, errors.WithMessage(err, "unable to unmarshal json body"), logData)
+		return nil, errs.ErrUnmarshallingJSON
+	}
+
+	return health, nil
+}
+
+// IndexExists checks the index (or alias) exists in the elasticsearch cluster,
+// returning ErrIndexNotFound only if elasticsearch says it does not
+func (api *API) IndexExists(ctx context.Context, index string) error {
+	path := api.url + "/" + index
+
+	logData := log.Data{"path": path}
+
+	_, status, err := api.CallElastic(ctx, path, "HEAD", nil)
+	logData["status"] = status
+	if err != nil {
+		log.ErrorCtx(ctx, errors.WithMessage(err, "failed to find elasticsearch index"), logData)
+		if status == http.StatusNotFound {
+			return errs.ErrIndexNotFound
+		}
+		return err
+	}
+
+	return nil
+}
+
+// CircuitBreakerOpen returns true if calls to elasticsearch are currently suspended
+func (api *API) CircuitBreakerOpen() bool {
+	return api.breaker.IsOpen()
+}
@@ -0,0 +1,39 @@
+package elasticsearch_test
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	errs "github.com/ofs/alpha-search-api/apierrors"
+	"github.com/ofs/alpha-search-api/elasticsearch/estest"
+)
+
+func TestIndexExists(t *testing.T) {
+	stub := newStub(t, "")
+	es := newAPI(stub, "", nil)
+
+	cases := map[string]struct {
+		status int
+		err    error
+	}{
+		"courses":     {http.StatusOK, nil},
+		"missing":     {http.StatusNotFound, errs.ErrIndexNotFound},
+		"forbidden":   {http.StatusForbidden, errs.ErrUnexpectedStatusCode},
+		"unavailable": {http.StatusServiceUnavailable, errs.ErrUnexpectedStatusCode},
+	}
+
+	for index, tc := range cases {
+		stub.Add(estest.Interaction{Request: estest.Request{Method: "HEAD", Path: "/" + index}, Response: estest.Response{Status: tc.status}})
+
+		if err := es.IndexExists(context.Background(), index); err != tc.err {
+			t.Errorf("%s: expected %v, got %v", index, tc.err, err)
+		}
+	}
+
+	// Only a missing index is reported as not found
+	stub.Close()
+	if err := es.IndexExists(context.Background(), "courses"); err == nil || err == errs.ErrIndexNotFound {
+		t.Errorf("expected the connection error, got %v", err)
+	}
+}
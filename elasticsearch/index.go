@@ -0,0 +1,180 @@
+package elasticsearch
+
+import (
+	"context"
+	"encoding/json"
+	"sort"
+
+	"github.com/methods/go-methods-lib/log"
+	errs "github.com/ofs/alpha-search-api/apierrors"
+	"github.com/ofs/alpha-search-api/models"
+	"github.com/pkg/errors"
+)
+
+// CreateIndex creates the index with the given settings and mappings
+func (api *API) CreateIndex(ctx context.Context, index string, body []byte) error {
+	path := api.url + "/" + index
+
+	logData := log.Data{"path": path}
+
+	_, status, err := api.CallElastic(ctx, path, "PUT", body)
+	logData["status"] = status
+	if err != nil {
+		log.ErrorCtx(ctx, errors.WithMessage(err, "failed to create elasticsearch index"), logData)
+		return err
+	}
+
+	log.InfoCtx(ctx, "created elasticsearch index", logData)
+
+	return nil
+}
+
+// Refresh makes documents recently added to the index available to searches
+func (api *API) Refresh(ctx context.Context, index string) error {
+	path := api.url + "/" + index + "/_refresh"
+
+	logData := log.Data{"path": path}
+
+	_, status, err := api.CallElastic(ctx, path, "POST", nil)
+	logData["status"] = status
+	if err != nil {
+		log.ErrorCtx(ctx, errors.WithMessage(err, "failed to refresh elasticsearch index"), logData)
+		return err
+	}
+
+	return nil
+}
+
+// Bulk sends newline delimited actions to the bulk api, returning the result of
+// each. A successful response may still hold actions which failed
+func (api *API) Bulk(ctx context.Context, body []byte) (*models.BulkResponse, int, error) {
+	path := api.url + "/_bulk"
+
+	logData := log.Data{"path": path, "size": len(body)}
+
+	responseBody, status, err := api.CallElastic(ctx, path, "POST", body)
+	logData["status"] = status
+	if err != nil {
+		log.ErrorCtx(ctx, errors.WithMessage(err, "failed to send bulk request to elasticsearch"), logData)
+		return nil, status, err
+	}
+
+	response := &models.BulkResponse{}
+	if err = json.Unmarshal(responseBody, response); err != nil {
+		log.ErrorCtx(ctx, errors.WithMessage(err, "unable to unmarshal json body"), logData)
+		return nil, status, errs.ErrUnmarshallingJSON
+	}
+
+	return response, status, nil
+}
+
+// Count returns the number of documents held by the index (or alias)
+func (api *API) Count(ctx context.Context, index string) (int, error) {
+	path := api.url + "/" + index + "/_count"
+
+	logData := log.Data{"path": path}
+
+	responseBody, status, err := api.CallElastic(ctx, path, "GET", nil)
+	logData["status"] = status
+	if err != nil {
+		log.ErrorCtx(ctx, errors.WithMessage(err, "failed to count documents in elasticsearch index"), logData)
+		return 0, err
+	}
+
+	response := &models.CountResponse{}
+	if err = json.Unmarshal(responseBody, response); err != nil {
+		log.ErrorCtx(ctx, errors.WithMessage(err, "unable to unmarshal json body"), logData)
+		return 0, errs.ErrUnmarshallingJSON
+	}
+
+	return response.Count, nil
+}
+
+// Indices returns the sorted names of the indices matching the pattern, which may
+// contain wildcards
+func (api *API) Indices(ctx context.Context, pattern string) ([]string, error) {
+	path := api.url + "/_cat/indices/" + pattern + "?format=json&h=index"
+
+	logData := log.Data{"path": path}
+
+	responseBody, status, err := api.CallElastic(ctx, path, "GET", nil)
+	logData["status"] = status
+	if err != nil {
+		log.ErrorCtx(ctx, errors.WithMessage(err, "failed to list elasticsearch indices"), logData)
+		return nil, err
+	}
+
+	var indices []models.CatIndex
+	if err = json.Unmarshal(responseBody, &indices); err != nil {
+		log.ErrorCtx(ctx, errors.WithMessage(err, "unable to unmarshal json body"), logData)
+		return nil, errs.ErrUnmarshallingJSON
+	}
+
+	names := make([]string, 0, len(indices))
+	for _, index := range indices {
+		names = append(names, index.Index)
+	}
+	sort.Strings(names)
+
+	return names, nil
+}
+
+// DeleteIndex deletes the index and every document it holds
+func (api *API) DeleteIndex(ctx context.Context, index string) error {
+	path := api.url + "/" + index
+
+	logData := log.Data{"path": path}
+
+	_, status, err := api.CallElastic(ctx, path, "DELETE", nil)
+	logData["status"] = status
+	if err != nil {
+		log.ErrorCtx(ctx, errors.WithMessage(err, "failed to delete elasticsearch index"), logData)
+		return err
+	}
+
+	log.InfoCtx(ctx, "deleted elasticsearch index", logData)
+
+	return nil
+}
+
+// aliasActions represents the request body to the aliases api, which applies
+// every action at once
+type aliasActions struct {
+	Actions []map[string]aliasAction `json:"actions"`
+}
+
+type aliasAction struct {
+	Index string `json:"index"`
+	Alias string `json:"alias"`
+}
+
+// SwapAlias points the alias at the index in a single atomic update, removing it
+// from the indices it previously referred to
+func (api *API) SwapAlias(ctx context.Context, alias, index string, previous []string) error {
+	path := api.url + "/_aliases"
+
+	logData := log.Data{"path": path, "alias": alias, "index": index, "previous": previous}
+
+	body := aliasActions{}
+	for _, name := range previous {
+		body.Actions = append(body.Actions, map[string]aliasAction{"remove": {Index: name, Alias: alias}})
+	}
+	body.Actions = append(body.Actions, map[string]aliasAction{"add": {Index: index, Alias: alias}})
+
+	bytes, err := json.Marshal(body)
+	if err != nil {
+		log.ErrorCtx(ctx, errors.WithMessage(err, "unable to marshal alias actions to bytes"), logData)
+		return errs.ErrMarshallingQuery
+	}
+
+	_, status, err := api.CallElastic(ctx, path, "POST", bytes)
+	logData["status"] = status
+	if err != nil {
+		log.ErrorCtx(ctx, errors.WithMessage(err, "failed to update elasticsearch alias"), logData)
+		return err
+	}
+
+	log.InfoCtx(ctx, "updated elasticsearch alias", logData)
+
+	return nil
+}
@@ -0,0 +1,137 @@
+package elasticsearch_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	errs "github.com/ofs/alpha-search-api/apierrors"
+	"github.com/ofs/alpha-search-api/elasticsearch/estest"
+)
+
+func TestCreateIndex(t *testing.T) {
+	stub := newStub(t, "")
+	stub.Add(
+		estest.Interaction{Request: estest.Request{Method: "PUT", Path: "/courses-1", Body: json.RawMessage(`{"mappings":{}}`)}, Response: estest.Response{Status: http.StatusOK, Body: json.RawMessage(`{"acknowledged":true}`)}},
+		estest.Interaction{Request: estest.Request{Method: "PUT", Path: "/courses", Body: json.RawMessage(`{"mappings":{}}`)}, Response: estest.Response{Status: http.StatusBadRequest, Body: json.RawMessage(`{"error":{"type":"resource_already_exists_exception"}}`)}},
+	)
+
+	es := newAPI(stub, "", nil)
+
+	if err := es.CreateIndex(context.Background(), "courses-1", []byte(`{"mappings":{}}`)); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+	if err := es.CreateIndex(context.Background(), "courses", []byte(`{"mappings":{}}`)); err != errs.ErrUnexpectedStatusCode {
+		t.Errorf("expected an error for an existing index, got %v", err)
+	}
+}
+
+func TestRefresh(t *testing.T) {
+	stub := newStub(t, "")
+	stub.Add(estest.Interaction{Request: estest.Request{Method: "POST", Path: "/courses/_refresh"}, Response: estest.Response{Status: http.StatusOK, Body: json.RawMessage(`{"_shards":{"total":2,"successful":1,"failed":0}}`)}})
+
+	es := newAPI(stub, "", nil)
+
+	if err := es.Refresh(context.Background(), "courses"); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
+
+func TestBulk(t *testing.T) {
+	stub := newStub(t, "")
+	stub.Add(estest.Interaction{
+		Request: estest.Request{Method: "POST", Path: "/_bulk", Body: json.RawMessage(`[
+			{"index": {"_index": "courses", "_type": "_doc", "_id": "1"}},
+			{"doc": {"kis_course_id": "A"}},
+			{"index": {"_index": "courses", "_type": "_doc", "_id": "2"}},
+			{"doc": {"kis_course_id": 2}}
+		]`)},
+		Response: estest.Response{Status: http.StatusOK, Body: json.RawMessage(`{"took": 3, "errors": true, "items": [
+			{"index": {"_index": "courses", "_id": "1", "status": 201}},
+			{"index": {"_index": "courses", "_id": "2", "status": 400, "error": {"type": "mapper_parsing_exception", "reason": "failed to parse field [doc.kis_course_id]"}}}
+		]}`)},
+	})
+
+	es := newAPI(stub, "", nil)
+
+	body := []byte(`{"index":{"_index":"courses","_type":"_doc","_id":"1"}}
+{"doc":{"kis_course_id":"A"}}
+{"index":{"_index":"courses","_type":"_doc","_id":"2"}}
+{"doc":{"kis_course_id":2}}
+`)
+
+	response, status, err := es.Bulk(context.Background(), body)
+	if err != nil || status != http.StatusOK {
+		t.Fatalf("unexpected response %d: %v", status, err)
+	}
+
+	if !response.Errors || len(response.Items) != 2 {
+		t.Fatalf("expected 2 items with errors, got %+v", response)
+	}
+	if created := response.Items[0]["index"]; created.ID != "1" || created.Status != http.StatusCreated || created.Error != nil {
+		t.Errorf("unexpected result %+v", created)
+	}
+	if failed := response.Items[1]["index"]; failed.Status != http.StatusBadRequest || failed.Error == nil || failed.Error.Type != "mapper_parsing_exception" {
+		t.Errorf("unexpected result %+v", failed)
+	}
+
+	if contentType := stub.Received()[0].Header.Get("Content-Type"); contentType != "application/json" {
+		t.Errorf("expected a json content type, got %q", contentType)
+	}
+}
+
+func TestCount(t *testing.T) {
+	stub := newStub(t, "")
+	stub.Add(estest.Interaction{Request: estest.Request{Method: "GET", Path: "/courses/_count"}, Response: estest.Response{Status: http.StatusOK, Body: json.RawMessage(`{"count":42,"_shards":{"total":5,"successful":5,"skipped":0,"failed":0}}`)}})
+
+	es := newAPI(stub, "", nil)
+
+	count, err := es.Count(context.Background(), "courses")
+	if err != nil || count != 42 {
+		t.Errorf("expected 42 documents, got %d and %v", count, err)
+	}
+}
+
+func TestIndices(t *testing.T) {
+	stub := newStub(t, "")
+	stub.Add(estest.Interaction{Request: estest.Request{Method: "GET", Path: "/_cat/indices/courses-*?format=json&h=index"}, Response: estest.Response{Status: http.StatusOK, Body: json.RawMessage(`[{"index":"courses-20261019000000"},{"index":"courses-20261018000000"}]`)}})
+
+	es := newAPI(stub, "", nil)
+
+	indices, err := es.Indices(context.Background(), "courses-*")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(indices) != 2 || indices[0] != "courses-20261018000000" || indices[1] != "courses-20261019000000" {
+		t.Errorf("expected the indices in order, got %v", indices)
+	}
+}
+
+func TestDeleteIndex(t *testing.T) {
+	stub := newStub(t, "")
+	stub.Add(estest.Interaction{Request: estest.Request{Method: "DELETE", Path: "/courses-20261018000000"}, Response: estest.Response{Status: http.StatusOK, Body: json.RawMessage(`{"acknowledged":true}`)}})
+
+	es := newAPI(stub, "", nil)
+
+	if err := es.DeleteIndex(context.Background(), "courses-20261018000000"); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
+
+func TestSwapAlias(t *testing.T) {
+	stub := newStub(t, "")
+	stub.Add(estest.Interaction{
+		Request: estest.Request{Method: "POST", Path: "/_aliases", Body: json.RawMessage(`{"actions": [
+			{"remove": {"index": "courses-20261018000000", "alias": "courses"}},
+			{"add": {"index": "courses-20261019000000", "alias": "courses"}}
+		]}`)},
+		Response: estest.Response{Status: http.StatusOK, Body: json.RawMessage(`{"acknowledged":true}`)},
+	})
+
+	es := newAPI(stub, "", nil)
+
+	if err := es.SwapAlias(context.Background(), "courses", "courses-20261019000000", []string{"courses-20261018000000"}); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
@@ -3,15 +3,24 @@ package elasticsearch
 import (
 	"context"
 	"encoding/json"
+	"time"
 
 	"github.com/methods/go-methods-lib/log"
 	errs "github.com/ofs/alpha-search-api/apierrors"
+	"github.com/ofs/alpha-search-api/logging"
 	"github.com/ofs/alpha-search-api/models"
+	"github.com/ofs/alpha-search-api/tracing"
 	"github.com/pkg/errors"
 )
 
 // QueryInstitutionCoursesSearch builds query as a json body to call an elasticsearch index with
 func (api *API) QueryInstitutionCoursesSearch(ctx context.Context, index, term string, filters map[string]string, countries, lengthOfCourse, institutions, subjects []string) (*models.SearchResponse, int, error) {
+	ctx, span := tracing.StartSpan(ctx, "QueryInstitutionCoursesSearch", tracing.KindInternal)
+	defer span.End()
+
+	span.SetAttribute("search.index", index)
+	span.SetAttribute("search.term_length", len(term))
+
 	response := &models.SearchResponse{}
 
 	path := api.url + "/" + index + "/_search"
@@ -21,8 +30,7 @@ func (api *API) QueryInstitutionCoursesSearch(ctx context.Context, index, term s
 	log.InfoCtx(ctx, "searching index", logData)
 
 	body := buildInstitutionSearchQuery(term, filters, countries, lengthOfCourse, institutions, subjects)
-
-	log.InfoCtx(ctx, "searching index", log.Data{"query": body})
+	body.Profile = profileEnabled(ctx)
 
 	bytes, err := json.Marshal(body)
 	if err != nil {
@@ -30,22 +38,49 @@ func (api *API) QueryInstitutionCoursesSearch(ctx context.Context, index, term s
 		return nil, 0, errs.ErrMarshallingQuery
 	}
 
-	logData["request_body"] = string(bytes)
+	capture := logging.CaptureEnabled(ctx)
+	if capture {
+		log.InfoCtx(ctx, "debug capture: elasticsearch query", log.Data{"path": path, "request_body": string(bytes)})
+	}
 
+	start := time.Now()
 	responseBody, status, err := api.CallElastic(ctx, path, "GET", bytes)
+	duration := time.Since(start)
 	logData["status"] = status
 	if err != nil {
 		log.ErrorCtx(ctx, errors.WithMessage(err, "failed to call elasticsearch"), logData)
+		span.RecordError(err)
+		// Elasticsearch being unavailable is reported as such rather than as a missing index
+		if err == errs.ErrCircuitBreakerOpen || err == errs.ErrShuttingDown {
+			return nil, status, err
+		}
 		return nil, status, errs.ErrIndexNotFound
 	}
 
-	logData["response_body"] = string(responseBody)
+	if capture {
+		log.InfoCtx(ctx, "debug capture: elasticsearch response", log.Data{"path": path, "status": status, "response_body": string(responseBody)})
+	}
 
 	if err = json.Unmarshal(responseBody, response); err != nil {
 		log.ErrorCtx(ctx, errors.WithMessage(err, "unable to unmarshal json body"), logData)
+		span.RecordError(err)
 		return nil, status, errs.ErrUnmarshallingJSON
 	}
 
+	span.SetAttribute("search.total_results", response.Hits.Total)
+	span.SetAttribute("search.took_ms", response.Took)
+
+	api.logSlowQuery(ctx, slowQuery{
+		name:           "institution_courses",
+		index:          index,
+		term:           term,
+		filters:        filters,
+		countries:      countries,
+		lengthOfCourse: lengthOfCourse,
+		institutions:   institutions,
+		subjects:       subjects,
+	}, duration, response)
+
 	log.InfoCtx(ctx, "search results", logData)
 
 	return response, status, nil
@@ -65,8 +100,8 @@ func buildInstitutionSearchQuery(term string, filters map[string]string, countri
 	}
 
 	if term != "" {
-		englishTitle["doc.english_title"] = term
-		welshTitle["doc.welsh_title"] = term
+		englishTitle[EnglishTitleField] = term
+		welshTitle[WelshTitleField] = term
 
 		englishTitleMatch := Match{
 			Match: englishTitle,
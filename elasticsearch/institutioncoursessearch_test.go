@@ -0,0 +1,65 @@
+package elasticsearch_test
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	errs "github.com/ofs/alpha-search-api/apierrors"
+)
+
+const institutionCoursesSearchFixture = "testdata/institutioncoursessearch.json"
+
+func TestQueryInstitutionCoursesSearch(t *testing.T) {
+	stub := newStub(t, institutionCoursesSearchFixture)
+	es := newAPI(stub, "", nil)
+
+	response, status, err := es.QueryInstitutionCoursesSearch(context.Background(), "courses", "mathematics", map[string]string{"year_abroad": "true"}, []string{"XI", "XF"}, nil, []string{""}, []string{""})
+	if err != nil || status != http.StatusOK {
+		t.Fatalf("unexpected response %d: %v", status, err)
+	}
+
+	if response.Hits.Total != 3 || len(response.Hits.HitList) != 3 {
+		t.Fatalf("expected 3 hits, got %d of %d", len(response.Hits.HitList), response.Hits.Total)
+	}
+
+	var institutions []string
+	for _, hit := range response.Hits.HitList {
+		if hit.Source.Doc.Institution == nil {
+			t.Fatalf("expected course %s to have an institution", hit.Source.Doc.KISCourseID)
+		}
+		institutions = append(institutions, hit.Source.Doc.Institution.UKPRNName)
+
+		if len(hit.Highlight.EnglishTitle) > 0 {
+			t.Errorf("expected no highlights, got %q", hit.Highlight.EnglishTitle)
+		}
+	}
+
+	expected := []string{"Aberystwyth University", "Aberystwyth University", "University of Leeds"}
+	for i := range expected {
+		if institutions[i] != expected[i] {
+			t.Errorf("expected institutions %v, got %v", expected, institutions)
+			break
+		}
+	}
+}
+
+func TestQueryInstitutionCoursesSearchIndexNotFound(t *testing.T) {
+	stub := newStub(t, institutionCoursesSearchFixture)
+	es := newAPI(stub, "", nil)
+
+	response, status, err := es.QueryInstitutionCoursesSearch(context.Background(), "missing", "mathematics", nil, nil, nil, []string{""}, []string{""})
+	if err != errs.ErrIndexNotFound || status != http.StatusNotFound || response != nil {
+		t.Errorf("expected index not found with a 404, got %d and %v", status, err)
+	}
+}
+
+func TestQueryInstitutionCoursesSearchInvalidResponse(t *testing.T) {
+	stub := newStub(t, institutionCoursesSearchFixture)
+	es := newAPI(stub, "", nil)
+
+	response, status, err := es.QueryInstitutionCoursesSearch(context.Background(), "broken", "mathematics", nil, nil, nil, []string{""}, []string{""})
+	if err != errs.ErrUnmarshallingJSON || status != http.StatusOK || response != nil {
+		t.Errorf("expected an unmarshalling error with a 200, got %d and %v", status, err)
+	}
+}
@@ -0,0 +1,142 @@
+package elasticsearch
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/methods/go-methods-lib/log"
+	errs "github.com/ofs/alpha-search-api/apierrors"
+	"github.com/ofs/alpha-search-api/models"
+	"github.com/pkg/errors"
+)
+
+// A list of the fields the search term is matched against
+const (
+	EnglishTitleField = "doc.english_title"
+	WelshTitleField   = "doc.welsh_title"
+)
+
+// QueryFields returns every field the query builder refers to, with the type each
+// must be mapped as. Fields filtered and sorted on are keywords, as they are
+// compared with the values given exactly, while those searched are text
+func QueryFields() map[string]string {
+	fields := map[string]string{
+		EnglishTitleField: "text",
+		WelshTitleField:   "text",
+	}
+
+	for _, t := range []reflect.Type{reflect.TypeOf(Terms{}), reflect.TypeOf(Criteria{})} {
+		for i := 0; i < t.NumField(); i++ {
+			name := strings.Split(t.Field(i).Tag.Get("json"), ",")[0]
+			if strings.HasPrefix(name, "doc.") {
+				fields[name] = "keyword"
+			}
+		}
+	}
+
+	return fields
+}
+
+// MappedFields returns the type of every field in the properties, including
+// subfields and the fields of objects, by its full name
+func MappedFields(properties map[string]models.FieldMapping) map[string]string {
+	fields := make(map[string]string)
+	addMappedFields("", properties, fields)
+
+	return fields
+}
+
+func addMappedFields(prefix string, properties map[string]models.FieldMapping, fields map[string]string) {
+	for name, field := range properties {
+		fieldType := field.Type
+		if fieldType == "" && field.Properties != nil {
+			fieldType = "object"
+		}
+		fields[prefix+name] = fieldType
+
+		addMappedFields(prefix+name+".", field.Properties, fields)
+		addMappedFields(prefix+name+".", field.Fields, fields)
+	}
+}
+
+// CheckFields returns a description of each field the query builder refers to
+// which is missing from the fields mapped, or mapped as a different type
+func CheckFields(mapped map[string]string) []string {
+	var problems []string
+	for name, expected := range QueryFields() {
+		actual, ok := mapped[name]
+		switch {
+		case !ok:
+			problems = append(problems, fmt.Sprintf("%s is not mapped, expected %s", name, expected))
+		case actual != expected:
+			problems = append(problems, fmt.Sprintf("%s is mapped as %s, expected %s", name, actual, expected))
+		}
+	}
+	sort.Strings(problems)
+
+	return problems
+}
+
+// Mapping retrieves the mappings of the index, keyed by the name of each index
+// if given an alias
+func (api *API) Mapping(ctx context.Context, index string) (map[string]models.IndexMapping, error) {
+	path := api.url + "/" + index + "/_mapping"
+
+	logData := log.Data{"path": path}
+
+	responseBody, status, err := api.CallElastic(ctx, path, "GET", nil)
+	logData["status"] = status
+	if err != nil {
+		log.ErrorCtx(ctx, errors.WithMessage(err, "failed to retrieve elasticsearch index mapping"), logData)
+		return nil, err
+	}
+
+	mappings := make(map[string]models.IndexMapping)
+	if err = json.Unmarshal(responseBody, &mappings); err != nil {
+		log.ErrorCtx(ctx, errors.WithMessage(err, "unable to unmarshal json body"), logData)
+		return nil, errs.ErrUnmarshallingJSON
+	}
+
+	return mappings, nil
+}
+
+// CheckMapping retrieves the mappings of the index (or every index behind an
+// alias) and returns a description of each field searches refer to which is
+// missing or mapped as a different type, prefixed by the name of the index
+func (api *API) CheckMapping(ctx context.Context, index string) ([]string, error) {
+	mappings, err := api.Mapping(ctx, index)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(mappings) == 0 {
+		return nil, errs.ErrIndexNotFound
+	}
+
+	names := make([]string, 0, len(mappings))
+	for name := range mappings {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var problems []string
+	for _, name := range names {
+		// Fields are gathered across document types, of which an index has one since elasticsearch 6
+		fields := make(map[string]string)
+		for _, mapping := range mappings[name].Mappings {
+			for field, fieldType := range MappedFields(mapping.Properties) {
+				fields[field] = fieldType
+			}
+		}
+
+		for _, problem := range CheckFields(fields) {
+			problems = append(problems, name+": "+problem)
+		}
+	}
+
+	return problems, nil
+}
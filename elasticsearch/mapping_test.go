@@ -0,0 +1,122 @@
+package elasticsearch_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"reflect"
+	"testing"
+
+	errs "github.com/ofs/alpha-search-api/apierrors"
+	"github.com/ofs/alpha-search-api/elasticsearch"
+	"github.com/ofs/alpha-search-api/elasticsearch/estest"
+)
+
+// mapping returns the mapping of an index holding every query field, other than
+// those given, which are replaced by the types given or removed if empty
+func mapping(t *testing.T, replaced map[string]string) json.RawMessage {
+	t.Helper()
+
+	doc := make(map[string]interface{})
+	for name, fieldType := range elasticsearch.QueryFields() {
+		if replacement, ok := replaced[name]; ok {
+			if replacement == "" {
+				continue
+			}
+			fieldType = replacement
+		}
+		addField(doc, name[len("doc."):], fieldType)
+	}
+
+	b, err := json.Marshal(map[string]interface{}{
+		"mappings": map[string]interface{}{
+			"_doc": map[string]interface{}{
+				"properties": map[string]interface{}{
+					"doc": map[string]interface{}{"properties": doc},
+				},
+			},
+		},
+	})
+	if err != nil {
+		t.Fatalf("failed to marshal mapping: %v", err)
+	}
+
+	return b
+}
+
+// addField adds the field to the properties, as a subfield if it is named keyword
+func addField(properties map[string]interface{}, name, fieldType string) {
+	for i := len(name) - 1; i >= 0; i-- {
+		if name[i] != '.' {
+			continue
+		}
+
+		parent, child := name[:i], name[i+1:]
+		if child == "keyword" {
+			field, ok := properties[parent].(map[string]interface{})
+			if !ok {
+				field = map[string]interface{}{"type": "text"}
+				properties[parent] = field
+			}
+			field["fields"] = map[string]interface{}{"keyword": map[string]interface{}{"type": fieldType}}
+			return
+		}
+
+		object, ok := properties[parent].(map[string]interface{})
+		if !ok {
+			object = map[string]interface{}{"properties": map[string]interface{}{}}
+			properties[parent] = object
+		}
+		addField(object["properties"].(map[string]interface{}), child, fieldType)
+		return
+	}
+
+	field, ok := properties[name].(map[string]interface{})
+	if !ok {
+		field = make(map[string]interface{})
+		properties[name] = field
+	}
+	field["type"] = fieldType
+}
+
+func TestCheckMapping(t *testing.T) {
+	complete := mapping(t, nil)
+	broken := mapping(t, map[string]string{
+		"doc.country_code.keyword":              "",
+		"doc.institution.lc_ukprn_name.keyword": "",
+		"doc.mode.keyword":                      "text",
+		elasticsearch.EnglishTitleField:         "keyword",
+	})
+
+	stub := newStub(t, "")
+	stub.Add(
+		estest.Interaction{Request: estest.Request{Method: "GET", Path: "/courses/_mapping"}, Response: estest.Response{Status: http.StatusOK, Body: json.RawMessage(`{"courses-1": ` + string(complete) + `}`)}},
+		estest.Interaction{Request: estest.Request{Method: "GET", Path: "/broken/_mapping"}, Response: estest.Response{Status: http.StatusOK, Body: json.RawMessage(`{"broken-2": ` + string(broken) + `, "broken-1": ` + string(complete) + `}`)}},
+		estest.Interaction{Request: estest.Request{Method: "GET", Path: "/missing/_mapping"}, Response: estest.Response{Status: http.StatusNotFound, Body: json.RawMessage(`{"status":404}`)}},
+	)
+
+	es := newAPI(stub, "", nil)
+
+	problems, err := es.CheckMapping(context.Background(), "courses")
+	if err != nil || len(problems) != 0 {
+		t.Errorf("expected a complete mapping, got %v and %v", problems, err)
+	}
+
+	problems, err = es.CheckMapping(context.Background(), "broken")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	expected := []string{
+		"broken-2: doc.country_code.keyword is not mapped, expected keyword",
+		"broken-2: doc.english_title is mapped as keyword, expected text",
+		"broken-2: doc.institution.lc_ukprn_name.keyword is not mapped, expected keyword",
+		"broken-2: doc.mode.keyword is mapped as text, expected keyword",
+	}
+	if !reflect.DeepEqual(problems, expected) {
+		t.Errorf("expected problems %v, got %v", expected, problems)
+	}
+
+	if _, err = es.CheckMapping(context.Background(), "missing"); err != errs.ErrUnexpectedStatusCode {
+		t.Errorf("expected an error for a missing index, got %v", err)
+	}
+}
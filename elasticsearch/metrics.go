@@ -0,0 +1,41 @@
+package elasticsearch
+
+import (
+	"net/url"
+	"strings"
+
+	"github.com/ofs/alpha-search-api/metrics"
+)
+
+var (
+	callDuration = metrics.NewHistogramVec(
+		"search_api_elasticsearch_request_duration_seconds",
+		"The time taken for elasticsearch to respond, partitioned by operation.",
+		metrics.DefaultBuckets,
+		"operation",
+	)
+
+	callErrorsTotal = metrics.NewCounterVec(
+		"search_api_elasticsearch_errors_total",
+		"The number of failed calls to elasticsearch, partitioned by operation.",
+		"operation",
+	)
+)
+
+// operation names the elasticsearch api being called from the request url,
+// e.g. "search" for /courses/_search or "cluster/health" for /_cluster/health
+func operation(method string, URL *url.URL) string {
+	path := strings.Trim(URL.Path, "/")
+	if path == "" {
+		return "ping"
+	}
+
+	segments := strings.Split(path, "/")
+	for i, segment := range segments {
+		if strings.HasPrefix(segment, "_") {
+			return strings.TrimPrefix(strings.Join(segments[i:], "/"), "_")
+		}
+	}
+
+	return strings.ToLower(method) + "_index"
+}
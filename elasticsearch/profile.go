@@ -0,0 +1,87 @@
+package elasticsearch
+
+import (
+	"context"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/methods/go-methods-lib/log"
+	"github.com/ofs/alpha-search-api/metrics"
+	"github.com/ofs/alpha-search-api/models"
+)
+
+type contextKey string
+
+const profileKey = contextKey("profile")
+
+var slowQueriesTotal = metrics.NewCounterVec(
+	"search_api_elasticsearch_slow_queries_total",
+	"The number of searches exceeding the slow query threshold, partitioned by query type.",
+	"query",
+)
+
+var whitespace = regexp.MustCompile(`\s+`)
+
+// WithProfile requests that elasticsearch profiles the searches made with the context
+func WithProfile(ctx context.Context) context.Context {
+	return context.WithValue(ctx, profileKey, true)
+}
+
+func profileEnabled(ctx context.Context) bool {
+	profile, _ := ctx.Value(profileKey).(bool)
+	return profile
+}
+
+// slowQuery describes the search made to elasticsearch for the slow query log
+type slowQuery struct {
+	name           string
+	index          string
+	term           string
+	filters        map[string]string
+	countries      []string
+	lengthOfCourse []string
+	institutions   []string
+	subjects       []string
+}
+
+// logSlowQuery records a structured slow query event if the search took longer than the threshold
+func (api *API) logSlowQuery(ctx context.Context, query slowQuery, duration time.Duration, response *models.SearchResponse) {
+	took := time.Duration(response.Took) * time.Millisecond
+	if api.slowQueryThreshold <= 0 || (duration < api.slowQueryThreshold && took < api.slowQueryThreshold) {
+		return
+	}
+
+	slowQueriesTotal.Inc(query.name)
+
+	log.InfoCtx(ctx, "slow query", log.Data{
+		"query":            query.name,
+		"index":            query.index,
+		"normalized_query": normalizeTerm(query.term),
+		"filters":          query.filters,
+		"countries":        query.countries,
+		"length_of_course": query.lengthOfCourse,
+		"institutions":     nonEmpty(query.institutions),
+		"subjects":         nonEmpty(query.subjects),
+		"took_ms":          response.Took,
+		"duration_ms":      duration.Seconds() * 1000,
+		"threshold_ms":     api.slowQueryThreshold.Seconds() * 1000,
+		"hits":             response.Hits.Total,
+	})
+}
+
+// normalizeTerm lowercases and collapses whitespace so that similar searches can be grouped
+func normalizeTerm(term string) string {
+	return whitespace.ReplaceAllString(strings.ToLower(strings.TrimSpace(term)), " ")
+}
+
+func nonEmpty(values []string) []string {
+	var result []string
+	for _, value := range values {
+		if value != "" {
+			result = append(result, value)
+		}
+	}
+
+	return result
+}
@@ -0,0 +1,99 @@
+package elasticsearch
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/methods/go-methods-lib/common"
+	"github.com/methods/go-methods-lib/log"
+	"github.com/ofs/alpha-search-api/models"
+)
+
+// recordEvents replaces the log event writer for the test, returning the events written
+func recordEvents(t *testing.T) *[]log.Data {
+	var events []log.Data
+
+	previous := log.Event
+	log.Event = func(name string, correlationKey string, data log.Data) {
+		if data["message"] == "slow query" {
+			data["correlation_key"] = correlationKey
+			events = append(events, data)
+		}
+	}
+	t.Cleanup(func() { log.Event = previous })
+
+	return &events
+}
+
+func TestSlowQueryThreshold(t *testing.T) {
+	testCases := map[string]struct {
+		threshold time.Duration
+		duration  time.Duration
+		took      int
+		logged    bool
+	}{
+		"disabled":                  {0, time.Hour, 100000, false},
+		"faster than threshold":     {time.Second, 999 * time.Millisecond, 999, false},
+		"duration at threshold":     {time.Second, time.Second, 10, true},
+		"duration over threshold":   {time.Second, 2 * time.Second, 10, true},
+		"elasticsearch took longer": {time.Second, 10 * time.Millisecond, 1000, true},
+	}
+
+	for name, tc := range testCases {
+		events := recordEvents(t)
+		api := &API{slowQueryThreshold: tc.threshold}
+
+		api.logSlowQuery(context.Background(), slowQuery{name: "courses"}, tc.duration, &models.SearchResponse{Took: tc.took})
+
+		if logged := len(*events) == 1; logged != tc.logged {
+			t.Errorf("%s: expected logged %t, got %d events", name, tc.logged, len(*events))
+		}
+	}
+}
+
+func TestSlowQueryRecord(t *testing.T) {
+	events := recordEvents(t)
+	api := &API{slowQueryThreshold: 100 * time.Millisecond}
+
+	query := slowQuery{
+		name:           "institution_courses",
+		index:          "courses",
+		term:           "  Maths\tAND   Physics ",
+		filters:        map[string]string{"part_time": "true"},
+		countries:      []string{"wales"},
+		lengthOfCourse: []string{"3"},
+		institutions:   []string{""},
+		subjects:       []string{"CAH09-01-01", ""},
+	}
+	response := &models.SearchResponse{Took: 120, Hits: models.Hits{Total: 42}}
+	ctx := common.WithRequestId(context.Background(), "request-1")
+
+	api.logSlowQuery(ctx, query, 250*time.Millisecond, response)
+
+	if len(*events) != 1 {
+		t.Fatalf("expected one slow query event, got %d", len(*events))
+	}
+
+	expected := log.Data{
+		"message":          "slow query",
+		"correlation_key":  "request-1",
+		"query":            "institution_courses",
+		"index":            "courses",
+		"normalized_query": "maths and physics",
+		"filters":          map[string]string{"part_time": "true"},
+		"countries":        []string{"wales"},
+		"length_of_course": []string{"3"},
+		"institutions":     []string(nil),
+		"subjects":         []string{"CAH09-01-01"},
+		"took_ms":          120,
+		"duration_ms":      float64(250),
+		"threshold_ms":     float64(100),
+		"hits":             42,
+	}
+
+	if got := (*events)[0]; !reflect.DeepEqual(got, expected) {
+		t.Errorf("unexpected slow query record\nexpected: %#v\ngot:      %#v", expected, got)
+	}
+}
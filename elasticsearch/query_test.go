@@ -0,0 +1,227 @@
+package elasticsearch
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/ofs/alpha-search-api/models"
+)
+
+var update = flag.Bool("update", false, "update the golden files of generated queries")
+
+// filterNames are every filter accepted by the search endpoints
+var filterNames = []string{"distance_learning", "foundation_year", "full_time", "honours_award", "part_time", "sandwich_year", "year_abroad"}
+
+// search holds the arguments of a search as they are passed by the handlers,
+// with countries as given in the request so their conversion to codes is covered
+type search struct {
+	term           string
+	limit, offset  int
+	filters        map[string]string
+	countries      string
+	lengthOfCourse []string
+	institutions   []string
+	subjects       []string
+}
+
+func (s search) countryCodes(t *testing.T) []string {
+	if s.countries == "" {
+		return nil
+	}
+
+	codes, errorObjects := models.ValidateCountries(s.countries)
+	if errorObjects != nil {
+		t.Fatalf("invalid countries %q", s.countries)
+	}
+
+	return codes
+}
+
+// goldenSearches are the searches whose queries are compared to golden files, named by file
+func goldenSearches() map[string]search {
+	none := []string{""}
+
+	searches := map[string]search{
+		"no_term":                 {limit: 20, institutions: none, subjects: none},
+		"term":                    {term: "mathematics", limit: 20, institutions: none, subjects: none},
+		"term_paged":              {term: "computer science", limit: 50, offset: 100, institutions: none, subjects: none},
+		"countries":               {countries: "wales,scotland", limit: 20, institutions: none, subjects: none},
+		"countries_excluded":      {countries: "-wales,-england", limit: 20, institutions: none, subjects: none},
+		"length_of_course":        {lengthOfCourse: []string{"3", "4"}, limit: 20, institutions: none, subjects: none},
+		"institutions":            {institutions: []string{"aberystwyth university", "university of leeds"}, limit: 20, subjects: none},
+		"subjects":                {subjects: []string{"CAH09-01-01", "CAH11-01-01"}, limit: 20, institutions: none},
+		"all_filters_included":    {filters: map[string]string{}, limit: 20, institutions: none, subjects: none},
+		"all_filters_excluded":    {filters: map[string]string{}, limit: 20, institutions: none, subjects: none},
+		"term_with_every_filter":  {term: "history", filters: map[string]string{"foundation_year": "true", "honours_award": "false", "part_time": "true", "year_abroad": "false"}, countries: "-northern_ireland", lengthOfCourse: []string{"1", "2", "3"}, institutions: []string{"queen's university belfast"}, subjects: []string{"CAH20-01-01"}, limit: 10, offset: 10},
+		"filters_without_lists":   {filters: map[string]string{"distance_learning": "true", "sandwich_year": "true"}, limit: 20},
+		"lists_without_filters":   {countries: "england", lengthOfCourse: []string{"7"}, institutions: []string{"university of leeds"}, subjects: []string{"CAH02-04-01"}, limit: 20},
+		"term_and_filter":         {term: "nursing", filters: map[string]string{"full_time": "true"}, limit: 20, institutions: none, subjects: none},
+		"term_and_excluded_lists": {term: "nursing", countries: "-scotland", limit: 20, institutions: none, subjects: none},
+	}
+
+	for _, name := range filterNames {
+		searches["filter_"+name] = search{filters: map[string]string{name: "true"}, limit: 20, institutions: none, subjects: none}
+		searches["filter_not_"+name] = search{filters: map[string]string{name: "false"}, limit: 20, institutions: none, subjects: none}
+
+		searches["all_filters_included"].filters[name] = "true"
+		searches["all_filters_excluded"].filters[name] = "false"
+	}
+
+	return searches
+}
+
+func TestBuildSearchQueryGolden(t *testing.T) {
+	for name, s := range goldenSearches() {
+		query := buildSearchQuery(s.term, s.limit, s.offset, s.filters, s.countryCodes(t), s.lengthOfCourse, s.institutions, s.subjects)
+		checkGolden(t, filepath.Join("testdata", "queries", "courses", name+".json"), query)
+	}
+}
+
+func TestBuildInstitutionSearchQueryGolden(t *testing.T) {
+	for name, s := range goldenSearches() {
+		if s.limit != 20 || s.offset != 0 {
+			// Institution searches are not paged by elasticsearch
+			continue
+		}
+
+		query := buildInstitutionSearchQuery(s.term, s.filters, s.countryCodes(t), s.lengthOfCourse, s.institutions, s.subjects)
+		checkGolden(t, filepath.Join("testdata", "queries", "institution_courses", name+".json"), query)
+	}
+}
+
+// TestQueriesAreDeterministic builds the query for every combination of filters,
+// each of which may be included, excluded or not set, several times over
+func TestQueriesAreDeterministic(t *testing.T) {
+	combinations := 1
+	for range filterNames {
+		combinations *= 3
+	}
+
+	for combination := 0; combination < combinations; combination++ {
+		filters := make(map[string]string)
+
+		n := combination
+		for _, name := range filterNames {
+			switch n % 3 {
+			case 1:
+				filters[name] = "true"
+			case 2:
+				filters[name] = "false"
+			}
+			n /= 3
+		}
+
+		expected := marshal(t, buildSearchQuery("term", 20, 0, filters, nil, nil, nil, nil))
+		expectedInstitution := marshal(t, buildInstitutionSearchQuery("term", filters, nil, nil, nil, nil))
+
+		for i := 0; i < 5; i++ {
+			// Copy the filters so that the map is iterated in a different order
+			copied := make(map[string]string, len(filters))
+			for key, value := range filters {
+				copied[key] = value
+			}
+
+			if query := marshal(t, buildSearchQuery("term", 20, 0, copied, nil, nil, nil, nil)); !bytes.Equal(query, expected) {
+				t.Fatalf("filters %v: courses query is not deterministic:\n%s\n%s", filters, expected, query)
+			}
+			if query := marshal(t, buildInstitutionSearchQuery("term", copied, nil, nil, nil, nil)); !bytes.Equal(query, expectedInstitution) {
+				t.Fatalf("filters %v: institution courses query is not deterministic:\n%s\n%s", filters, expectedInstitution, query)
+			}
+		}
+
+		if len(filters) > 0 && !bytes.Contains(expected, []byte(`"filter"`)) {
+			t.Errorf("filters %v: expected a filter in the query %s", filters, expected)
+		}
+	}
+}
+
+func marshal(t *testing.T, query *Body) []byte {
+	t.Helper()
+
+	b, err := json.MarshalIndent(query, "", "  ")
+	if err != nil {
+		t.Fatalf("failed to marshal query: %v", err)
+	}
+
+	return append(b, '\n')
+}
+
+// TestQueryFields checks every field referred to by the queries built is one whose
+// mapping is checked
+func TestQueryFields(t *testing.T) {
+	fields := QueryFields()
+
+	referenced := make(map[string]bool)
+	for _, s := range goldenSearches() {
+		for _, query := range []*Body{
+			buildSearchQuery(s.term, s.limit, s.offset, s.filters, s.countryCodes(t), s.lengthOfCourse, s.institutions, s.subjects),
+			buildInstitutionSearchQuery(s.term, s.filters, s.countryCodes(t), s.lengthOfCourse, s.institutions, s.subjects),
+		} {
+			var decoded interface{}
+			if err := json.Unmarshal(marshal(t, query), &decoded); err != nil {
+				t.Fatalf("failed to decode query: %v", err)
+			}
+			addFieldNames(decoded, referenced)
+		}
+	}
+
+	for name := range referenced {
+		if _, ok := fields[name]; !ok {
+			t.Errorf("expected %s to be one of the query fields", name)
+		}
+	}
+	for name := range fields {
+		if !referenced[name] {
+			t.Errorf("expected %s to be referred to by a query", name)
+		}
+	}
+}
+
+// addFieldNames adds each key naming a document field in the decoded query
+func addFieldNames(value interface{}, names map[string]bool) {
+	switch value := value.(type) {
+	case map[string]interface{}:
+		for key, child := range value {
+			if strings.HasPrefix(key, "doc.") {
+				names[key] = true
+			}
+			addFieldNames(child, names)
+		}
+	case []interface{}:
+		for _, child := range value {
+			addFieldNames(child, names)
+		}
+	}
+}
+
+// checkGolden compares the query to the golden file, rewriting it instead when -update is given
+func checkGolden(t *testing.T, path string, query *Body) {
+	t.Helper()
+
+	actual := marshal(t, query)
+
+	if *update {
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatalf("failed to create golden file directory: %v", err)
+		}
+		if err := ioutil.WriteFile(path, actual, 0644); err != nil {
+			t.Fatalf("failed to update golden file: %v", err)
+		}
+		return
+	}
+
+	expected, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatalf("failed to read golden file, run go test with -update to create it: %v", err)
+	}
+
+	if !bytes.Equal(actual, expected) {
+		t.Errorf("query does not match %s, run go test with -update if the change is intended:\n%s", path, actual)
+	}
+}
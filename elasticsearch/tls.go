@@ -0,0 +1,69 @@
+package elasticsearch
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"io/ioutil"
+	"net/http"
+
+	"github.com/pkg/errors"
+)
+
+// TLSVersions maps the names of TLS versions to their values
+var TLSVersions = map[string]uint16{
+	"1.0": tls.VersionTLS10,
+	"1.1": tls.VersionTLS11,
+	"1.2": tls.VersionTLS12,
+	"1.3": tls.VersionTLS13,
+}
+
+// NewTLSConfig creates the TLS configuration for connections to elasticsearch,
+// trusting the CA bundle as well as the system roots and presenting the client
+// certificate if given, so that clusters with private CAs or requiring mutual
+// TLS can be used. The files are read immediately so problems are found on startup
+func NewTLSConfig(caFile, certFile, keyFile, serverName, minVersion string) (*tls.Config, error) {
+	config := &tls.Config{ServerName: serverName}
+
+	if minVersion != "" {
+		version, ok := TLSVersions[minVersion]
+		if !ok {
+			return nil, errors.Errorf("unknown TLS version [%s]", minVersion)
+		}
+		config.MinVersion = version
+	}
+
+	if caFile != "" {
+		pem, err := ioutil.ReadFile(caFile)
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to read CA file")
+		}
+
+		pool, err := x509.SystemCertPool()
+		if err != nil {
+			pool = x509.NewCertPool()
+		}
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, errors.Errorf("no certificates found in CA file %s", caFile)
+		}
+		config.RootCAs = pool
+	}
+
+	if certFile != "" || keyFile != "" {
+		certificate, err := tls.LoadX509KeyPair(certFile, keyFile)
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to load client certificate")
+		}
+		config.Certificates = []tls.Certificate{certificate}
+	}
+
+	return config, nil
+}
+
+// NewHTTPClient creates the client requests to elasticsearch are made with,
+// using the TLS configuration given
+func NewHTTPClient(tlsConfig *tls.Config) http.Client {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.TLSClientConfig = tlsConfig
+
+	return http.Client{Transport: transport}
+}
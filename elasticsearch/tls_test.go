@@ -0,0 +1,200 @@
+package elasticsearch_test
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io/ioutil"
+	"log"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ofs/alpha-search-api/elasticsearch"
+)
+
+// certificate is a certificate and key generated for a test
+type certificate struct {
+	cert *x509.Certificate
+	key  *ecdsa.PrivateKey
+	der  []byte
+}
+
+func newCertificate(t *testing.T, template *x509.Certificate, parent *certificate) *certificate {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	template.NotBefore = time.Now().Add(-time.Hour)
+	template.NotAfter = time.Now().Add(time.Hour)
+
+	parentCert, parentKey := template, key
+	if parent != nil {
+		parentCert, parentKey = parent.cert, parent.key
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, parentCert, &key.PublicKey, parentKey)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	return &certificate{cert: cert, key: key, der: der}
+}
+
+// write saves the certificate and key as PEM files, returning their paths
+func (c *certificate) write(t *testing.T, dir, name string) (string, string) {
+	certFile, keyFile := filepath.Join(dir, name+".pem"), filepath.Join(dir, name+"-key.pem")
+
+	key, err := x509.MarshalECPrivateKey(c.key)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if err = ioutil.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: c.der}), 0600); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err = ioutil.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: key}), 0600); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	return certFile, keyFile
+}
+
+func (c *certificate) tls() tls.Certificate {
+	return tls.Certificate{Certificate: [][]byte{c.der}, PrivateKey: c.key}
+}
+
+func TestTLSConfig(t *testing.T) {
+	dir := t.TempDir()
+
+	ca := newCertificate(t, &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "search test ca"},
+		IsCA:                  true,
+		KeyUsage:              x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+	}, nil)
+	server := newCertificate(t, &x509.Certificate{
+		SerialNumber: big.NewInt(2),
+		Subject:      pkix.Name{CommonName: "search.internal"},
+		DNSNames:     []string{"search.internal"},
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}, ca)
+	client := newCertificate(t, &x509.Certificate{
+		SerialNumber: big.NewInt(3),
+		Subject:      pkix.Name{CommonName: "alpha-search-api"},
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}, ca)
+
+	caFile, _ := ca.write(t, dir, "ca")
+	certFile, keyFile := client.write(t, dir, "client")
+
+	clientCAs := x509.NewCertPool()
+	clientCAs.AddCert(ca.cert)
+
+	// The cluster requires a client certificate, and its certificate is only
+	// valid for its name rather than the address connected to
+	es := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		w.Write([]byte(req.TLS.PeerCertificates[0].Subject.CommonName))
+	}))
+	es.TLS = &tls.Config{
+		Certificates: []tls.Certificate{server.tls()},
+		ClientAuth:   tls.RequireAndVerifyClientCert,
+		ClientCAs:    clientCAs,
+		MaxVersion:   tls.VersionTLS12,
+	}
+	es.Config.ErrorLog = log.New(ioutil.Discard, "", 0)
+	es.StartTLS()
+	defer es.Close()
+
+	testCases := map[string]struct {
+		caFile, certFile, keyFile, serverName, minVersion string
+		problem                                           string
+	}{
+		"mutual tls":           {caFile, certFile, keyFile, "search.internal", "1.2", ""},
+		"no client cert":       {caFile, "", "", "search.internal", "1.2", "handshake failure"},
+		"untrusted":            {"", certFile, keyFile, "search.internal", "1.2", "certificate signed by unknown authority"},
+		"no server name":       {caFile, certFile, keyFile, "", "1.2", "doesn't contain any IP SANs"},
+		"minimum version":      {caFile, certFile, keyFile, "search.internal", "1.3", "protocol version"},
+		"default version used": {caFile, certFile, keyFile, "search.internal", "", ""},
+	}
+
+	for name, tc := range testCases {
+		tlsConfig, err := elasticsearch.NewTLSConfig(tc.caFile, tc.certFile, tc.keyFile, tc.serverName, tc.minVersion)
+		if err != nil {
+			t.Errorf("%s: unexpected error: %v", name, err)
+			continue
+		}
+
+		httpClient := elasticsearch.NewHTTPClient(tlsConfig)
+		resp, err := httpClient.Get(es.URL)
+		if tc.problem != "" {
+			if err == nil || !strings.Contains(err.Error(), tc.problem) {
+				t.Errorf("%s: expected %q, got %v", name, tc.problem, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: unexpected error: %v", name, err)
+			continue
+		}
+
+		b, _ := ioutil.ReadAll(resp.Body)
+		resp.Body.Close()
+		if string(b) != "alpha-search-api" {
+			t.Errorf("%s: expected the client certificate to be presented, got %q", name, b)
+		}
+	}
+}
+
+func TestTLSConfigFiles(t *testing.T) {
+	dir := t.TempDir()
+
+	ca := newCertificate(t, &x509.Certificate{SerialNumber: big.NewInt(1), IsCA: true, BasicConstraintsValid: true}, nil)
+	certFile, keyFile := ca.write(t, dir, "ca")
+
+	notPEM := filepath.Join(dir, "not.pem")
+	if err := ioutil.WriteFile(notPEM, []byte("not a certificate"), 0600); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	testCases := map[string]struct {
+		caFile, certFile, keyFile, minVersion string
+		problem                               string
+	}{
+		"missing ca file":   {filepath.Join(dir, "missing.pem"), "", "", "", "failed to read CA file"},
+		"empty ca file":     {notPEM, "", "", "", "no certificates found in CA file"},
+		"missing key":       {"", certFile, "", "", "failed to load client certificate"},
+		"mismatched key":    {"", notPEM, keyFile, "", "failed to load client certificate"},
+		"unknown version":   {"", "", "", "1.4", "unknown TLS version [1.4]"},
+		"valid client pair": {certFile, certFile, keyFile, "1.2", ""},
+	}
+
+	for name, tc := range testCases {
+		_, err := elasticsearch.NewTLSConfig(tc.caFile, tc.certFile, tc.keyFile, "", tc.minVersion)
+		if tc.problem == "" {
+			if err != nil {
+				t.Errorf("%s: unexpected error: %v", name, err)
+			}
+			continue
+		}
+		if err == nil || !strings.Contains(err.Error(), tc.problem) {
+			t.Errorf("%s: expected %q, got %v", name, tc.problem, err)
+		}
+	}
+}
@@ -0,0 +1,133 @@
+package health
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	errs "github.com/ofs/alpha-search-api/apierrors"
+	"github.com/ofs/alpha-search-api/models"
+)
+
+// checkTimeout is the maximum time a single dependency check can take
+const checkTimeout = 5 * time.Second
+
+// shutdownCheck is reported as failing once the service has started shutting down
+const shutdownCheck = "shutdown"
+
+// Checker checks the state of a single dependency, returning an error if it is unhealthy
+type Checker func(ctx context.Context) error
+
+type check struct {
+	name     string
+	checker  Checker
+	critical bool
+}
+
+// Health runs a list of dependency checks, caching the results for the
+// given interval so that frequent probes do not hammer dependencies
+type Health struct {
+	mu          sync.Mutex
+	checks      []check
+	interval    time.Duration
+	lastChecked time.Time
+	results     []models.HealthCheck
+	draining    time.Time
+}
+
+// New creates a Health object which caches check results for interval
+func New(interval time.Duration) *Health {
+	return &Health{
+		interval: interval,
+	}
+}
+
+// AddCheck registers a named dependency check
+func (h *Health) AddCheck(name string, checker Checker) {
+	h.add(check{name: name, checker: checker, critical: true})
+}
+
+// AddWarningCheck registers a named check whose failure is reported as a warning
+// without making the service unready
+func (h *Health) AddWarningCheck(name string, checker Checker) {
+	h.add(check{name: name, checker: checker})
+}
+
+func (h *Health) add(c check) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.checks = append(h.checks, c)
+	h.lastChecked = time.Time{}
+}
+
+// Drain marks the service as shutting down, after which it is reported as not
+// ready whatever the state of its dependencies
+func (h *Health) Drain() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if h.draining.IsZero() {
+		h.draining = time.Now()
+	}
+}
+
+// Ready runs all registered checks (or returns the cached results if they are
+// still fresh) and returns true if every dependency is healthy. The checks are
+// not tied to the probe which happens to run them, as their results are shared
+func (h *Health) Ready() (bool, []models.HealthCheck) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if h.lastChecked.IsZero() || time.Since(h.lastChecked) >= h.interval {
+		h.results = h.run()
+		h.lastChecked = time.Now()
+	}
+
+	ready := true
+	results := make([]models.HealthCheck, len(h.results))
+	for i, result := range h.results {
+		if result.Status == models.HealthStatusFail {
+			ready = false
+		}
+		results[i] = result
+	}
+
+	if !h.draining.IsZero() {
+		ready = false
+		results = append(results, models.HealthCheck{
+			Name:        shutdownCheck,
+			Status:      models.HealthStatusFail,
+			Message:     errs.ErrShuttingDown.Error(),
+			LastChecked: h.draining,
+		})
+	}
+
+	return ready, results
+}
+
+func (h *Health) run() []models.HealthCheck {
+	results := make([]models.HealthCheck, len(h.checks))
+
+	for i, c := range h.checks {
+		result := models.HealthCheck{
+			Name:        c.name,
+			Status:      models.HealthStatusOK,
+			LastChecked: time.Now(),
+		}
+
+		checkCtx, cancel := context.WithTimeout(context.Background(), checkTimeout)
+		if err := c.checker(checkCtx); err != nil {
+			result.Status = models.HealthStatusWarn
+			if c.critical {
+				result.Status = models.HealthStatusFail
+			}
+			result.Message = err.Error()
+		}
+		cancel()
+
+		results[i] = result
+	}
+
+	return results
+}
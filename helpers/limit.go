@@ -20,9 +20,10 @@ func CalculateLimit(ctx context.Context, defaultLimit, maximumLimit int, request
 	var errorValues = make(map[string]string)
 	errorValues["limit"] = requestedLimit
 
+	// Numbers too large to parse are reported as being greater than the maximum
 	requestedLimitNumber, err := strconv.Atoi(requestedLimit)
-	if err != nil {
-		log.ErrorCtx(ctx, errors.WithMessage(err, errs.ErrLimitWrongType.Error()), log.Data{"requested_limit": requestedLimitNumber})
+	if err != nil && !IsOutOfRange(err) {
+		log.ErrorCtx(ctx, errors.WithMessage(err, errs.ErrLimitWrongType.Error()), log.Data{"requested_limit": requestedLimit})
 		return 0, errs.New(errs.ErrLimitWrongType, http.StatusBadRequest, errorValues)
 	}
 
@@ -0,0 +1,40 @@
+package helpers_test
+
+import (
+	"context"
+	"strconv"
+	"testing"
+
+	errs "github.com/ofs/alpha-search-api/apierrors"
+	"github.com/ofs/alpha-search-api/helpers"
+)
+
+func FuzzCalculateLimit(f *testing.F) {
+	for _, seed := range []string{"", "0", "20", "1000", "1001", "-1", "+5", "007", " 5", "five", "1.5", "99999999999999999999", "-99999999999999999999", "٣"} {
+		f.Add(seed)
+	}
+
+	f.Fuzz(func(t *testing.T, requested string) {
+		limit, err := helpers.CalculateLimit(context.Background(), 20, 1000, requested)
+		if err != nil {
+			if errorObject, ok := err.(*errs.ErrorObject); !ok || errorObject.Status() != 400 {
+				t.Fatalf("%q: expected a bad request error, got %v", requested, err)
+			}
+			return
+		}
+
+		if requested == "" {
+			if limit != 20 {
+				t.Fatalf("expected the default limit, got %d", limit)
+			}
+			return
+		}
+
+		if limit < 0 || limit > 1000 {
+			t.Fatalf("%q: limit %d is out of range", requested, limit)
+		}
+		if n, err := strconv.Atoi(requested); err != nil || n != limit {
+			t.Fatalf("%q: accepted as %d, which is not the number given", requested, limit)
+		}
+	})
+}
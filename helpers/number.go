@@ -0,0 +1,10 @@
+package helpers
+
+import "strconv"
+
+// IsOutOfRange returns whether strconv failed to parse a number only because it
+// is too large, in which case the value returned is the nearest it can represent
+func IsOutOfRange(err error) bool {
+	numError, ok := err.(*strconv.NumError)
+	return ok && numError.Err == strconv.ErrRange
+}
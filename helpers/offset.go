@@ -17,17 +17,19 @@ func CalculateOffset(ctx context.Context, requestedOffset string) (offset int, e
 	errorValues["offset"] = requestedOffset
 
 	if requestedOffset != "" {
+		// Numbers too large to parse are reported as being greater than the maximum
+		// offset, which is checked with the page
 		offset, err = strconv.Atoi(requestedOffset)
-		if err != nil {
+		if err != nil && !IsOutOfRange(err) {
 			log.ErrorCtx(ctx, errors.WithMessage(err, errs.ErrOffsetWrongType.Error()), log.Data{"requested_offset": requestedOffset})
 			return 0, errs.New(errs.ErrOffsetWrongType, http.StatusBadRequest, errorValues)
 		}
 
 		if offset < 0 {
-			log.ErrorCtx(ctx, errors.WithMessage(err, errs.ErrNegativeLimit.Error()), log.Data{"requested_offset": requestedOffset})
+			log.ErrorCtx(ctx, errs.ErrNegativeOffset, log.Data{"requested_offset": requestedOffset})
 			return 0, errs.New(errs.ErrNegativeOffset, http.StatusBadRequest, errorValues)
 		}
 	}
 
-	return
+	return offset, nil
 }
@@ -0,0 +1,39 @@
+package helpers_test
+
+import (
+	"context"
+	"strconv"
+	"testing"
+
+	errs "github.com/ofs/alpha-search-api/apierrors"
+	"github.com/ofs/alpha-search-api/helpers"
+)
+
+func FuzzCalculateOffset(f *testing.F) {
+	for _, seed := range []string{"", "0", "20", "-1", "+5", " 5", "five", "1e3", "99999999999999999999", "-99999999999999999999"} {
+		f.Add(seed)
+	}
+
+	f.Fuzz(func(t *testing.T, requested string) {
+		offset, err := helpers.CalculateOffset(context.Background(), requested)
+		if err != nil {
+			if errorObject, ok := err.(*errs.ErrorObject); !ok || errorObject.Status() != 400 {
+				t.Fatalf("%q: expected a bad request error, got %v", requested, err)
+			}
+			return
+		}
+
+		if offset < 0 {
+			t.Fatalf("%q: offset %d is negative", requested, offset)
+		}
+		if requested == "" {
+			if offset != 0 {
+				t.Fatalf("expected no offset, got %d", offset)
+			}
+			return
+		}
+		if n, err := strconv.Atoi(requested); (err != nil && !helpers.IsOutOfRange(err)) || n != offset {
+			t.Fatalf("%q: accepted as %d, which is not the number given", requested, offset)
+		}
+	})
+}
@@ -0,0 +1,19 @@
+package helpers
+
+import (
+	"net/http"
+
+	errs "github.com/ofs/alpha-search-api/apierrors"
+)
+
+// ParseProfile returns whether a profile of the search has been requested
+func ParseProfile(requestedProfile string) (bool, error) {
+	switch requestedProfile {
+	case "", "false":
+		return false, nil
+	case "true":
+		return true, nil
+	}
+
+	return false, errs.New(errs.ErrProfileWrongType, http.StatusBadRequest, map[string]string{"profile": requestedProfile})
+}
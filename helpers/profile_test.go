@@ -0,0 +1,41 @@
+package helpers_test
+
+import (
+	"testing"
+
+	errs "github.com/ofs/alpha-search-api/apierrors"
+	"github.com/ofs/alpha-search-api/helpers"
+)
+
+func TestParseProfile(t *testing.T) {
+	testCases := map[string]bool{
+		"":      false,
+		"false": false,
+		"true":  true,
+	}
+
+	for requested, expected := range testCases {
+		profile, err := helpers.ParseProfile(requested)
+		if err != nil {
+			t.Errorf("%q: unexpected error: %v", requested, err)
+		}
+		if profile != expected {
+			t.Errorf("%q: expected %t, got %t", requested, expected, profile)
+		}
+	}
+
+	for _, requested := range []string{"yes", "1", "TRUE", "True", " true"} {
+		profile, err := helpers.ParseProfile(requested)
+
+		errorObject, ok := err.(*errs.ErrorObject)
+		if !ok || errorObject.Status() != 400 || errorObject.Error() != errs.ErrProfileWrongType.Error() {
+			t.Errorf("%q: expected a bad request error, got %v", requested, err)
+		}
+		if errorObject != nil && errorObject.Values()["profile"] != requested {
+			t.Errorf("%q: expected the value in the error, got %v", requested, errorObject.Values())
+		}
+		if profile {
+			t.Errorf("%q: expected no profile", requested)
+		}
+	}
+}
@@ -9,12 +9,6 @@ const WordSeparator = ","
 // StringifyWords concatenates a list of strings (string array)
 // into a single string with the WordSeparator defining where
 // a word ends and new one begins
-func StringifyWords(words []string) (w string) {
-	for _, v := range words {
-		w = w + v + WordSeparator
-	}
-
-	w = strings.TrimSuffix(w, WordSeparator)
-
-	return w
+func StringifyWords(words []string) string {
+	return strings.Join(words, WordSeparator)
 }
@@ -0,0 +1,182 @@
+package indexer
+
+import (
+	"context"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/methods/go-methods-lib/log"
+	errs "github.com/ofs/alpha-search-api/apierrors"
+	"github.com/pkg/errors"
+)
+
+// IndexTimeFormat is the layout of the time in the name of each index built for an alias
+const IndexTimeFormat = "20060102150405"
+
+// IndexName returns the name of the index built for the alias at the given time
+func IndexName(alias string, t time.Time) string {
+	return alias + "-" + t.UTC().Format(IndexTimeFormat)
+}
+
+// Aliases is the part of the elasticsearch client used to build an index and swap
+// an alias to it
+type Aliases interface {
+	Elasticsearch
+	ResolveAlias(ctx context.Context, alias string) (string, error)
+	Count(ctx context.Context, index string) (int, error)
+	Indices(ctx context.Context, pattern string) ([]string, error)
+	SwapAlias(ctx context.Context, alias, index string, previous []string) error
+	DeleteIndex(ctx context.Context, index string) error
+}
+
+// AliasReport describes the indices an alias referred to before and after a build
+type AliasReport struct {
+	Name     string   `json:"name"`
+	Previous []string `json:"previous,omitempty"`
+	Swapped  bool     `json:"swapped"`
+	Pruned   []string `json:"pruned,omitempty"`
+}
+
+// Builder loads courses into a new index, and swaps an alias to it once it holds
+// every course, so that searches against the alias never see a partly built index
+type Builder struct {
+	es       Aliases
+	loader   *Loader
+	alias    string
+	keep     int
+	minRatio float64
+}
+
+// NewBuilder creates a builder which swaps the alias to the index the loader adds
+// courses to. The index is refused if it holds fewer than minRatio times the
+// documents behind the alias, and the newest keep indices previously built for the
+// alias are kept so that it can be swapped back
+func NewBuilder(es Aliases, loader *Loader, alias string, keep int, minRatio float64) *Builder {
+	return &Builder{
+		es:       es,
+		loader:   loader,
+		alias:    alias,
+		keep:     keep,
+		minRatio: minRatio,
+	}
+}
+
+// Build creates the index and loads the courses into it, then checks the number of
+// documents it holds before swapping the alias and pruning old indices. An index
+// which fails the checks is left in place, behind no alias, to be looked into
+func (b *Builder) Build(ctx context.Context, courses []*Course, report *Report) error {
+	index := b.loader.index
+	report.Index = index
+	report.Alias = &AliasReport{Name: b.alias}
+
+	previous, err := b.previous(ctx)
+	if err != nil {
+		return err
+	}
+	report.Alias.Previous = previous
+
+	previousCount := 0
+	if len(previous) > 0 {
+		if previousCount, err = b.es.Count(ctx, b.alias); err != nil {
+			return errors.WithMessage(err, "failed to count documents behind alias "+b.alias)
+		}
+	}
+
+	if err = b.es.CreateIndex(ctx, index, Mappings); err != nil {
+		return errors.WithMessage(err, "failed to create index "+index)
+	}
+
+	if err = b.loader.Load(ctx, courses, report); err != nil {
+		return err
+	}
+
+	if err = b.check(ctx, index, previousCount, report); err != nil {
+		return err
+	}
+
+	if err = b.es.SwapAlias(ctx, b.alias, index, previous); err != nil {
+		return errors.WithMessage(err, "failed to swap alias "+b.alias)
+	}
+	report.Alias.Swapped = true
+
+	log.InfoCtx(ctx, "swapped alias to index", log.Data{"alias": b.alias, "index": index, "previous": previous})
+
+	return b.prune(ctx, index, report)
+}
+
+// previous returns the indices behind the alias, if it exists
+func (b *Builder) previous(ctx context.Context) ([]string, error) {
+	resolved, err := b.es.ResolveAlias(ctx, b.alias)
+	if err == errs.ErrIndexNotFound {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, errors.WithMessage(err, "failed to resolve alias "+b.alias)
+	}
+
+	if resolved == b.alias {
+		return nil, errors.Errorf("%s is an index rather than an alias, it must be deleted before an alias of the same name can be created", b.alias)
+	}
+
+	return strings.Split(resolved, ","), nil
+}
+
+// check returns an error if the index does not hold every course indexed, or holds
+// far fewer documents than were behind the alias
+func (b *Builder) check(ctx context.Context, index string, previousCount int, report *Report) error {
+	if len(report.Failed) > 0 {
+		return errors.Errorf("%d courses failed to be indexed into %s", len(report.Failed), index)
+	}
+
+	count, err := b.es.Count(ctx, index)
+	if err != nil {
+		return errors.WithMessage(err, "failed to count documents in index "+index)
+	}
+
+	if count == 0 {
+		return errors.Errorf("index %s holds no documents", index)
+	}
+	if count != report.Documents {
+		return errors.Errorf("index %s holds %d documents, expected %d", index, count, report.Documents)
+	}
+	if float64(count) < b.minRatio*float64(previousCount) {
+		return errors.Errorf("index %s holds %d documents, fewer than %g times the %d behind alias %s", index, count, b.minRatio, previousCount, b.alias)
+	}
+
+	return nil
+}
+
+// prune deletes the indices built for the alias which it no longer refers to,
+// other than the newest few
+func (b *Builder) prune(ctx context.Context, index string, report *Report) error {
+	names, err := b.es.Indices(ctx, b.alias+"-*")
+	if err != nil {
+		return errors.WithMessage(err, "failed to list indices built for alias "+b.alias)
+	}
+
+	var old []string
+	for _, name := range names {
+		if name == index {
+			continue
+		}
+		// Only indices named by IndexName are pruned, not others sharing the prefix
+		if _, err = time.Parse(IndexTimeFormat, strings.TrimPrefix(name, b.alias+"-")); err == nil {
+			old = append(old, name)
+		}
+	}
+
+	sort.Sort(sort.Reverse(sort.StringSlice(old)))
+	if len(old) <= b.keep {
+		return nil
+	}
+
+	for _, name := range old[b.keep:] {
+		if err = b.es.DeleteIndex(ctx, name); err != nil {
+			return errors.WithMessage(err, "failed to prune index "+name)
+		}
+		report.Alias.Pruned = append(report.Alias.Pruned, name)
+	}
+
+	return nil
+}
@@ -0,0 +1,164 @@
+package indexer_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ofs/alpha-search-api/elasticsearch"
+	"github.com/ofs/alpha-search-api/indexer"
+	"github.com/ofs/alpha-search-api/models"
+)
+
+func TestIndexName(t *testing.T) {
+	at := time.Date(2026, time.October, 19, 15, 4, 5, 0, time.FixedZone("BST", 3600))
+
+	if name := indexer.IndexName("courses", at); name != "courses-20261019140405" {
+		t.Errorf("expected the time in UTC, got %q", name)
+	}
+}
+
+func TestBuilderSwapsAlias(t *testing.T) {
+	es := &fakeElasticsearch{
+		resolved: "courses-20261018000000",
+		counts:   map[string]int{"courses": 3},
+		indices:  []string{"courses-20261016000000", "courses-20261017000000", "courses-20261018000000", "courses-20261019000000", "courses-archive"},
+	}
+	loader := indexer.NewLoader(es, "courses-20261019000000", 2, 0, 0)
+
+	report := &indexer.Report{}
+	if err := indexer.NewBuilder(es, loader, "courses", 2, 0.9).Build(context.Background(), courses(t, "A", "B", "C"), report); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if es.created == nil {
+		t.Error("expected the index to be created")
+	}
+	if expected := []string{"courses", "courses-20261019000000", "courses-20261018000000"}; !reflect.DeepEqual(es.swapped, expected) {
+		t.Errorf("expected the alias to be swapped from the previous index, got %v", es.swapped)
+	}
+
+	// The alias can be swapped back to the two newest indices, and others sharing the prefix are left alone
+	if expected := []string{"courses-20261016000000"}; !reflect.DeepEqual(es.deleted, expected) {
+		t.Errorf("expected the oldest index to be pruned, got %v", es.deleted)
+	}
+
+	if report.Index != "courses-20261019000000" || report.Documents != 3 || report.Version != indexer.MappingsVersion {
+		t.Errorf("unexpected report %+v", report)
+	}
+	if alias := report.Alias; alias.Name != "courses" || !alias.Swapped || len(alias.Previous) != 1 || len(alias.Pruned) != 1 {
+		t.Errorf("unexpected alias report %+v", alias)
+	}
+}
+
+func TestBuilderCreatesAlias(t *testing.T) {
+	es := &fakeElasticsearch{}
+	loader := indexer.NewLoader(es, "courses-20261019000000", 2, 0, 0)
+
+	// Courses read twice replace the document indexed
+	report := &indexer.Report{}
+	if err := indexer.NewBuilder(es, loader, "courses", 2, 0.9).Build(context.Background(), courses(t, "A", "B", "A"), report); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if expected := []string{"courses", "courses-20261019000000"}; !reflect.DeepEqual(es.swapped, expected) {
+		t.Errorf("expected the alias to be created, got %v", es.swapped)
+	}
+	if report.Indexed != 3 || report.Documents != 2 {
+		t.Errorf("expected 2 documents from 3 courses, got %+v", report)
+	}
+}
+
+func TestBuilderRefusesIndex(t *testing.T) {
+	testCases := map[string]struct {
+		es      *fakeElasticsearch
+		courses []string
+		err     string
+	}{
+		"an index named as the alias": {
+			es:      &fakeElasticsearch{resolved: "courses"},
+			courses: []string{"A"},
+			err:     "courses is an index rather than an alias",
+		},
+		"too few documents": {
+			es:      &fakeElasticsearch{resolved: "courses-20261018000000", counts: map[string]int{"courses": 4}},
+			courses: []string{"A", "B", "C"},
+			err:     "fewer than 0.9 times the 4 behind alias courses",
+		},
+		"documents missing": {
+			es:      &fakeElasticsearch{counts: map[string]int{"courses-20261019000000": 2}},
+			courses: []string{"A", "B", "C"},
+			err:     "holds 2 documents, expected 3",
+		},
+		"no documents": {
+			es:  &fakeElasticsearch{},
+			err: "holds no documents",
+		},
+		"failed courses": {
+			es:      &fakeElasticsearch{results: []bulkResult{{statuses: []int{http.StatusCreated, http.StatusBadRequest}}}},
+			courses: []string{"A", "B"},
+			err:     "1 courses failed to be indexed",
+		},
+	}
+
+	for name, tc := range testCases {
+		loader := indexer.NewLoader(tc.es, "courses-20261019000000", 2, 0, 0)
+
+		err := indexer.NewBuilder(tc.es, loader, "courses", 2, 0.9).Build(context.Background(), courses(t, tc.courses...), &indexer.Report{})
+		if err == nil || !strings.Contains(err.Error(), tc.err) {
+			t.Errorf("%s: expected an error containing %q, got %v", name, tc.err, err)
+		}
+		if tc.es.swapped != nil || tc.es.deleted != nil {
+			t.Errorf("%s: expected the alias not to be swapped, got %v", name, tc.es.swapped)
+		}
+	}
+}
+
+func TestMappings(t *testing.T) {
+	var mapping models.IndexMapping
+	if err := json.Unmarshal(indexer.Mappings, &mapping); err != nil {
+		t.Fatalf("expected the mappings to be valid json: %v", err)
+	}
+
+	// Every field searched, filtered or sorted on must be mapped as the query builder expects
+	fields := elasticsearch.MappedFields(mapping.Mappings["_doc"].Properties)
+	for _, problem := range elasticsearch.CheckFields(fields) {
+		t.Error(problem)
+	}
+
+	var index struct {
+		Mappings struct {
+			Doc struct {
+				Meta struct {
+					Version int `json:"version"`
+				} `json:"_meta"`
+				Properties struct {
+					Doc struct {
+						Properties map[string]struct {
+							Analyzer string `json:"analyzer"`
+						} `json:"properties"`
+					} `json:"doc"`
+				} `json:"properties"`
+			} `json:"_doc"`
+		} `json:"mappings"`
+	}
+	if err := json.Unmarshal(indexer.Mappings, &index); err != nil {
+		t.Fatalf("expected the mappings to be valid json: %v", err)
+	}
+
+	if version := index.Mappings.Doc.Meta.Version; version != indexer.MappingsVersion {
+		t.Errorf("expected the mappings to be version %d, got %d", indexer.MappingsVersion, version)
+	}
+
+	properties := index.Mappings.Doc.Properties.Doc.Properties
+	if analyzer := properties["english_title"].Analyzer; analyzer != "english" {
+		t.Errorf("expected english titles to be analysed as english, got %q", analyzer)
+	}
+	if analyzer := properties["welsh_title"].Analyzer; analyzer != "welsh" {
+		t.Errorf("expected welsh titles to be analysed as welsh, got %q", analyzer)
+	}
+}
@@ -0,0 +1,73 @@
+package indexer
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/ofs/alpha-search-api/models"
+	"github.com/pkg/errors"
+)
+
+// Course is a course document as stored in the index, including the keyword
+// fields used by filters which are not returned by the api
+type Course struct {
+	models.Document
+	CountryCode          string `json:"country_code"`
+	DistanceLearningCode string `json:"distance_learning_code"`
+}
+
+// source is the document stored in the index for each course
+type source struct {
+	Doc *Course `json:"doc"`
+}
+
+// ID identifies the course in the index, so loading the same courses again
+// replaces them rather than adding duplicates. Courses are published by each
+// institution once per mode of study
+func (c *Course) ID() string {
+	return fmt.Sprintf("%s-%s-%s", c.Institution.UKPRN, c.KISCourseID, strings.ToLower(c.Mode))
+}
+
+// prepare checks the course can be searched and derives the fields used for
+// filtering and sorting which are missing from older documents
+func (c *Course) prepare() error {
+	if c.KISCourseID == "" {
+		return errors.New("kis_course_id is required")
+	}
+	if c.Institution == nil {
+		return fmt.Errorf("course [%s] has no institution", c.KISCourseID)
+	}
+	if c.Institution.UKPRN == "" {
+		return fmt.Errorf("course [%s] has no institution ukprn", c.KISCourseID)
+	}
+
+	if c.CountryCode == "" {
+		c.CountryCode = models.CountryCodes[c.Country]
+	}
+	if c.Country == "" {
+		for name, code := range models.CountryCodes {
+			if code == c.CountryCode {
+				c.Country = name
+			}
+		}
+	}
+	if c.DistanceLearningCode == "" {
+		c.DistanceLearningCode = c.DistanceLearning
+	}
+
+	if c.Institution.LCUKPRNName == "" {
+		c.Institution.LCUKPRNName = strings.ToLower(c.Institution.UKPRNName)
+	}
+	if c.SortName == "" {
+		c.SortName = strings.ToLower(c.Institution.PublicUKPRNName)
+	}
+
+	// Fields added to search results by the api are not stored
+	c.Score = 0
+	c.Matches = models.Matches{}
+	c.Institution.Score = 0
+	c.Institution.Count = 0
+	c.Institution.Courses = nil
+
+	return nil
+}
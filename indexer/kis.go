@@ -0,0 +1,221 @@
+package indexer
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/ofs/alpha-search-api/models"
+	"github.com/pkg/errors"
+)
+
+// Files of a KIS export read by ReadKIS, only KISCOURSE.csv is required
+const (
+	KISCourseFile      = "KISCOURSE.csv"
+	KISInstitutionFile = "INSTITUTION.csv"
+	KISSubjectFile     = "SBJ.csv"
+	KISUKRLPFile       = "UKRLP.csv"
+)
+
+// kisModes are the modes of study for each KISMODE code
+var kisModes = map[string]string{
+	"1": "Full-time",
+	"2": "Part-time",
+}
+
+// kisAvailability describes the FOUNDATION, SANDWICH and YEARABROAD codes
+var kisAvailability = map[string]string{
+	"0": "Not available",
+	"1": "Optional",
+	"2": "Compulsory",
+}
+
+// kisHonours describes the HONOURS codes
+var kisHonours = map[string]string{
+	"0": "Not available",
+	"1": "Available",
+}
+
+// ReadKIS reads the courses in a KIS export directory, taking the country of each
+// institution from INSTITUTION.csv, institution names from UKRLP.csv and the first
+// subject of each course from SBJ.csv when they are present. Courses with codes
+// which are not recognised are rejected
+func ReadKIS(dir string) ([]*Course, []Rejected, error) {
+	countries := make(map[string]string)
+	if err := readOptionalCSV(filepath.Join(dir, KISInstitutionFile), func(row csvRow) error {
+		countries[row.get("PUBUKPRN")] = row.get("PUBUKPRNCOUNTRY")
+		return nil
+	}); err != nil {
+		return nil, nil, err
+	}
+
+	names := make(map[string]string)
+	if err := readOptionalCSV(filepath.Join(dir, KISUKRLPFile), func(row csvRow) error {
+		names[row.get("UKPRN")] = row.get("LEGAL_NAME")
+		return nil
+	}); err != nil {
+		return nil, nil, err
+	}
+
+	subjects := make(map[string]string)
+	if err := readOptionalCSV(filepath.Join(dir, KISSubjectFile), func(row csvRow) error {
+		key := kisCourseKey(row)
+		if _, ok := subjects[key]; !ok {
+			subjects[key] = row.get("SBJ")
+		}
+		return nil
+	}); err != nil {
+		return nil, nil, err
+	}
+
+	var courses []*Course
+	var rejected []Rejected
+
+	err := readCSV(filepath.Join(dir, KISCourseFile), func(row csvRow) error {
+		course, err := kisCourse(row, countries, names, subjects)
+		if err == nil {
+			err = course.prepare()
+		}
+		if err != nil {
+			rejected = append(rejected, Rejected{Source: KISCourseFile, Record: row.record, Reason: err.Error()})
+			return nil
+		}
+
+		courses = append(courses, course)
+		return nil
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return courses, rejected, nil
+}
+
+func kisCourse(row csvRow, countries, names, subjects map[string]string) (*Course, error) {
+	id := row.get("KISCOURSEID")
+
+	mode, ok := kisModes[row.get("KISMODE")]
+	if !ok {
+		return nil, fmt.Errorf("course [%s] has an unknown KISMODE [%s]", id, row.get("KISMODE"))
+	}
+
+	course := &Course{
+		Document: models.Document{
+			KISCourseID:      id,
+			EnglishTitle:     row.get("TITLE"),
+			WelshTitle:       row.get("TITLEW"),
+			Link:             row.get("CRSEURL"),
+			Mode:             mode,
+			DistanceLearning: row.get("DISTANCE"),
+			LengthOfCourse:   row.get("NUMSTAGE"),
+			NHSFunded:        row.get("NHS"),
+			SubjectCode:      subjects[kisCourseKey(row)],
+			Institution: &models.Institution{
+				PublicUKPRN:     row.get("PUBUKPRN"),
+				PublicUKPRNName: names[row.get("PUBUKPRN")],
+				UKPRN:           row.get("UKPRN"),
+				UKPRNName:       names[row.get("UKPRN")],
+			},
+		},
+		CountryCode: countries[row.get("PUBUKPRN")],
+	}
+
+	if code := row.get("KISAIMCODE"); code != "" {
+		course.Qualification = &models.Qualification{Code: code}
+	}
+
+	for _, field := range []struct {
+		column string
+		codes  map[string]string
+		value  *string
+	}{
+		{"FOUNDATION", kisAvailability, &course.FoundationYear},
+		{"SANDWICH", kisAvailability, &course.SandwichYear},
+		{"YEARABROAD", kisAvailability, &course.YearAbroad},
+		{"HONOURS", kisHonours, &course.HonoursAward},
+	} {
+		code := row.get(field.column)
+		if code == "" {
+			continue
+		}
+
+		value, ok := field.codes[code]
+		if !ok {
+			return nil, fmt.Errorf("course [%s] has an unknown %s [%s]", id, field.column, code)
+		}
+		*field.value = value
+	}
+
+	return course, nil
+}
+
+// kisCourseKey identifies a course across the files of a KIS export
+func kisCourseKey(row csvRow) string {
+	return row.get("PUBUKPRN") + "|" + row.get("KISCOURSEID") + "|" + row.get("KISMODE")
+}
+
+// csvRow is a record of a CSV file, with values looked up by column name
+type csvRow struct {
+	record  int
+	columns map[string]int
+	values  []string
+}
+
+func (r csvRow) get(column string) string {
+	i, ok := r.columns[column]
+	if !ok || i >= len(r.values) {
+		return ""
+	}
+
+	return strings.TrimSpace(r.values[i])
+}
+
+// readOptionalCSV reads a CSV file if it exists
+func readOptionalCSV(path string, fn func(row csvRow) error) error {
+	if _, err := os.Stat(path); os.IsNotExist(err) {
+		return nil
+	}
+
+	return readCSV(path, fn)
+}
+
+// readCSV calls fn with each record of a CSV file, naming columns by its header
+func readCSV(path string, fn func(row csvRow) error) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return errors.Wrap(err, "failed to open KIS file")
+	}
+	defer f.Close()
+
+	reader := csv.NewReader(f)
+	reader.FieldsPerRecord = -1
+	reader.LazyQuotes = true
+
+	header, err := reader.Read()
+	if err != nil {
+		return errors.Wrapf(err, "failed to read header of %s", filepath.Base(path))
+	}
+
+	columns := make(map[string]int)
+	for i, column := range header {
+		// Exports saved by spreadsheets may begin with a byte order mark
+		column = strings.TrimPrefix(column, "\ufeff")
+		columns[strings.ToUpper(strings.TrimSpace(column))] = i
+	}
+
+	for record := 1; ; record++ {
+		values, err := reader.Read()
+		if err == io.EOF {
+			return nil
+		} else if err != nil {
+			return errors.Wrapf(err, "failed to read %s", filepath.Base(path))
+		}
+
+		if err = fn(csvRow{record: record, columns: columns, values: values}); err != nil {
+			return err
+		}
+	}
+}
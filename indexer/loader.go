@@ -0,0 +1,270 @@
+package indexer
+
+import (
+	"bytes"
+	"context"
+	_ "embed" // required to embed the mappings
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/methods/go-methods-lib/log"
+	errs "github.com/ofs/alpha-search-api/apierrors"
+	"github.com/ofs/alpha-search-api/models"
+	"github.com/pkg/errors"
+)
+
+// Mappings declare the analysers and the type of every field stored, and are sent
+// when creating the index
+//
+//go:embed mappings.json
+var Mappings []byte
+
+// MappingsVersion is recorded in the mappings' _meta, and must be increased whenever
+// they change so that the index an alias refers to shows which were used to build it
+const MappingsVersion = 2
+
+// Elasticsearch is the part of the elasticsearch client used to load courses
+type Elasticsearch interface {
+	IndexExists(ctx context.Context, index string) error
+	CreateIndex(ctx context.Context, index string, body []byte) error
+	Bulk(ctx context.Context, body []byte) (*models.BulkResponse, int, error)
+	Refresh(ctx context.Context, index string) error
+}
+
+// Loader indexes courses with the bulk api, in batches which are retried when
+// elasticsearch is unavailable or rejects actions as it is too busy
+type Loader struct {
+	es            Elasticsearch
+	index         string
+	batchSize     int
+	retries       int
+	retryInterval time.Duration
+}
+
+// NewLoader creates a loader adding courses to the index. Failed batches are
+// retried up to the given number of times, waiting twice as long each time
+func NewLoader(es Elasticsearch, index string, batchSize, retries int, retryInterval time.Duration) *Loader {
+	return &Loader{
+		es:            es,
+		index:         index,
+		batchSize:     batchSize,
+		retries:       retries,
+		retryInterval: retryInterval,
+	}
+}
+
+// Report describes the outcome of loading courses
+type Report struct {
+	Index     string       `json:"index"`
+	Version   int          `json:"mappings_version"`
+	Read      int          `json:"read"`
+	Rejected  []Rejected   `json:"rejected,omitempty"`
+	Indexed   int          `json:"indexed"`
+	Documents int          `json:"documents"`
+	Failed    []Failure    `json:"failed,omitempty"`
+	Batches   int          `json:"batches"`
+	Retries   int          `json:"retries"`
+	Duration  string       `json:"duration"`
+	Alias     *AliasReport `json:"alias,omitempty"`
+
+	ids map[string]bool
+}
+
+// Failure is a course elasticsearch did not index
+type Failure struct {
+	ID     string `json:"id"`
+	Status int    `json:"status,omitempty"`
+	Type   string `json:"type,omitempty"`
+	Reason string `json:"reason"`
+}
+
+// OK returns true if every course read was indexed
+func (r *Report) OK() bool {
+	return len(r.Rejected) == 0 && len(r.Failed) == 0
+}
+
+// indexed counts a course as indexed. Documents counts each id once, as a course
+// read more than once replaces the document already indexed
+func (r *Report) indexed(id string) {
+	if r.ids == nil {
+		r.ids = make(map[string]bool)
+	}
+
+	r.Indexed++
+	if !r.ids[id] {
+		r.ids[id] = true
+		r.Documents++
+	}
+}
+
+// CreateIndex creates the index with the mappings unless it already exists,
+// returning whether it was created
+func (l *Loader) CreateIndex(ctx context.Context) (bool, error) {
+	err := l.es.IndexExists(ctx, l.index)
+	if err == nil {
+		return false, nil
+	}
+	if err != errs.ErrIndexNotFound {
+		return false, errors.WithMessage(err, "failed to check whether index "+l.index+" exists")
+	}
+
+	if err = l.es.CreateIndex(ctx, l.index, Mappings); err != nil {
+		return false, errors.WithMessage(err, "failed to create index "+l.index)
+	}
+
+	return true, nil
+}
+
+// action is a course to index, as the two lines sent to the bulk api
+type action struct {
+	id   string
+	body []byte
+}
+
+// Load indexes the courses, adding those which fail to the report, and refreshes
+// the index so they can be searched straight away
+func (l *Loader) Load(ctx context.Context, courses []*Course, report *Report) error {
+	start := time.Now()
+	report.Index = l.index
+	report.Version = MappingsVersion
+
+	var batch []action
+	for i, course := range courses {
+		a, err := l.action(course)
+		if err != nil {
+			return err
+		}
+		batch = append(batch, a)
+
+		if len(batch) < l.batchSize && i < len(courses)-1 {
+			continue
+		}
+
+		if err = l.send(ctx, batch, report); err != nil {
+			return err
+		}
+		batch = nil
+
+		log.InfoCtx(ctx, "indexed batch of courses", log.Data{
+			"index":   l.index,
+			"batch":   report.Batches,
+			"indexed": report.Indexed,
+			"failed":  len(report.Failed),
+			"total":   len(courses),
+		})
+	}
+
+	report.Duration = time.Since(start).String()
+
+	if err := l.es.Refresh(ctx, l.index); err != nil {
+		return errors.WithMessage(err, "failed to refresh index "+l.index)
+	}
+
+	return nil
+}
+
+func (l *Loader) action(course *Course) (action, error) {
+	meta := map[string]map[string]string{"index": {"_index": l.index, "_type": "_doc", "_id": course.ID()}}
+
+	var body bytes.Buffer
+	encoder := json.NewEncoder(&body)
+	if err := encoder.Encode(meta); err != nil {
+		return action{}, errors.Wrap(err, "failed to marshal bulk action")
+	}
+	if err := encoder.Encode(source{Doc: course}); err != nil {
+		return action{}, errors.Wrapf(err, "failed to marshal course [%s]", course.KISCourseID)
+	}
+
+	return action{id: course.ID(), body: body.Bytes()}, nil
+}
+
+// send indexes a batch, retrying the whole batch if elasticsearch could not be
+// reached and any actions it rejected as too many requests were being handled
+func (l *Loader) send(ctx context.Context, batch []action, report *Report) error {
+	report.Batches++
+
+	for attempt := 0; ; attempt++ {
+		var body []byte
+		for _, a := range batch {
+			body = append(body, a.body...)
+		}
+
+		response, status, err := l.es.Bulk(ctx, body)
+
+		var retry []action
+		switch {
+		case err != nil && retryable(status, err):
+			retry = batch
+		case err != nil:
+			l.fail(batch, status, err.Error(), report)
+		case len(response.Items) != len(batch):
+			l.fail(batch, status, "bulk response does not hold a result for every course", report)
+		default:
+			for i, item := range response.Items {
+				result := bulkResult(item)
+				switch {
+				case result.Status >= http.StatusOK && result.Status < http.StatusMultipleChoices && result.Error == nil:
+					report.indexed(batch[i].id)
+				case result.Status == http.StatusTooManyRequests:
+					retry = append(retry, batch[i])
+				default:
+					failure := Failure{ID: batch[i].id, Status: result.Status}
+					if result.Error != nil {
+						failure.Type = result.Error.Type
+						failure.Reason = result.Error.Reason
+					}
+					report.Failed = append(report.Failed, failure)
+				}
+			}
+		}
+
+		if len(retry) == 0 {
+			return nil
+		}
+
+		reason := "elasticsearch was too busy to index the course"
+		if err != nil {
+			reason = err.Error()
+		}
+
+		if attempt >= l.retries {
+			l.fail(retry, status, reason, report)
+			return nil
+		}
+
+		wait := l.retryInterval << uint(attempt)
+		log.InfoCtx(ctx, "retrying bulk request", log.Data{"index": l.index, "attempt": attempt + 1, "courses": len(retry), "reason": reason, "retry_interval": wait})
+
+		select {
+		case <-time.After(wait):
+		case <-ctx.Done():
+			l.fail(retry, 0, ctx.Err().Error(), report)
+			return ctx.Err()
+		}
+
+		report.Retries++
+		batch = retry
+	}
+}
+
+func (l *Loader) fail(batch []action, status int, reason string, report *Report) {
+	for _, a := range batch {
+		report.Failed = append(report.Failed, Failure{ID: a.id, Status: status, Reason: reason})
+	}
+}
+
+// retryable returns true if the bulk request failed as elasticsearch could not be
+// reached or was temporarily unable to handle it
+func retryable(status int, err error) bool {
+	return status == 0 || status == http.StatusTooManyRequests || status >= http.StatusInternalServerError || err == errs.ErrCircuitBreakerOpen
+}
+
+// bulkResult returns the result of an action, which is keyed by the action's name
+func bulkResult(item map[string]models.BulkItem) models.BulkItem {
+	for _, result := range item {
+		return result
+	}
+
+	return models.BulkItem{}
+}
@@ -0,0 +1,269 @@
+package indexer_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	errs "github.com/ofs/alpha-search-api/apierrors"
+	"github.com/ofs/alpha-search-api/indexer"
+	"github.com/ofs/alpha-search-api/models"
+	"github.com/pkg/errors"
+)
+
+// bulkResult is the response to a bulk request, or an error with its status
+type bulkResult struct {
+	statuses []int
+	status   int
+	err      error
+}
+
+// fakeElasticsearch answers bulk requests in turn with the results given,
+// indexing every action once they run out
+type fakeElasticsearch struct {
+	exists    bool
+	existsErr error
+	created   []byte
+	refreshed bool
+	results   []bulkResult
+	requests  [][]string
+	documents map[string]bool
+
+	// The alias resolves to the indices given, with counts overriding the number
+	// of documents indexed
+	resolved string
+	counts   map[string]int
+	indices  []string
+	swapped  []string
+	deleted  []string
+}
+
+func (f *fakeElasticsearch) IndexExists(ctx context.Context, index string) error {
+	if f.existsErr != nil {
+		return f.existsErr
+	}
+	if !f.exists {
+		return errs.ErrIndexNotFound
+	}
+	return nil
+}
+
+func (f *fakeElasticsearch) CreateIndex(ctx context.Context, index string, body []byte) error {
+	f.created = body
+	return nil
+}
+
+func (f *fakeElasticsearch) Refresh(ctx context.Context, index string) error {
+	f.refreshed = true
+	return nil
+}
+
+func (f *fakeElasticsearch) Bulk(ctx context.Context, body []byte) (*models.BulkResponse, int, error) {
+	lines := strings.Split(strings.TrimSuffix(string(body), "\n"), "\n")
+
+	var ids []string
+	for i := 0; i < len(lines); i += 2 {
+		var meta map[string]map[string]string
+		if err := json.Unmarshal([]byte(lines[i]), &meta); err != nil {
+			return nil, http.StatusBadRequest, err
+		}
+		ids = append(ids, meta["index"]["_id"])
+	}
+	f.requests = append(f.requests, ids)
+
+	result := bulkResult{}
+	if len(f.results) > 0 {
+		result, f.results = f.results[0], f.results[1:]
+	}
+	if result.err != nil {
+		return nil, result.status, result.err
+	}
+
+	if f.documents == nil {
+		f.documents = make(map[string]bool)
+	}
+
+	response := &models.BulkResponse{}
+	for i, id := range ids {
+		item := models.BulkItem{ID: id, Status: http.StatusCreated}
+		if i < len(result.statuses) {
+			item.Status = result.statuses[i]
+		}
+		if item.Status >= http.StatusBadRequest {
+			response.Errors = true
+			item.Error = &models.BulkError{Type: "mapper_parsing_exception", Reason: "failed to parse"}
+		} else if item.Status != http.StatusTooManyRequests {
+			f.documents[id] = true
+		}
+		response.Items = append(response.Items, map[string]models.BulkItem{"index": item})
+	}
+
+	return response, http.StatusOK, nil
+}
+
+func (f *fakeElasticsearch) ResolveAlias(ctx context.Context, alias string) (string, error) {
+	if f.resolved == "" {
+		return "", errs.ErrIndexNotFound
+	}
+	return f.resolved, nil
+}
+
+func (f *fakeElasticsearch) Count(ctx context.Context, index string) (int, error) {
+	if count, ok := f.counts[index]; ok {
+		return count, nil
+	}
+	return len(f.documents), nil
+}
+
+func (f *fakeElasticsearch) Indices(ctx context.Context, pattern string) ([]string, error) {
+	return f.indices, nil
+}
+
+func (f *fakeElasticsearch) SwapAlias(ctx context.Context, alias, index string, previous []string) error {
+	f.swapped = append([]string{alias, index}, previous...)
+	return nil
+}
+
+func (f *fakeElasticsearch) DeleteIndex(ctx context.Context, index string) error {
+	f.deleted = append(f.deleted, index)
+	return nil
+}
+
+func courses(t *testing.T, ids ...string) []*indexer.Course {
+	t.Helper()
+
+	var input bytes.Buffer
+	for _, id := range ids {
+		input.WriteString(`{"kis_course_id": "` + id + `", "mode": "Full-time", "institution": {"ukprn": "1"}}` + "\n")
+	}
+
+	read, _, err := indexer.ReadJSON(&input, "courses.ndjson")
+	if err != nil {
+		t.Fatalf("failed to read courses: %v", err)
+	}
+
+	return read
+}
+
+func TestLoaderCreateIndex(t *testing.T) {
+	es := &fakeElasticsearch{}
+	loader := indexer.NewLoader(es, "courses", 2, 0, 0)
+
+	created, err := loader.CreateIndex(context.Background())
+	if err != nil || !created {
+		t.Fatalf("expected the index to be created, got %v and %v", created, err)
+	}
+	if !bytes.Equal(es.created, indexer.Mappings) {
+		t.Error("expected the index to be created with the mappings")
+	}
+
+	var mappings map[string]interface{}
+	if err = json.Unmarshal(indexer.Mappings, &mappings); err != nil {
+		t.Errorf("expected the mappings to be valid json: %v", err)
+	}
+
+	es = &fakeElasticsearch{exists: true}
+	if created, err = indexer.NewLoader(es, "courses", 2, 0, 0).CreateIndex(context.Background()); err != nil || created || es.created != nil {
+		t.Errorf("expected an existing index not to be created, got %v and %v", created, err)
+	}
+
+	// An index is only created when elasticsearch says it does not exist
+	es = &fakeElasticsearch{existsErr: errs.ErrCircuitBreakerOpen}
+	if created, err = indexer.NewLoader(es, "courses", 2, 0, 0).CreateIndex(context.Background()); errors.Cause(err) != errs.ErrCircuitBreakerOpen || created || es.created != nil {
+		t.Errorf("expected the failure to check the index to be returned, got %v and %v", created, err)
+	}
+}
+
+func TestLoaderBatches(t *testing.T) {
+	es := &fakeElasticsearch{}
+	loader := indexer.NewLoader(es, "courses", 2, 0, 0)
+
+	report := &indexer.Report{}
+	if err := loader.Load(context.Background(), courses(t, "A", "B", "C", "D", "E"), report); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if len(es.requests) != 3 || len(es.requests[0]) != 2 || len(es.requests[2]) != 1 {
+		t.Errorf("expected batches of 2, 2 and 1 courses, got %v", es.requests)
+	}
+	if es.requests[0][0] != "1-A-full-time" {
+		t.Errorf("expected courses to be indexed by id, got %v", es.requests[0])
+	}
+	if report.Indexed != 5 || report.Batches != 3 || !report.OK() {
+		t.Errorf("unexpected report %+v", report)
+	}
+	if !es.refreshed {
+		t.Error("expected the index to be refreshed")
+	}
+}
+
+func TestLoaderRetries(t *testing.T) {
+	es := &fakeElasticsearch{results: []bulkResult{
+		{status: http.StatusServiceUnavailable, err: errs.ErrUnexpectedStatusCode},
+		{statuses: []int{http.StatusCreated, http.StatusTooManyRequests, http.StatusBadRequest}},
+	}}
+	loader := indexer.NewLoader(es, "courses", 3, 2, time.Millisecond)
+
+	report := &indexer.Report{}
+	if err := loader.Load(context.Background(), courses(t, "A", "B", "C"), report); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	// The batch is sent again when unavailable, then only the course rejected as too busy
+	if len(es.requests) != 3 || len(es.requests[1]) != 3 || len(es.requests[2]) != 1 || es.requests[2][0] != "1-B-full-time" {
+		t.Errorf("unexpected requests %v", es.requests)
+	}
+	if report.Indexed != 2 || report.Retries != 2 {
+		t.Errorf("expected 2 courses to be indexed after 2 retries, got %+v", report)
+	}
+
+	if len(report.Failed) != 1 || report.OK() {
+		t.Fatalf("expected a failed course, got %+v", report.Failed)
+	}
+	if failure := report.Failed[0]; failure.ID != "1-C-full-time" || failure.Status != http.StatusBadRequest || failure.Type != "mapper_parsing_exception" {
+		t.Errorf("unexpected failure %+v", failure)
+	}
+}
+
+func TestLoaderGivesUp(t *testing.T) {
+	unavailable := bulkResult{status: http.StatusServiceUnavailable, err: errs.ErrUnexpectedStatusCode}
+
+	es := &fakeElasticsearch{results: []bulkResult{unavailable, unavailable, {status: http.StatusBadRequest, err: errs.ErrUnexpectedStatusCode}}}
+	loader := indexer.NewLoader(es, "courses", 2, 1, time.Millisecond)
+
+	report := &indexer.Report{}
+	if err := loader.Load(context.Background(), courses(t, "A", "B", "C"), report); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	// The first batch fails after a retry, the second is not retried as the request is invalid
+	if len(es.requests) != 3 {
+		t.Errorf("expected 3 requests, got %v", es.requests)
+	}
+	if report.Indexed != 0 || len(report.Failed) != 3 || report.Retries != 1 {
+		t.Fatalf("expected every course to fail, got %+v", report)
+	}
+	if failure := report.Failed[0]; failure.Status != http.StatusServiceUnavailable || failure.Reason != errs.ErrUnexpectedStatusCode.Error() {
+		t.Errorf("unexpected failure %+v", failure)
+	}
+}
+
+func TestLoaderCancelled(t *testing.T) {
+	es := &fakeElasticsearch{results: []bulkResult{{status: 0, err: errs.ErrCircuitBreakerOpen}}}
+	loader := indexer.NewLoader(es, "courses", 2, 3, time.Hour)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	report := &indexer.Report{}
+	if err := loader.Load(ctx, courses(t, "A", "B", "C"), report); err != context.Canceled {
+		t.Fatalf("expected the load to be cancelled, got %v", err)
+	}
+	if len(report.Failed) != 2 || len(es.requests) != 1 {
+		t.Errorf("expected the first batch to fail without retrying, got %+v", report)
+	}
+}
@@ -0,0 +1,118 @@
+package indexer
+
+import (
+	"bufio"
+	"encoding/json"
+	"io"
+	"os"
+	"path/filepath"
+
+	"github.com/pkg/errors"
+)
+
+// Rejected is a record which could not be read as a course, so was not indexed.
+// Records are numbered from one, not counting the header of CSV files
+type Rejected struct {
+	Source string `json:"source"`
+	Record int    `json:"record"`
+	Reason string `json:"reason"`
+}
+
+// ReadFile reads the courses in a JSON or newline delimited JSON file
+func ReadFile(path string) ([]*Course, []Rejected, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, nil, errors.Wrap(err, "failed to open course file")
+	}
+	defer f.Close()
+
+	return ReadJSON(f, filepath.Base(path))
+}
+
+// ReadJSON reads courses from a JSON array or newline delimited JSON, where each
+// record is the source stored in the index ({"doc": {...}}) or the course itself.
+// Records which are not valid courses are rejected, but the remaining courses are
+// still returned; an error is only returned if the input is not valid JSON
+func ReadJSON(r io.Reader, name string) ([]*Course, []Rejected, error) {
+	reader := bufio.NewReader(r)
+
+	array, err := startsWithArray(reader)
+	if err != nil {
+		return nil, nil, errors.Wrapf(err, "failed to read %s", name)
+	}
+
+	decoder := json.NewDecoder(reader)
+	if array {
+		if _, err = decoder.Token(); err != nil {
+			return nil, nil, errors.Wrapf(err, "failed to parse %s", name)
+		}
+	}
+
+	var courses []*Course
+	var rejected []Rejected
+
+	for record := 1; ; record++ {
+		if array && !decoder.More() {
+			break
+		}
+
+		var message json.RawMessage
+		if err = decoder.Decode(&message); err == io.EOF && !array {
+			break
+		} else if err != nil {
+			return nil, nil, errors.Wrapf(err, "failed to parse record %d of %s", record, name)
+		}
+
+		course, err := parseCourse(message)
+		if err != nil {
+			rejected = append(rejected, Rejected{Source: name, Record: record, Reason: err.Error()})
+			continue
+		}
+
+		courses = append(courses, course)
+	}
+
+	return courses, rejected, nil
+}
+
+// startsWithArray returns whether the first character other than whitespace opens an array
+func startsWithArray(reader *bufio.Reader) (bool, error) {
+	for {
+		b, err := reader.Peek(1)
+		if err == io.EOF {
+			return false, nil
+		} else if err != nil {
+			return false, err
+		}
+
+		switch b[0] {
+		case ' ', '\t', '\r', '\n':
+			reader.ReadByte()
+		default:
+			return b[0] == '[', nil
+		}
+	}
+}
+
+func parseCourse(message json.RawMessage) (*Course, error) {
+	var s struct {
+		Doc json.RawMessage `json:"doc"`
+	}
+	if err := json.Unmarshal(message, &s); err != nil {
+		return nil, errors.Wrap(err, "record is not a course")
+	}
+	if s.Doc != nil {
+		message = s.Doc
+	}
+
+	course := &Course{}
+	if err := json.Unmarshal(message, course); err != nil {
+		return nil, errors.Wrap(err, "record is not a course")
+	}
+
+	if err := course.prepare(); err != nil {
+		return nil, err
+	}
+
+	return course, nil
+}
@@ -0,0 +1,155 @@
+package indexer_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/ofs/alpha-search-api/indexer"
+)
+
+const institution = `"institution": {"public_ukprn": "10007856", "public_ukprn_name": "Aberystwyth University", "ukprn": "10007856", "ukprn_name": "Aberystwyth University"}`
+
+func TestReadJSON(t *testing.T) {
+	inputs := map[string]string{
+		"array":  `[{"doc": {"kis_course_id": "AB-MATH", "mode": "Full-time", "country": "Wales", ` + institution + `}}, {"kis_course_id": "AB-PHYS", "mode": "Part-time", "country_code": "XI", "distance_learning": "1", ` + institution + `}]`,
+		"ndjson": `{"doc": {"kis_course_id": "AB-MATH", "mode": "Full-time", "country": "Wales", ` + institution + `}}` + "\n\n" + `{"kis_course_id": "AB-PHYS", "mode": "Part-time", "country_code": "XI", "distance_learning": "1", ` + institution + `}` + "\n",
+	}
+
+	for name, input := range inputs {
+		courses, rejected, err := indexer.ReadJSON(strings.NewReader(input), name)
+		if err != nil {
+			t.Fatalf("%s: unexpected error: %v", name, err)
+		}
+		if len(rejected) != 0 {
+			t.Errorf("%s: expected no rejected records, got %v", name, rejected)
+		}
+		if len(courses) != 2 {
+			t.Fatalf("%s: expected 2 courses, got %d", name, len(courses))
+		}
+
+		maths, physics := courses[0], courses[1]
+
+		if maths.ID() != "10007856-AB-MATH-full-time" {
+			t.Errorf("%s: unexpected id %q", name, maths.ID())
+		}
+
+		// Fields used for filtering and sorting are derived when missing
+		if maths.CountryCode != "XI" || physics.Country != "Wales" {
+			t.Errorf("%s: expected the country and its code, got %q and %q", name, maths.CountryCode, physics.Country)
+		}
+		if physics.DistanceLearningCode != "1" {
+			t.Errorf("%s: expected the distance learning code, got %q", name, physics.DistanceLearningCode)
+		}
+		if maths.SortName != "aberystwyth university" || maths.Institution.LCUKPRNName != "aberystwyth university" {
+			t.Errorf("%s: expected lower case institution names, got %q and %q", name, maths.SortName, maths.Institution.LCUKPRNName)
+		}
+	}
+}
+
+func TestReadJSONRejectsInvalidCourses(t *testing.T) {
+	input := `{"kis_course_id": "AB-MATH", ` + institution + `}
+{"english_title": "No id", ` + institution + `}
+{"kis_course_id": "NO-INST"}
+{"kis_course_id": "NO-UKPRN", "institution": {"public_ukprn": "10007856"}}
+["not", "a", "course"]
+`
+
+	courses, rejected, err := indexer.ReadJSON(strings.NewReader(input), "courses.ndjson")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if len(courses) != 1 || courses[0].KISCourseID != "AB-MATH" {
+		t.Errorf("expected only the valid course, got %d", len(courses))
+	}
+
+	expected := []indexer.Rejected{
+		{Source: "courses.ndjson", Record: 2, Reason: "kis_course_id is required"},
+		{Source: "courses.ndjson", Record: 3, Reason: "course [NO-INST] has no institution"},
+		{Source: "courses.ndjson", Record: 4, Reason: "course [NO-UKPRN] has no institution ukprn"},
+	}
+	if len(rejected) != 4 {
+		t.Fatalf("expected 4 rejected records, got %v", rejected)
+	}
+	for i := range expected {
+		if rejected[i] != expected[i] {
+			t.Errorf("expected %+v, got %+v", expected[i], rejected[i])
+		}
+	}
+	if rejected[3].Record != 5 || !strings.HasPrefix(rejected[3].Reason, "record is not a course") {
+		t.Errorf("expected the array to be rejected, got %+v", rejected[3])
+	}
+}
+
+func TestReadJSONInvalid(t *testing.T) {
+	for _, input := range []string{`[{"kis_course_id": "AB-MATH"`, `{"kis_course_id": "AB-MATH"} {`, `[{}, oops]`} {
+		if _, _, err := indexer.ReadJSON(strings.NewReader(input), "courses.json"); err == nil {
+			t.Errorf("%s: expected an error for invalid json", input)
+		}
+	}
+
+	courses, rejected, err := indexer.ReadJSON(strings.NewReader("  \n"), "empty.json")
+	if err != nil || len(courses) != 0 || len(rejected) != 0 {
+		t.Errorf("expected nothing to be read from an empty file, got %d, %v and %v", len(courses), rejected, err)
+	}
+}
+
+func TestReadKIS(t *testing.T) {
+	courses, rejected, err := indexer.ReadKIS("testdata/kis")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if len(courses) != 3 {
+		t.Fatalf("expected 3 courses, got %d", len(courses))
+	}
+
+	maths := courses[0]
+	if maths.ID() != "10007856-AB-MATH-full-time" || maths.EnglishTitle != "Mathematics" || maths.WelshTitle != "Mathemateg" {
+		t.Errorf("unexpected course %+v", maths.Document)
+	}
+	if maths.Country != "Wales" || maths.CountryCode != "XI" {
+		t.Errorf("expected the country of the institution, got %q and %q", maths.Country, maths.CountryCode)
+	}
+	if maths.Institution.PublicUKPRNName != "Aberystwyth University" || maths.SortName != "aberystwyth university" {
+		t.Errorf("expected the name of the institution, got %+v", maths.Institution)
+	}
+	if maths.FoundationYear != "Optional" || maths.SandwichYear != "Not available" || maths.YearAbroad != "Optional" || maths.HonoursAward != "Available" {
+		t.Errorf("expected codes to be described, got %+v", maths.Document)
+	}
+	if maths.LengthOfCourse != "3" || maths.DistanceLearningCode != "0" || maths.Qualification == nil || maths.Qualification.Code != "021" {
+		t.Errorf("unexpected course %+v", maths.Document)
+	}
+	if maths.SubjectCode != "CAH09-01-01" {
+		t.Errorf("expected the first subject, got %q", maths.SubjectCode)
+	}
+
+	// The same course is published for each mode of study
+	partTime := courses[1]
+	if partTime.ID() != "10007856-AB-MATH-part-time" || partTime.SandwichYear != "" || partTime.SubjectCode != "" {
+		t.Errorf("unexpected part-time course %+v", partTime.Document)
+	}
+
+	if history := courses[2]; history.EnglishTitle != "History, Politics" || history.SandwichYear != "Compulsory" || history.Qualification != nil {
+		t.Errorf("unexpected course %+v", history.Document)
+	}
+
+	expected := []indexer.Rejected{
+		{Source: "KISCOURSE.csv", Record: 4, Reason: "course [LD-PART] has an unknown KISMODE [3]"},
+		{Source: "KISCOURSE.csv", Record: 5, Reason: "course [LD-FDN] has an unknown FOUNDATION [5]"},
+	}
+	if len(rejected) != len(expected) {
+		t.Fatalf("expected %d rejected courses, got %v", len(expected), rejected)
+	}
+	for i := range expected {
+		if rejected[i] != expected[i] {
+			t.Errorf("expected %+v, got %+v", expected[i], rejected[i])
+		}
+	}
+}
+
+func TestReadKISMissingCourses(t *testing.T) {
+	if _, _, err := indexer.ReadKIS(t.TempDir()); err == nil {
+		t.Error("expected an error for a directory without " + indexer.KISCourseFile)
+	}
+}
@@ -0,0 +1,92 @@
+package lifecycle
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"github.com/methods/go-methods-lib/log"
+	"github.com/pkg/errors"
+)
+
+// Closer stops a component, returning once it has finished or the context is done
+type Closer func(ctx context.Context) error
+
+type step struct {
+	name  string
+	close Closer
+}
+
+// Shutdown closes the components of the application in the order they were
+// added, sharing a single timeout between them
+type Shutdown struct {
+	timeout time.Duration
+	steps   []step
+}
+
+// New creates a shutdown which must complete within the timeout
+func New(timeout time.Duration) *Shutdown {
+	return &Shutdown{
+		timeout: timeout,
+	}
+}
+
+// Add registers a named component to be closed after those already added
+func (s *Shutdown) Add(name string, close Closer) {
+	s.steps = append(s.steps, step{name: name, close: close})
+}
+
+// Wait returns a closer which waits for the delay, or until the context is done,
+// so that other processes have time to notice an earlier step before the next
+func Wait(delay time.Duration) Closer {
+	return func(ctx context.Context) error {
+		timer := time.NewTimer(delay)
+		defer timer.Stop()
+
+		select {
+		case <-timer.C:
+			return nil
+		case <-ctx.Done():
+			return errors.Wrapf(ctx.Err(), "waited less than %v", delay)
+		}
+	}
+}
+
+// Run closes every component in turn. A component which fails does not stop the
+// others being closed, and once the timeout has passed each remaining component
+// is given a context which is already done. An error naming every component which
+// failed is returned, or nil if the shutdown was clean
+func (s *Shutdown) Run() error {
+	start := time.Now()
+	log.Info("shutting down", log.Data{"timeout": s.timeout})
+
+	ctx, cancel := context.WithTimeout(context.Background(), s.timeout)
+	defer cancel()
+
+	var failed []string
+	for _, step := range s.steps {
+		stepStart := time.Now()
+		logData := log.Data{"component": step.name}
+
+		err := step.close(ctx)
+		logData["duration"] = time.Since(stepStart)
+		if err != nil {
+			failed = append(failed, step.name)
+			log.ErrorC("failed to close component", err, logData)
+			continue
+		}
+
+		log.Info("component closed", logData)
+	}
+
+	logData := log.Data{"shutdown_duration": time.Since(start)}
+	if len(failed) > 0 {
+		err := errors.Errorf("failed to close %s", strings.Join(failed, ", "))
+		log.ErrorC("shutdown failed", err, logData)
+		return err
+	}
+
+	log.Info("shutdown complete", logData)
+
+	return nil
+}
@@ -0,0 +1,92 @@
+package lifecycle_test
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ofs/alpha-search-api/lifecycle"
+)
+
+func TestShutdown(t *testing.T) {
+	var closed []string
+	closer := func(name string, err error) lifecycle.Closer {
+		return func(ctx context.Context) error {
+			closed = append(closed, name)
+			return err
+		}
+	}
+
+	shutdown := lifecycle.New(time.Second)
+	shutdown.Add("first", closer("first", nil))
+	shutdown.Add("second", closer("second", nil))
+
+	if err := shutdown.Run(); err != nil {
+		t.Errorf("expected a clean shutdown, got %v", err)
+	}
+	if expected := []string{"first", "second"}; !reflect.DeepEqual(closed, expected) {
+		t.Errorf("expected components to be closed in the order added, got %v", closed)
+	}
+
+	// A failure is reported once every component has been closed
+	closed = nil
+	shutdown = lifecycle.New(time.Second)
+	shutdown.Add("first", closer("first", errors.New("failed")))
+	shutdown.Add("second", closer("second", nil))
+
+	err := shutdown.Run()
+	if err == nil || !strings.Contains(err.Error(), "first") || strings.Contains(err.Error(), "second") {
+		t.Errorf("expected the failed component to be named, got %v", err)
+	}
+	if len(closed) != 2 {
+		t.Errorf("expected every component to be closed, got %v", closed)
+	}
+}
+
+func TestShutdownTimeout(t *testing.T) {
+	shutdown := lifecycle.New(10 * time.Millisecond)
+
+	// The timeout is shared, so a slow component leaves no time for the rest
+	shutdown.Add("slow", func(ctx context.Context) error {
+		<-ctx.Done()
+		return ctx.Err()
+	})
+
+	var remaining error
+	shutdown.Add("remaining", func(ctx context.Context) error {
+		remaining = ctx.Err()
+		return nil
+	})
+
+	if err := shutdown.Run(); err == nil || !strings.Contains(err.Error(), "slow") {
+		t.Errorf("expected the slow component to fail, got %v", err)
+	}
+	if remaining != context.DeadlineExceeded {
+		t.Errorf("expected the remaining component to be given a done context, got %v", remaining)
+	}
+}
+
+func TestWait(t *testing.T) {
+	start := time.Now()
+	if err := lifecycle.Wait(20 * time.Millisecond)(context.Background()); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+	if waited := time.Since(start); waited < 20*time.Millisecond {
+		t.Errorf("expected to wait for the delay, waited %v", waited)
+	}
+
+	// The delay is cut short when the shared timeout is reached
+	shutdown := lifecycle.New(20 * time.Millisecond)
+	shutdown.Add("delay", lifecycle.Wait(time.Minute))
+
+	start = time.Now()
+	if err := shutdown.Run(); err == nil || err.Error() != "failed to close delay" {
+		t.Errorf("expected the delay to fail once the timeout passed, got %v", err)
+	}
+	if waited := time.Since(start); waited > time.Second {
+		t.Errorf("expected the delay to end at the timeout, waited %v", waited)
+	}
+}
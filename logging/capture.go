@@ -0,0 +1,19 @@
+package logging
+
+import "context"
+
+type contextKey string
+
+const captureKey = contextKey("debug-capture")
+
+// WithCapture marks the request on the context as one whose elasticsearch
+// query and response bodies should be logged
+func WithCapture(ctx context.Context) context.Context {
+	return context.WithValue(ctx, captureKey, true)
+}
+
+// CaptureEnabled returns true if query and response bodies should be logged for the request
+func CaptureEnabled(ctx context.Context) bool {
+	capture, _ := ctx.Value(captureKey).(bool)
+	return capture
+}
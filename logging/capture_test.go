@@ -0,0 +1,23 @@
+package logging_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/ofs/alpha-search-api/logging"
+)
+
+func TestCapture(t *testing.T) {
+	ctx := context.Background()
+	if logging.CaptureEnabled(ctx) {
+		t.Error("expected capture to be disabled by default")
+	}
+
+	if !logging.CaptureEnabled(logging.WithCapture(ctx)) {
+		t.Error("expected capture to be enabled on the returned context")
+	}
+
+	if logging.CaptureEnabled(ctx) {
+		t.Error("expected the parent context to be unchanged")
+	}
+}
@@ -0,0 +1,128 @@
+package logging
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	"github.com/methods/go-methods-lib/log"
+)
+
+// A list of log levels in order of increasing severity
+const (
+	LevelTrace = "trace"
+	LevelDebug = "debug"
+	LevelInfo  = "info"
+	LevelError = "error"
+)
+
+// redacted replaces the value of any field configured to be redacted
+const redacted = "[REDACTED]"
+
+var severity = map[string]int{
+	LevelTrace: 0,
+	LevelDebug: 1,
+	LevelInfo:  2,
+	LevelError: 3,
+	// request events are emitted by the http server for every request
+	"request": 2,
+}
+
+// Options determine which log events are written and how their data is sanitised
+type Options struct {
+	Level          string
+	MaxPayloadSize int
+	RedactFields   []string
+}
+
+var (
+	current atomic.Value
+	install sync.Once
+)
+
+// Configure replaces the event writer of the log package so that events below
+// the given level are discarded, configured fields are redacted and long
+// strings are truncated. It can be called again to change the options at runtime
+func Configure(opts Options) error {
+	minimum, ok := severity[strings.ToLower(opts.Level)]
+	if !ok {
+		return fmt.Errorf("invalid log level [%s], must be one of trace, debug, info or error", opts.Level)
+	}
+
+	redact := make(map[string]bool)
+	for _, field := range opts.RedactFields {
+		if field = strings.ToLower(strings.TrimSpace(field)); field != "" {
+			redact[field] = true
+		}
+	}
+
+	current.Store(&sanitiser{
+		minimum:        minimum,
+		maxPayloadSize: opts.MaxPayloadSize,
+		redact:         redact,
+	})
+
+	install.Do(func() {
+		next := log.Event
+
+		log.Event = func(name string, correlationKey string, data log.Data) {
+			s := current.Load().(*sanitiser)
+
+			if level, ok := severity[name]; ok && level < s.minimum {
+				return
+			}
+
+			next(name, correlationKey, s.data(data))
+		}
+	})
+
+	return nil
+}
+
+type sanitiser struct {
+	minimum        int
+	maxPayloadSize int
+	redact         map[string]bool
+}
+
+// data returns a sanitised copy of the log data, leaving the caller's map untouched
+func (s *sanitiser) data(data log.Data) log.Data {
+	if data == nil {
+		return nil
+	}
+
+	sanitised := make(log.Data, len(data))
+	for key, value := range data {
+		sanitised[key] = s.value(key, value)
+	}
+
+	return sanitised
+}
+
+func (s *sanitiser) value(key string, value interface{}) interface{} {
+	if s.redact[strings.ToLower(key)] {
+		return redacted
+	}
+
+	switch v := value.(type) {
+	case string:
+		return s.truncate(v)
+	case []byte:
+		return s.truncate(string(v))
+	case log.Data:
+		return s.data(v)
+	case map[string]interface{}:
+		return map[string]interface{}(s.data(log.Data(v)))
+	}
+
+	return value
+}
+
+func (s *sanitiser) truncate(value string) string {
+	if s.maxPayloadSize <= 0 || len(value) <= s.maxPayloadSize {
+		return value
+	}
+
+	return fmt.Sprintf("%s...[truncated %d bytes]", value[:s.maxPayloadSize], len(value)-s.maxPayloadSize)
+}
@@ -0,0 +1,166 @@
+package logging_test
+
+import (
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/methods/go-methods-lib/log"
+	"github.com/ofs/alpha-search-api/logging"
+)
+
+type event struct {
+	name string
+	data log.Data
+}
+
+var (
+	eventsMutex sync.Mutex
+	events      []event
+	recordOnce  sync.Once
+)
+
+// configure records the events written by the log package, which must be in
+// place before the first call to Configure wraps the event writer
+func configure(t *testing.T, opts logging.Options) {
+	t.Helper()
+
+	recordOnce.Do(func() {
+		log.Event = func(name string, correlationKey string, data log.Data) {
+			eventsMutex.Lock()
+			events = append(events, event{name: name, data: data})
+			eventsMutex.Unlock()
+		}
+	})
+
+	if err := logging.Configure(opts); err != nil {
+		t.Fatalf("failed to configure logging: %v", err)
+	}
+
+	eventsMutex.Lock()
+	events = nil
+	eventsMutex.Unlock()
+}
+
+func recorded() []event {
+	eventsMutex.Lock()
+	defer eventsMutex.Unlock()
+
+	return append([]event(nil), events...)
+}
+
+func TestConfigureInvalidLevel(t *testing.T) {
+	if err := logging.Configure(logging.Options{Level: "warn"}); err == nil {
+		t.Error("expected an error for an unknown level")
+	}
+}
+
+func TestLevelFiltering(t *testing.T) {
+	testCases := map[string][]string{
+		"trace": {"trace", "debug", "info", "request", "error"},
+		"debug": {"debug", "info", "request", "error"},
+		"INFO":  {"info", "request", "error"},
+		"error": {"error"},
+	}
+
+	for level, expected := range testCases {
+		configure(t, logging.Options{Level: level})
+
+		log.Trace("trace", nil)
+		log.Debug("debug", nil)
+		log.Info("info", nil)
+		log.Event("request", "", log.Data{})
+		log.Error(nil, nil)
+
+		var names []string
+		for _, e := range recorded() {
+			names = append(names, e.name)
+		}
+
+		if strings.Join(names, ",") != strings.Join(expected, ",") {
+			t.Errorf("level %s: expected events %v, got %v", level, expected, names)
+		}
+	}
+}
+
+func TestRedaction(t *testing.T) {
+	configure(t, logging.Options{Level: "info", RedactFields: []string{" Authorization ", "api_key", ""}})
+
+	headers := log.Data{"authorization": "Bearer secret", "accept": "application/json"}
+	query := map[string]interface{}{"API_KEY": "secret", "term": "maths", "nested": log.Data{"Authorization": "secret"}}
+
+	log.Info("request", log.Data{
+		"Authorization": "secret",
+		"headers":       headers,
+		"query":         query,
+		"status":        200,
+	})
+
+	events := recorded()
+	if len(events) != 1 {
+		t.Fatalf("expected one event, got %d", len(events))
+	}
+	data := events[0].data
+
+	if data["Authorization"] != "[REDACTED]" {
+		t.Errorf("expected top level field to be redacted, got %v", data["Authorization"])
+	}
+	if data["status"] != 200 || data["message"] != "request" {
+		t.Errorf("expected other fields to be kept, got %v", data)
+	}
+
+	sanitisedHeaders := data["headers"].(log.Data)
+	if sanitisedHeaders["authorization"] != "[REDACTED]" || sanitisedHeaders["accept"] != "application/json" {
+		t.Errorf("expected nested log data to be redacted, got %v", sanitisedHeaders)
+	}
+
+	sanitisedQuery := data["query"].(map[string]interface{})
+	if sanitisedQuery["API_KEY"] != "[REDACTED]" || sanitisedQuery["term"] != "maths" {
+		t.Errorf("expected nested maps to be redacted ignoring case, got %v", sanitisedQuery)
+	}
+	if deeper := sanitisedQuery["nested"].(log.Data); deeper["Authorization"] != "[REDACTED]" {
+		t.Errorf("expected deeply nested fields to be redacted, got %v", deeper)
+	}
+
+	// The caller's data must not be changed, it may be logged again or reused
+	if headers["authorization"] != "Bearer secret" || query["API_KEY"] != "secret" {
+		t.Error("expected the caller's data to be left untouched")
+	}
+}
+
+func TestTruncation(t *testing.T) {
+	configure(t, logging.Options{Level: "info", MaxPayloadSize: 5})
+
+	log.Info("", log.Data{
+		"short":  "abcde",
+		"long":   "abcdefgh",
+		"bytes":  []byte("abcdefghij"),
+		"nested": log.Data{"body": "abcdefg"},
+		"number": 123456789,
+	})
+
+	data := recorded()[0].data
+
+	expected := map[string]interface{}{
+		"short":  "abcde",
+		"long":   "abcde...[truncated 3 bytes]",
+		"bytes":  "abcde...[truncated 5 bytes]",
+		"number": 123456789,
+	}
+	for key, value := range expected {
+		if data[key] != value {
+			t.Errorf("%s: expected %v, got %v", key, value, data[key])
+		}
+	}
+	if body := data["nested"].(log.Data)["body"]; body != "abcde...[truncated 2 bytes]" {
+		t.Errorf("expected nested strings to be truncated, got %v", body)
+	}
+
+	configure(t, logging.Options{Level: "info"})
+
+	log.Info("", log.Data{"long": "abcdefgh"})
+
+	if long := recorded()[0].data["long"]; long != "abcdefgh" {
+		t.Errorf("expected no truncation without a maximum size, got %v", long)
+	}
+}
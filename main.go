@@ -2,7 +2,8 @@ package main
 
 import (
 	"context"
-	"net/http"
+	"flag"
+	"fmt"
 	"os"
 	"os/signal"
 	"syscall"
@@ -10,8 +11,17 @@ import (
 
 	"github.com/methods/go-methods-lib/log"
 	"github.com/ofs/alpha-search-api/api"
+	errs "github.com/ofs/alpha-search-api/apierrors"
+	"github.com/ofs/alpha-search-api/auth"
+	"github.com/ofs/alpha-search-api/cache"
 	"github.com/ofs/alpha-search-api/config"
 	"github.com/ofs/alpha-search-api/elasticsearch"
+	"github.com/ofs/alpha-search-api/lifecycle"
+	"github.com/ofs/alpha-search-api/logging"
+	"github.com/ofs/alpha-search-api/memory"
+	"github.com/ofs/alpha-search-api/signer"
+	"github.com/ofs/alpha-search-api/tracing"
+	"github.com/pkg/errors"
 )
 
 func main() {
@@ -20,53 +30,273 @@ func main() {
 	signals := make(chan os.Signal, 1)
 	signal.Notify(signals, syscall.SIGINT, syscall.SIGTERM)
 
-	cfg, err := config.Get()
+	reloads := make(chan os.Signal, 1)
+	signal.Notify(reloads, syscall.SIGHUP)
+
+	configFile := flag.String("config", os.Getenv("CONFIG_FILE"), "a YAML or JSON file holding configuration, which environment variables override")
+	printConfig := flag.Bool("print-config", false, "print the configuration in effect, with secrets redacted, and exit")
+	flag.Parse()
+
+	cfg, err := config.Load(*configFile)
+
+	if *printConfig {
+		os.Exit(printConfiguration(cfg, err))
+	}
+
 	if err != nil {
-		log.ErrorC("errored getting configuration", err, log.Data{"config": cfg})
+		log.ErrorC("errored getting configuration", err, log.Data{"config": cfg, "config_file": *configFile})
 		os.Exit(1)
 	}
 
-	log.Info("configuration on startup", log.Data{"config": cfg})
+	logOptions := logging.Options{
+		Level:          cfg.LogLevel,
+		MaxPayloadSize: cfg.LogMaxPayloadSize,
+		RedactFields:   cfg.LogRedactFields,
+	}
+	if err = logging.Configure(logOptions); err != nil {
+		log.ErrorC("errored configuring logging", err, log.Data{"log_level": cfg.LogLevel})
+		os.Exit(1)
+	}
 
-	elasticClient := http.Client{}
-	elasticsearch := elasticsearch.NewElasticSearchAPI(elasticClient, cfg.ElasticSearchConfig.DestURL, cfg.ElasticSearchConfig.SignedRequests)
+	log.Info("configuration on startup", log.Data{"config": cfg})
 
-	// Check elastic search connection can be made
-	_, status, err := elasticsearch.CallElastic(context.Background(), cfg.ElasticSearchConfig.DestURL, "GET", nil)
-	if err != nil {
-		log.ErrorC("failed to start up, unable to connect to elastic search instance", err, log.Data{"http_status": status})
+	if err = tracing.Configure(cfg.TracingExporter, cfg.TracingFile, log.Namespace); err != nil {
+		log.ErrorC("errored configuring tracing", err, log.Data{"exporter": cfg.TracingExporter})
 		os.Exit(1)
 	}
 
-	apiErrors := make(chan error, 1)
+	var keys *auth.Keys
+	if cfg.APIKeysFile != "" {
+		if keys, err = auth.LoadKeys(cfg.APIKeysFile); err != nil {
+			log.ErrorC("errored loading api keys", err, log.Data{"api_keys_file": cfg.APIKeysFile})
+			os.Exit(1)
+		}
+		log.Info("api keys loaded", log.Data{"api_keys_file": cfg.APIKeysFile, "keys": keys.Len()})
+	}
+
+	var (
+		searcher     api.Elasticsearcher
+		checker      api.ElasticHealthChecker
+		versioner    api.IndexVersioner
+		es           *elasticsearch.API
+		indexWatcher *elasticsearch.IndexWatcher
+	)
+
+	switch cfg.SearchBackend {
+	case config.BackendMemory:
+		fixture, err := memory.Load(cfg.SearchFixtureFile)
+		if err != nil {
+			log.ErrorC("errored loading search fixture", err, log.Data{"search_fixture_file": cfg.SearchFixtureFile})
+			os.Exit(1)
+		}
+		log.Info("searching documents in memory", log.Data{"search_fixture_file": cfg.SearchFixtureFile, "documents": fixture.Len()})
+
+		searcher = fixture
+		versioner = fixture
+
+	case config.BackendElasticsearch:
+		requestSigner, err := signer.New(cfg.ElasticSearchConfig)
+		if err != nil {
+			log.ErrorC("errored configuring elasticsearch request signing", err, log.Data{"signer": cfg.ElasticSearchConfig.SignerName()})
+			os.Exit(1)
+		}
+
+		tlsConfig, err := elasticsearch.NewTLSConfig(cfg.ElasticSearchConfig.TLSCAFile, cfg.ElasticSearchConfig.TLSCertFile, cfg.ElasticSearchConfig.TLSKeyFile, cfg.ElasticSearchConfig.TLSServerName, cfg.ElasticSearchConfig.TLSMinVersion)
+		if err != nil {
+			log.ErrorC("errored configuring tls for elasticsearch", err, log.Data{"ca_file": cfg.ElasticSearchConfig.TLSCAFile, "cert_file": cfg.ElasticSearchConfig.TLSCertFile, "key_file": cfg.ElasticSearchConfig.TLSKeyFile})
+			os.Exit(1)
+		}
 
-	api.CreateSearchAPI(*cfg, elasticsearch, apiErrors)
+		elasticClient := elasticsearch.NewHTTPClient(tlsConfig)
+		breaker := elasticsearch.NewCircuitBreaker(cfg.ElasticSearchConfig.CircuitBreakerThreshold, cfg.ElasticSearchConfig.CircuitBreakerTimeout)
+		es = elasticsearch.NewElasticSearchAPI(elasticClient, cfg.ElasticSearchConfig.DestURL, requestSigner, breaker, cfg.ElasticSearchConfig.SlowQueryThreshold)
 
-	// Gracefully shutdown the application closing any open resources.
-	gracefulShutdown := func() {
-		start := time.Now()
-		log.Info("shutdown with timeout in seconds", log.Data{"timeout": cfg.GracefulShutdownTimeout})
-		ctx, cancel := context.WithTimeout(context.Background(), cfg.GracefulShutdownTimeout)
+		// Check elastic search connection can be made, waiting for the cluster to become available
+		stopped, err := waitForElasticsearch(es, cfg.ElasticSearchConfig, signals)
+		if err != nil {
+			log.ErrorC("failed to start up, unable to connect to elastic search instance", err, nil)
+			os.Exit(1)
+		}
+		// Being stopped before elasticsearch is available is not a failure
+		if stopped != nil {
+			log.Info("os signal received while waiting for elastic search, stopping", log.Data{"os_signal": stopped})
+			os.Exit(0)
+		}
 
-		// stop any incoming requests before closing any outbound connections
-		api.Close(ctx)
+		// Filters on fields the index does not map as keywords silently match nothing
+		if err = checkMapping(es, cfg.ElasticSearchConfig); err != nil {
+			log.ErrorC("failed to start up, unable to use elastic search index", err, nil)
+			os.Exit(1)
+		}
 
-		// TODO close connection to database
+		// Watch the index behind the alias so cached search results are discarded, and
+		// ETags change, when it is swapped
+		indexWatcher = elasticsearch.NewIndexWatcher(es, cfg.ElasticSearchConfig.DestIndex, cfg.CacheAliasCheckInterval)
 
-		log.Info("shutdown complete", log.Data{"shutdown_duration": time.Since(start)})
+		searcher = es
+		checker = es
+		versioner = indexWatcher
 
-		cancel()
+	default:
+		log.Error(errors.Errorf("unknown SEARCH_BACKEND [%s], must be one of %s or %s", cfg.SearchBackend, config.BackendElasticsearch, config.BackendMemory), nil)
 		os.Exit(1)
 	}
 
+	var searchCache *cache.Searcher
+	if cfg.CacheSize > 0 {
+		searchCache = cache.NewSearcher(searcher, cfg.CacheSize, cfg.CacheTTL)
+		if indexWatcher != nil {
+			indexWatcher.OnChange(searchCache.Purge)
+		}
+		searcher = searchCache
+	}
+
+	if indexWatcher != nil {
+		indexWatcher.Start()
+	}
+
+	apiErrors := make(chan error, 1)
+
+	searchAPI := api.CreateSearchAPI(*cfg, searcher, checker, versioner, keys, apiErrors)
+
+	// Settings such as limits and API keys are reloaded on SIGHUP without a restart
+	reloader := api.NewReloader(*configFile, cfg, searchAPI, searchCache)
+
+	shutdown := newShutdown(cfg, searchAPI, es, indexWatcher)
+
 	for {
 		select {
 		case err := <-apiErrors:
-			log.Info("api error received", log.Data{"api_error": err})
-			gracefulShutdown()
+			log.ErrorC("http server failed, shutting down", err, nil)
+			shutdown.Run()
+			os.Exit(1)
+		case signal := <-signals:
+			log.Info("os signal received, shutting down", log.Data{"os_signal": signal})
+			if err := shutdown.Run(); err != nil {
+				os.Exit(1)
+			}
+			os.Exit(0)
+		case signal := <-reloads:
+			log.Info("os signal received, reloading configuration", log.Data{"os_signal": signal})
+			reloader.Reload()
+		}
+	}
+}
+
+// newShutdown closes the application in order: readiness checks fail so no new
+// traffic is routed to it, and once probes have had time to notice the http server
+// stops accepting connections and waits for requests in flight, and then outbound
+// connections and exporters are closed
+func newShutdown(cfg *config.Configuration, searchAPI *api.SearchAPI, es *elasticsearch.API, indexWatcher *elasticsearch.IndexWatcher) *lifecycle.Shutdown {
+	shutdown := lifecycle.New(cfg.GracefulShutdownTimeout)
+
+	// Readiness checks fail for a while before the server closes, so that probes
+	// and load balancers stop routing traffic while it is still accepted
+	shutdown.Add("readiness", func(ctx context.Context) error {
+		searchAPI.HealthCheck.Drain()
+		return nil
+	})
+	if cfg.ShutdownReadinessDelay > 0 {
+		shutdown.Add("readiness delay", lifecycle.Wait(cfg.ShutdownReadinessDelay))
+	}
+	shutdown.Add("http server", api.Close)
+
+	if indexWatcher != nil {
+		shutdown.Add("index watcher", func(ctx context.Context) error {
+			indexWatcher.Close()
+			return nil
+		})
+	}
+
+	if es != nil {
+		shutdown.Add("elasticsearch", es.Close)
+	}
+
+	shutdown.Add("tracing", func(ctx context.Context) error {
+		return tracing.Shutdown()
+	})
+
+	return shutdown
+}
+
+// waitForElasticsearch retries connecting to elasticsearch until a connection
+// is made, the startup timeout is reached or the application is signalled to stop,
+// in which case the signal received is returned
+func waitForElasticsearch(es *elasticsearch.API, cfg *config.ElasticSearchConfig, signals chan os.Signal) (os.Signal, error) {
+	timeout := time.After(cfg.StartupTimeout)
+
+	for attempt := 1; ; attempt++ {
+		status, err := es.Ping(context.Background())
+		if err == nil {
+			return nil, nil
+		}
+
+		log.Info("unable to connect to elastic search instance, retrying", log.Data{"attempt": attempt, "http_status": status, "retry_interval": cfg.StartupRetryInterval})
+
+		select {
+		case <-time.After(cfg.StartupRetryInterval):
+		case <-timeout:
+			return nil, errors.Wrap(err, "timed out waiting for elastic search")
 		case signal := <-signals:
-			log.Info("os signal received", log.Data{"os_signal": signal})
-			gracefulShutdown()
+			return signal, nil
+		}
+	}
+}
+
+// checkMapping logs the fields searched which the index does not map as the query
+// builder expects, returning an error if the check is enforced and any are found
+func checkMapping(es *elasticsearch.API, cfg *config.ElasticSearchConfig) error {
+	switch cfg.MappingCheck {
+	case "", api.ValidationOff:
+		return nil
+	case api.ValidationLog, api.ValidationEnforce:
+	default:
+		return errors.Errorf("unknown ES_MAPPING_CHECK [%s], must be one of %s, %s or %s", cfg.MappingCheck, api.ValidationOff, api.ValidationLog, api.ValidationEnforce)
+	}
+
+	enforce := cfg.MappingCheck == api.ValidationEnforce
+	logData := log.Data{"index": cfg.DestIndex, "mapping_check": cfg.MappingCheck}
+
+	problems, err := es.CheckMapping(context.Background(), cfg.DestIndex)
+	if err != nil {
+		log.ErrorC("unable to check index mapping", err, logData)
+		if enforce {
+			return err
 		}
+		return nil
 	}
+
+	if len(problems) > 0 {
+		logData["problems"] = problems
+		log.ErrorC("index mapping does not match the fields searched", errs.ErrMappingMismatch, logData)
+		if enforce {
+			return errs.ErrMappingMismatch
+		}
+		return nil
+	}
+
+	logData["fields"] = len(elasticsearch.QueryFields())
+	log.Info("index mapping holds every field searched", logData)
+
+	return nil
+}
+
+// printConfiguration writes the configuration to stdout in the format of a config
+// file, followed by any error loading it, returning the status to exit with
+func printConfiguration(cfg *config.Configuration, err error) int {
+	if cfg != nil {
+		b, marshalErr := cfg.YAML()
+		if marshalErr != nil {
+			fmt.Fprintln(os.Stderr, marshalErr)
+			return 1
+		}
+		os.Stdout.Write(b)
+	}
+
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+
+	return 0
 }
@@ -0,0 +1,186 @@
+package memory
+
+import (
+	"math"
+	"strings"
+	"unicode"
+
+	"github.com/ofs/alpha-search-api/models"
+)
+
+// Tags wrapping matched terms in highlights, as requested of elasticsearch
+const (
+	preTag  = "\u0001S"
+	postTag = "\u0001E"
+)
+
+// filterTerms are the values of the keyword field each filter accepts when set
+// (include) or negated (exclude), matching the terms queried in elasticsearch
+var filterTerms = map[string]struct {
+	field            func(doc *document) string
+	include, exclude []string
+}{
+	"distance_learning": {func(doc *document) string { return doc.DistanceLearningCode }, []string{"1", "2"}, []string{"0", "2"}},
+	"foundation_year":   {func(doc *document) string { return doc.FoundationYear }, []string{"Optional", "Compulsory"}, []string{"Not available", "Optional"}},
+	"full_time":         {func(doc *document) string { return doc.Mode }, []string{"Full-time"}, []string{"Part-time"}},
+	"honours_award":     {func(doc *document) string { return doc.HonoursAward }, []string{"Available"}, []string{"Not available"}},
+	"part_time":         {func(doc *document) string { return doc.Mode }, []string{"Part-time"}, []string{"Full-time"}},
+	"sandwich_year":     {func(doc *document) string { return doc.SandwichYear }, []string{"Optional", "Compulsory"}, []string{"Not available", "Optional"}},
+	"year_abroad":       {func(doc *document) string { return doc.YearAbroad }, []string{"Optional", "Compulsory"}, []string{"Not available", "Optional"}},
+}
+
+// termFilter requires a keyword field of the document to be one of the values
+type termFilter struct {
+	field  func(doc *document) string
+	values []string
+}
+
+type termFilters []termFilter
+
+func newTermFilters(filters map[string]string, countries, lengthOfCourse, institutions, subjects []string) termFilters {
+	var terms termFilters
+
+	for key, value := range filters {
+		filter, ok := filterTerms[key]
+		if !ok {
+			continue
+		}
+
+		values := filter.include
+		if value != "true" {
+			values = filter.exclude
+		}
+		terms = append(terms, termFilter{field: filter.field, values: values})
+	}
+
+	if len(countries) > 0 {
+		terms = append(terms, termFilter{field: func(doc *document) string { return doc.CountryCode }, values: countries})
+	}
+
+	if len(lengthOfCourse) > 0 {
+		terms = append(terms, termFilter{field: func(doc *document) string { return doc.LengthOfCourse }, values: lengthOfCourse})
+	}
+
+	if len(institutions) > 0 && institutions[0] != "" {
+		terms = append(terms, termFilter{field: func(doc *document) string { return doc.Institution.LCUKPRNName }, values: institutions})
+	}
+
+	if len(subjects) > 0 && subjects[0] != "" {
+		terms = append(terms, termFilter{field: func(doc *document) string { return doc.SubjectCode }, values: subjects})
+	}
+
+	return terms
+}
+
+// match returns true if the document matches every filter
+func (terms termFilters) match(doc *document) bool {
+	for _, term := range terms {
+		if !contains(term.values, term.field(doc)) {
+			return false
+		}
+	}
+
+	return true
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+
+	return false
+}
+
+// tokenize splits text into lower case words, as the standard analyzer does
+func tokenize(text string) []string {
+	return strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !isWordRune(r)
+	})
+}
+
+// score returns the relevance of the document to the query and whether it
+// matches, which it must for at least one title when a term is given. Each title
+// scores the proportion of its words matched, so shorter titles rank higher. As in
+// elasticsearch, all documents match an empty query, scoring 1 unless filtered
+func (doc *document) score(query []string, filtered bool) (float64, bool) {
+	if len(query) == 0 {
+		if filtered {
+			return 0, true
+		}
+		return 1, true
+	}
+
+	score := fieldScore(query, doc.englishTitle) + fieldScore(query, doc.welshTitle)
+
+	return score, score > 0
+}
+
+func fieldScore(query, field []string) float64 {
+	if len(field) == 0 {
+		return 0
+	}
+
+	var matched int
+	for _, word := range field {
+		if contains(query, word) {
+			matched++
+		}
+	}
+
+	return float64(matched) / math.Sqrt(float64(len(field)))
+}
+
+// highlight returns the titles with the words matching the query tagged, for
+// titles with a match only
+func (doc *document) highlight(query []string) models.Highlight {
+	var highlight models.Highlight
+
+	if tagged, ok := tag(doc.EnglishTitle, query); ok {
+		highlight.EnglishTitle = []string{tagged}
+	}
+	if tagged, ok := tag(doc.WelshTitle, query); ok {
+		highlight.WelshTitle = []string{tagged}
+	}
+
+	return highlight
+}
+
+func tag(text string, query []string) (string, bool) {
+	if len(query) == 0 {
+		return "", false
+	}
+
+	var b strings.Builder
+	var tagged bool
+
+	runes := []rune(text)
+	for i := 0; i < len(runes); {
+		if !isWordRune(runes[i]) {
+			b.WriteRune(runes[i])
+			i++
+			continue
+		}
+
+		j := i
+		for j < len(runes) && isWordRune(runes[j]) {
+			j++
+		}
+
+		word := string(runes[i:j])
+		if contains(query, strings.ToLower(word)) {
+			b.WriteString(preTag + word + postTag)
+			tagged = true
+		} else {
+			b.WriteString(word)
+		}
+		i = j
+	}
+
+	return b.String(), tagged
+}
+
+func isWordRune(r rune) bool {
+	return unicode.IsLetter(r) || unicode.IsNumber(r)
+}
@@ -0,0 +1,223 @@
+package memory
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"sort"
+	"time"
+
+	"github.com/methods/go-methods-lib/log"
+	"github.com/ofs/alpha-search-api/models"
+	"github.com/ofs/alpha-search-api/tracing"
+	"github.com/pkg/errors"
+)
+
+// institutionSearchSize matches the number of courses requested from
+// elasticsearch when grouping courses by institution
+const institutionSearchSize = 3500
+
+// Searcher searches course documents held in memory, matching terms, filters,
+// sorting and paging in the same way as the queries made to elasticsearch so
+// that the api can be run without an elasticsearch instance
+type Searcher struct {
+	documents []*document
+	version   string
+}
+
+// document is a course as stored in the index, including the keyword fields
+// used by filters which are not returned by the api
+type document struct {
+	models.Document
+	CountryCode          string `json:"country_code"`
+	DistanceLearningCode string `json:"distance_learning_code"`
+
+	englishTitle []string
+	welshTitle   []string
+}
+
+// Load reads course documents from a fixture file, either a JSON array or
+// newline delimited JSON, where each document is the source stored in the
+// index ({"doc": {...}}) or the course document itself
+func Load(path string) (*Searcher, error) {
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to read search fixture")
+	}
+
+	searcher, err := Parse(b)
+	if err != nil {
+		return nil, errors.WithMessage(err, fmt.Sprintf("invalid search fixture [%s]", path))
+	}
+
+	return searcher, nil
+}
+
+// Parse reads course documents from the contents of a fixture
+func Parse(b []byte) (*Searcher, error) {
+	var raw []json.RawMessage
+
+	if trimmed := bytes.TrimSpace(b); len(trimmed) > 0 && trimmed[0] == '[' {
+		if err := json.Unmarshal(trimmed, &raw); err != nil {
+			return nil, errors.Wrap(err, "failed to parse json array")
+		}
+	} else {
+		decoder := json.NewDecoder(bytes.NewReader(b))
+		for {
+			var message json.RawMessage
+			if err := decoder.Decode(&message); err == io.EOF {
+				break
+			} else if err != nil {
+				return nil, errors.Wrapf(err, "failed to parse document %d", len(raw)+1)
+			}
+			raw = append(raw, message)
+		}
+	}
+
+	var documents []*document
+	for i, message := range raw {
+		doc, err := parseDocument(message)
+		if err != nil {
+			return nil, errors.WithMessage(err, fmt.Sprintf("document %d", i+1))
+		}
+		documents = append(documents, doc)
+	}
+
+	sum := sha256.Sum256(b)
+
+	return &Searcher{
+		documents: documents,
+		version:   "memory-" + hex.EncodeToString(sum[:6]),
+	}, nil
+}
+
+func parseDocument(message json.RawMessage) (*document, error) {
+	var source struct {
+		Doc json.RawMessage `json:"doc"`
+	}
+	if err := json.Unmarshal(message, &source); err != nil {
+		return nil, errors.Wrap(err, "failed to parse document")
+	}
+	if source.Doc != nil {
+		message = source.Doc
+	}
+
+	doc := &document{}
+	if err := json.Unmarshal(message, doc); err != nil {
+		return nil, errors.Wrap(err, "failed to parse document")
+	}
+
+	if doc.KISCourseID == "" {
+		return nil, errors.New("kis_course_id is required")
+	}
+	if doc.Institution == nil {
+		return nil, fmt.Errorf("course [%s] has no institution", doc.KISCourseID)
+	}
+
+	// Older documents only hold the country name and distance learning code
+	if doc.CountryCode == "" {
+		doc.CountryCode = models.CountryCodes[doc.Country]
+	}
+	if doc.DistanceLearningCode == "" {
+		doc.DistanceLearningCode = doc.DistanceLearning
+	}
+
+	doc.englishTitle = tokenize(doc.EnglishTitle)
+	doc.welshTitle = tokenize(doc.WelshTitle)
+
+	return doc, nil
+}
+
+// Len returns the number of documents held
+func (s *Searcher) Len() int {
+	return len(s.documents)
+}
+
+// Version identifies the documents held, so ETags change when the fixture does
+func (s *Searcher) Version() string {
+	return s.version
+}
+
+// QueryCoursesSearch returns a page of the courses matching the term and filters, with highlights
+func (s *Searcher) QueryCoursesSearch(ctx context.Context, index, term string, limit, offset int, filters map[string]string, countries, lengthOfCourse, institutions, subjects []string) (*models.SearchResponse, int, error) {
+	return s.search(ctx, "QueryCoursesSearch", index, term, offset, limit, true, filters, countries, lengthOfCourse, institutions, subjects)
+}
+
+// QueryInstitutionCoursesSearch returns the courses matching the term and filters, for grouping by institution
+func (s *Searcher) QueryInstitutionCoursesSearch(ctx context.Context, index, term string, filters map[string]string, countries, lengthOfCourse, institutions, subjects []string) (*models.SearchResponse, int, error) {
+	return s.search(ctx, "QueryInstitutionCoursesSearch", index, term, 0, institutionSearchSize, false, filters, countries, lengthOfCourse, institutions, subjects)
+}
+
+func (s *Searcher) search(ctx context.Context, name, index, term string, from, size int, highlight bool, filters map[string]string, countries, lengthOfCourse, institutions, subjects []string) (*models.SearchResponse, int, error) {
+	ctx, span := tracing.StartSpan(ctx, name, tracing.KindInternal)
+	defer span.End()
+
+	span.SetAttribute("search.index", index)
+	span.SetAttribute("search.term_length", len(term))
+
+	start := time.Now()
+
+	query := tokenize(term)
+	terms := newTermFilters(filters, countries, lengthOfCourse, institutions, subjects)
+
+	var hits []models.HitList
+	for _, doc := range s.documents {
+		if !terms.match(doc) {
+			continue
+		}
+
+		score, ok := doc.score(query, len(terms) > 0)
+		if !ok {
+			continue
+		}
+
+		hit := models.HitList{Score: score, Source: models.SearchResult{Doc: doc.Document}}
+		if highlight {
+			hit.Highlight = doc.highlight(query)
+		}
+		hits = append(hits, hit)
+	}
+
+	// Sorted by relevance and then institution, as requested of elasticsearch,
+	// with the course id making the order of equal courses stable
+	sort.SliceStable(hits, func(i, j int) bool {
+		a, b := hits[i], hits[j]
+		if a.Score != b.Score {
+			return a.Score > b.Score
+		}
+		if a.Source.Doc.SortName != b.Source.Doc.SortName {
+			return a.Source.Doc.SortName < b.Source.Doc.SortName
+		}
+		return a.Source.Doc.KISCourseID < b.Source.Doc.KISCourseID
+	})
+
+	response := &models.SearchResponse{
+		Hits: models.Hits{Total: len(hits), HitList: page(hits, from, size)},
+		Took: int(time.Since(start) / time.Millisecond),
+	}
+
+	span.SetAttribute("search.total_results", response.Hits.Total)
+
+	log.DebugCtx(ctx, "searched documents in memory", log.Data{"term": term, "filters": filters, "total_results": response.Hits.Total})
+
+	return response, http.StatusOK, nil
+}
+
+func page(hits []models.HitList, from, size int) []models.HitList {
+	if from < 0 || from >= len(hits) || size <= 0 {
+		return []models.HitList{}
+	}
+
+	end := from + size
+	if end > len(hits) {
+		end = len(hits)
+	}
+
+	return hits[from:end]
+}
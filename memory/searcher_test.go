@@ -0,0 +1,243 @@
+package memory_test
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/ofs/alpha-search-api/memory"
+	"github.com/ofs/alpha-search-api/models"
+)
+
+const fixture = "testdata/courses.ndjson"
+
+func load(t *testing.T) *memory.Searcher {
+	t.Helper()
+
+	searcher, err := memory.Load(fixture)
+	if err != nil {
+		t.Fatalf("failed to load fixture: %v", err)
+	}
+
+	return searcher
+}
+
+func courseIDs(response *models.SearchResponse) string {
+	var ids []string
+	for _, hit := range response.Hits.HitList {
+		ids = append(ids, hit.Source.Doc.KISCourseID)
+	}
+
+	return strings.Join(ids, ",")
+}
+
+func TestLoad(t *testing.T) {
+	searcher := load(t)
+
+	if searcher.Len() != 8 {
+		t.Errorf("expected 8 documents, got %d", searcher.Len())
+	}
+	if !strings.HasPrefix(searcher.Version(), "memory-") {
+		t.Errorf("expected a memory version, got %q", searcher.Version())
+	}
+}
+
+func TestParseFormats(t *testing.T) {
+	cases := map[string]string{
+		"ndjson":         `{"doc":{"kis_course_id":"A","institution":{}}}` + "\n" + `{"doc":{"kis_course_id":"B","institution":{}}}`,
+		"array":          `[{"doc":{"kis_course_id":"A","institution":{}}},{"doc":{"kis_course_id":"B","institution":{}}}]`,
+		"bare documents": `{"kis_course_id":"A","institution":{}}` + "\n" + `{"kis_course_id":"B","institution":{}}`,
+		"blank lines":    "\n" + `{"kis_course_id":"A","institution":{}}` + "\n\n" + `{"kis_course_id":"B","institution":{}}` + "\n",
+		"spaced array":   ` [ {"kis_course_id":"A","institution":{}}, {"kis_course_id":"B","institution":{}} ] `,
+	}
+
+	for name, input := range cases {
+		searcher, err := memory.Parse([]byte(input))
+		if err != nil {
+			t.Errorf("%s: unexpected error: %v", name, err)
+			continue
+		}
+		if searcher.Len() != 2 {
+			t.Errorf("%s: expected 2 documents, got %d", name, searcher.Len())
+		}
+	}
+}
+
+func TestParseInvalid(t *testing.T) {
+	cases := map[string]string{
+		"invalid json":         `{"kis_course_id":`,
+		"missing course id":    `{"doc":{"institution":{}}}`,
+		"missing institution":  `{"doc":{"kis_course_id":"A"}}`,
+		"invalid array":        `[{"kis_course_id":"A","institution":{}},]`,
+		"wrong type for field": `{"kis_course_id":1,"institution":{}}`,
+	}
+
+	for name, input := range cases {
+		if _, err := memory.Parse([]byte(input)); err == nil {
+			t.Errorf("%s: expected an error", name)
+		}
+	}
+}
+
+func TestVersionChangesWithFixture(t *testing.T) {
+	a, _ := memory.Parse([]byte(`{"kis_course_id":"A","institution":{}}`))
+	b, _ := memory.Parse([]byte(`{"kis_course_id":"B","institution":{}}`))
+
+	if a.Version() == b.Version() {
+		t.Errorf("expected different versions, both were %q", a.Version())
+	}
+}
+
+func TestQueryCoursesSearchTerm(t *testing.T) {
+	searcher := load(t)
+
+	cases := []struct {
+		term     string
+		expected string
+	}{
+		// Shorter titles score higher, ties are sorted by institution and course
+		{"mathematics", "AB-MATH,QB-MATH,AB-PHYS,LD-MATH"},
+		{"MATHEMATICS", "AB-MATH,QB-MATH,AB-PHYS,LD-MATH"},
+		// Welsh titles are searched as well as english titles
+		{"mathemateg", "AB-MATH,AB-PHYS"},
+		{"history", "AB-HIST,ED-HIST"},
+		{"computer science", "LD-CS"},
+		{"astrophysics", ""},
+	}
+
+	for _, tc := range cases {
+		response, status, err := searcher.QueryCoursesSearch(context.Background(), "courses", tc.term, 10, 0, nil, nil, nil, nil, nil)
+		if err != nil || status != http.StatusOK {
+			t.Fatalf("%q: unexpected response %d: %v", tc.term, status, err)
+		}
+
+		if ids := courseIDs(response); ids != tc.expected {
+			t.Errorf("%q: expected %s, got %s", tc.term, tc.expected, ids)
+		}
+		if response.Hits.Total != len(response.Hits.HitList) {
+			t.Errorf("%q: expected a total of %d, got %d", tc.term, len(response.Hits.HitList), response.Hits.Total)
+		}
+	}
+}
+
+func TestQueryCoursesSearchWithoutTerm(t *testing.T) {
+	searcher := load(t)
+
+	response, _, _ := searcher.QueryCoursesSearch(context.Background(), "courses", "", 10, 0, nil, nil, nil, nil, nil)
+
+	expected := "AB-HIST,AB-MATH,AB-PHYS,QB-MATH,ED-HIST,LD-CS,LD-MATH,LD-NURS"
+	if ids := courseIDs(response); ids != expected {
+		t.Errorf("expected every course sorted by institution, %s, got %s", expected, ids)
+	}
+}
+
+func TestQueryCoursesSearchFilters(t *testing.T) {
+	searcher := load(t)
+
+	cases := []struct {
+		name           string
+		filters        map[string]string
+		countries      []string
+		lengthOfCourse []string
+		institutions   []string
+		subjects       []string
+		expected       string
+	}{
+		{name: "part time", filters: map[string]string{"part_time": "true"}, expected: "AB-HIST,ED-HIST"},
+		{name: "not part time", filters: map[string]string{"part_time": "false"}, expected: "AB-MATH,AB-PHYS,QB-MATH,LD-CS,LD-MATH,LD-NURS"},
+		{name: "full time", filters: map[string]string{"full_time": "true"}, expected: "AB-MATH,AB-PHYS,QB-MATH,LD-CS,LD-MATH,LD-NURS"},
+		{name: "distance learning", filters: map[string]string{"distance_learning": "true"}, expected: "AB-HIST,AB-MATH,ED-HIST"},
+		{name: "not distance learning", filters: map[string]string{"distance_learning": "false"}, expected: "AB-HIST,AB-PHYS,QB-MATH,LD-CS,LD-MATH,LD-NURS"},
+		{name: "foundation year", filters: map[string]string{"foundation_year": "true"}, expected: "AB-MATH,LD-MATH"},
+		{name: "honours award", filters: map[string]string{"honours_award": "false"}, expected: "LD-NURS"},
+		{name: "sandwich year", filters: map[string]string{"sandwich_year": "true"}, expected: "AB-PHYS,LD-CS,LD-MATH"},
+		{name: "year abroad", filters: map[string]string{"year_abroad": "true"}, expected: "AB-MATH,ED-HIST,LD-CS,LD-MATH"},
+		{name: "combined filters", filters: map[string]string{"year_abroad": "true", "part_time": "false"}, expected: "AB-MATH,LD-CS,LD-MATH"},
+		{name: "countries", countries: []string{"XI", "XH"}, expected: "AB-HIST,AB-MATH,AB-PHYS,ED-HIST"},
+		{name: "length of course", lengthOfCourse: []string{"4"}, expected: "QB-MATH,ED-HIST,LD-MATH"},
+		{name: "institutions", institutions: []string{"university of leeds"}, expected: "LD-CS,LD-MATH,LD-NURS"},
+		{name: "empty institutions", institutions: []string{""}, expected: "AB-HIST,AB-MATH,AB-PHYS,QB-MATH,ED-HIST,LD-CS,LD-MATH,LD-NURS"},
+		{name: "subjects", subjects: []string{"CAH09-01-01", "CAH11-01-01"}, expected: "AB-MATH,QB-MATH,LD-CS,LD-MATH"},
+		{name: "no matches", countries: []string{"XG"}, subjects: []string{"CAH20-01-01"}, expected: ""},
+	}
+
+	for _, tc := range cases {
+		response, _, err := searcher.QueryCoursesSearch(context.Background(), "courses", "", 10, 0, tc.filters, tc.countries, tc.lengthOfCourse, tc.institutions, tc.subjects)
+		if err != nil {
+			t.Fatalf("%s: unexpected error: %v", tc.name, err)
+		}
+
+		if ids := courseIDs(response); ids != tc.expected {
+			t.Errorf("%s: expected %s, got %s", tc.name, tc.expected, ids)
+		}
+	}
+}
+
+func TestQueryCoursesSearchPaging(t *testing.T) {
+	searcher := load(t)
+
+	cases := []struct {
+		limit, offset int
+		expected      string
+	}{
+		{3, 0, "AB-HIST,AB-MATH,AB-PHYS"},
+		{3, 3, "QB-MATH,ED-HIST,LD-CS"},
+		{3, 6, "LD-MATH,LD-NURS"},
+		{3, 8, ""},
+		{0, 0, ""},
+	}
+
+	for _, tc := range cases {
+		response, _, _ := searcher.QueryCoursesSearch(context.Background(), "courses", "", tc.limit, tc.offset, nil, nil, nil, nil, nil)
+
+		if ids := courseIDs(response); ids != tc.expected {
+			t.Errorf("limit %d, offset %d: expected %s, got %s", tc.limit, tc.offset, tc.expected, ids)
+		}
+		if response.Hits.Total != 8 {
+			t.Errorf("limit %d, offset %d: expected a total of 8, got %d", tc.limit, tc.offset, response.Hits.Total)
+		}
+	}
+}
+
+func TestQueryCoursesSearchHighlights(t *testing.T) {
+	searcher := load(t)
+
+	response, _, _ := searcher.QueryCoursesSearch(context.Background(), "courses", "mathematics", 10, 0, nil, nil, nil, nil, nil)
+
+	for _, hit := range response.Hits.HitList {
+		if hit.Source.Doc.KISCourseID != "AB-PHYS" {
+			continue
+		}
+
+		expected := "Physics with \u0001SMathematics\u0001E"
+		if len(hit.Highlight.EnglishTitle) != 1 || hit.Highlight.EnglishTitle[0] != expected {
+			t.Errorf("expected english title highlight %q, got %q", expected, hit.Highlight.EnglishTitle)
+		}
+		if len(hit.Highlight.WelshTitle) != 0 {
+			t.Errorf("expected no welsh title highlight, got %q", hit.Highlight.WelshTitle)
+		}
+		return
+	}
+
+	t.Error("expected AB-PHYS to match")
+}
+
+func TestQueryInstitutionCoursesSearch(t *testing.T) {
+	searcher := load(t)
+
+	response, status, err := searcher.QueryInstitutionCoursesSearch(context.Background(), "courses", "history", nil, nil, nil, nil, nil)
+	if err != nil || status != http.StatusOK {
+		t.Fatalf("unexpected response %d: %v", status, err)
+	}
+
+	if ids := courseIDs(response); ids != "AB-HIST,ED-HIST" {
+		t.Errorf("expected AB-HIST,ED-HIST, got %s", ids)
+	}
+
+	for _, hit := range response.Hits.HitList {
+		if len(hit.Highlight.EnglishTitle) > 0 {
+			t.Errorf("expected no highlights for institution searches, got %q", hit.Highlight.EnglishTitle)
+		}
+	}
+}
@@ -0,0 +1,252 @@
+package metrics
+
+import (
+	"bufio"
+	"fmt"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/methods/go-methods-lib/log"
+	"github.com/pkg/errors"
+)
+
+// DefaultBuckets are histogram buckets (in seconds) suitable for measuring request latency
+var DefaultBuckets = []float64{0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10}
+
+// labelEscaper escapes label values as required by the prometheus text format
+var labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+// DefaultRegistry is the registry metrics are added to when created
+var DefaultRegistry = NewRegistry()
+
+type collector interface {
+	write(w *bufio.Writer)
+}
+
+// Registry holds a list of metrics to be exposed
+type Registry struct {
+	mu         sync.Mutex
+	collectors []collector
+}
+
+// NewRegistry creates an empty registry
+func NewRegistry() *Registry {
+	return &Registry{}
+}
+
+func (r *Registry) register(c collector) {
+	r.mu.Lock()
+	r.collectors = append(r.collectors, c)
+	r.mu.Unlock()
+}
+
+// Handler exposes all metrics in the registry using the prometheus text format
+func (r *Registry) Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+
+		buf := bufio.NewWriter(w)
+
+		r.mu.Lock()
+		for _, c := range r.collectors {
+			c.write(buf)
+		}
+		r.mu.Unlock()
+
+		if err := buf.Flush(); err != nil {
+			log.ErrorCtx(req.Context(), errors.WithMessage(err, "failed to write metrics response body"), nil)
+		}
+	})
+}
+
+// Handler exposes all metrics in the default registry
+func Handler() http.Handler {
+	return DefaultRegistry.Handler()
+}
+
+// metric holds the label names and each set of label values of a metric
+type metric struct {
+	mu     sync.Mutex
+	name   string
+	help   string
+	labels []string
+	series map[string][]string
+}
+
+func newMetric(name, help string, labels []string) metric {
+	return metric{
+		name:   name,
+		help:   help,
+		labels: labels,
+		series: make(map[string][]string),
+	}
+}
+
+// key returns a unique key for a set of label values, caller must hold the lock
+func (m *metric) key(labelValues []string) string {
+	if len(labelValues) != len(m.labels) {
+		panic(fmt.Sprintf("metrics: %s expects %d label values, got %d", m.name, len(m.labels), len(labelValues)))
+	}
+
+	key := strings.Join(labelValues, "\xff")
+	if _, ok := m.series[key]; !ok {
+		m.series[key] = append([]string(nil), labelValues...)
+	}
+
+	return key
+}
+
+// sortedKeys returns the keys of every series in a stable order, caller must hold the lock
+func (m *metric) sortedKeys() []string {
+	keys := make([]string, 0, len(m.series))
+	for key := range m.series {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	return keys
+}
+
+func (m *metric) writeHeader(w *bufio.Writer, metricType string) {
+	fmt.Fprintf(w, "# HELP %s %s\n", m.name, strings.Replace(m.help, "\n", " ", -1))
+	fmt.Fprintf(w, "# TYPE %s %s\n", m.name, metricType)
+}
+
+func (m *metric) labelPairs(labelValues []string, extra ...string) string {
+	var pairs []string
+	for i, name := range m.labels {
+		pairs = append(pairs, name+`="`+labelEscaper.Replace(labelValues[i])+`"`)
+	}
+	for i := 0; i+1 < len(extra); i += 2 {
+		pairs = append(pairs, extra[i]+`="`+labelEscaper.Replace(extra[i+1])+`"`)
+	}
+
+	if len(pairs) == 0 {
+		return ""
+	}
+
+	return "{" + strings.Join(pairs, ",") + "}"
+}
+
+// CounterVec is a monotonically increasing value partitioned by labels
+type CounterVec struct {
+	metric
+	values map[string]float64
+}
+
+// NewCounterVec creates a counter and adds it to the default registry
+func NewCounterVec(name, help string, labels ...string) *CounterVec {
+	c := &CounterVec{
+		metric: newMetric(name, help, labels),
+		values: make(map[string]float64),
+	}
+	DefaultRegistry.register(c)
+
+	return c
+}
+
+// Inc increments the counter for the given label values by one
+func (c *CounterVec) Inc(labelValues ...string) {
+	c.Add(1, labelValues...)
+}
+
+// Add increases the counter for the given label values by v
+func (c *CounterVec) Add(v float64, labelValues ...string) {
+	c.mu.Lock()
+	c.values[c.key(labelValues)] += v
+	c.mu.Unlock()
+}
+
+func (c *CounterVec) write(w *bufio.Writer) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.writeHeader(w, "counter")
+	for _, key := range c.sortedKeys() {
+		fmt.Fprintf(w, "%s%s %s\n", c.name, c.labelPairs(c.series[key]), formatFloat(c.values[key]))
+	}
+}
+
+// HistogramVec counts observations in configurable buckets partitioned by labels
+type HistogramVec struct {
+	metric
+	buckets []float64
+	values  map[string]*histogramValue
+}
+
+type histogramValue struct {
+	counts []uint64
+	count  uint64
+	sum    float64
+}
+
+// NewHistogramVec creates a histogram and adds it to the default registry
+func NewHistogramVec(name, help string, buckets []float64, labels ...string) *HistogramVec {
+	b := append([]float64(nil), buckets...)
+	sort.Float64s(b)
+
+	h := &HistogramVec{
+		metric:  newMetric(name, help, labels),
+		buckets: b,
+		values:  make(map[string]*histogramValue),
+	}
+	DefaultRegistry.register(h)
+
+	return h
+}
+
+// Observe records a single value for the given label values
+func (h *HistogramVec) Observe(v float64, labelValues ...string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	key := h.key(labelValues)
+	value, ok := h.values[key]
+	if !ok {
+		value = &histogramValue{counts: make([]uint64, len(h.buckets))}
+		h.values[key] = value
+	}
+
+	for i, upper := range h.buckets {
+		if v <= upper {
+			value.counts[i]++
+		}
+	}
+	value.count++
+	value.sum += v
+}
+
+func (h *HistogramVec) write(w *bufio.Writer) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.writeHeader(w, "histogram")
+	for _, key := range h.sortedKeys() {
+		labelValues := h.series[key]
+		value := h.values[key]
+
+		for i, upper := range h.buckets {
+			fmt.Fprintf(w, "%s_bucket%s %d\n", h.name, h.labelPairs(labelValues, "le", formatFloat(upper)), value.counts[i])
+		}
+		fmt.Fprintf(w, "%s_bucket%s %d\n", h.name, h.labelPairs(labelValues, "le", "+Inf"), value.count)
+		fmt.Fprintf(w, "%s_sum%s %s\n", h.name, h.labelPairs(labelValues), formatFloat(value.sum))
+		fmt.Fprintf(w, "%s_count%s %d\n", h.name, h.labelPairs(labelValues), value.count)
+	}
+}
+
+func formatFloat(v float64) string {
+	switch {
+	case math.IsInf(v, 1):
+		return "+Inf"
+	case math.IsInf(v, -1):
+		return "-Inf"
+	case math.IsNaN(v):
+		return "NaN"
+	}
+
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
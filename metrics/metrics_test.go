@@ -0,0 +1,142 @@
+package metrics
+
+import (
+	"io/ioutil"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// scrape returns the metrics in the registry as they are exposed
+func scrape(t *testing.T, r *Registry) string {
+	t.Helper()
+
+	w := httptest.NewRecorder()
+	r.Handler().ServeHTTP(w, httptest.NewRequest("GET", "/metrics", nil))
+
+	if contentType := w.Header().Get("Content-Type"); !strings.HasPrefix(contentType, "text/plain; version=0.0.4") {
+		t.Errorf("expected the prometheus text format, got %q", contentType)
+	}
+
+	b, _ := ioutil.ReadAll(w.Body)
+	return string(b)
+}
+
+func expectLines(t *testing.T, exposed string, lines ...string) {
+	t.Helper()
+
+	for _, line := range lines {
+		if !strings.Contains(exposed, line+"\n") {
+			t.Errorf("expected %q in:\n%s", line, exposed)
+		}
+	}
+}
+
+func TestCounter(t *testing.T) {
+	r := NewRegistry()
+	c := NewCounterVec("test_counter_total", "A counter\nover two lines.", "route", "status")
+	r.register(c)
+
+	c.Inc("/b", "200")
+	c.Inc("/a", "200")
+	c.Add(2.5, "/a", "200")
+	c.Inc("/a", "500")
+
+	expected := `# HELP test_counter_total A counter over two lines.
+# TYPE test_counter_total counter
+test_counter_total{route="/a",status="200"} 3.5
+test_counter_total{route="/a",status="500"} 1
+test_counter_total{route="/b",status="200"} 1
+`
+	if exposed := scrape(t, r); exposed != expected {
+		t.Errorf("expected:\n%s\ngot:\n%s", expected, exposed)
+	}
+}
+
+func TestLabelEscaping(t *testing.T) {
+	r := NewRegistry()
+	c := NewCounterVec("test_escaped_total", "Escaped labels.", "value")
+	r.register(c)
+
+	c.Inc(`a "quoted" \path` + "\nwith a newline")
+
+	expectLines(t, scrape(t, r), `test_escaped_total{value="a \"quoted\" \\path\nwith a newline"} 1`)
+}
+
+func TestLabelCount(t *testing.T) {
+	c := NewCounterVec("test_label_count_total", "Label count.", "route")
+
+	defer func() {
+		if recover() == nil {
+			t.Error("expected the wrong number of label values to panic")
+		}
+	}()
+	c.Inc("/a", "200")
+}
+
+func TestHistogram(t *testing.T) {
+	r := NewRegistry()
+	h := NewHistogramVec("test_duration_seconds", "A histogram.", []float64{1, 0.1, 0.5}, "route")
+	r.register(h)
+
+	for _, v := range []float64{0.05, 0.1, 0.3, 0.7, 2} {
+		h.Observe(v, "/a")
+	}
+
+	// Buckets are sorted and cumulative, with +Inf counting every observation
+	expectLines(t, scrape(t, r),
+		"# TYPE test_duration_seconds histogram",
+		`test_duration_seconds_bucket{route="/a",le="0.1"} 2`,
+		`test_duration_seconds_bucket{route="/a",le="0.5"} 3`,
+		`test_duration_seconds_bucket{route="/a",le="1"} 4`,
+		`test_duration_seconds_bucket{route="/a",le="+Inf"} 5`,
+		`test_duration_seconds_sum{route="/a"} 3.15`,
+		`test_duration_seconds_count{route="/a"} 5`,
+	)
+}
+
+func TestHistogramWithoutLabels(t *testing.T) {
+	r := NewRegistry()
+	h := NewHistogramVec("test_size", "Unlabelled.", []float64{10})
+	r.register(h)
+
+	h.Observe(20)
+
+	expectLines(t, scrape(t, r),
+		`test_size_bucket{le="10"} 0`,
+		`test_size_bucket{le="+Inf"} 1`,
+		"test_size_sum 20",
+		"test_size_count 1",
+	)
+}
+
+func TestConcurrentUpdates(t *testing.T) {
+	r := NewRegistry()
+	c := NewCounterVec("test_concurrent_total", "Concurrent counter.", "route")
+	h := NewHistogramVec("test_concurrent_seconds", "Concurrent histogram.", []float64{1}, "route")
+	r.register(c)
+	r.register(h)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				c.Inc("/a")
+				h.Observe(0.5, "/a")
+				if j%10 == 0 {
+					scrape(t, r)
+				}
+			}
+		}()
+	}
+	wg.Wait()
+
+	expectLines(t, scrape(t, r),
+		`test_concurrent_total{route="/a"} 1000`,
+		`test_concurrent_seconds_bucket{route="/a",le="1"} 1000`,
+		`test_concurrent_seconds_count{route="/a"} 1000`,
+	)
+}
@@ -4,7 +4,8 @@ import errs "github.com/ofs/alpha-search-api/apierrors"
 
 // ErrorResponse builds a list of errors for an unsuccessful request
 type ErrorResponse struct {
-	Errors []*ErrorObject `json:"errors"`
+	Errors    []*ErrorObject `json:"errors"`
+	RequestID string         `json:"request_id,omitempty"`
 }
 
 // ErrorObject contains an error message and error values
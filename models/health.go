@@ -0,0 +1,30 @@
+package models
+
+import "time"
+
+// A list of health check statuses
+const (
+	HealthStatusOK   = "OK"
+	HealthStatusWarn = "WARN"
+	HealthStatusFail = "FAIL"
+)
+
+// HealthResponse represents the overall state of the service and its dependencies
+type HealthResponse struct {
+	Status string        `json:"status"`
+	Checks []HealthCheck `json:"checks,omitempty"`
+}
+
+// HealthCheck represents the result of checking a single dependency
+type HealthCheck struct {
+	Name        string    `json:"name"`
+	Status      string    `json:"status"`
+	Message     string    `json:"message,omitempty"`
+	LastChecked time.Time `json:"last_checked"`
+}
+
+// ClusterHealth represents the health of an elasticsearch cluster
+type ClusterHealth struct {
+	ClusterName string `json:"cluster_name"`
+	Status      string `json:"status"`
+}
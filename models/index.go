@@ -0,0 +1,50 @@
+package models
+
+// BulkResponse represents the result of each action sent to the elasticsearch bulk api
+type BulkResponse struct {
+	Took   int                   `json:"took"`
+	Errors bool                  `json:"errors"`
+	Items  []map[string]BulkItem `json:"items"`
+}
+
+// BulkItem represents the result of a single bulk action, keyed by the action name
+type BulkItem struct {
+	Index  string     `json:"_index"`
+	ID     string     `json:"_id"`
+	Status int        `json:"status"`
+	Error  *BulkError `json:"error,omitempty"`
+}
+
+// BulkError describes why elasticsearch rejected a bulk action
+type BulkError struct {
+	Type   string `json:"type"`
+	Reason string `json:"reason"`
+}
+
+// CountResponse represents the number of documents held by an index
+type CountResponse struct {
+	Count int `json:"count"`
+}
+
+// CatIndex represents an index listed by the elasticsearch cat indices api
+type CatIndex struct {
+	Index string `json:"index"`
+}
+
+// IndexMapping represents the mappings of an index, keyed by document type
+type IndexMapping struct {
+	Mappings map[string]TypeMapping `json:"mappings"`
+}
+
+// TypeMapping represents the fields mapped for a document type
+type TypeMapping struct {
+	Properties map[string]FieldMapping `json:"properties"`
+}
+
+// FieldMapping represents how a field is stored, along with any fields it holds
+// as an object and any subfields it is also indexed as
+type FieldMapping struct {
+	Type       string                  `json:"type,omitempty"`
+	Properties map[string]FieldMapping `json:"properties,omitempty"`
+	Fields     map[string]FieldMapping `json:"fields,omitempty"`
+}
@@ -8,6 +8,7 @@ type InstitutionCoursesSearchResult struct {
 	Offset               int           `json:"offset"`
 	TotalResults         int           `json:"total_results"`
 	TotalNumberOfCourses int           `json:"total_number_of_courses"`
+	Debug                *SearchDebug  `json:"debug,omitempty"`
 }
 
 // Institution represents institution data of a single item in returned list
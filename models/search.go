@@ -1,6 +1,7 @@
 package models
 
 import (
+	"encoding/json"
 	"errors"
 	"strconv"
 )
@@ -10,8 +11,15 @@ func ErrorMaximumOffsetReached(m int) error {
 	return err
 }
 
+func ErrorMaximumLengthExceeded(name string, m int) error {
+	err := errors.New(name + " exceeded the maximum length, " + name + " cannot be longer than " + strconv.Itoa(m) + " characters")
+	return err
+}
+
 type SearchResponse struct {
-	Hits Hits `json:"hits"`
+	Took    int             `json:"took"`
+	Hits    Hits            `json:"hits"`
+	Profile json.RawMessage `json:"profile,omitempty"`
 }
 
 type Hits struct {
@@ -25,20 +33,29 @@ type HitList struct {
 	Source    SearchResult `json:"_source"`
 }
 
+// Highlight holds the fragments of each field which matched, keyed by elasticsearch
+// by the full path of the field requested
 type Highlight struct {
-	KISCourseID     []string `json:"kis_course_id,omitempty"`
-	EnglishTitle    []string `json:"english_title,omitempty"`
-	WelshTitle      []string `json:"welsh_title,omitempty"`
-	InstitutionName []string `json:"institution_public_name,omitempty"`
+	KISCourseID     []string `json:"doc.kis_course_id,omitempty"`
+	EnglishTitle    []string `json:"doc.english_title,omitempty"`
+	WelshTitle      []string `json:"doc.welsh_title,omitempty"`
+	InstitutionName []string `json:"doc.institution.public_ukprn_name,omitempty"`
 }
 
 // CoursesSearchResults represents a structure for a list of returned objects
 type CoursesSearchResults struct {
-	TotalResults int        `json:"total_results"`
-	Count        int        `json:"number_of_items"`
-	Items        []Document `json:"items"`
-	Limit        int        `json:"limit"`
-	Offset       int        `json:"offset"`
+	TotalResults int          `json:"total_results"`
+	Count        int          `json:"number_of_items"`
+	Items        []Document   `json:"items"`
+	Limit        int          `json:"limit"`
+	Offset       int          `json:"offset"`
+	Debug        *SearchDebug `json:"debug,omitempty"`
+}
+
+// SearchDebug contains timings and the profile breakdown of a search from elasticsearch
+type SearchDebug struct {
+	Took    int             `json:"took"`
+	Profile json.RawMessage `json:"profile,omitempty"`
 }
 
 // SearchResult represents data on a single item of search results
@@ -102,6 +119,10 @@ func (page *PageVariables) ValidateQueryParameters(term string) []*ErrorObject {
 	// 	errorObjects = append(errorObjects, &ErrorObject{Error: errs.ErrEmptySearchTerm.Error(), ErrorValues: termErrorValue})
 	// }
 
+	if errorObject := validateTermLength(term); errorObject != nil {
+		errorObjects = append(errorObjects, errorObject)
+	}
+
 	if page.Offset >= page.DefaultMaxResults {
 		pagingErrorValue := make(map[string](string))
 		pagingErrorValue["offset"] = strconv.Itoa(page.Offset)
@@ -1,21 +1,68 @@
 package models
 
 import (
+	"sort"
 	"strconv"
 	"strings"
+	"unicode/utf8"
 
-	"github.com/methods/go-methods-lib/log"
 	errs "github.com/ofs/alpha-search-api/apierrors"
 	"github.com/ofs/alpha-search-api/helpers"
 )
 
+const (
+	// MaxTermLength is the maximum number of characters in a search term
+	MaxTermLength = 200
+	// MaxListLength is the maximum number of characters in a comma separated list parameter
+	MaxListLength = 1000
+)
+
+// ValidateList checks the comma separated list is not too long and has no empty
+// values, returning each value with surrounding whitespace removed
+func ValidateList(name, list string) ([]string, []*ErrorObject) {
+	if length := utf8.RuneCountInString(list); length > MaxListLength {
+		return nil, []*ErrorObject{lengthErrorObject(name, length, MaxListLength)}
+	}
+
+	values := strings.Split(list, ",")
+	for i, value := range values {
+		values[i] = strings.TrimSpace(value)
+		if values[i] == "" {
+			return nil, []*ErrorObject{&ErrorObject{Error: errs.ErrEmptyListValue.Error(), ErrorValues: map[string]string{name: list}}}
+		}
+	}
+
+	return values, nil
+}
+
+// validateTermLength checks the search term is not too long
+func validateTermLength(term string) *ErrorObject {
+	if length := utf8.RuneCountInString(term); length > MaxTermLength {
+		return lengthErrorObject("q", length, MaxTermLength)
+	}
+
+	return nil
+}
+
+// lengthErrorObject reports the length of a value rather than the value itself,
+// as it may be enormous
+func lengthErrorObject(name string, length, maximum int) *ErrorObject {
+	return &ErrorObject{
+		Error:       ErrorMaximumLengthExceeded(name, maximum).Error(),
+		ErrorValues: map[string]string{name: strconv.Itoa(length) + " characters"},
+	}
+}
+
 // ValidateFilters checks the filters set are valid
 func ValidateFilters(filters string) (map[string]string, []*ErrorObject) {
-	var errorObjects []*ErrorObject
 	var err error
 
+	fs, errorObjects := ValidateList("filters", filters)
+	if errorObjects != nil {
+		return nil, errorObjects
+	}
+
 	newFilters := make(map[string]string)
-	fs := strings.Split(filters, ",")
 
 	countFilters := make(map[string]int)
 	var invalidFilters, duplicateFilters []string
@@ -39,8 +86,8 @@ func ValidateFilters(filters string) (map[string]string, []*ErrorObject) {
 			newFilters[filterWithoutPrefix] = "true"
 		}
 
-		// Find duplicate filters
-		if countFilters[filterWithoutPrefix] > 1 {
+		// Find duplicate filters, reporting each once
+		if countFilters[filterWithoutPrefix] == 2 {
 			duplicateFilters = append(duplicateFilters, filterWithoutPrefix)
 		}
 	}
@@ -62,7 +109,7 @@ func ValidateFilters(filters string) (map[string]string, []*ErrorObject) {
 	}
 
 	if errorObjects != nil {
-		return newFilters, errorObjects
+		return nil, errorObjects
 	}
 
 	return newFilters, nil
@@ -85,20 +132,31 @@ func checkFilterIsValid(filter string) error {
 	return nil
 }
 
-// ValidateCountries checks the filters set are valid
+// ValidateCountries checks the countries set are valid, returning the codes of
+// the countries to include in order of code
 func ValidateCountries(countries string) ([]string, []*ErrorObject) {
-	var errorObjects []*ErrorObject
 	var err error
 
-	var mustHaveCountries, mustNotHaveCountries, invalidCountries []string
+	cs, errorObjects := ValidateList("countries", countries)
+	if errorObjects != nil {
+		return nil, errorObjects
+	}
+
+	var mustHaveCountries, mustNotHaveCountries, invalidCountries, duplicateCountries []string
 	var countryCode string
 
-	cs := strings.Split(countries, ",")
+	countCountries := make(map[string]int)
 
 	for _, country := range cs {
 		countryCode, err = checkCountryIsValid(country)
 		if err != nil {
 			invalidCountries = append(invalidCountries, country)
+			continue
+		}
+
+		countCountries[countryCode]++
+		if countCountries[countryCode] == 2 {
+			duplicateCountries = append(duplicateCountries, strings.TrimPrefix(country, "-"))
 		}
 
 		if strings.HasPrefix(country, "-") {
@@ -111,10 +169,19 @@ func ValidateCountries(countries string) ([]string, []*ErrorObject) {
 	if len(invalidCountries) > 0 {
 		invalidCountryList := map[string]string{"countries": helpers.StringifyWords(invalidCountries)}
 		errorObjects = append(errorObjects, &ErrorObject{Error: errs.ErrInvalidCountry.Error(), ErrorValues: invalidCountryList})
+	}
+
+	if len(duplicateCountries) > 0 {
+		duplicateCountryList := map[string]string{"countries": helpers.StringifyWords(duplicateCountries)}
+		errorObjects = append(errorObjects, &ErrorObject{Error: errs.ErrDuplicateCountries.Error(), ErrorValues: duplicateCountryList})
+	}
+
+	if errorObjects != nil {
 		return nil, errorObjects
 	}
 
 	if len(mustHaveCountries) > 0 {
+		sort.Strings(mustHaveCountries)
 		return mustHaveCountries, nil
 	}
 
@@ -123,6 +190,14 @@ func ValidateCountries(countries string) ([]string, []*ErrorObject) {
 	return mustHaveCountries, nil
 }
 
+// CountryCodes are the codes stored in the index for each country name
+var CountryCodes = map[string]string{
+	"England":          "XF",
+	"Northern Ireland": "XG",
+	"Scotland":         "XH",
+	"Wales":            "XI",
+}
+
 func checkCountryIsValid(country string) (string, error) {
 	c := strings.TrimPrefix(country, "-")
 
@@ -143,21 +218,17 @@ func checkCountryIsValid(country string) (string, error) {
 	return countryCode, nil
 }
 
+// convert returns the codes of every country not excluded, in order of code
 func convert(mustNotHaveCountries []string) []string {
 	var mustHaveCountries []string
 
-	countries := make(map[string]bool)
-	countries["XF"] = true
-	countries["XG"] = true
-	countries["XH"] = true
-	countries["XI"] = true
-
+	excluded := make(map[string]bool)
 	for _, country := range mustNotHaveCountries {
-		countries[country] = false
+		excluded[country] = true
 	}
 
-	for country, ok := range countries {
-		if ok {
+	for _, country := range []string{"XF", "XG", "XH", "XI"} {
+		if !excluded[country] {
 			mustHaveCountries = append(mustHaveCountries, country)
 		}
 	}
@@ -165,40 +236,48 @@ func convert(mustNotHaveCountries []string) []string {
 	return mustHaveCountries
 }
 
+// ValidateLengthOfCourse checks the lengths of course set are numbers of years
+// between 1 and 7, returning them without signs or leading zeros
 func ValidateLengthOfCourse(lengthOfCourse string) ([]string, []*ErrorObject) {
-	var errorObjects []*ErrorObject
-	var newLengthOfCourse, invalidType, outOfRange []string
+	loc, errorObjects := ValidateList("length_of_course", lengthOfCourse)
+	if errorObjects != nil {
+		return nil, errorObjects
+	}
 
-	loc := strings.Split(lengthOfCourse, ",")
+	var newLengthOfCourse, invalidType, outOfRange []string
+	found := make(map[int]bool)
 
 	for _, length := range loc {
-		log.Debug("length", log.Data{"length": length})
 		l, err := strconv.Atoi(length)
-		if err != nil {
+		if err != nil && !helpers.IsOutOfRange(err) {
 			invalidType = append(invalidType, length)
 			continue
 		}
 
 		if l < 1 || l > 7 {
 			outOfRange = append(outOfRange, length)
+			continue
 		}
 
-		newLengthOfCourse = append(newLengthOfCourse, length)
+		// The same length given twice, e.g. as 3 and 03, is only searched for once
+		if !found[l] {
+			found[l] = true
+			newLengthOfCourse = append(newLengthOfCourse, strconv.Itoa(l))
+		}
 	}
 
 	if len(invalidType) > 0 {
-		invalidTypeList := map[string]string{"countries": helpers.StringifyWords(invalidType)}
+		invalidTypeList := map[string]string{"length_of_course": helpers.StringifyWords(invalidType)}
 		errorObjects = append(errorObjects, &ErrorObject{Error: errs.ErrLengthOfCourseWrongType.Error(), ErrorValues: invalidTypeList})
 	}
 
 	if len(outOfRange) > 0 {
-		outOfRangeList := map[string]string{"countries": helpers.StringifyWords(outOfRange)}
+		outOfRangeList := map[string]string{"length_of_course": helpers.StringifyWords(outOfRange)}
 		errorObjects = append(errorObjects, &ErrorObject{Error: errs.ErrLengthOfCourseOutOfRange.Error(), ErrorValues: outOfRangeList})
 	}
 
 	if errorObjects != nil {
-		log.Debug("did we get here?", log.Data{"error": errorObjects})
-		return newLengthOfCourse, errorObjects
+		return nil, errorObjects
 	}
 
 	return newLengthOfCourse, nil
@@ -0,0 +1,186 @@
+package models_test
+
+import (
+	"sort"
+	"strings"
+	"testing"
+	"unicode/utf8"
+
+	"github.com/ofs/alpha-search-api/models"
+)
+
+var listSeeds = []string{
+	"",
+	",",
+	"wales,",
+	",wales",
+	"wales,,scotland",
+	" wales , -scotland ",
+	"wales, ",
+	"wales,wales",
+	"wales,-wales",
+	"-wales,-england",
+	"part_time,-sandwich_year",
+	"part_time,full_time",
+	"part_time,part_time,part_time",
+	"--part_time",
+	"3,4",
+	"+3,03",
+	"0,8",
+	"99999999999999999999",
+	"٣",
+	"\xff",
+	strings.Repeat("wales,", 200) + "wales",
+}
+
+// checkErrors fails the test if the validator both returned values and reported
+// errors, or if an error echoes more than the longest list accepted
+func checkErrors(t *testing.T, list string, values int, errorObjects []*models.ErrorObject) {
+	t.Helper()
+
+	if errorObjects == nil {
+		return
+	}
+
+	if values > 0 {
+		t.Fatalf("%q: expected no values alongside errors %v", list, errorObjects)
+	}
+
+	for _, errorObject := range errorObjects {
+		if errorObject.Error == "" {
+			t.Fatalf("%q: expected an error message", list)
+		}
+		for key, value := range errorObject.ErrorValues {
+			if utf8.RuneCountInString(value) > models.MaxListLength {
+				t.Fatalf("%q: error value of %s is too long to return", list, key)
+			}
+		}
+	}
+}
+
+// checkList fails the test if a list the validator accepted is too long or has empty values
+func checkList(t *testing.T, list string) {
+	t.Helper()
+
+	if utf8.RuneCountInString(list) > models.MaxListLength {
+		t.Fatalf("%q: accepted a list longer than the maximum", list)
+	}
+
+	for _, value := range strings.Split(list, ",") {
+		if strings.TrimSpace(value) == "" {
+			t.Fatalf("%q: accepted a list with an empty value", list)
+		}
+	}
+}
+
+func FuzzValidateFilters(f *testing.F) {
+	for _, seed := range listSeeds {
+		f.Add(seed)
+	}
+
+	f.Fuzz(func(t *testing.T, filters string) {
+		newFilters, errorObjects := models.ValidateFilters(filters)
+		checkErrors(t, filters, len(newFilters), errorObjects)
+		if errorObjects != nil {
+			return
+		}
+
+		checkList(t, filters)
+
+		if len(newFilters) != len(strings.Split(filters, ",")) {
+			t.Fatalf("%q: expected each filter once, got %v", filters, newFilters)
+		}
+
+		for name, value := range newFilters {
+			if strings.ContainsAny(name, " -,") || (value != "true" && value != "false") {
+				t.Fatalf("%q: invalid filter %s=%s", filters, name, value)
+			}
+		}
+
+		if _, ok := newFilters["part_time"]; ok {
+			if _, ok := newFilters["full_time"]; ok {
+				t.Fatalf("%q: accepted both modes", filters)
+			}
+		}
+	})
+}
+
+func FuzzValidateCountries(f *testing.F) {
+	for _, seed := range listSeeds {
+		f.Add(seed)
+	}
+
+	codes := map[string]bool{"XF": true, "XG": true, "XH": true, "XI": true}
+
+	f.Fuzz(func(t *testing.T, countries string) {
+		newCountries, errorObjects := models.ValidateCountries(countries)
+		checkErrors(t, countries, len(newCountries), errorObjects)
+		if errorObjects != nil {
+			return
+		}
+
+		checkList(t, countries)
+
+		if !sort.StringsAreSorted(newCountries) {
+			t.Fatalf("%q: expected codes in order, got %v", countries, newCountries)
+		}
+
+		for i, code := range newCountries {
+			if !codes[code] {
+				t.Fatalf("%q: invalid country code %q", countries, code)
+			}
+			if i > 0 && newCountries[i-1] == code {
+				t.Fatalf("%q: duplicate country code %q", countries, code)
+			}
+		}
+	})
+}
+
+func FuzzValidateLengthOfCourse(f *testing.F) {
+	for _, seed := range listSeeds {
+		f.Add(seed)
+	}
+
+	f.Fuzz(func(t *testing.T, lengthOfCourse string) {
+		newLengthOfCourse, errorObjects := models.ValidateLengthOfCourse(lengthOfCourse)
+		checkErrors(t, lengthOfCourse, len(newLengthOfCourse), errorObjects)
+		if errorObjects != nil {
+			return
+		}
+
+		checkList(t, lengthOfCourse)
+
+		found := make(map[string]bool)
+		for _, length := range newLengthOfCourse {
+			if len(length) != 1 || length < "1" || length > "7" {
+				t.Fatalf("%q: invalid length of course %q", lengthOfCourse, length)
+			}
+			if found[length] {
+				t.Fatalf("%q: duplicate length of course %q", lengthOfCourse, length)
+			}
+			found[length] = true
+		}
+	})
+}
+
+func TestValidateListErrors(t *testing.T) {
+	cases := []struct {
+		list  string
+		error string
+	}{
+		{"wales,", "lists cannot contain empty values, values must be separated by a single comma"},
+		{strings.Repeat("a", models.MaxListLength+1), "institutions exceeded the maximum length, institutions cannot be longer than 1000 characters"},
+	}
+
+	for _, tc := range cases {
+		values, errorObjects := models.ValidateList("institutions", tc.list)
+		if values != nil || len(errorObjects) != 1 || errorObjects[0].Error != tc.error {
+			t.Errorf("%.20q: expected the error %q, got %v", tc.list, tc.error, errorObjects)
+		}
+	}
+
+	values, errorObjects := models.ValidateList("institutions", " University of Leeds ,Aberystwyth University")
+	if errorObjects != nil || len(values) != 2 || values[0] != "University of Leeds" {
+		t.Errorf("expected values without surrounding whitespace, got %q and %v", values, errorObjects)
+	}
+}
@@ -0,0 +1,222 @@
+package openapi
+
+import (
+	_ "embed" // required to embed the spec
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/pkg/errors"
+	yaml "gopkg.in/yaml.v2"
+)
+
+// Spec is the OpenAPI specification of the api
+//
+//go:embed swagger.yml
+var Spec []byte
+
+// Docs is a page rendering the specification with Swagger UI
+//
+//go:embed docs.html
+var Docs []byte
+
+// Document is a parsed specification, holding each operation by path and method
+type Document struct {
+	root       map[string]interface{}
+	operations map[string]map[string]*Operation
+}
+
+// Operation describes a single method of a path
+type Operation struct {
+	Path       string
+	Method     string
+	Parameters []*Parameter
+	responses  map[string]interface{}
+}
+
+// Parameter describes a query or header parameter of an operation
+type Parameter struct {
+	Name     string
+	In       string
+	Required bool
+	Example  string
+	Schema   map[string]interface{}
+}
+
+// Load parses the embedded specification
+func Load() (*Document, error) {
+	return Parse(Spec)
+}
+
+// Parse parses a specification, resolving references to shared parameters
+func Parse(b []byte) (*Document, error) {
+	var raw interface{}
+	if err := yaml.Unmarshal(b, &raw); err != nil {
+		return nil, errors.Wrap(err, "failed to parse openapi specification")
+	}
+
+	root, ok := normalise(raw).(map[string]interface{})
+	if !ok {
+		return nil, errors.New("openapi specification must be an object")
+	}
+
+	doc := &Document{
+		root:       root,
+		operations: make(map[string]map[string]*Operation),
+	}
+
+	paths, _ := root["paths"].(map[string]interface{})
+	for path, item := range paths {
+		methods, _ := item.(map[string]interface{})
+
+		for method, value := range methods {
+			op, err := doc.operation(path, strings.ToUpper(method), value)
+			if err != nil {
+				return nil, err
+			}
+
+			if doc.operations[path] == nil {
+				doc.operations[path] = make(map[string]*Operation)
+			}
+			doc.operations[path][op.Method] = op
+		}
+	}
+
+	return doc, nil
+}
+
+func (d *Document) operation(path, method string, value interface{}) (*Operation, error) {
+	definition, ok := value.(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("operation %s %s must be an object", method, path)
+	}
+
+	op := &Operation{Path: path, Method: method}
+	op.responses, _ = definition["responses"].(map[string]interface{})
+
+	parameters, _ := definition["parameters"].([]interface{})
+	for _, p := range parameters {
+		resolved, err := d.resolve(p)
+		if err != nil {
+			return nil, errors.WithMessage(err, fmt.Sprintf("operation %s %s", method, path))
+		}
+
+		name, _ := resolved["name"].(string)
+		in, _ := resolved["in"].(string)
+		required, _ := resolved["required"].(bool)
+		schema, _ := resolved["schema"].(map[string]interface{})
+
+		if name == "" || in == "" {
+			return nil, fmt.Errorf("operation %s %s has a parameter without a name or location", method, path)
+		}
+
+		var example string
+		if value, ok := resolved["example"]; ok {
+			example = fmt.Sprint(value)
+		}
+
+		op.Parameters = append(op.Parameters, &Parameter{Name: name, In: in, Required: required, Example: example, Schema: schema})
+	}
+
+	return op, nil
+}
+
+// Operation returns the operation for the path template and method, if documented
+func (d *Document) Operation(path, method string) (*Operation, bool) {
+	op, ok := d.operations[path][strings.ToUpper(method)]
+	return op, ok
+}
+
+// Operations returns every documented operation, sorted by path and method
+func (d *Document) Operations() []*Operation {
+	var ops []*Operation
+	for _, methods := range d.operations {
+		for _, op := range methods {
+			ops = append(ops, op)
+		}
+	}
+
+	sort.Slice(ops, func(i, j int) bool {
+		if ops[i].Path != ops[j].Path {
+			return ops[i].Path < ops[j].Path
+		}
+		return ops[i].Method < ops[j].Method
+	})
+
+	return ops
+}
+
+// Parameter returns the parameter of the operation with the given name and location
+func (op *Operation) Parameter(in, name string) (*Parameter, bool) {
+	for _, p := range op.Parameters {
+		if p.In == in && p.Name == name {
+			return p, true
+		}
+	}
+
+	return nil, false
+}
+
+// Statuses returns the documented response status codes of the operation, sorted
+func (op *Operation) Statuses() []string {
+	var statuses []string
+	for status := range op.responses {
+		statuses = append(statuses, status)
+	}
+	sort.Strings(statuses)
+
+	return statuses
+}
+
+// resolve follows a $ref to an object in the same document
+func (d *Document) resolve(value interface{}) (map[string]interface{}, error) {
+	object, ok := value.(map[string]interface{})
+	if !ok {
+		return nil, errors.New("expected an object")
+	}
+
+	for depth := 0; depth < 32; depth++ {
+		ref, ok := object["$ref"].(string)
+		if !ok {
+			return object, nil
+		}
+
+		if !strings.HasPrefix(ref, "#/") {
+			return nil, fmt.Errorf("unsupported reference [%s], only references within the specification are supported", ref)
+		}
+
+		var current interface{} = d.root
+		for _, part := range strings.Split(strings.TrimPrefix(ref, "#/"), "/") {
+			m, _ := current.(map[string]interface{})
+			if current = m[part]; current == nil {
+				return nil, fmt.Errorf("reference [%s] not found", ref)
+			}
+		}
+
+		if object, ok = current.(map[string]interface{}); !ok {
+			return nil, fmt.Errorf("reference [%s] must be an object", ref)
+		}
+	}
+
+	return nil, errors.New("too many nested references")
+}
+
+// normalise converts the maps produced by the yaml package to ones keyed by
+// strings, as used by encoding/json, so that status codes such as 200 are strings
+func normalise(value interface{}) interface{} {
+	switch v := value.(type) {
+	case map[interface{}]interface{}:
+		m := make(map[string]interface{}, len(v))
+		for key, item := range v {
+			m[fmt.Sprint(key)] = normalise(item)
+		}
+		return m
+	case []interface{}:
+		for i, item := range v {
+			v[i] = normalise(item)
+		}
+		return v
+	}
+
+	return value
+}
@@ -0,0 +1,371 @@
+package openapi
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"mime"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// ValidateRequest returns a description of each way the query parameters of
+// the request do not match the operation, including parameters it does not document
+func (d *Document) ValidateRequest(op *Operation, r *http.Request) []string {
+	var violations []string
+
+	query := r.URL.Query()
+
+	var names []string
+	for name := range query {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		if _, ok := op.Parameter("query", name); !ok {
+			violations = append(violations, fmt.Sprintf("query parameter %s is not documented", name))
+		}
+	}
+
+	for _, p := range op.Parameters {
+		var values []string
+		switch p.In {
+		case "query":
+			values = query[p.Name]
+		case "header":
+			values = r.Header[http.CanonicalHeaderKey(p.Name)]
+		default:
+			continue
+		}
+
+		if len(values) == 0 {
+			if p.Required {
+				violations = append(violations, fmt.Sprintf("%s parameter %s is required", p.In, p.Name))
+			}
+			continue
+		}
+
+		for _, value := range values {
+			violations = append(violations, d.validateParameter(p, value)...)
+		}
+	}
+
+	return violations
+}
+
+func (d *Document) validateParameter(p *Parameter, raw string) []string {
+	name := p.In + " parameter " + p.Name
+
+	schema, err := d.resolve(p.Schema)
+	if err != nil {
+		return []string{name + ": " + err.Error()}
+	}
+
+	var value interface{} = raw
+	switch schema["type"] {
+	case "integer":
+		if _, err := strconv.ParseInt(raw, 10, 64); err != nil {
+			return []string{fmt.Sprintf("%s: %q is not an integer", name, raw)}
+		}
+		value = json.Number(raw)
+	case "number":
+		if _, err := strconv.ParseFloat(raw, 64); err != nil {
+			return []string{fmt.Sprintf("%s: %q is not a number", name, raw)}
+		}
+		value = json.Number(raw)
+	case "boolean":
+		b, err := strconv.ParseBool(raw)
+		if err != nil {
+			return []string{fmt.Sprintf("%s: %q is not a boolean", name, raw)}
+		}
+		value = b
+	}
+
+	var violations []string
+	d.validate(schema, value, name, &violations)
+
+	return violations
+}
+
+// ValidateResponse returns a description of each way the response does not
+// match the operation, checking the status is documented and that JSON bodies
+// match the schema of the response
+func (d *Document) ValidateResponse(op *Operation, status int, contentType string, body []byte) []string {
+	definition, ok := op.responses[strconv.Itoa(status)]
+	if !ok {
+		if definition, ok = op.responses["default"]; !ok {
+			return []string{fmt.Sprintf("response status %d is not documented", status)}
+		}
+	}
+
+	response, err := d.resolve(definition)
+	if err != nil {
+		return []string{fmt.Sprintf("response %d: %s", status, err.Error())}
+	}
+
+	content, _ := response["content"].(map[string]interface{})
+	if len(content) == 0 {
+		if len(body) > 0 {
+			return []string{fmt.Sprintf("response %d has a body but none is documented", status)}
+		}
+		return nil
+	}
+
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return []string{fmt.Sprintf("response %d has an invalid content type %q", status, contentType)}
+	}
+
+	media, ok := content[mediaType].(map[string]interface{})
+	if !ok {
+		return []string{fmt.Sprintf("response %d content type %s is not documented", status, mediaType)}
+	}
+
+	schema, ok := media["schema"].(map[string]interface{})
+	if !ok || (mediaType != "application/json" && !strings.HasSuffix(mediaType, "+json")) {
+		return nil
+	}
+
+	decoder := json.NewDecoder(bytes.NewReader(body))
+	decoder.UseNumber()
+
+	var value interface{}
+	if err := decoder.Decode(&value); err != nil {
+		return []string{fmt.Sprintf("response %d body is not valid json: %s", status, err.Error())}
+	}
+
+	var violations []string
+	d.validate(schema, value, "response body", &violations)
+
+	return violations
+}
+
+// ValidateValue returns a description of each way a decoded JSON value (using
+// json.Number for numbers) does not match the schema
+func (d *Document) ValidateValue(schema map[string]interface{}, value interface{}) []string {
+	var violations []string
+	d.validate(schema, value, "value", &violations)
+
+	return violations
+}
+
+// validate checks the value against the subset of JSON schema used by the
+// specification. Objects with declared properties may not have undeclared ones,
+// unless additionalProperties allows them, so fields added to responses without
+// being documented are reported
+func (d *Document) validate(schema map[string]interface{}, value interface{}, at string, violations *[]string) {
+	d.validateSchema(schema, value, at, violations, true)
+}
+
+// validateSchema checks the value, only reporting undeclared properties if
+// checkProperties is set as members of allOf each declare some of the properties
+func (d *Document) validateSchema(schema map[string]interface{}, value interface{}, at string, violations *[]string, checkProperties bool) {
+	schema, err := d.resolve(schema)
+	if err != nil {
+		*violations = append(*violations, at+": "+err.Error())
+		return
+	}
+
+	if value == nil {
+		if nullable, _ := schema["nullable"].(bool); !nullable {
+			*violations = append(*violations, at+": must not be null")
+		}
+		return
+	}
+
+	if all, ok := schema["allOf"].([]interface{}); ok {
+		for _, member := range all {
+			m, _ := member.(map[string]interface{})
+			d.validateSchema(m, value, at, violations, false)
+		}
+		if checkProperties {
+			d.checkProperties(schema, value, at, violations)
+		}
+		return
+	}
+
+	switch schema["type"] {
+	case "object":
+		object, ok := value.(map[string]interface{})
+		if !ok {
+			*violations = append(*violations, at+": must be an object")
+			return
+		}
+		d.validateObject(schema, object, at, violations)
+		if checkProperties {
+			d.checkProperties(schema, value, at, violations)
+		}
+
+	case "array":
+		array, ok := value.([]interface{})
+		if !ok {
+			*violations = append(*violations, at+": must be an array")
+			return
+		}
+		if items, ok := schema["items"].(map[string]interface{}); ok {
+			for i, item := range array {
+				d.validate(items, item, fmt.Sprintf("%s[%d]", at, i), violations)
+			}
+		}
+
+	case "string":
+		s, ok := value.(string)
+		if !ok {
+			*violations = append(*violations, at+": must be a string")
+			return
+		}
+		if maxLength, ok := toFloat(schema["maxLength"]); ok && float64(utf8.RuneCountInString(s)) > maxLength {
+			*violations = append(*violations, fmt.Sprintf("%s: is longer than the maximum length of %v", at, schema["maxLength"]))
+		}
+
+	case "integer":
+		n, ok := value.(json.Number)
+		if _, err := n.Int64(); !ok || err != nil {
+			*violations = append(*violations, at+": must be an integer")
+			return
+		}
+		d.validateRange(schema, n, at, violations)
+
+	case "number":
+		n, ok := value.(json.Number)
+		if _, err := n.Float64(); !ok || err != nil {
+			*violations = append(*violations, at+": must be a number")
+			return
+		}
+		d.validateRange(schema, n, at, violations)
+
+	case "boolean":
+		if _, ok := value.(bool); !ok {
+			*violations = append(*violations, at+": must be a boolean")
+			return
+		}
+	}
+
+	if enum, ok := schema["enum"].([]interface{}); ok {
+		for _, allowed := range enum {
+			if fmt.Sprint(allowed) == fmt.Sprint(value) {
+				return
+			}
+		}
+		*violations = append(*violations, fmt.Sprintf("%s: %v is not one of the allowed values", at, value))
+	}
+}
+
+func (d *Document) validateObject(schema map[string]interface{}, object map[string]interface{}, at string, violations *[]string) {
+	required, _ := schema["required"].([]interface{})
+	for _, name := range required {
+		if _, ok := object[fmt.Sprint(name)]; !ok {
+			*violations = append(*violations, fmt.Sprintf("%s: property %v is required", at, name))
+		}
+	}
+
+	properties, _ := schema["properties"].(map[string]interface{})
+
+	var names []string
+	for name := range object {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		if property, ok := properties[name].(map[string]interface{}); ok {
+			d.validate(property, object[name], at+"."+name, violations)
+			continue
+		}
+
+		if additional, ok := schema["additionalProperties"].(map[string]interface{}); ok {
+			d.validate(additional, object[name], at+"."+name, violations)
+		}
+	}
+}
+
+// checkProperties reports properties of the value not declared by the schema or,
+// for allOf, by any of its members
+func (d *Document) checkProperties(schema map[string]interface{}, value interface{}, at string, violations *[]string) {
+	object, ok := value.(map[string]interface{})
+	if !ok {
+		return
+	}
+
+	declared, open := d.declaredProperties(schema)
+	if open || declared == nil {
+		return
+	}
+
+	var names []string
+	for name := range object {
+		if !declared[name] {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		*violations = append(*violations, fmt.Sprintf("%s: property %s is not documented", at, name))
+	}
+}
+
+// declaredProperties returns the names of properties declared by the schema and
+// whether it allows other properties
+func (d *Document) declaredProperties(schema map[string]interface{}) (map[string]bool, bool) {
+	schema, err := d.resolve(schema)
+	if err != nil {
+		return nil, true
+	}
+
+	if additional, ok := schema["additionalProperties"]; ok && additional != false {
+		return nil, true
+	}
+
+	var declared map[string]bool
+
+	if properties, ok := schema["properties"].(map[string]interface{}); ok {
+		declared = make(map[string]bool)
+		for name := range properties {
+			declared[name] = true
+		}
+	}
+
+	all, _ := schema["allOf"].([]interface{})
+	for _, member := range all {
+		m, _ := member.(map[string]interface{})
+		names, open := d.declaredProperties(m)
+		if open {
+			return nil, true
+		}
+		if names != nil && declared == nil {
+			declared = make(map[string]bool)
+		}
+		for name := range names {
+			declared[name] = true
+		}
+	}
+
+	return declared, false
+}
+
+func (d *Document) validateRange(schema map[string]interface{}, n json.Number, at string, violations *[]string) {
+	value, _ := n.Float64()
+
+	if minimum, ok := toFloat(schema["minimum"]); ok && value < minimum {
+		*violations = append(*violations, fmt.Sprintf("%s: %s is less than the minimum of %v", at, n, schema["minimum"]))
+	}
+	if maximum, ok := toFloat(schema["maximum"]); ok && value > maximum {
+		*violations = append(*violations, fmt.Sprintf("%s: %s is greater than the maximum of %v", at, n, schema["maximum"]))
+	}
+}
+
+func toFloat(value interface{}) (float64, bool) {
+	switch v := value.(type) {
+	case int:
+		return float64(v), true
+	case float64:
+		return v, true
+	}
+
+	return 0, false
+}
@@ -0,0 +1,286 @@
+package openapi_test
+
+import (
+	"encoding/json"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/ofs/alpha-search-api/openapi"
+)
+
+var spec = []byte(`
+openapi: 3.0.0
+paths:
+  /items:
+    get:
+      parameters:
+        - $ref: '#/components/parameters/limit'
+        - name: q
+          in: query
+          required: true
+          schema:
+            type: string
+            maxLength: 5
+        - name: sort
+          in: query
+          schema:
+            type: string
+            enum: [name, date]
+        - name: profile
+          in: query
+          schema:
+            type: boolean
+        - name: X-Api-Key
+          in: header
+          required: true
+          schema:
+            type: string
+      responses:
+        200:
+          description: ok
+          content:
+            application/json:
+              schema:
+                $ref: '#/components/schemas/page'
+        304:
+          description: not modified
+        default:
+          description: error
+          content:
+            application/json:
+              schema:
+                $ref: '#/components/schemas/error'
+  /health:
+    get:
+      responses:
+        200:
+          description: ok
+components:
+  parameters:
+    limit:
+      name: limit
+      in: query
+      schema:
+        type: integer
+        minimum: 0
+        maximum: 100
+  schemas:
+    page:
+      allOf:
+        - $ref: '#/components/schemas/paging'
+        - type: object
+          properties:
+            items:
+              type: array
+              items:
+                $ref: '#/components/schemas/item'
+    paging:
+      type: object
+      required: [total]
+      properties:
+        total:
+          type: integer
+    item:
+      type: object
+      required: [id]
+      properties:
+        id:
+          type: integer
+          minimum: 1
+        name:
+          type: string
+          nullable: true
+        score:
+          type: number
+          minimum: 0
+          maximum: 1
+        kind:
+          type: string
+          enum: [course, institution]
+    labels:
+      type: object
+      additionalProperties:
+        type: string
+    extensible:
+      type: object
+      properties:
+        id:
+          type: integer
+      additionalProperties: true
+    error:
+      type: object
+      properties:
+        message:
+          type: string
+`)
+
+func parse(t *testing.T) *openapi.Document {
+	t.Helper()
+
+	doc, err := openapi.Parse(spec)
+	if err != nil {
+		t.Fatalf("failed to parse specification: %v", err)
+	}
+
+	return doc
+}
+
+func operation(t *testing.T, doc *openapi.Document, path string) *openapi.Operation {
+	t.Helper()
+
+	op, ok := doc.Operation(path, "get")
+	if !ok {
+		t.Fatalf("operation GET %s not found", path)
+	}
+
+	return op
+}
+
+// decode unmarshals JSON as the validator expects, with numbers as json.Number
+func decode(t *testing.T, s string) interface{} {
+	t.Helper()
+
+	decoder := json.NewDecoder(strings.NewReader(s))
+	decoder.UseNumber()
+
+	var value interface{}
+	if err := decoder.Decode(&value); err != nil {
+		t.Fatalf("invalid test json %s: %v", s, err)
+	}
+
+	return value
+}
+
+func TestValidateValue(t *testing.T) {
+	doc := parse(t)
+
+	testCases := []struct {
+		schema     string
+		value      string
+		violations []string
+	}{
+		{"page", `{"total":1,"items":[{"id":1,"name":"maths","score":0.5,"kind":"course"}]}`, nil},
+
+		// allOf members each declare some of the properties of the object
+		{"page", `{"total":1}`, nil},
+		{"page", `{"items":[]}`, []string{"value: property total is required"}},
+		{"page", `{"total":1,"extra":true}`, []string{"value: property extra is not documented"}},
+		{"page", `{"total":"1","items":{}}`, []string{"value.total: must be an integer", "value.items: must be an array"}},
+		{"page", `{"total":1,"items":[{"id":1,"other":1}]}`, []string{"value.items[0]: property other is not documented"}},
+
+		{"item", `[]`, []string{"value: must be an object"}},
+		{"item", `{}`, []string{"value: property id is required"}},
+
+		// nullable
+		{"item", `{"id":1,"name":null}`, nil},
+		{"item", `{"id":null}`, []string{"value.id: must not be null"}},
+		{"item", `null`, []string{"value: must not be null"}},
+
+		// enum
+		{"item", `{"id":1,"kind":"institution"}`, nil},
+		{"item", `{"id":1,"kind":"subject"}`, []string{"value.kind: subject is not one of the allowed values"}},
+
+		// integer and number ranges
+		{"item", `{"id":100000}`, nil},
+		{"item", `{"id":0}`, []string{"value.id: 0 is less than the minimum of 1"}},
+		{"item", `{"id":1.5}`, []string{"value.id: must be an integer"}},
+		{"item", `{"id":99999999999999999999}`, []string{"value.id: must be an integer"}},
+		{"item", `{"id":"1"}`, []string{"value.id: must be an integer"}},
+		{"item", `{"id":1,"score":0}`, nil},
+		{"item", `{"id":1,"score":1.01}`, []string{"value.score: 1.01 is greater than the maximum of 1"}},
+		{"item", `{"id":1,"score":-0.5}`, []string{"value.score: -0.5 is less than the minimum of 0"}},
+
+		// additionalProperties
+		{"labels", `{}`, nil},
+		{"labels", `{"a":"x","b":"y"}`, nil},
+		{"labels", `{"a":"x","b":1}`, []string{"value.b: must be a string"}},
+		{"extensible", `{"id":1,"anything":[1]}`, nil},
+		{"extensible", `{"id":"1"}`, []string{"value.id: must be an integer"}},
+	}
+
+	for _, tc := range testCases {
+		schema := map[string]interface{}{"$ref": "#/components/schemas/" + tc.schema}
+
+		if violations := doc.ValidateValue(schema, decode(t, tc.value)); !reflect.DeepEqual(violations, tc.violations) {
+			t.Errorf("%s %s: expected %q, got %q", tc.schema, tc.value, tc.violations, violations)
+		}
+	}
+}
+
+func TestValidateRequest(t *testing.T) {
+	doc := parse(t)
+	op := operation(t, doc, "/items")
+
+	testCases := []struct {
+		query      string
+		noKey      bool
+		violations []string
+	}{
+		{"q=maths&limit=10&sort=name&profile=true", false, nil},
+		{"q=maths&limit=0", false, nil},
+		{"q=maths&limit=100", false, nil},
+		{"", true, []string{"query parameter q is required", "header parameter X-Api-Key is required"}},
+		{"q=maths&other=1&another=2", false, []string{"query parameter another is not documented", "query parameter other is not documented"}},
+		{"q=maths&limit=abc", false, []string{`query parameter limit: "abc" is not an integer`}},
+		{"q=maths&limit=1.5", false, []string{`query parameter limit: "1.5" is not an integer`}},
+		{"q=maths&limit=-1", false, []string{"query parameter limit: -1 is less than the minimum of 0"}},
+		{"q=maths&limit=101", false, []string{"query parameter limit: 101 is greater than the maximum of 100"}},
+		{"q=maths&sort=size", false, []string{"query parameter sort: size is not one of the allowed values"}},
+		{"q=maths&profile=yes", false, []string{`query parameter profile: "yes" is not a boolean`}},
+		{"q=physics", false, []string{"query parameter q: is longer than the maximum length of 5"}},
+		{"q=maths&q=physics", false, []string{"query parameter q: is longer than the maximum length of 5"}},
+	}
+
+	for _, tc := range testCases {
+		r := httptest.NewRequest("GET", "/items?"+tc.query, nil)
+		if !tc.noKey {
+			r.Header.Set("X-Api-Key", "key")
+		}
+
+		if violations := doc.ValidateRequest(op, r); !reflect.DeepEqual(violations, tc.violations) {
+			t.Errorf("%q: expected %q, got %q", tc.query, tc.violations, violations)
+		}
+	}
+}
+
+func TestValidateResponse(t *testing.T) {
+	doc := parse(t)
+	items := operation(t, doc, "/items")
+	health := operation(t, doc, "/health")
+
+	testCases := []struct {
+		op          *openapi.Operation
+		status      int
+		contentType string
+		body        string
+		violations  []string
+	}{
+		{items, 200, "application/json", `{"total":0,"items":[]}`, nil},
+		{items, 200, "application/json; charset=utf-8", `{"total":0}`, nil},
+		{items, 200, "application/json", `{"items":[]}`, []string{"response body: property total is required"}},
+		{items, 200, "text/plain", `ok`, []string{"response 200 content type text/plain is not documented"}},
+		{items, 200, "", `{}`, []string{`response 200 has an invalid content type ""`}},
+		{items, 304, "", ``, nil},
+		{items, 304, "", `{}`, []string{"response 304 has a body but none is documented"}},
+
+		// undocumented statuses are checked against the default response
+		{items, 500, "application/json", `{"message":"failed"}`, nil},
+		{items, 500, "application/json", `{"message":1}`, []string{"response body.message: must be a string"}},
+		{health, 200, "", ``, nil},
+		{health, 404, "application/json", `{}`, []string{"response status 404 is not documented"}},
+	}
+
+	for _, tc := range testCases {
+		violations := doc.ValidateResponse(tc.op, tc.status, tc.contentType, []byte(tc.body))
+		if !reflect.DeepEqual(violations, tc.violations) {
+			t.Errorf("%s %d %s: expected %q, got %q", tc.op.Path, tc.status, tc.body, tc.violations, violations)
+		}
+	}
+
+	violations := doc.ValidateResponse(items, 200, "application/json", []byte(`{"total":`))
+	if len(violations) != 1 || !strings.HasPrefix(violations[0], "response 200 body is not valid json") {
+		t.Errorf("expected invalid json to be reported, got %q", violations)
+	}
+}
@@ -0,0 +1,136 @@
+package ratelimit
+
+import (
+	"math"
+	"sync"
+	"time"
+)
+
+// sweepInterval is how often buckets which have refilled are discarded
+const sweepInterval = time.Minute
+
+// Limit is the sustained number of requests allowed per second and the number
+// of requests that can be made at once before being limited
+type Limit struct {
+	Rate  float64
+	Burst int
+}
+
+// Enabled returns false if requests are not limited
+func (l Limit) Enabled() bool {
+	return l.Rate > 0
+}
+
+func (l Limit) burst() float64 {
+	if l.Burst <= 0 {
+		return math.Max(1, math.Ceil(l.Rate))
+	}
+	return float64(l.Burst)
+}
+
+// Result describes the state of a bucket after a request has been counted
+type Result struct {
+	Allowed    bool
+	Limit      int
+	Remaining  int
+	Reset      time.Duration
+	RetryAfter time.Duration
+}
+
+type bucket struct {
+	tokens float64
+	last   time.Time
+	limit  Limit
+}
+
+// Limiter keeps a token bucket per key, e.g. per client or per IP address
+type Limiter struct {
+	mu        sync.Mutex
+	buckets   map[string]*bucket
+	lastSweep time.Time
+	now       func() time.Time
+}
+
+// New creates an empty limiter
+func New() *Limiter {
+	return &Limiter{
+		buckets:   make(map[string]*bucket),
+		lastSweep: time.Now(),
+		now:       time.Now,
+	}
+}
+
+// Allow takes a token from the bucket for key if one is available. The limit
+// is given on each call so that it can differ per key and change at runtime
+func (l *Limiter) Allow(key string, limit Limit) Result {
+	burst := limit.burst()
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := l.now()
+	l.sweep(now)
+
+	b, ok := l.buckets[key]
+	if !ok {
+		b = &bucket{tokens: burst, last: now}
+		l.buckets[key] = b
+	}
+
+	b.tokens = math.Min(burst, b.tokens+now.Sub(b.last).Seconds()*limit.Rate)
+	b.last = now
+	b.limit = limit
+
+	result := Result{Limit: int(burst)}
+
+	if b.tokens >= 1 {
+		b.tokens--
+		result.Allowed = true
+	} else {
+		result.RetryAfter = seconds((1 - b.tokens) / limit.Rate)
+	}
+
+	result.Remaining = int(b.tokens)
+	result.Reset = seconds((burst - b.tokens) / limit.Rate)
+
+	return result
+}
+
+// Peek returns the state of the bucket for key without taking a token, so that
+// requests which are not limited can report the limit of the caller
+func (l *Limiter) Peek(key string, limit Limit) Result {
+	burst := limit.burst()
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	tokens := burst
+	if b, ok := l.buckets[key]; ok {
+		tokens = math.Min(burst, b.tokens+l.now().Sub(b.last).Seconds()*limit.Rate)
+	}
+
+	return Result{
+		Allowed:   tokens >= 1,
+		Limit:     int(burst),
+		Remaining: int(tokens),
+		Reset:     seconds((burst - tokens) / limit.Rate),
+	}
+}
+
+// sweep discards buckets which would have refilled, as they are equivalent to new ones
+func (l *Limiter) sweep(now time.Time) {
+	if now.Sub(l.lastSweep) < sweepInterval {
+		return
+	}
+	l.lastSweep = now
+
+	for key, b := range l.buckets {
+		if now.Sub(b.last) > sweepInterval && b.tokens+now.Sub(b.last).Seconds()*b.limit.Rate >= b.limit.burst() {
+			delete(l.buckets, key)
+		}
+	}
+}
+
+func seconds(s float64) time.Duration {
+	return time.Duration(s * float64(time.Second))
+}
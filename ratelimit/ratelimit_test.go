@@ -0,0 +1,149 @@
+package ratelimit
+
+import (
+	"testing"
+	"time"
+)
+
+func newTestLimiter() (*Limiter, *time.Time) {
+	now := time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)
+
+	l := New()
+	l.lastSweep = now
+	l.now = func() time.Time { return now }
+
+	return l, &now
+}
+
+func TestBurst(t *testing.T) {
+	l, _ := newTestLimiter()
+	limit := Limit{Rate: 1, Burst: 3}
+
+	for i, remaining := range []int{2, 1, 0} {
+		result := l.Allow("client", limit)
+		if !result.Allowed || result.Remaining != remaining || result.Limit != 3 {
+			t.Errorf("request %d: expected to be allowed with %d remaining, got %+v", i, remaining, result)
+		}
+	}
+
+	result := l.Allow("client", limit)
+	if result.Allowed || result.Remaining != 0 {
+		t.Errorf("expected the request over the burst to be limited, got %+v", result)
+	}
+
+	// Buckets are independent
+	if result = l.Allow("other", limit); !result.Allowed {
+		t.Errorf("expected another key to be allowed, got %+v", result)
+	}
+}
+
+func TestRefill(t *testing.T) {
+	l, now := newTestLimiter()
+	limit := Limit{Rate: 2, Burst: 2}
+
+	l.Allow("client", limit)
+	l.Allow("client", limit)
+
+	// Half a token has been added
+	*now = now.Add(250 * time.Millisecond)
+	result := l.Allow("client", limit)
+	if result.Allowed {
+		t.Fatalf("expected the request to be limited, got %+v", result)
+	}
+	if result.RetryAfter != 250*time.Millisecond {
+		t.Errorf("expected to retry after the rest of a token is added, got %v", result.RetryAfter)
+	}
+	if result.Reset != 750*time.Millisecond {
+		t.Errorf("expected the bucket to be full after 1.5 tokens are added, got %v", result.Reset)
+	}
+
+	*now = now.Add(result.RetryAfter)
+	if result = l.Allow("client", limit); !result.Allowed || result.Remaining != 0 {
+		t.Errorf("expected the request to be allowed once a token was added, got %+v", result)
+	}
+
+	// Tokens are never added beyond the burst
+	*now = now.Add(time.Hour)
+	if result = l.Allow("client", limit); !result.Allowed || result.Remaining != 1 || result.Reset != 500*time.Millisecond {
+		t.Errorf("expected a full bucket, less the request, got %+v", result)
+	}
+}
+
+func TestDefaultBurst(t *testing.T) {
+	if burst := (Limit{Rate: 2.5}).burst(); burst != 3 {
+		t.Errorf("expected the burst to default to the rate rounded up, got %v", burst)
+	}
+	if burst := (Limit{Rate: 0.1}).burst(); burst != 1 {
+		t.Errorf("expected a burst of at least 1, got %v", burst)
+	}
+	if (Limit{}).Enabled() {
+		t.Error("expected a limit without a rate not to be enabled")
+	}
+}
+
+func TestLimitChanged(t *testing.T) {
+	l, _ := newTestLimiter()
+
+	l.Allow("client", Limit{Rate: 1, Burst: 10})
+
+	// A lower burst applies straight away
+	result := l.Allow("client", Limit{Rate: 1, Burst: 2})
+	if !result.Allowed || result.Remaining != 1 || result.Limit != 2 {
+		t.Errorf("expected the tokens to be capped at the new burst, got %+v", result)
+	}
+}
+
+func TestSweep(t *testing.T) {
+	l, now := newTestLimiter()
+	limit := Limit{Rate: 0.01, Burst: 1}
+
+	l.Allow("refilled", Limit{Rate: 1, Burst: 1})
+	l.Allow("empty", limit)
+
+	*now = now.Add(sweepInterval + time.Second)
+	l.Allow("new", limit)
+
+	if _, ok := l.buckets["refilled"]; ok {
+		t.Error("expected a bucket which has refilled to be discarded")
+	}
+	if _, ok := l.buckets["empty"]; !ok {
+		t.Error("expected a bucket which has not refilled to be kept")
+	}
+
+	// A discarded bucket starts full again
+	if result := l.Allow("refilled", Limit{Rate: 1, Burst: 1}); !result.Allowed {
+		t.Errorf("expected a discarded bucket to start full, got %+v", result)
+	}
+	if result := l.Allow("empty", limit); result.Allowed {
+		t.Errorf("expected a kept bucket to still be limited, got %+v", result)
+	}
+}
+
+func TestPeek(t *testing.T) {
+	now := time.Date(2020, 1, 2, 3, 0, 0, 0, time.UTC)
+	l := New()
+	l.now = func() time.Time { return now }
+
+	limit := Limit{Rate: 1, Burst: 3}
+
+	if result := l.Peek("a", limit); !result.Allowed || result.Remaining != 3 || result.Limit != 3 || result.Reset != 0 {
+		t.Errorf("expected a full bucket for a new key, got %+v", result)
+	}
+	if len(l.buckets) != 0 {
+		t.Error("expected peeking not to create a bucket")
+	}
+
+	l.Allow("a", limit)
+	l.Allow("a", limit)
+
+	for i := 0; i < 2; i++ {
+		if result := l.Peek("a", limit); result.Remaining != 1 || result.Reset != 2*time.Second {
+			t.Errorf("peek %d: expected the bucket not to be taken from, got %+v", i+1, result)
+		}
+	}
+
+	now = now.Add(time.Second)
+	if result := l.Peek("a", limit); result.Remaining != 2 {
+		t.Errorf("expected the bucket to have refilled, got %+v", result)
+	}
+}
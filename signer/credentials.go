@@ -0,0 +1,314 @@
+package signer
+
+import (
+	"bufio"
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/methods/go-methods-lib/log"
+	"github.com/pkg/errors"
+)
+
+// refreshWindow is how long before they expire credentials are refreshed, so
+// that a request is never signed with credentials which expire while in flight
+const refreshWindow = 5 * time.Minute
+
+// retryInterval is how long a failure to retrieve credentials is returned for
+// before trying again, so that each request does not wait on providers which
+// cannot succeed
+const retryInterval = 30 * time.Second
+
+// refreshTimeout is how long a refresh may take, independent of the request
+// which started it so that its result can be used by the requests after it
+const refreshTimeout = 10 * time.Second
+
+// Credentials are the AWS keys requests are signed with
+type Credentials struct {
+	AccessKeyID     string
+	SecretAccessKey string
+	SessionToken    string
+	// Expires is zero for credentials which do not expire
+	Expires time.Time
+	// Source names the provider the credentials were retrieved from
+	Source string
+}
+
+// CredentialsProvider retrieves AWS credentials
+type CredentialsProvider interface {
+	Retrieve(ctx context.Context) (Credentials, error)
+}
+
+// StaticCredentials are given in the configuration and never expire
+type StaticCredentials struct {
+	credentials Credentials
+}
+
+// NewStaticCredentials creates a provider of the keys given
+func NewStaticCredentials(accessKeyID, secretAccessKey, sessionToken string) *StaticCredentials {
+	return &StaticCredentials{
+		credentials: Credentials{
+			AccessKeyID:     accessKeyID,
+			SecretAccessKey: secretAccessKey,
+			SessionToken:    sessionToken,
+			Source:          "static",
+		},
+	}
+}
+
+// Retrieve returns the keys given
+func (s *StaticCredentials) Retrieve(ctx context.Context) (Credentials, error) {
+	return s.credentials, nil
+}
+
+// EnvCredentials are read from the standard AWS environment variables
+type EnvCredentials struct{}
+
+// Retrieve returns the keys in AWS_ACCESS_KEY_ID and AWS_SECRET_ACCESS_KEY
+func (EnvCredentials) Retrieve(ctx context.Context) (Credentials, error) {
+	credentials := Credentials{
+		AccessKeyID:     firstEnv("AWS_ACCESS_KEY_ID", "AWS_ACCESS_KEY"),
+		SecretAccessKey: firstEnv("AWS_SECRET_ACCESS_KEY", "AWS_SECRET_KEY"),
+		SessionToken:    firstEnv("AWS_SESSION_TOKEN", "AWS_SECURITY_TOKEN"),
+		Source:          "environment",
+	}
+
+	if credentials.AccessKeyID == "" || credentials.SecretAccessKey == "" {
+		return Credentials{}, errors.New("AWS_ACCESS_KEY_ID and AWS_SECRET_ACCESS_KEY are not set")
+	}
+
+	return credentials, nil
+}
+
+// FileCredentials are read from a profile in a shared credentials file
+type FileCredentials struct {
+	path    string
+	profile string
+}
+
+// NewFileCredentials creates a provider reading the profile from the file. The
+// file defaults to AWS_SHARED_CREDENTIALS_FILE or ~/.aws/credentials, and the
+// profile to AWS_PROFILE or default
+func NewFileCredentials(path, profile string) *FileCredentials {
+	if path == "" {
+		path = os.Getenv("AWS_SHARED_CREDENTIALS_FILE")
+	}
+	if path == "" {
+		if home, err := os.UserHomeDir(); err == nil {
+			path = filepath.Join(home, ".aws", "credentials")
+		}
+	}
+	if profile == "" {
+		profile = firstEnv("AWS_PROFILE", "AWS_DEFAULT_PROFILE")
+	}
+	if profile == "" {
+		profile = "default"
+	}
+
+	return &FileCredentials{path: path, profile: profile}
+}
+
+// Retrieve reads the keys from the profile, the file is read on every call so
+// that rotated keys are picked up
+func (f *FileCredentials) Retrieve(ctx context.Context) (Credentials, error) {
+	file, err := os.Open(f.path)
+	if err != nil {
+		return Credentials{}, errors.Wrap(err, "failed to open aws credentials file")
+	}
+	defer file.Close()
+
+	credentials := Credentials{Source: "file " + f.path}
+	found := false
+
+	section := ""
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") || strings.HasPrefix(line, ";") {
+			continue
+		}
+
+		if strings.HasPrefix(line, "[") && strings.HasSuffix(line, "]") {
+			section = strings.TrimSpace(line[1 : len(line)-1])
+			continue
+		}
+
+		if section != f.profile {
+			continue
+		}
+		found = true
+
+		i := strings.Index(line, "=")
+		if i < 0 {
+			continue
+		}
+		key, value := strings.TrimSpace(line[:i]), strings.TrimSpace(line[i+1:])
+
+		switch strings.ToLower(key) {
+		case "aws_access_key_id":
+			credentials.AccessKeyID = value
+		case "aws_secret_access_key":
+			credentials.SecretAccessKey = value
+		case "aws_session_token":
+			credentials.SessionToken = value
+		}
+	}
+	if err = scanner.Err(); err != nil {
+		return Credentials{}, errors.Wrap(err, "failed to read aws credentials file")
+	}
+
+	if !found {
+		return Credentials{}, errors.Errorf("profile [%s] not found in aws credentials file %s", f.profile, f.path)
+	}
+	if credentials.AccessKeyID == "" || credentials.SecretAccessKey == "" {
+		return Credentials{}, errors.Errorf("profile [%s] in aws credentials file %s does not have aws_access_key_id and aws_secret_access_key", f.profile, f.path)
+	}
+
+	return credentials, nil
+}
+
+// ChainCredentials retrieves credentials from the first of its providers which has them
+type ChainCredentials struct {
+	providers []CredentialsProvider
+}
+
+// NewChainCredentials creates a provider trying each of the providers in turn
+func NewChainCredentials(providers ...CredentialsProvider) *ChainCredentials {
+	return &ChainCredentials{providers: providers}
+}
+
+// Retrieve returns the credentials of the first provider which succeeds, or an
+// error listing why each failed
+func (c *ChainCredentials) Retrieve(ctx context.Context) (Credentials, error) {
+	var problems []string
+	for _, provider := range c.providers {
+		credentials, err := provider.Retrieve(ctx)
+		if err == nil {
+			return credentials, nil
+		}
+		problems = append(problems, err.Error())
+	}
+
+	return Credentials{}, errors.Errorf("no aws credentials found: %s", strings.Join(problems, "; "))
+}
+
+// CachedCredentials holds credentials retrieved from another provider until
+// shortly before they expire
+type CachedCredentials struct {
+	mu          sync.Mutex
+	provider    CredentialsProvider
+	credentials *Credentials
+	err         error
+	retryAt     time.Time
+	// refreshing is closed when the refresh in progress, if any, completes
+	refreshing chan struct{}
+	now        func() time.Time
+}
+
+// NewCachedCredentials wraps the provider so that credentials are only
+// retrieved when first needed and when they are about to expire
+func NewCachedCredentials(provider CredentialsProvider) *CachedCredentials {
+	return &CachedCredentials{
+		provider: provider,
+		now:      time.Now,
+	}
+}
+
+// Retrieve returns the cached credentials, refreshing them if they are about to
+// expire. Only one refresh is made at a time, and while it is in progress other
+// callers use the cached credentials if they have not expired or wait for it.
+// If refreshing fails the cached credentials are used until they expire, and if
+// there are none the failure is returned, until the retry interval passes
+func (c *CachedCredentials) Retrieve(ctx context.Context) (Credentials, error) {
+	c.mu.Lock()
+
+	now := c.now()
+	credentials := c.credentials
+	if credentials != nil && (credentials.Expires.IsZero() || now.Before(credentials.Expires.Add(-refreshWindow))) {
+		c.mu.Unlock()
+		return *credentials, nil
+	}
+	usable := credentials != nil && now.Before(credentials.Expires)
+
+	if now.Before(c.retryAt) {
+		err := c.err
+		c.mu.Unlock()
+		if usable {
+			return *credentials, nil
+		}
+		return Credentials{}, err
+	}
+
+	refreshing := c.refreshing
+	if refreshing == nil {
+		refreshing = make(chan struct{})
+		c.refreshing = refreshing
+		go c.refresh(refreshing)
+	} else if usable {
+		c.mu.Unlock()
+		return *credentials, nil
+	}
+	c.mu.Unlock()
+
+	select {
+	case <-refreshing:
+	case <-ctx.Done():
+		if usable {
+			return *credentials, nil
+		}
+		return Credentials{}, errors.Wrap(ctx.Err(), "stopped waiting for aws credentials")
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.credentials == nil {
+		return Credentials{}, c.err
+	}
+	return *c.credentials, nil
+}
+
+// refresh retrieves credentials from the provider and caches the result,
+// closing done once it is available
+func (c *CachedCredentials) refresh(done chan struct{}) {
+	ctx, cancel := context.WithTimeout(context.Background(), refreshTimeout)
+	defer cancel()
+
+	credentials, err := c.provider.Retrieve(ctx)
+
+	c.mu.Lock()
+	defer func() {
+		c.refreshing = nil
+		close(done)
+		c.mu.Unlock()
+	}()
+
+	now := c.now()
+	if err != nil {
+		c.err, c.retryAt = err, now.Add(retryInterval)
+		if c.credentials != nil && now.Before(c.credentials.Expires) {
+			log.ErrorC("failed to refresh aws credentials, using those which have not yet expired", err, log.Data{"source": c.credentials.Source, "expires": c.credentials.Expires})
+			return
+		}
+		log.ErrorC("failed to retrieve aws credentials", err, nil)
+		c.credentials = nil
+		return
+	}
+
+	c.credentials, c.err, c.retryAt = &credentials, nil, time.Time{}
+	log.Info("aws credentials retrieved", log.Data{"source": credentials.Source, "access_key_id": credentials.AccessKeyID, "expires": credentials.Expires})
+}
+
+// firstEnv returns the value of the first of the environment variables which is set
+func firstEnv(names ...string) string {
+	for _, name := range names {
+		if value := os.Getenv(name); value != "" {
+			return value
+		}
+	}
+
+	return ""
+}
@@ -0,0 +1,384 @@
+package signer
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+func TestFileCredentials(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "credentials")
+	file := `
+# keys for local development
+[default]
+aws_access_key_id = AKIDDEFAULT
+aws_secret_access_key = default-secret
+
+[search]
+aws_access_key_id=AKIDSEARCH
+aws_secret_access_key=search-secret
+aws_session_token=search-session
+
+[empty]
+region = eu-west-2
+`
+	if err := ioutil.WriteFile(path, []byte(file), 0600); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	t.Setenv("AWS_PROFILE", "")
+	t.Setenv("AWS_DEFAULT_PROFILE", "")
+
+	credentials, err := NewFileCredentials(path, "").Retrieve(context.Background())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if credentials.AccessKeyID != "AKIDDEFAULT" || credentials.SecretAccessKey != "default-secret" || credentials.SessionToken != "" {
+		t.Errorf("expected the default profile, got %+v", credentials)
+	}
+
+	credentials, err = NewFileCredentials(path, "search").Retrieve(context.Background())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if credentials.AccessKeyID != "AKIDSEARCH" || credentials.SecretAccessKey != "search-secret" || credentials.SessionToken != "search-session" {
+		t.Errorf("expected the search profile, got %+v", credentials)
+	}
+
+	for profile, problem := range map[string]string{"missing": "profile [missing] not found", "empty": "does not have aws_access_key_id"} {
+		if _, err = NewFileCredentials(path, profile).Retrieve(context.Background()); err == nil || !strings.Contains(err.Error(), problem) {
+			t.Errorf("%s: expected %q, got %v", profile, problem, err)
+		}
+	}
+}
+
+func TestChainCredentials(t *testing.T) {
+	failing := providerFunc(func(ctx context.Context) (Credentials, error) {
+		return Credentials{}, errors.New("not here")
+	})
+
+	chain := NewChainCredentials(failing, NewStaticCredentials("AKID", "secret", ""), failing)
+	credentials, err := chain.Retrieve(context.Background())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if credentials.Source != "static" {
+		t.Errorf("expected the first credentials found, got %+v", credentials)
+	}
+
+	_, err = NewChainCredentials(failing, failing).Retrieve(context.Background())
+	if err == nil || err.Error() != "no aws credentials found: not here; not here" {
+		t.Errorf("expected each failure to be listed, got %v", err)
+	}
+}
+
+func TestCachedCredentials(t *testing.T) {
+	now := time.Date(2020, 1, 2, 3, 0, 0, 0, time.UTC)
+
+	calls := 0
+	var failure error
+	provider := providerFunc(func(ctx context.Context) (Credentials, error) {
+		calls++
+		if failure != nil {
+			return Credentials{}, failure
+		}
+		return Credentials{AccessKeyID: "AKID", Expires: now.Add(time.Hour)}, nil
+	})
+
+	cached := NewCachedCredentials(provider)
+	cached.now = func() time.Time { return now }
+
+	retrieve := func() (Credentials, error) {
+		return cached.Retrieve(context.Background())
+	}
+
+	start := now
+	retrieve()
+	now = start.Add(50 * time.Minute)
+	retrieve()
+	if calls != 1 {
+		t.Errorf("expected the credentials to be cached, got %d calls", calls)
+	}
+
+	// Refreshed within five minutes of expiring
+	now = start.Add(56 * time.Minute)
+	credentials, _ := retrieve()
+	if calls != 2 || !credentials.Expires.Equal(now.Add(time.Hour)) {
+		t.Errorf("expected the credentials to be refreshed, got %d calls", calls)
+	}
+
+	// Used until they expire if refreshing fails
+	failure = errors.New("sts unavailable")
+	expires := credentials.Expires
+	now = expires.Add(-time.Minute)
+	if credentials, err := retrieve(); err != nil || !credentials.Expires.Equal(expires) {
+		t.Errorf("expected the cached credentials to be used, got %+v, %v", credentials, err)
+	}
+
+	// Not refreshed again until the retry interval has passed
+	calls = 0
+	now = expires.Add(-time.Minute + retryInterval - time.Second)
+	if credentials, err := retrieve(); err != nil || !credentials.Expires.Equal(expires) || calls != 0 {
+		t.Errorf("expected the cached credentials without retrying, got %+v, %v and %d calls", credentials, err, calls)
+	}
+
+	now = expires.Add(time.Second)
+	if _, err := retrieve(); err != failure {
+		t.Errorf("expected the failure once the credentials expired, got %v", err)
+	}
+
+	// Not retried until the retry interval has passed
+	calls = 0
+	now = now.Add(retryInterval - time.Second)
+	if _, err := retrieve(); err != failure || calls != 0 {
+		t.Errorf("expected the failure to be returned without retrying, got %v and %d calls", err, calls)
+	}
+
+	failure = nil
+	now = now.Add(time.Second)
+	if _, err := retrieve(); err != nil || calls != 1 {
+		t.Errorf("expected a retry after the retry interval, got %v and %d calls", err, calls)
+	}
+}
+
+func TestCachedCredentialsRefresh(t *testing.T) {
+	now := time.Date(2020, 1, 2, 3, 0, 0, 0, time.UTC)
+
+	started := make(chan struct{}, 2)
+	block := make(chan struct{})
+	provider := providerFunc(func(ctx context.Context) (Credentials, error) {
+		started <- struct{}{}
+		if _, ok := ctx.Deadline(); !ok {
+			t.Error("expected the refresh to have a timeout")
+		}
+		<-block
+		if err := ctx.Err(); err != nil {
+			return Credentials{}, err
+		}
+		return Credentials{AccessKeyID: "AKID", Expires: now.Add(time.Hour)}, nil
+	})
+
+	cached := NewCachedCredentials(provider)
+	cached.now = func() time.Time { return now }
+
+	type retrieved struct {
+		credentials Credentials
+		err         error
+	}
+	waiting := make(chan retrieved, 1)
+	go func() {
+		credentials, err := cached.Retrieve(context.Background())
+		waiting <- retrieved{credentials, err}
+	}()
+
+	// A caller which gives up does not stop the refresh, or cause its failure
+	canceled, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := cached.Retrieve(canceled); errors.Cause(err) != context.Canceled {
+		t.Errorf("expected the caller to stop waiting, got %v", err)
+	}
+
+	block <- struct{}{}
+	if r := <-waiting; r.err != nil || r.credentials.AccessKeyID != "AKID" {
+		t.Errorf("expected the refreshed credentials, got %+v and %v", r.credentials, r.err)
+	}
+	if credentials, err := cached.Retrieve(context.Background()); err != nil || credentials.AccessKeyID != "AKID" {
+		t.Errorf("expected the refreshed credentials to be cached, got %+v and %v", credentials, err)
+	}
+	if refreshes := len(started); refreshes != 1 {
+		t.Errorf("expected a single refresh, got %d", refreshes)
+	}
+	<-started
+
+	// Callers do not wait on a refresh while the cached credentials are still valid
+	now = now.Add(56 * time.Minute)
+	if credentials, err := cached.Retrieve(canceled); err != nil || credentials.AccessKeyID != "AKID" {
+		t.Errorf("expected the cached credentials, got %+v and %v", credentials, err)
+	}
+	<-started
+
+	go func() {
+		credentials, err := cached.Retrieve(context.Background())
+		waiting <- retrieved{credentials, err}
+	}()
+	select {
+	case r := <-waiting:
+		if r.err != nil || r.credentials.AccessKeyID != "AKID" {
+			t.Errorf("expected the cached credentials, got %+v and %v", r.credentials, r.err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Error("expected the cached credentials to be used while refreshing")
+	}
+
+	close(block)
+}
+
+func TestAssumeRoleCredentials(t *testing.T) {
+	var received *http.Request
+	var form string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		b, _ := ioutil.ReadAll(req.Body)
+		received, form = req, string(b)
+
+		if strings.Contains(form, "RoleArn=arn%3Aaws%3Aiam%3A%3A123456789012%3Arole%2Fmissing") {
+			w.WriteHeader(http.StatusForbidden)
+			w.Write([]byte(`<ErrorResponse><Error><Code>AccessDenied</Code><Message>not authorized</Message></Error></ErrorResponse>`))
+			return
+		}
+
+		w.Write([]byte(`<AssumeRoleResponse><AssumeRoleResult><Credentials>
+			<AccessKeyId>ASIAROLE</AccessKeyId>
+			<SecretAccessKey>role-secret</SecretAccessKey>
+			<SessionToken>role-session</SessionToken>
+			<Expiration>2020-01-02T04:00:00Z</Expiration>
+		</Credentials></AssumeRoleResult></AssumeRoleResponse>`))
+	}))
+	defer server.Close()
+
+	base := NewStaticCredentials("AKIDBASE", "base-secret", "")
+	provider := NewAssumeRoleCredentials(server.Client(), server.URL, "eu-west-2", base, "arn:aws:iam::123456789012:role/search", "alpha-search-api")
+
+	credentials, err := provider.Retrieve(context.Background())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	expected := Credentials{
+		AccessKeyID:     "ASIAROLE",
+		SecretAccessKey: "role-secret",
+		SessionToken:    "role-session",
+		Expires:         time.Date(2020, 1, 2, 4, 0, 0, 0, time.UTC),
+		Source:          "assume role arn:aws:iam::123456789012:role/search",
+	}
+	if credentials != expected {
+		t.Errorf("expected %+v, got %+v", expected, credentials)
+	}
+
+	if !strings.Contains(form, "Action=AssumeRole&") || !strings.Contains(form, "RoleSessionName=alpha-search-api") {
+		t.Errorf("unexpected form %s", form)
+	}
+	if authorization := received.Header.Get("Authorization"); !strings.Contains(authorization, "Credential=AKIDBASE/") || !strings.Contains(authorization, "/eu-west-2/sts/aws4_request") {
+		t.Errorf("expected the request to be signed with the base credentials, got %q", authorization)
+	}
+
+	provider = NewAssumeRoleCredentials(server.Client(), server.URL, "eu-west-2", base, "arn:aws:iam::123456789012:role/missing", "alpha-search-api")
+	if _, err = provider.Retrieve(context.Background()); err == nil || !strings.Contains(err.Error(), "sts returned 403 AccessDenied: not authorized") {
+		t.Errorf("expected the sts error, got %v", err)
+	}
+}
+
+func TestWebIdentityCredentials(t *testing.T) {
+	tokenFile := filepath.Join(t.TempDir(), "token")
+	if err := ioutil.WriteFile(tokenFile, []byte("web-token\n"), 0600); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	var received *http.Request
+	var form string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		b, _ := ioutil.ReadAll(req.Body)
+		received, form = req, string(b)
+
+		w.Write([]byte(`<AssumeRoleWithWebIdentityResponse><AssumeRoleWithWebIdentityResult><Credentials>
+			<AccessKeyId>ASIAWEB</AccessKeyId>
+			<SecretAccessKey>web-secret</SecretAccessKey>
+			<SessionToken>web-session</SessionToken>
+			<Expiration>2020-01-02T04:00:00Z</Expiration>
+		</Credentials></AssumeRoleWithWebIdentityResult></AssumeRoleWithWebIdentityResponse>`))
+	}))
+	defer server.Close()
+
+	provider := NewWebIdentityCredentials(server.Client(), server.URL, "arn:aws:iam::123456789012:role/search", "alpha-search-api", tokenFile)
+
+	credentials, err := provider.Retrieve(context.Background())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if credentials.AccessKeyID != "ASIAWEB" || credentials.SessionToken != "web-session" {
+		t.Errorf("unexpected credentials %+v", credentials)
+	}
+
+	if !strings.Contains(form, "Action=AssumeRoleWithWebIdentity&") || !strings.HasSuffix(form, "&WebIdentityToken=web-token") {
+		t.Errorf("unexpected form %s", form)
+	}
+	if authorization := received.Header.Get("Authorization"); authorization != "" {
+		t.Errorf("expected the request not to be signed, got %q", authorization)
+	}
+
+	provider = NewWebIdentityCredentials(server.Client(), server.URL, "arn:aws:iam::123456789012:role/search", "alpha-search-api", tokenFile+".missing")
+	if _, err = provider.Retrieve(context.Background()); err == nil || !strings.Contains(err.Error(), "failed to read web identity token file") {
+		t.Errorf("expected the missing token file to fail, got %v", err)
+	}
+}
+
+func TestContainerCredentials(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if req.URL.Path != "/credentials" || req.Header.Get("Authorization") != "container-token" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+
+		w.Write([]byte(`{"AccessKeyId":"ASIATASK","SecretAccessKey":"task-secret","Token":"task-session","Expiration":"2020-01-02T04:00:00Z"}`))
+	}))
+	defer server.Close()
+
+	provider := NewContainerCredentials(server.Client(), ContainerEndpoint)
+
+	t.Setenv("AWS_CONTAINER_CREDENTIALS_RELATIVE_URI", "")
+	t.Setenv("AWS_CONTAINER_CREDENTIALS_FULL_URI", "")
+	if _, err := provider.Retrieve(context.Background()); err == nil {
+		t.Error("expected an error outside a container")
+	}
+
+	t.Setenv("AWS_CONTAINER_CREDENTIALS_FULL_URI", server.URL+"/credentials")
+	t.Setenv("AWS_CONTAINER_AUTHORIZATION_TOKEN", "container-token")
+
+	credentials, err := provider.Retrieve(context.Background())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if credentials.AccessKeyID != "ASIATASK" || credentials.SessionToken != "task-session" || credentials.Source != "container" {
+		t.Errorf("unexpected credentials %+v", credentials)
+	}
+}
+
+func TestInstanceCredentials(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if req.URL.Path == "/latest/api/token" {
+			if req.Method != "PUT" {
+				w.WriteHeader(http.StatusMethodNotAllowed)
+				return
+			}
+			w.Write([]byte("imds-token"))
+			return
+		}
+
+		if req.Header.Get("X-Aws-Ec2-Metadata-Token") != "imds-token" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+
+		switch req.URL.Path {
+		case "/latest/meta-data/iam/security-credentials/":
+			w.Write([]byte("search-instance\n"))
+		case "/latest/meta-data/iam/security-credentials/search-instance":
+			w.Write([]byte(`{"AccessKeyId":"ASIAINSTANCE","SecretAccessKey":"instance-secret","Token":"instance-session","Expiration":"2020-01-02T04:00:00Z"}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	credentials, err := NewInstanceCredentials(server.Client(), server.URL).Retrieve(context.Background())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if credentials.AccessKeyID != "ASIAINSTANCE" || credentials.Source != "instance role search-instance" {
+		t.Errorf("unexpected credentials %+v", credentials)
+	}
+}
@@ -0,0 +1,154 @@
+package signer
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// A list of the addresses credentials are served from inside AWS
+const (
+	ContainerEndpoint = "http://169.254.170.2"
+	InstanceEndpoint  = "http://169.254.169.254"
+)
+
+// metadataTimeout limits calls for credentials from within AWS, which cannot
+// succeed elsewhere, so that they fail quickly
+const metadataTimeout = 2 * time.Second
+
+// metadataCredentials is the format credentials are returned in to containers and instances
+type metadataCredentials struct {
+	AccessKeyID     string    `json:"AccessKeyId"`
+	SecretAccessKey string    `json:"SecretAccessKey"`
+	Token           string    `json:"Token"`
+	Expiration      time.Time `json:"Expiration"`
+}
+
+// ContainerCredentials are those of the task role, served to ECS containers
+type ContainerCredentials struct {
+	client   *http.Client
+	endpoint string
+}
+
+// NewContainerCredentials creates a provider calling the container credentials
+// endpoint, which is given in AWS_CONTAINER_CREDENTIALS_RELATIVE_URI or
+// AWS_CONTAINER_CREDENTIALS_FULL_URI
+func NewContainerCredentials(client *http.Client, endpoint string) *ContainerCredentials {
+	return &ContainerCredentials{client: client, endpoint: endpoint}
+}
+
+// Retrieve returns the credentials of the task role
+func (c *ContainerCredentials) Retrieve(ctx context.Context) (Credentials, error) {
+	uri := os.Getenv("AWS_CONTAINER_CREDENTIALS_FULL_URI")
+	if relative := os.Getenv("AWS_CONTAINER_CREDENTIALS_RELATIVE_URI"); relative != "" {
+		uri = c.endpoint + relative
+	}
+	if uri == "" {
+		return Credentials{}, errors.New("AWS_CONTAINER_CREDENTIALS_RELATIVE_URI and AWS_CONTAINER_CREDENTIALS_FULL_URI are not set")
+	}
+
+	header := http.Header{}
+	if token := os.Getenv("AWS_CONTAINER_AUTHORIZATION_TOKEN"); token != "" {
+		header.Set("Authorization", token)
+	}
+
+	credentials := &metadataCredentials{}
+	if err := callMetadata(ctx, c.client, "GET", uri, header, credentials); err != nil {
+		return Credentials{}, errors.WithMessage(err, "failed to retrieve container credentials")
+	}
+
+	return credentials.credentials("container"), nil
+}
+
+// InstanceCredentials are those of the instance profile, served to EC2 instances
+type InstanceCredentials struct {
+	client   *http.Client
+	endpoint string
+}
+
+// NewInstanceCredentials creates a provider calling the instance metadata service
+func NewInstanceCredentials(client *http.Client, endpoint string) *InstanceCredentials {
+	return &InstanceCredentials{client: client, endpoint: endpoint}
+}
+
+// Retrieve returns the credentials of the role of the instance profile, using
+// a session token as version 2 of the instance metadata service requires
+func (i *InstanceCredentials) Retrieve(ctx context.Context) (Credentials, error) {
+	var token string
+	header := http.Header{"X-Aws-Ec2-Metadata-Token-Ttl-Seconds": {"21600"}}
+	if err := callMetadata(ctx, i.client, "PUT", i.endpoint+"/latest/api/token", header, &token); err != nil {
+		return Credentials{}, errors.WithMessage(err, "failed to retrieve instance metadata token")
+	}
+
+	header = http.Header{"X-Aws-Ec2-Metadata-Token": {token}}
+	path := i.endpoint + "/latest/meta-data/iam/security-credentials/"
+
+	var roles string
+	if err := callMetadata(ctx, i.client, "GET", path, header, &roles); err != nil {
+		return Credentials{}, errors.WithMessage(err, "failed to retrieve instance role")
+	}
+	role := strings.TrimSpace(strings.Split(roles, "\n")[0])
+	if role == "" {
+		return Credentials{}, errors.New("no role found for the instance profile")
+	}
+
+	credentials := &metadataCredentials{}
+	if err := callMetadata(ctx, i.client, "GET", path+role, header, credentials); err != nil {
+		return Credentials{}, errors.WithMessage(err, "failed to retrieve instance credentials")
+	}
+
+	return credentials.credentials("instance role " + role), nil
+}
+
+func (m *metadataCredentials) credentials(source string) Credentials {
+	return Credentials{
+		AccessKeyID:     m.AccessKeyID,
+		SecretAccessKey: m.SecretAccessKey,
+		SessionToken:    m.Token,
+		Expires:         m.Expiration,
+		Source:          source,
+	}
+}
+
+// callMetadata makes a call to a metadata service, decoding a JSON response into
+// the value given or returning the body if the value is a string
+func callMetadata(ctx context.Context, client *http.Client, method, uri string, header http.Header, value interface{}) error {
+	ctx, cancel := context.WithTimeout(ctx, metadataTimeout)
+	defer cancel()
+
+	req, err := http.NewRequest(method, uri, nil)
+	if err != nil {
+		return errors.Wrap(err, "failed to create request")
+	}
+	req = req.WithContext(ctx)
+	req.Header = header
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	b, err := ioutil.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return errors.Wrap(err, "failed to read response")
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		return errors.Errorf("%s returned %d", uri, resp.StatusCode)
+	}
+
+	if s, ok := value.(*string); ok {
+		*s = string(b)
+		return nil
+	}
+
+	return errors.Wrap(json.Unmarshal(b, value), "failed to parse response")
+}
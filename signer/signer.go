@@ -0,0 +1,172 @@
+// Package signer authenticates requests to elasticsearch, with AWS signature
+// version 4 for AWS managed clusters or basic auth or an API key for others
+package signer
+
+import (
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/ofs/alpha-search-api/config"
+	"github.com/pkg/errors"
+)
+
+// stsTimeout limits calls to STS for temporary credentials
+const stsTimeout = 10 * time.Second
+
+// Signer authenticates a request, given its body, before it is sent
+type Signer interface {
+	Sign(req *http.Request, body []byte) error
+}
+
+// BasicAuth signs requests with a username and password
+type BasicAuth struct {
+	username string
+	password string
+}
+
+// NewBasicAuth creates a signer sending the username and password
+func NewBasicAuth(username, password string) *BasicAuth {
+	return &BasicAuth{username: username, password: password}
+}
+
+// Sign sets the authorization header of the request
+func (b *BasicAuth) Sign(req *http.Request, body []byte) error {
+	req.SetBasicAuth(b.username, b.password)
+	return nil
+}
+
+// APIKey signs requests with an elasticsearch API key
+type APIKey struct {
+	key string
+}
+
+// NewAPIKey creates a signer sending the key, which is the base64 encoding of
+// the id and key joined by a colon, as returned by elasticsearch
+func NewAPIKey(key string) *APIKey {
+	return &APIKey{key: key}
+}
+
+// Sign sets the authorization header of the request
+func (a *APIKey) Sign(req *http.Request, body []byte) error {
+	req.Header.Set("Authorization", "ApiKey "+a.key)
+	return nil
+}
+
+// New creates the signer configured for elasticsearch, or nil if requests are
+// not signed
+func New(cfg *config.ElasticSearchConfig) (Signer, error) {
+	switch cfg.SignerName() {
+	case config.SignerNone:
+		return nil, nil
+	case config.SignerBasic:
+		return NewBasicAuth(cfg.Username, cfg.Password), nil
+	case config.SignerAPIKey:
+		return NewAPIKey(cfg.APIKey), nil
+	case config.SignerAWS:
+		return newSigV4(cfg)
+	default:
+		return nil, errors.Errorf("unknown ES_SIGNER [%s]", cfg.Signer)
+	}
+}
+
+// newSigV4 creates a signer for the region and service of the cluster, which are
+// taken from its host name unless configured, using the credentials configured
+// or otherwise those found in the environment, shared credentials file or from
+// the container or instance
+func newSigV4(cfg *config.ElasticSearchConfig) (*SigV4, error) {
+	hostRegion, hostService := regionAndService(cfg.DestURL)
+
+	// Signing is optional when only asked for by ES_SIGNED_REQUESTS
+	optional := cfg.Signer == ""
+
+	region := firstOf(cfg.AWSRegion, hostRegion, os.Getenv("AWS_REGION"), os.Getenv("AWS_DEFAULT_REGION"))
+	if region == "" && optional {
+		region = "us-east-1"
+	}
+	if region == "" {
+		return nil, errors.New("unable to sign requests to elasticsearch, ES_AWS_REGION must be given when it cannot be taken from the host name")
+	}
+	service := firstOf(cfg.AWSService, hostService, "es")
+
+	stsClient := &http.Client{Timeout: stsTimeout}
+	stsEndpoint := STSEndpoint(region)
+
+	var credentials CredentialsProvider
+	switch {
+	case cfg.AWSAccessKeyID != "":
+		credentials = NewStaticCredentials(cfg.AWSAccessKeyID, cfg.AWSSecretAccessKey, cfg.AWSSessionToken)
+	case cfg.AWSCredentialsFile != "" || cfg.AWSProfile != "":
+		credentials = NewFileCredentials(cfg.AWSCredentialsFile, cfg.AWSProfile)
+	case cfg.AWSWebIdentityTokenFile != "":
+		credentials = NewWebIdentityCredentials(stsClient, stsEndpoint, cfg.AWSRoleARN, cfg.AWSRoleSessionName, cfg.AWSWebIdentityTokenFile)
+	default:
+		credentials = defaultCredentials(stsClient, stsEndpoint, cfg.AWSRoleSessionName)
+	}
+
+	// A role assumed with a web identity needs no other credentials
+	if cfg.AWSRoleARN != "" && cfg.AWSWebIdentityTokenFile == "" {
+		credentials = NewAssumeRoleCredentials(stsClient, stsEndpoint, region, NewCachedCredentials(credentials), cfg.AWSRoleARN, cfg.AWSRoleSessionName)
+	}
+
+	signer := NewSigV4(NewCachedCredentials(credentials), region, service)
+	signer.optional = optional
+
+	return signer, nil
+}
+
+// defaultCredentials searches for credentials in the same places as the AWS SDKs
+func defaultCredentials(client *http.Client, stsEndpoint, sessionName string) CredentialsProvider {
+	providers := []CredentialsProvider{
+		EnvCredentials{},
+		NewFileCredentials("", ""),
+	}
+
+	if tokenFile, roleARN := os.Getenv("AWS_WEB_IDENTITY_TOKEN_FILE"), os.Getenv("AWS_ROLE_ARN"); tokenFile != "" && roleARN != "" {
+		if name := os.Getenv("AWS_ROLE_SESSION_NAME"); name != "" {
+			sessionName = name
+		}
+		providers = append(providers, NewWebIdentityCredentials(client, stsEndpoint, roleARN, sessionName, tokenFile))
+	}
+
+	metadataClient := &http.Client{Timeout: metadataTimeout}
+
+	return NewChainCredentials(append(providers,
+		NewContainerCredentials(metadataClient, ContainerEndpoint),
+		NewInstanceCredentials(metadataClient, InstanceEndpoint),
+	)...)
+}
+
+// regionAndService returns the region and service of an AWS host name, such as
+// search-courses-abc123.eu-west-2.es.amazonaws.com
+func regionAndService(rawURL string) (string, string) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return "", ""
+	}
+
+	host := strings.TrimSuffix(u.Hostname(), ".amazonaws.com")
+	if host == u.Hostname() {
+		return "", ""
+	}
+
+	labels := strings.Split(host, ".")
+	if len(labels) < 2 {
+		return "", ""
+	}
+
+	return labels[len(labels)-2], labels[len(labels)-1]
+}
+
+// firstOf returns the first value which is not empty
+func firstOf(values ...string) string {
+	for _, value := range values {
+		if value != "" {
+			return value
+		}
+	}
+
+	return ""
+}
@@ -0,0 +1,101 @@
+package signer_test
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/ofs/alpha-search-api/config"
+	"github.com/ofs/alpha-search-api/signer"
+)
+
+func TestNew(t *testing.T) {
+	t.Setenv("AWS_REGION", "")
+	t.Setenv("AWS_DEFAULT_REGION", "")
+
+	testCases := map[string]struct {
+		configure func(*config.ElasticSearchConfig)
+		signer    interface{}
+	}{
+		"none":           {func(es *config.ElasticSearchConfig) { es.Signer = config.SignerNone }, nil},
+		"unsigned":       {func(es *config.ElasticSearchConfig) { es.SignedRequests = false }, nil},
+		"signed":         {func(es *config.ElasticSearchConfig) {}, &signer.SigV4{}},
+		"aws":            {func(es *config.ElasticSearchConfig) { es.Signer, es.AWSRegion = config.SignerAWS, "eu-west-2" }, &signer.SigV4{}},
+		"basic auth":     {func(es *config.ElasticSearchConfig) { es.Signer, es.Username = config.SignerBasic, "search" }, &signer.BasicAuth{}},
+		"api key":        {func(es *config.ElasticSearchConfig) { es.Signer, es.APIKey = config.SignerAPIKey, "a2V5" }, &signer.APIKey{}},
+		"region in host": {func(es *config.ElasticSearchConfig) { es.DestURL = "https://search-courses.eu-west-2.es.amazonaws.com" }, &signer.SigV4{}},
+	}
+
+	for name, tc := range testCases {
+		cfg := config.Default().ElasticSearchConfig
+		tc.configure(cfg)
+
+		s, err := signer.New(cfg)
+		if err != nil {
+			t.Errorf("%s: unexpected error: %v", name, err)
+			continue
+		}
+
+		switch tc.signer.(type) {
+		case nil:
+			if s != nil {
+				t.Errorf("%s: expected no signer, got %T", name, s)
+			}
+		case *signer.SigV4:
+			if _, ok := s.(*signer.SigV4); !ok {
+				t.Errorf("%s: expected a sigv4 signer, got %T", name, s)
+			}
+		case *signer.BasicAuth:
+			if _, ok := s.(*signer.BasicAuth); !ok {
+				t.Errorf("%s: expected a basic auth signer, got %T", name, s)
+			}
+		case *signer.APIKey:
+			if _, ok := s.(*signer.APIKey); !ok {
+				t.Errorf("%s: expected an api key signer, got %T", name, s)
+			}
+		}
+	}
+}
+
+func TestNewWithoutRegion(t *testing.T) {
+	t.Setenv("AWS_REGION", "")
+	t.Setenv("AWS_DEFAULT_REGION", "")
+
+	cfg := config.Default().ElasticSearchConfig
+	cfg.Signer = config.SignerAWS
+
+	_, err := signer.New(cfg)
+	if err == nil || !strings.Contains(err.Error(), "ES_AWS_REGION must be given") {
+		t.Errorf("expected the missing region to fail, got %v", err)
+	}
+
+	// The region is otherwise taken from the environment as the AWS SDKs do
+	t.Setenv("AWS_DEFAULT_REGION", "eu-west-1")
+	if _, err = signer.New(cfg); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
+
+func TestBasicAuth(t *testing.T) {
+	req, _ := http.NewRequest("GET", "https://search.example.com/", nil)
+
+	if err := signer.NewBasicAuth("search", "secret").Sign(req, nil); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if username, password, ok := req.BasicAuth(); !ok || username != "search" || password != "secret" {
+		t.Errorf("expected basic auth, got %q", req.Header.Get("Authorization"))
+	}
+}
+
+func TestAPIKey(t *testing.T) {
+	req, _ := http.NewRequest("GET", "https://search.example.com/", nil)
+
+	if err := signer.NewAPIKey("aWQ6a2V5").Sign(req, nil); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if authorization := req.Header.Get("Authorization"); authorization != "ApiKey aWQ6a2V5" {
+		t.Errorf("expected the api key, got %q", authorization)
+	}
+}
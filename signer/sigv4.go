@@ -0,0 +1,196 @@
+package signer
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// A list of the formats of times used in signatures
+const (
+	amzDateFormat = "20060102T150405Z"
+	dateFormat    = "20060102"
+)
+
+const sigV4Algorithm = "AWS4-HMAC-SHA256"
+
+// SigV4 signs requests with AWS signature version 4
+type SigV4 struct {
+	credentials CredentialsProvider
+	region      string
+	service     string
+	now         func() time.Time
+	// optional sends requests unsigned when no credentials are found, as
+	// ES_SIGNED_REQUESTS always has, so that clusters outside AWS still work
+	optional bool
+}
+
+// NewSigV4 creates a signer for the service in the region, using credentials
+// from the provider
+func NewSigV4(credentials CredentialsProvider, region, service string) *SigV4 {
+	return &SigV4{
+		credentials: credentials,
+		region:      region,
+		service:     service,
+		now:         time.Now,
+	}
+}
+
+// Sign adds the headers of a version 4 signature to the request, with the hash
+// of the body given, so that it must be signed after any other headers are set
+func (s *SigV4) Sign(req *http.Request, body []byte) error {
+	credentials, err := s.credentials.Retrieve(req.Context())
+	if err != nil {
+		if s.optional {
+			return nil
+		}
+		return errors.WithMessage(err, "failed to retrieve aws credentials")
+	}
+
+	payloadHash := sha256.Sum256(body)
+
+	req.Header.Set("X-Amz-Date", s.now().UTC().Format(amzDateFormat))
+	req.Header.Set("X-Amz-Content-Sha256", hex.EncodeToString(payloadHash[:]))
+	if credentials.SessionToken != "" {
+		req.Header.Set("X-Amz-Security-Token", credentials.SessionToken)
+	} else {
+		req.Header.Del("X-Amz-Security-Token")
+	}
+
+	s.sign(req, hex.EncodeToString(payloadHash[:]), credentials)
+
+	return nil
+}
+
+// sign adds the authorization header, signing the host, content type and amz
+// headers already on the request
+func (s *SigV4) sign(req *http.Request, payloadHash string, credentials Credentials) {
+	amzDate := req.Header.Get("X-Amz-Date")
+	date := amzDate[:len(dateFormat)]
+	scope := strings.Join([]string{date, s.region, s.service, "aws4_request"}, "/")
+
+	canonicalHeaders, signedHeaders := canonicalHeaders(req)
+
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		canonicalURI(req),
+		canonicalQuery(req),
+		canonicalHeaders,
+		signedHeaders,
+		payloadHash,
+	}, "\n")
+
+	requestHash := sha256.Sum256([]byte(canonicalRequest))
+	stringToSign := strings.Join([]string{
+		sigV4Algorithm,
+		amzDate,
+		scope,
+		hex.EncodeToString(requestHash[:]),
+	}, "\n")
+
+	key := hmacSHA256([]byte("AWS4"+credentials.SecretAccessKey), date)
+	key = hmacSHA256(key, s.region)
+	key = hmacSHA256(key, s.service)
+	key = hmacSHA256(key, "aws4_request")
+	signature := hex.EncodeToString(hmacSHA256(key, stringToSign))
+
+	req.Header.Set("Authorization", fmt.Sprintf("%s Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		sigV4Algorithm, credentials.AccessKeyID, scope, signedHeaders, signature))
+}
+
+// canonicalURI returns the escaped path of the request escaped again, as AWS
+// services other than S3 expect
+func canonicalURI(req *http.Request) string {
+	path := req.URL.EscapedPath()
+	if path == "" {
+		return "/"
+	}
+
+	return uriEncode(path, false)
+}
+
+// canonicalQuery returns the query parameters sorted by name and then value
+func canonicalQuery(req *http.Request) string {
+	query := req.URL.Query()
+
+	var pairs []string
+	for name, values := range query {
+		for _, value := range values {
+			pairs = append(pairs, uriEncode(name, true)+"="+uriEncode(value, true))
+		}
+	}
+	sort.Strings(pairs)
+
+	return strings.Join(pairs, "&")
+}
+
+// canonicalHeaders returns the headers signed and their names. Only the host,
+// content type and amz headers are signed, so that headers added by proxies or
+// the http client do not invalidate the signature
+func canonicalHeaders(req *http.Request) (string, string) {
+	host := req.Host
+	if host == "" {
+		host = req.URL.Host
+	}
+
+	values := map[string]string{"host": host}
+	for name, header := range req.Header {
+		name = strings.ToLower(name)
+		if name != "content-type" && !strings.HasPrefix(name, "x-amz-") {
+			continue
+		}
+
+		trimmed := make([]string, len(header))
+		for i, value := range header {
+			trimmed[i] = strings.Join(strings.Fields(value), " ")
+		}
+		values[name] = strings.Join(trimmed, ",")
+	}
+
+	names := make([]string, 0, len(values))
+	for name := range values {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var canonical strings.Builder
+	for _, name := range names {
+		canonical.WriteString(name + ":" + values[name] + "\n")
+	}
+
+	return canonical.String(), strings.Join(names, ";")
+}
+
+// uriEncode escapes every byte other than unreserved characters, and slashes
+// unless they are part of a query parameter
+func uriEncode(s string, encodeSlash bool) string {
+	var encoded strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case 'A' <= c && c <= 'Z', 'a' <= c && c <= 'z', '0' <= c && c <= '9',
+			c == '-', c == '_', c == '.', c == '~':
+			encoded.WriteByte(c)
+		case c == '/' && !encodeSlash:
+			encoded.WriteByte(c)
+		default:
+			fmt.Fprintf(&encoded, "%%%02X", c)
+		}
+	}
+
+	return encoded.String()
+}
+
+func hmacSHA256(key []byte, content string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(content))
+
+	return mac.Sum(nil)
+}
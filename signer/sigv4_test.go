@@ -0,0 +1,128 @@
+package signer
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+// emptyHash is the sha256 of an empty payload
+const emptyHash = "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855"
+
+func TestSignVectors(t *testing.T) {
+	// Taken from the AWS signature version 4 test suite
+	testCases := map[string]struct {
+		url       string
+		signature string
+	}{
+		"get vanilla":     {"https://example.amazonaws.com/", "5fa00fa31553b73ebf1942676e86291e8372ff2a2260956d9b8aae1d763fbf31"},
+		"get query order": {"https://example.amazonaws.com/?Param2=value2&Param1=value1", "b97d918cfa904a5beff61c982a1b6f458b799221646efd99d3219ec94cdf2500"},
+	}
+
+	credentials := Credentials{AccessKeyID: "AKIDEXAMPLE", SecretAccessKey: "wJalrXUtnFEMI/K7MDENG+bPxRfiCYEXAMPLEKEY"}
+	s := NewSigV4(NewStaticCredentials(credentials.AccessKeyID, credentials.SecretAccessKey, ""), "us-east-1", "service")
+
+	for name, tc := range testCases {
+		req, err := http.NewRequest("GET", tc.url, nil)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		req.Header.Set("X-Amz-Date", "20150830T123600Z")
+
+		s.sign(req, emptyHash, credentials)
+
+		expected := "AWS4-HMAC-SHA256 Credential=AKIDEXAMPLE/20150830/us-east-1/service/aws4_request, SignedHeaders=host;x-amz-date, Signature=" + tc.signature
+		if authorization := req.Header.Get("Authorization"); authorization != expected {
+			t.Errorf("%s: expected %q, got %q", name, expected, authorization)
+		}
+	}
+}
+
+func TestSign(t *testing.T) {
+	s := NewSigV4(NewStaticCredentials("AKID", "secret", "session"), "eu-west-2", "es")
+	s.now = func() time.Time { return time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC) }
+
+	req, err := http.NewRequest("POST", "https://search.example.com/courses/_search", strings.NewReader("{}"))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "alpha-search-api")
+
+	if err = s.Sign(req, []byte("{}")); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	expected := map[string]string{
+		"X-Amz-Date":           "20200102T030405Z",
+		"X-Amz-Content-Sha256": "44136fa355b3678a1146ad16f7e8649e94fb4fc21fe77e8310c060f61caaff8a",
+		"X-Amz-Security-Token": "session",
+	}
+	for name, value := range expected {
+		if got := req.Header.Get(name); got != value {
+			t.Errorf("expected %s to be %q, got %q", name, value, got)
+		}
+	}
+
+	authorization := req.Header.Get("Authorization")
+	if !strings.HasPrefix(authorization, "AWS4-HMAC-SHA256 Credential=AKID/20200102/eu-west-2/es/aws4_request, SignedHeaders=content-type;host;x-amz-content-sha256;x-amz-date;x-amz-security-token, ") {
+		t.Errorf("expected the user agent not to be signed, got %q", authorization)
+	}
+}
+
+func TestSignWithoutCredentials(t *testing.T) {
+	s := NewSigV4(NewChainCredentials(), "eu-west-2", "es")
+
+	req, _ := http.NewRequest("GET", "https://search.example.com/", nil)
+	if err := s.Sign(req, nil); err == nil {
+		t.Error("expected an error when no credentials are found")
+	}
+
+	// Requests are sent unsigned when signing was only asked for by ES_SIGNED_REQUESTS
+	s.optional = true
+	req, _ = http.NewRequest("GET", "https://search.example.com/", nil)
+	if err := s.Sign(req, nil); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+	if authorization := req.Header.Get("Authorization"); authorization != "" {
+		t.Errorf("expected an unsigned request, got %q", authorization)
+	}
+}
+
+func TestCanonicalURI(t *testing.T) {
+	testCases := map[string]string{
+		"https://example.com":                 "/",
+		"https://example.com/courses/_search": "/courses/_search",
+		"https://example.com/a%20b/c":         "/a%2520b/c",
+	}
+
+	for raw, expected := range testCases {
+		req, _ := http.NewRequest("GET", raw, nil)
+		if uri := canonicalURI(req); uri != expected {
+			t.Errorf("%s: expected %q, got %q", raw, expected, uri)
+		}
+	}
+}
+
+func TestSignRetrievesWithRequestContext(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	provider := providerFunc(func(ctx context.Context) (Credentials, error) {
+		return Credentials{}, ctx.Err()
+	})
+	s := NewSigV4(provider, "eu-west-2", "es")
+
+	req, _ := http.NewRequest("GET", "https://search.example.com/", nil)
+	if err := s.Sign(req.WithContext(ctx), nil); err == nil || !strings.Contains(err.Error(), context.Canceled.Error()) {
+		t.Errorf("expected the request context to be used, got %v", err)
+	}
+}
+
+type providerFunc func(ctx context.Context) (Credentials, error)
+
+func (f providerFunc) Retrieve(ctx context.Context) (Credentials, error) {
+	return f(ctx)
+}
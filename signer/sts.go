@@ -0,0 +1,198 @@
+package signer
+
+import (
+	"context"
+	"encoding/xml"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// stsVersion is the version of the STS api called
+const stsVersion = "2011-06-15"
+
+// roleDuration is how long credentials for an assumed role are requested for
+const roleDuration = time.Hour
+
+// stsResponse holds the credentials returned by AssumeRole or AssumeRoleWithWebIdentity
+type stsResponse struct {
+	AssumeRole      *stsCredentials `xml:"AssumeRoleResult>Credentials"`
+	WebIdentityRole *stsCredentials `xml:"AssumeRoleWithWebIdentityResult>Credentials"`
+}
+
+type stsCredentials struct {
+	AccessKeyID     string    `xml:"AccessKeyId"`
+	SecretAccessKey string    `xml:"SecretAccessKey"`
+	SessionToken    string    `xml:"SessionToken"`
+	Expiration      time.Time `xml:"Expiration"`
+}
+
+type stsError struct {
+	Code    string `xml:"Error>Code"`
+	Message string `xml:"Error>Message"`
+}
+
+// STSEndpoint returns the regional STS endpoint, or the global endpoint if no region is given
+func STSEndpoint(region string) string {
+	if region == "" {
+		return "https://sts.amazonaws.com/"
+	}
+
+	return "https://sts." + region + ".amazonaws.com/"
+}
+
+// AssumeRoleCredentials are temporary credentials for a role, assumed using the
+// credentials of another provider
+type AssumeRoleCredentials struct {
+	client      *http.Client
+	endpoint    string
+	sts         *SigV4
+	roleARN     string
+	sessionName string
+}
+
+// NewAssumeRoleCredentials creates a provider assuming the role by calling the
+// STS endpoint given, signing with credentials from the base provider
+func NewAssumeRoleCredentials(client *http.Client, endpoint, region string, base CredentialsProvider, roleARN, sessionName string) *AssumeRoleCredentials {
+	if region == "" {
+		region = "us-east-1"
+	}
+
+	return &AssumeRoleCredentials{
+		client:      client,
+		endpoint:    endpoint,
+		sts:         NewSigV4(base, region, "sts"),
+		roleARN:     roleARN,
+		sessionName: sessionName,
+	}
+}
+
+// Retrieve assumes the role, returning credentials which expire after an hour
+func (a *AssumeRoleCredentials) Retrieve(ctx context.Context) (Credentials, error) {
+	form := url.Values{
+		"Action":          {"AssumeRole"},
+		"Version":         {stsVersion},
+		"RoleArn":         {a.roleARN},
+		"RoleSessionName": {a.sessionName},
+		"DurationSeconds": {strconv.Itoa(int(roleDuration.Seconds()))},
+	}
+
+	response, err := callSTS(ctx, a.client, a.endpoint, form, a.sts)
+	if err != nil {
+		return Credentials{}, errors.Wrapf(err, "failed to assume role %s", a.roleARN)
+	}
+	if response.AssumeRole == nil {
+		return Credentials{}, errors.Errorf("failed to assume role %s: no credentials returned", a.roleARN)
+	}
+
+	return response.AssumeRole.credentials("assume role " + a.roleARN), nil
+}
+
+// WebIdentityCredentials are temporary credentials for a role, assumed with a
+// web identity token such as one projected into a kubernetes pod
+type WebIdentityCredentials struct {
+	client      *http.Client
+	endpoint    string
+	roleARN     string
+	sessionName string
+	tokenFile   string
+}
+
+// NewWebIdentityCredentials creates a provider assuming the role by calling the
+// STS endpoint given with the token read from the file
+func NewWebIdentityCredentials(client *http.Client, endpoint, roleARN, sessionName, tokenFile string) *WebIdentityCredentials {
+	return &WebIdentityCredentials{
+		client:      client,
+		endpoint:    endpoint,
+		roleARN:     roleARN,
+		sessionName: sessionName,
+		tokenFile:   tokenFile,
+	}
+}
+
+// Retrieve reads the token, which may have been rotated, and assumes the role
+func (w *WebIdentityCredentials) Retrieve(ctx context.Context) (Credentials, error) {
+	token, err := ioutil.ReadFile(w.tokenFile)
+	if err != nil {
+		return Credentials{}, errors.Wrap(err, "failed to read web identity token file")
+	}
+
+	form := url.Values{
+		"Action":           {"AssumeRoleWithWebIdentity"},
+		"Version":          {stsVersion},
+		"RoleArn":          {w.roleARN},
+		"RoleSessionName":  {w.sessionName},
+		"WebIdentityToken": {strings.TrimSpace(string(token))},
+		"DurationSeconds":  {strconv.Itoa(int(roleDuration.Seconds()))},
+	}
+
+	// The token authenticates the request, so it is not signed
+	response, err := callSTS(ctx, w.client, w.endpoint, form, nil)
+	if err != nil {
+		return Credentials{}, errors.Wrapf(err, "failed to assume role %s with web identity", w.roleARN)
+	}
+	if response.WebIdentityRole == nil {
+		return Credentials{}, errors.Errorf("failed to assume role %s with web identity: no credentials returned", w.roleARN)
+	}
+
+	return response.WebIdentityRole.credentials("web identity " + w.roleARN), nil
+}
+
+func (c *stsCredentials) credentials(source string) Credentials {
+	return Credentials{
+		AccessKeyID:     c.AccessKeyID,
+		SecretAccessKey: c.SecretAccessKey,
+		SessionToken:    c.SessionToken,
+		Expires:         c.Expiration,
+		Source:          source,
+	}
+}
+
+// callSTS posts the form to the STS endpoint, signing it if a signer is given
+func callSTS(ctx context.Context, client *http.Client, endpoint string, form url.Values, signer *SigV4) (*stsResponse, error) {
+	body := []byte(form.Encode())
+
+	req, err := http.NewRequest("POST", endpoint, strings.NewReader(string(body)))
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to create request to sts")
+	}
+	req = req.WithContext(ctx)
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded; charset=utf-8")
+
+	if signer != nil {
+		if err = signer.Sign(req, body); err != nil {
+			return nil, err
+		}
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to call sts")
+	}
+	defer resp.Body.Close()
+
+	b, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to read response from sts")
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		var stsErr stsError
+		if xml.Unmarshal(b, &stsErr) == nil && stsErr.Code != "" {
+			return nil, errors.Errorf("sts returned %d %s: %s", resp.StatusCode, stsErr.Code, stsErr.Message)
+		}
+		return nil, errors.Errorf("sts returned %d", resp.StatusCode)
+	}
+
+	response := &stsResponse{}
+	if err = xml.Unmarshal(b, response); err != nil {
+		return nil, errors.Wrap(err, "failed to parse response from sts")
+	}
+
+	return response, nil
+}
@@ -0,0 +1,269 @@
+package tracing
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"sync"
+
+	"github.com/pkg/errors"
+)
+
+// A list of supported exporters
+const (
+	ExporterNone   = "none"
+	ExporterStdout = "stdout"
+	ExporterFile   = "file"
+)
+
+// instrumentationScope names the library producing spans in exported data
+const instrumentationScope = "github.com/ofs/alpha-search-api"
+
+// Exporter receives completed spans
+type Exporter interface {
+	Export(span *Span)
+	Close() error
+}
+
+var (
+	exporterMu  sync.RWMutex
+	exporter    Exporter
+	serviceName = "alpha-search-api"
+)
+
+// Configure sets up the global exporter by name; "file" exporters write to path.
+// Spans are still created and propagated when the exporter is "none" but are not recorded
+func Configure(name, path, service string) error {
+	var e Exporter
+
+	switch name {
+	case "", ExporterNone:
+	case ExporterStdout:
+		e = NewJSONExporter(os.Stdout, nil)
+	case ExporterFile:
+		f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+		if err != nil {
+			return errors.Wrap(err, "failed to open trace file")
+		}
+		e = NewJSONExporter(f, f)
+	default:
+		return fmt.Errorf("unknown tracing exporter [%s]", name)
+	}
+
+	SetExporter(e, service)
+
+	return nil
+}
+
+// SetExporter replaces the global exporter, closing any previous one
+func SetExporter(e Exporter, service string) {
+	exporterMu.Lock()
+	previous := exporter
+	exporter = e
+	if service != "" {
+		serviceName = service
+	}
+	exporterMu.Unlock()
+
+	if previous != nil {
+		previous.Close()
+	}
+}
+
+// Shutdown closes the global exporter, flushing any buffered spans
+func Shutdown() error {
+	exporterMu.Lock()
+	e := exporter
+	exporter = nil
+	exporterMu.Unlock()
+
+	if e == nil {
+		return nil
+	}
+
+	return e.Close()
+}
+
+func exporterEnabled() bool {
+	exporterMu.RLock()
+	defer exporterMu.RUnlock()
+
+	return exporter != nil
+}
+
+func export(s *Span) {
+	exporterMu.RLock()
+	e := exporter
+	exporterMu.RUnlock()
+
+	if e != nil {
+		e.Export(s)
+	}
+}
+
+// JSONExporter writes each span as a line of OTLP JSON, the format used by the
+// OpenTelemetry collector's file exporter and receiver
+type JSONExporter struct {
+	mu     sync.Mutex
+	w      io.Writer
+	closer io.Closer
+}
+
+// NewJSONExporter creates an exporter writing to w, closer (if set) is closed on Close
+func NewJSONExporter(w io.Writer, closer io.Closer) *JSONExporter {
+	return &JSONExporter{
+		w:      w,
+		closer: closer,
+	}
+}
+
+// Export writes a single span
+func (e *JSONExporter) Export(s *Span) {
+	b, err := json.Marshal(toOTLP(s))
+	if err != nil {
+		return
+	}
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	e.w.Write(append(b, '\n'))
+}
+
+// Close closes the underlying writer if required
+func (e *JSONExporter) Close() error {
+	if e.closer == nil {
+		return nil
+	}
+
+	return e.closer.Close()
+}
+
+type otlpTraces struct {
+	ResourceSpans []otlpResourceSpans `json:"resourceSpans"`
+}
+
+type otlpResourceSpans struct {
+	Resource   otlpResource     `json:"resource"`
+	ScopeSpans []otlpScopeSpans `json:"scopeSpans"`
+}
+
+type otlpResource struct {
+	Attributes []otlpAttribute `json:"attributes"`
+}
+
+type otlpScopeSpans struct {
+	Scope otlpScope  `json:"scope"`
+	Spans []otlpSpan `json:"spans"`
+}
+
+type otlpScope struct {
+	Name string `json:"name"`
+}
+
+type otlpSpan struct {
+	TraceID           string          `json:"traceId"`
+	SpanID            string          `json:"spanId"`
+	ParentSpanID      string          `json:"parentSpanId,omitempty"`
+	Name              string          `json:"name"`
+	Kind              int             `json:"kind"`
+	StartTimeUnixNano string          `json:"startTimeUnixNano"`
+	EndTimeUnixNano   string          `json:"endTimeUnixNano"`
+	Attributes        []otlpAttribute `json:"attributes,omitempty"`
+	Status            otlpStatus      `json:"status"`
+}
+
+type otlpStatus struct {
+	Code    int    `json:"code,omitempty"`
+	Message string `json:"message,omitempty"`
+}
+
+type otlpAttribute struct {
+	Key   string    `json:"key"`
+	Value otlpValue `json:"value"`
+}
+
+type otlpValue struct {
+	StringValue *string  `json:"stringValue,omitempty"`
+	IntValue    *string  `json:"intValue,omitempty"`
+	DoubleValue *float64 `json:"doubleValue,omitempty"`
+	BoolValue   *bool    `json:"boolValue,omitempty"`
+}
+
+func toOTLP(s *Span) otlpTraces {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	exporterMu.RLock()
+	service := serviceName
+	exporterMu.RUnlock()
+
+	span := otlpSpan{
+		TraceID:           s.context.TraceID.String(),
+		SpanID:            s.context.SpanID.String(),
+		Name:              s.name,
+		Kind:              s.kind,
+		StartTimeUnixNano: strconv.FormatInt(s.start.UnixNano(), 10),
+		EndTimeUnixNano:   strconv.FormatInt(s.end.UnixNano(), 10),
+		Attributes:        toAttributes(s.attributes),
+	}
+
+	if s.parentSpanID.IsValid() {
+		span.ParentSpanID = s.parentSpanID.String()
+	}
+
+	if s.errMessage != "" {
+		// 2 represents STATUS_CODE_ERROR
+		span.Status = otlpStatus{Code: 2, Message: s.errMessage}
+	}
+
+	return otlpTraces{
+		ResourceSpans: []otlpResourceSpans{{
+			Resource: otlpResource{
+				Attributes: toAttributes(map[string]interface{}{"service.name": service}),
+			},
+			ScopeSpans: []otlpScopeSpans{{
+				Scope: otlpScope{Name: instrumentationScope},
+				Spans: []otlpSpan{span},
+			}},
+		}},
+	}
+}
+
+func toAttributes(attributes map[string]interface{}) []otlpAttribute {
+	keys := make([]string, 0, len(attributes))
+	for key := range attributes {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	var result []otlpAttribute
+	for _, key := range keys {
+		var value otlpValue
+
+		switch v := attributes[key].(type) {
+		case string:
+			value.StringValue = &v
+		case int:
+			i := strconv.Itoa(v)
+			value.IntValue = &i
+		case int64:
+			i := strconv.FormatInt(v, 10)
+			value.IntValue = &i
+		case float64:
+			value.DoubleValue = &v
+		case bool:
+			value.BoolValue = &v
+		default:
+			str := fmt.Sprintf("%v", v)
+			value.StringValue = &str
+		}
+
+		result = append(result, otlpAttribute{Key: key, Value: value})
+	}
+
+	return result
+}
@@ -0,0 +1,193 @@
+package tracing_test
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+
+	"github.com/ofs/alpha-search-api/tracing"
+	"github.com/pkg/errors"
+)
+
+type exportedTraces struct {
+	ResourceSpans []struct {
+		Resource struct {
+			Attributes []exportedAttribute `json:"attributes"`
+		} `json:"resource"`
+		ScopeSpans []struct {
+			Scope struct {
+				Name string `json:"name"`
+			} `json:"scope"`
+			Spans []exportedSpan `json:"spans"`
+		} `json:"scopeSpans"`
+	} `json:"resourceSpans"`
+}
+
+type exportedSpan struct {
+	TraceID           string              `json:"traceId"`
+	SpanID            string              `json:"spanId"`
+	ParentSpanID      string              `json:"parentSpanId"`
+	Name              string              `json:"name"`
+	Kind              int                 `json:"kind"`
+	StartTimeUnixNano string              `json:"startTimeUnixNano"`
+	EndTimeUnixNano   string              `json:"endTimeUnixNano"`
+	Attributes        []exportedAttribute `json:"attributes"`
+	Status            struct {
+		Code    int    `json:"code"`
+		Message string `json:"message"`
+	} `json:"status"`
+}
+
+type exportedAttribute struct {
+	Key   string                     `json:"key"`
+	Value map[string]json.RawMessage `json:"value"`
+}
+
+// readSpans decodes every line written by a JSON exporter
+func readSpans(t *testing.T, data []byte) []exportedTraces {
+	t.Helper()
+
+	var result []exportedTraces
+
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		var traces exportedTraces
+		if err := json.Unmarshal(scanner.Bytes(), &traces); err != nil {
+			t.Fatalf("failed to decode exported line %q: %v", scanner.Text(), err)
+		}
+		result = append(result, traces)
+	}
+
+	return result
+}
+
+func TestJSONExporter(t *testing.T) {
+	var buf bytes.Buffer
+	tracing.SetExporter(tracing.NewJSONExporter(&buf, nil), "test-service")
+	t.Cleanup(func() { tracing.Shutdown() })
+
+	parent, _ := tracing.ParseTraceparent("00-" + validTraceID + "-" + validSpanID + "-01")
+	ctx := tracing.ContextWithRemoteSpanContext(context.Background(), parent)
+
+	_, span := tracing.StartSpan(ctx, "GET /search/courses", tracing.KindServer)
+	span.SetAttribute("http.method", "GET")
+	span.SetAttribute("http.status_code", 500)
+	span.SetAttribute("cache.hit", false)
+	span.SetAttribute("duration", 1.5)
+	span.RecordError(errors.New("Internal Server Error"))
+	span.End()
+	span.End()
+
+	exported := readSpans(t, buf.Bytes())
+	if len(exported) != 1 {
+		t.Fatalf("expected the span to be exported once, got %d lines", len(exported))
+	}
+
+	resource := exported[0].ResourceSpans[0]
+	if len(resource.Resource.Attributes) != 1 || resource.Resource.Attributes[0].Key != "service.name" || string(resource.Resource.Attributes[0].Value["stringValue"]) != `"test-service"` {
+		t.Errorf("expected the service name as the only resource attribute, got %+v", resource.Resource.Attributes)
+	}
+	if resource.ScopeSpans[0].Scope.Name != "github.com/ofs/alpha-search-api" {
+		t.Errorf("unexpected instrumentation scope %q", resource.ScopeSpans[0].Scope.Name)
+	}
+
+	got := resource.ScopeSpans[0].Spans[0]
+	if got.TraceID != validTraceID || got.ParentSpanID != validSpanID || got.SpanID != span.SpanContext().SpanID.String() {
+		t.Errorf("unexpected ids: trace %s, parent %s, span %s", got.TraceID, got.ParentSpanID, got.SpanID)
+	}
+	if got.Name != "GET /search/courses" || got.Kind != tracing.KindServer {
+		t.Errorf("unexpected name %q or kind %d", got.Name, got.Kind)
+	}
+	start, _ := strconv.ParseInt(got.StartTimeUnixNano, 10, 64)
+	end, _ := strconv.ParseInt(got.EndTimeUnixNano, 10, 64)
+	if start == 0 || end < start {
+		t.Errorf("unexpected times: start %s, end %s", got.StartTimeUnixNano, got.EndTimeUnixNano)
+	}
+	if got.Status.Code != 2 || got.Status.Message != "Internal Server Error" {
+		t.Errorf("expected an error status, got %+v", got.Status)
+	}
+
+	// Attributes are sorted by key, with integers encoded as strings as OTLP JSON requires
+	expected := []struct {
+		key, kind, value string
+	}{
+		{"cache.hit", "boolValue", `false`},
+		{"duration", "doubleValue", `1.5`},
+		{"http.method", "stringValue", `"GET"`},
+		{"http.status_code", "intValue", `"500"`},
+	}
+
+	if len(got.Attributes) != len(expected) {
+		t.Fatalf("expected %d attributes, got %+v", len(expected), got.Attributes)
+	}
+	for i, e := range expected {
+		attribute := got.Attributes[i]
+		if attribute.Key != e.key || len(attribute.Value) != 1 || string(attribute.Value[e.kind]) != e.value {
+			t.Errorf("expected attribute %s with %s %s, got %s %v", e.key, e.kind, e.value, attribute.Key, attribute.Value)
+		}
+	}
+}
+
+func TestUnsampledSpansAreNotExported(t *testing.T) {
+	var buf bytes.Buffer
+	tracing.SetExporter(tracing.NewJSONExporter(&buf, nil), "")
+	t.Cleanup(func() { tracing.Shutdown() })
+
+	parent, _ := tracing.ParseTraceparent("00-" + validTraceID + "-" + validSpanID + "-00")
+
+	_, span := tracing.StartSpan(tracing.ContextWithRemoteSpanContext(context.Background(), parent), "unsampled", tracing.KindInternal)
+	span.End()
+
+	if buf.Len() != 0 {
+		t.Errorf("expected nothing to be exported, got %s", buf.String())
+	}
+}
+
+func TestFileExporter(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "traces.json")
+
+	if err := tracing.Configure(tracing.ExporterFile, path, "file-service"); err != nil {
+		t.Fatalf("failed to configure file exporter: %v", err)
+	}
+	t.Cleanup(func() { tracing.Shutdown() })
+
+	for _, name := range []string{"first", "second"} {
+		_, span := tracing.StartSpan(context.Background(), name, tracing.KindInternal)
+		span.End()
+	}
+
+	if err := tracing.Shutdown(); err != nil {
+		t.Fatalf("failed to close file exporter: %v", err)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("failed to read trace file: %v", err)
+	}
+
+	exported := readSpans(t, data)
+	if len(exported) != 2 {
+		t.Fatalf("expected one line per span, got %d", len(exported))
+	}
+
+	for i, name := range []string{"first", "second"} {
+		got := exported[i].ResourceSpans[0].ScopeSpans[0].Spans[0]
+		if got.Name != name {
+			t.Errorf("expected span %q on line %d, got %q", name, i+1, got.Name)
+		}
+		if got.ParentSpanID != "" {
+			t.Errorf("expected a root span, got parent %s", got.ParentSpanID)
+		}
+	}
+}
+
+func TestConfigureUnknownExporter(t *testing.T) {
+	if err := tracing.Configure("jaeger", "", ""); err == nil {
+		t.Error("expected an error for an unknown exporter")
+	}
+}
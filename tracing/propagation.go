@@ -0,0 +1,106 @@
+package tracing
+
+import (
+	"context"
+	"encoding/hex"
+	"net/http"
+	"strings"
+)
+
+// TraceparentHeader is the W3C trace context header used to propagate spans between services
+const TraceparentHeader = "traceparent"
+
+const (
+	traceparentVersion = "00"
+	flagSampled        = "01"
+	flagNotSampled     = "00"
+)
+
+// Extract reads a W3C traceparent header from the incoming request headers and
+// sets the remote span context on the returned context, invalid headers are ignored
+func Extract(ctx context.Context, header http.Header) context.Context {
+	sc, ok := ParseTraceparent(header.Get(TraceparentHeader))
+	if !ok {
+		return ctx
+	}
+
+	return ContextWithRemoteSpanContext(ctx, sc)
+}
+
+// Inject writes the W3C traceparent header for the current span on the context
+// to the outgoing request headers
+func Inject(ctx context.Context, header http.Header) {
+	sc := SpanContextFromContext(ctx)
+	if !sc.IsValid() {
+		return
+	}
+
+	header.Set(TraceparentHeader, FormatTraceparent(sc))
+}
+
+// FormatTraceparent encodes a span context as a W3C traceparent header value
+func FormatTraceparent(sc SpanContext) string {
+	flags := flagNotSampled
+	if sc.Sampled {
+		flags = flagSampled
+	}
+
+	return traceparentVersion + "-" + sc.TraceID.String() + "-" + sc.SpanID.String() + "-" + flags
+}
+
+// ParseTraceparent decodes a W3C traceparent header value, e.g.
+// 00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01
+func ParseTraceparent(value string) (SpanContext, bool) {
+	var sc SpanContext
+
+	parts := strings.Split(strings.TrimSpace(value), "-")
+	if len(parts) < 4 {
+		return sc, false
+	}
+
+	version, traceID, spanID, flags := parts[0], parts[1], parts[2], parts[3]
+
+	// Version ff is forbidden; future versions may append fields so only
+	// version 00 is required to have exactly four
+	if len(version) != 2 || version == "ff" || (version == traceparentVersion && len(parts) != 4) {
+		return sc, false
+	}
+
+	if len(traceID) != 32 || len(spanID) != 16 || len(flags) != 2 {
+		return sc, false
+	}
+
+	if !isLowerHex(version) || !isLowerHex(traceID) || !isLowerHex(spanID) || !isLowerHex(flags) {
+		return sc, false
+	}
+
+	if _, err := hex.Decode(sc.TraceID[:], []byte(traceID)); err != nil {
+		return sc, false
+	}
+
+	if _, err := hex.Decode(sc.SpanID[:], []byte(spanID)); err != nil {
+		return sc, false
+	}
+
+	flagBytes, err := hex.DecodeString(flags)
+	if err != nil {
+		return sc, false
+	}
+	sc.Sampled = flagBytes[0]&1 == 1
+
+	if !sc.IsValid() {
+		return SpanContext{}, false
+	}
+
+	return sc, true
+}
+
+func isLowerHex(s string) bool {
+	for _, c := range s {
+		if !(c >= '0' && c <= '9') && !(c >= 'a' && c <= 'f') {
+			return false
+		}
+	}
+
+	return true
+}
@@ -0,0 +1,124 @@
+package tracing_test
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/ofs/alpha-search-api/tracing"
+)
+
+const (
+	validTraceID = "4bf92f3577b34da6a3ce929d0e0e4736"
+	validSpanID  = "00f067aa0ba902b7"
+)
+
+func TestParseTraceparent(t *testing.T) {
+	cases := []struct {
+		name    string
+		value   string
+		valid   bool
+		sampled bool
+	}{
+		{"sampled", "00-" + validTraceID + "-" + validSpanID + "-01", true, true},
+		{"not sampled", "00-" + validTraceID + "-" + validSpanID + "-00", true, false},
+		{"surrounding whitespace", " 00-" + validTraceID + "-" + validSpanID + "-01 ", true, true},
+		{"future version with extra fields", "01-" + validTraceID + "-" + validSpanID + "-01-extra", true, true},
+		{"empty", "", false, false},
+		{"forbidden version", "ff-" + validTraceID + "-" + validSpanID + "-01", false, false},
+		{"short version", "0-" + validTraceID + "-" + validSpanID + "-01", false, false},
+		{"non hex version", "zz-" + validTraceID + "-" + validSpanID + "-01", false, false},
+		{"version 00 with extra fields", "00-" + validTraceID + "-" + validSpanID + "-01-extra", false, false},
+		{"missing flags", "00-" + validTraceID + "-" + validSpanID, false, false},
+		{"all zero trace id", "00-" + strings.Repeat("0", 32) + "-" + validSpanID + "-01", false, false},
+		{"all zero span id", "00-" + validTraceID + "-" + strings.Repeat("0", 16) + "-01", false, false},
+		{"short trace id", "00-" + validTraceID[1:] + "-" + validSpanID + "-01", false, false},
+		{"long trace id", "00-" + validTraceID + "0-" + validSpanID + "-01", false, false},
+		{"short span id", "00-" + validTraceID + "-" + validSpanID[1:] + "-01", false, false},
+		{"long span id", "00-" + validTraceID + "-" + validSpanID + "0-01", false, false},
+		{"long flags", "00-" + validTraceID + "-" + validSpanID + "-001", false, false},
+		{"uppercase trace id", "00-" + strings.ToUpper(validTraceID) + "-" + validSpanID + "-01", false, false},
+		{"uppercase span id", "00-" + validTraceID + "-" + strings.ToUpper(validSpanID) + "-01", false, false},
+		{"non hex trace id", "00-" + strings.Repeat("g", 32) + "-" + validSpanID + "-01", false, false},
+	}
+
+	for _, tc := range cases {
+		sc, ok := tracing.ParseTraceparent(tc.value)
+		if ok != tc.valid {
+			t.Errorf("%s: expected valid %t, got %t", tc.name, tc.valid, ok)
+			continue
+		}
+
+		if !ok {
+			if sc.IsValid() {
+				t.Errorf("%s: expected an empty span context for an invalid header, got %+v", tc.name, sc)
+			}
+			continue
+		}
+
+		if sc.TraceID.String() != validTraceID || sc.SpanID.String() != validSpanID {
+			t.Errorf("%s: expected ids %s and %s, got %s and %s", tc.name, validTraceID, validSpanID, sc.TraceID, sc.SpanID)
+		}
+		if sc.Sampled != tc.sampled {
+			t.Errorf("%s: expected sampled %t, got %t", tc.name, tc.sampled, sc.Sampled)
+		}
+	}
+}
+
+func TestFormatTraceparent(t *testing.T) {
+	for _, value := range []string{
+		"00-" + validTraceID + "-" + validSpanID + "-01",
+		"00-" + validTraceID + "-" + validSpanID + "-00",
+	} {
+		sc, ok := tracing.ParseTraceparent(value)
+		if !ok {
+			t.Fatalf("failed to parse %q", value)
+		}
+
+		if formatted := tracing.FormatTraceparent(sc); formatted != value {
+			t.Errorf("expected %q, got %q", value, formatted)
+		}
+	}
+}
+
+func TestExtractAndInject(t *testing.T) {
+	incoming := http.Header{}
+	incoming.Set(tracing.TraceparentHeader, "00-"+validTraceID+"-"+validSpanID+"-01")
+
+	ctx, span := tracing.StartSpan(tracing.Extract(context.Background(), incoming), "child", tracing.KindInternal)
+	defer span.End()
+
+	outgoing := http.Header{}
+	tracing.Inject(ctx, outgoing)
+
+	sc, ok := tracing.ParseTraceparent(outgoing.Get(tracing.TraceparentHeader))
+	if !ok {
+		t.Fatalf("expected a valid traceparent header, got %q", outgoing.Get(tracing.TraceparentHeader))
+	}
+	if sc.TraceID.String() != validTraceID {
+		t.Errorf("expected trace id %s, got %s", validTraceID, sc.TraceID)
+	}
+	if sc.SpanID != span.SpanContext().SpanID || sc.SpanID.String() == validSpanID {
+		t.Errorf("expected the child span id %s, got %s", span.SpanContext().SpanID, sc.SpanID)
+	}
+	if !sc.Sampled {
+		t.Error("expected the sampled flag to be propagated")
+	}
+}
+
+func TestExtractIgnoresInvalidHeader(t *testing.T) {
+	incoming := http.Header{}
+	incoming.Set(tracing.TraceparentHeader, "00-"+strings.Repeat("0", 32)+"-"+validSpanID+"-01")
+
+	ctx := tracing.Extract(context.Background(), incoming)
+	if sc := tracing.SpanContextFromContext(ctx); sc.IsValid() {
+		t.Errorf("expected no remote span context, got %+v", sc)
+	}
+
+	outgoing := http.Header{}
+	tracing.Inject(ctx, outgoing)
+	if value := outgoing.Get(tracing.TraceparentHeader); value != "" {
+		t.Errorf("expected no traceparent header without a span, got %q", value)
+	}
+}
@@ -0,0 +1,177 @@
+package tracing
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// A list of span kinds, values match those used by OpenTelemetry
+const (
+	KindInternal = 1
+	KindServer   = 2
+	KindClient   = 3
+)
+
+type contextKey string
+
+const (
+	spanKey          = contextKey("span")
+	remoteContextKey = contextKey("remote-span-context")
+)
+
+// TraceID uniquely identifies a trace
+type TraceID [16]byte
+
+// String returns the lowercase hex encoding of the trace id
+func (t TraceID) String() string {
+	return hex.EncodeToString(t[:])
+}
+
+// IsValid returns false if every byte of the trace id is zero
+func (t TraceID) IsValid() bool {
+	return t != TraceID{}
+}
+
+// SpanID uniquely identifies a span within a trace
+type SpanID [8]byte
+
+// String returns the lowercase hex encoding of the span id
+func (s SpanID) String() string {
+	return hex.EncodeToString(s[:])
+}
+
+// IsValid returns false if every byte of the span id is zero
+func (s SpanID) IsValid() bool {
+	return s != SpanID{}
+}
+
+// SpanContext identifies a span and is propagated across process boundaries
+type SpanContext struct {
+	TraceID TraceID
+	SpanID  SpanID
+	Sampled bool
+}
+
+// IsValid returns true if both the trace and span ids are set
+func (sc SpanContext) IsValid() bool {
+	return sc.TraceID.IsValid() && sc.SpanID.IsValid()
+}
+
+// Span represents a single timed operation within a trace
+type Span struct {
+	mu           sync.Mutex
+	name         string
+	kind         int
+	context      SpanContext
+	parentSpanID SpanID
+	start        time.Time
+	end          time.Time
+	attributes   map[string]interface{}
+	errMessage   string
+	ended        bool
+}
+
+// StartSpan creates a span as a child of any span (local or remote) found on
+// the context, returning a new context holding the span
+func StartSpan(ctx context.Context, name string, kind int) (context.Context, *Span) {
+	span := &Span{
+		name:       name,
+		kind:       kind,
+		start:      time.Now(),
+		attributes: make(map[string]interface{}),
+	}
+
+	parent := SpanContextFromContext(ctx)
+	if parent.IsValid() {
+		span.context.TraceID = parent.TraceID
+		span.context.Sampled = parent.Sampled
+		span.parentSpanID = parent.SpanID
+	} else {
+		span.context.TraceID = newTraceID()
+		span.context.Sampled = exporterEnabled()
+	}
+	span.context.SpanID = newSpanID()
+
+	return context.WithValue(ctx, spanKey, span), span
+}
+
+// SpanFromContext returns the current span on the context or nil if there is none
+func SpanFromContext(ctx context.Context) *Span {
+	span, _ := ctx.Value(spanKey).(*Span)
+	return span
+}
+
+// SpanContextFromContext returns the span context of the current span, or of
+// the remote parent extracted from an incoming request if there is no local span
+func SpanContextFromContext(ctx context.Context) SpanContext {
+	if span := SpanFromContext(ctx); span != nil {
+		return span.context
+	}
+
+	sc, _ := ctx.Value(remoteContextKey).(SpanContext)
+	return sc
+}
+
+// ContextWithRemoteSpanContext sets the span context of a remote parent on the context
+func ContextWithRemoteSpanContext(ctx context.Context, sc SpanContext) context.Context {
+	return context.WithValue(ctx, remoteContextKey, sc)
+}
+
+// SpanContext returns the identifying context of the span
+func (s *Span) SpanContext() SpanContext {
+	return s.context
+}
+
+// SetAttribute records a key value pair against the span
+func (s *Span) SetAttribute(key string, value interface{}) {
+	s.mu.Lock()
+	s.attributes[key] = value
+	s.mu.Unlock()
+}
+
+// RecordError marks the span as failed
+func (s *Span) RecordError(err error) {
+	if err == nil {
+		return
+	}
+
+	s.mu.Lock()
+	s.errMessage = err.Error()
+	s.mu.Unlock()
+}
+
+// End completes the span and passes it to the configured exporter if it is sampled
+func (s *Span) End() {
+	s.mu.Lock()
+	if s.ended {
+		s.mu.Unlock()
+		return
+	}
+	s.ended = true
+	s.end = time.Now()
+	s.mu.Unlock()
+
+	if s.context.Sampled {
+		export(s)
+	}
+}
+
+func newTraceID() (id TraceID) {
+	randomBytes(id[:])
+	return
+}
+
+func newSpanID() (id SpanID) {
+	randomBytes(id[:])
+	return
+}
+
+func randomBytes(b []byte) {
+	if _, err := rand.Read(b); err != nil {
+		panic(fmt.Sprintf("tracing: unable to generate random id: %v", err))
+	}
+}